@@ -0,0 +1,24 @@
+module github.com/gabor-boros/go-oauth2-pg/admingrpc
+
+go 1.19
+
+require (
+	github.com/gabor-boros/go-oauth2-pg v0.0.0-20261016074631-76a630171ac1
+	github.com/go-oauth2/oauth2/v4 v4.5.2
+	github.com/jackc/pgx/v5 v5.3.1
+	google.golang.org/grpc v1.58.3
+	google.golang.org/protobuf v1.31.0
+)
+
+require (
+	github.com/golang/protobuf v1.5.3 // indirect
+	github.com/jackc/pgpassfile v1.0.0 // indirect
+	github.com/jackc/pgservicefile v0.0.0-20221227161230-091c0ba34f0a // indirect
+	github.com/jackc/puddle/v2 v2.2.0 // indirect
+	golang.org/x/crypto v0.11.0 // indirect
+	golang.org/x/net v0.12.0 // indirect
+	golang.org/x/sync v0.3.0 // indirect
+	golang.org/x/sys v0.10.0 // indirect
+	golang.org/x/text v0.11.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20230711160842-782d3b101e98 // indirect
+)
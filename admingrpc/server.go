@@ -0,0 +1,213 @@
+// Package admingrpc provides a gRPC server for administering the clients and
+// tokens of the pgstore stores. The service is described in
+// adminpb/admin.proto; run "go generate" after changing it.
+package admingrpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"github.com/go-oauth2/oauth2/v4/models"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pgstore "github.com/gabor-boros/go-oauth2-pg"
+	"github.com/gabor-boros/go-oauth2-pg/admingrpc/adminpb"
+)
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative adminpb/admin.proto
+
+// Server implements the admin service backed by the stores. The server does
+// not authenticate the calls; use an interceptor for that.
+type Server struct {
+	adminpb.UnimplementedAdminServiceServer
+
+	tokens  *pgstore.TokenStore
+	clients *pgstore.ClientStore
+}
+
+// NewServer creates a new Server backed by the stores.
+func NewServer(tokens *pgstore.TokenStore, clients *pgstore.ClientStore) *Server {
+	return &Server{tokens: tokens, clients: clients}
+}
+
+// Register registers the server on the gRPC service registrar.
+func Register(registrar grpc.ServiceRegistrar, srv *Server) {
+	adminpb.RegisterAdminServiceServer(registrar, srv)
+}
+
+// ListClients returns every enabled client.
+func (s *Server) ListClients(ctx context.Context, _ *adminpb.ListClientsRequest) (*adminpb.ListClientsResponse, error) {
+	infos, err := s.clients.List(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	clients := make([]*adminpb.Client, 0, len(infos))
+	for _, info := range infos {
+		clients = append(clients, toClient(info))
+	}
+
+	return &adminpb.ListClientsResponse{Clients: clients}, nil
+}
+
+// GetClient returns the client by its ID.
+func (s *Server) GetClient(ctx context.Context, req *adminpb.GetClientRequest) (*adminpb.Client, error) {
+	info, err := s.clients.GetByID(ctx, req.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return toClient(info), nil
+}
+
+// CreateClient creates a client. It fails with AlreadyExists if the client
+// exists.
+func (s *Server) CreateClient(_ context.Context, req *adminpb.CreateClientRequest) (*adminpb.Client, error) {
+	info := fromClient(req.GetClient())
+	if info.ID == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing client id")
+	}
+
+	if err := s.clients.Create(info); err != nil {
+		return nil, toStatus(err)
+	}
+
+	client := toClient(info)
+	client.Version = 1
+
+	return client, nil
+}
+
+// UpdateClient replaces the client if it is still at the version of the
+// request. It fails with Aborted if the client was changed since.
+func (s *Server) UpdateClient(ctx context.Context, req *adminpb.UpdateClientRequest) (*adminpb.Client, error) {
+	info := fromClient(req.GetClient())
+	if info.ID == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing client id")
+	}
+
+	version, err := s.clients.Update(ctx, info, req.GetClient().GetVersion())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	client := toClient(info)
+	client.Version = version
+
+	return client, nil
+}
+
+// DeleteClient deletes the client by its ID.
+func (s *Server) DeleteClient(ctx context.Context, req *adminpb.DeleteClientRequest) (*adminpb.DeleteClientResponse, error) {
+	if err := s.clients.RemoveByID(ctx, req.GetId()); err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &adminpb.DeleteClientResponse{}, nil
+}
+
+// RevokeToken revokes an access or refresh token. The access and refresh
+// tokens are stored in the same row, so revoking either removes both.
+func (s *Server) RevokeToken(ctx context.Context, req *adminpb.RevokeTokenRequest) (*adminpb.RevokeTokenResponse, error) {
+	if req.GetToken() == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing token")
+	}
+
+	if err := s.tokens.RemoveByAccess(ctx, req.GetToken()); err != nil {
+		return nil, toStatus(err)
+	}
+
+	if err := s.tokens.RemoveByRefresh(ctx, req.GetToken()); err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &adminpb.RevokeTokenResponse{}, nil
+}
+
+// Stats returns the token and client statistics.
+func (s *Server) Stats(ctx context.Context, _ *adminpb.StatsRequest) (*adminpb.StatsResponse, error) {
+	tokenStats, err := s.tokens.Stats(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	clientStats, err := s.clients.Stats(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	perGrantType := make(map[string]int64, len(tokenStats.PerGrantType))
+	for grantType, count := range tokenStats.PerGrantType {
+		perGrantType[string(grantType)] = count
+	}
+
+	return &adminpb.StatsResponse{
+		ActiveTokens:  tokenStats.Active,
+		ExpiredTokens: tokenStats.Expired,
+		PerClient:     tokenStats.PerClient,
+		PerGrantType:  perGrantType,
+		Clients:       clientStats.Total,
+	}, nil
+}
+
+// fromClient converts the client message to client information.
+func fromClient(client *adminpb.Client) *models.Client {
+	return &models.Client{
+		ID:     client.GetId(),
+		Secret: client.GetSecret(),
+		Domain: client.GetDomain(),
+		Public: client.GetPublic(),
+		UserID: client.GetUserId(),
+	}
+}
+
+// toClient converts the client information to a client message without the
+// secret.
+func toClient(info oauth2.ClientInfo) *adminpb.Client {
+	client := &adminpb.Client{
+		Id:     info.GetID(),
+		Domain: info.GetDomain(),
+		Public: info.IsPublic(),
+		UserId: info.GetUserID(),
+	}
+
+	if stored, ok := info.(*pgstore.Client); ok {
+		client.Version = stored.Version
+	}
+
+	return client
+}
+
+// errorCodes maps the errors of the stores to gRPC codes. The message of
+// other errors is not sent, as it may reveal details of the database.
+var errorCodes = []struct {
+	err  error
+	code codes.Code
+}{
+	{pgstore.ErrClientNotFound, codes.NotFound},
+	{pgstore.ErrTokenNotFound, codes.NotFound},
+	{pgstore.ErrUniqueViolation, codes.AlreadyExists},
+	{pgstore.ErrClientRealmMismatch, codes.AlreadyExists},
+	{pgstore.ErrSerializationFailure, codes.Aborted},
+	{pgstore.ErrConflict, codes.Aborted},
+	{pgstore.ErrClientDisabled, codes.FailedPrecondition},
+	{pgstore.ErrReadOnly, codes.FailedPrecondition},
+	{pgstore.ErrStoreUnavailable, codes.Unavailable},
+	{pgstore.ErrConnection, codes.Unavailable},
+	{context.Canceled, codes.Canceled},
+	{context.DeadlineExceeded, codes.DeadlineExceeded},
+}
+
+// toStatus converts the store error to a gRPC status error.
+func toStatus(err error) error {
+	for _, e := range errorCodes {
+		if errors.Is(err, e.err) {
+			return status.Error(e.code, e.err.Error())
+		}
+	}
+
+	return status.Error(codes.Internal, "internal error")
+}
@@ -0,0 +1,153 @@
+package admingrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pgstore "github.com/gabor-boros/go-oauth2-pg"
+	"github.com/gabor-boros/go-oauth2-pg/admingrpc/adminpb"
+)
+
+// row is a row scanning the value into the first destination or failing with
+// the error.
+type row struct {
+	value any
+	err   error
+}
+
+func (r row) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+
+	switch d := dest[0].(type) {
+	case *int64:
+		*d = r.value.(int64)
+	case *bool:
+		*d = r.value.(bool)
+	}
+
+	return nil
+}
+
+// fakeDB is a database failing every statement with the error and returning
+// the rows in order.
+type fakeDB struct {
+	pgstore.DB
+	err  error
+	rows []row
+}
+
+func (d *fakeDB) Exec(context.Context, string, ...any) (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, d.err
+}
+
+func (d *fakeDB) QueryRow(context.Context, string, ...any) pgx.Row {
+	r := d.rows[0]
+	d.rows = d.rows[1:]
+
+	return r
+}
+
+func newTestServer(t *testing.T, db pgstore.DB) *Server {
+	t.Helper()
+
+	clients, err := pgstore.NewClientStore(pgstore.WithClientStoreDB(db))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { clients.Close(context.Background()) })
+
+	return NewServer(nil, clients)
+}
+
+func TestServerCreateClient(t *testing.T) {
+	tests := []struct {
+		name   string
+		client *adminpb.Client
+		err    error
+		want   codes.Code
+	}{
+		{"created", &adminpb.Client{Id: "client"}, nil, codes.OK},
+		{"missing id", &adminpb.Client{}, nil, codes.InvalidArgument},
+		{"exists", &adminpb.Client{Id: "client"}, &pgconn.PgError{Code: "23505"}, codes.AlreadyExists},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer(t, &fakeDB{err: tt.err})
+
+			client, err := srv.CreateClient(context.Background(), &adminpb.CreateClientRequest{Client: tt.client})
+			if got := status.Code(err); got != tt.want {
+				t.Fatalf("got code %s, want %s", got, tt.want)
+			}
+
+			if err == nil && client.GetVersion() != 1 {
+				t.Fatalf("got version %d, want 1", client.GetVersion())
+			}
+		})
+	}
+}
+
+func TestServerUpdateClient(t *testing.T) {
+	tests := []struct {
+		name        string
+		rows        []row
+		want        codes.Code
+		wantVersion int64
+	}{
+		{"updated", []row{{value: int64(3)}}, codes.OK, 3},
+		{"changed since", []row{{err: pgx.ErrNoRows}, {value: true}}, codes.Aborted, 0},
+		{"missing", []row{{err: pgx.ErrNoRows}, {value: false}}, codes.NotFound, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer(t, &fakeDB{rows: tt.rows})
+
+			client, err := srv.UpdateClient(context.Background(), &adminpb.UpdateClientRequest{
+				Client: &adminpb.Client{Id: "client", Version: 2},
+			})
+			if got := status.Code(err); got != tt.want {
+				t.Fatalf("got code %s, want %s", got, tt.want)
+			}
+
+			if client.GetVersion() != tt.wantVersion {
+				t.Fatalf("got version %d, want %d", client.GetVersion(), tt.wantVersion)
+			}
+		})
+	}
+}
+
+func TestToStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want codes.Code
+	}{
+		{pgstore.ErrClientNotFound, codes.NotFound},
+		{fmt.Errorf("getting client: %w", pgstore.ErrClientDisabled), codes.FailedPrecondition},
+		{pgstore.ErrConflict, codes.Aborted},
+		{context.DeadlineExceeded, codes.DeadlineExceeded},
+		{errors.New("connecting to postgres://admin:hunter2@db failed"), codes.Internal},
+	}
+
+	for _, tt := range tests {
+		st := status.Convert(toStatus(tt.err))
+		if st.Code() != tt.want {
+			t.Errorf("%v: got code %s, want %s", tt.err, st.Code(), tt.want)
+		}
+
+		if strings.Contains(st.Message(), "hunter2") {
+			t.Errorf("%v: got message %q revealing the error", tt.err, st.Message())
+		}
+	}
+}
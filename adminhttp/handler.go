@@ -0,0 +1,250 @@
+// Package adminhttp provides net/http handlers for administering the clients
+// and tokens of the pgstore stores.
+package adminhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"github.com/go-oauth2/oauth2/v4/models"
+
+	pgstore "github.com/gabor-boros/go-oauth2-pg"
+)
+
+// maxBodySize is the maximum size of a request body.
+const maxBodySize = 1 << 20
+
+// errorStatuses maps the errors of the stores to response statuses. The
+// message of other errors is not sent, as it may reveal details of the
+// database.
+var errorStatuses = []struct {
+	err    error
+	status int
+}{
+	{pgstore.ErrClientNotFound, http.StatusNotFound},
+	{pgstore.ErrClientDisabled, http.StatusForbidden},
+	{pgstore.ErrReadOnly, http.StatusForbidden},
+	{pgstore.ErrUniqueViolation, http.StatusConflict},
+	{pgstore.ErrConflict, http.StatusConflict},
+	{pgstore.ErrClientRealmMismatch, http.StatusConflict},
+	{pgstore.ErrRateLimited, http.StatusTooManyRequests},
+	{pgstore.ErrStoreUnavailable, http.StatusServiceUnavailable},
+}
+
+// Client is the JSON representation of a client. The secret is only read
+// when creating a client and never returned.
+type Client struct {
+	ID     string `json:"id"`
+	Secret string `json:"secret,omitempty"`
+	Domain string `json:"domain"`
+	Public bool   `json:"public"`
+	UserID string `json:"user_id,omitempty"`
+}
+
+// revokeRequest is the body of a token revocation request.
+type revokeRequest struct {
+	Token string `json:"token"`
+}
+
+// errorResponse is the body of an error response.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Handler serves the admin API:
+//
+//	GET    /clients       lists the clients
+//	POST   /clients       creates a client, failing if it already exists
+//	GET    /clients/{id}  returns a client
+//	DELETE /clients/{id}  deletes a client
+//	POST   /tokens/revoke revokes an access or refresh token
+//
+// The handler does not authenticate the requests; it must be mounted behind
+// the authentication of the application.
+type Handler struct {
+	tokens  *pgstore.TokenStore
+	clients *pgstore.ClientStore
+	mux     *http.ServeMux
+}
+
+// NewHandler creates a new Handler backed by the stores.
+func NewHandler(tokens *pgstore.TokenStore, clients *pgstore.ClientStore) *Handler {
+	h := &Handler{
+		tokens:  tokens,
+		clients: clients,
+		mux:     http.NewServeMux(),
+	}
+
+	h.mux.HandleFunc("/clients", h.handleClients)
+	h.mux.HandleFunc("/clients/", h.handleClient)
+	h.mux.HandleFunc("/tokens/revoke", h.handleRevoke)
+
+	return h
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) handleClients(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		infos, err := h.clients.List(r.Context())
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+
+		clients := make([]Client, 0, len(infos))
+		for _, info := range infos {
+			clients = append(clients, toClient(info))
+		}
+
+		writeJSON(w, http.StatusOK, clients)
+	case http.MethodPost:
+		var client Client
+		if !decodeJSON(w, r, &client) {
+			return
+		}
+
+		if client.ID == "" {
+			writeError(w, http.StatusBadRequest, errors.New("missing client id"))
+			return
+		}
+
+		info := &models.Client{
+			ID:     client.ID,
+			Secret: client.Secret,
+			Domain: client.Domain,
+			Public: client.Public,
+			UserID: client.UserID,
+		}
+
+		if err := h.clients.Create(info); err != nil {
+			writeStoreError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, toClient(info))
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	}
+}
+
+func (h *Handler) handleClient(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/clients/")
+	if id == "" || strings.Contains(id, "/") {
+		writeError(w, http.StatusNotFound, pgstore.ErrClientNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		info, err := h.clients.GetByID(r.Context(), id)
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, toClient(info))
+	case http.MethodDelete:
+		if err := h.clients.RemoveByID(r.Context(), id); err != nil {
+			writeStoreError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, DELETE")
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	}
+}
+
+func (h *Handler) handleRevoke(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	var req revokeRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if req.Token == "" {
+		writeError(w, http.StatusBadRequest, errors.New("missing token"))
+		return
+	}
+
+	// The access and refresh tokens are stored in the same row, so revoking
+	// either removes both.
+	if err := h.tokens.RemoveByAccess(r.Context(), req.Token); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	if err := h.tokens.RemoveByRefresh(r.Context(), req.Token); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// toClient converts the client information to its JSON representation
+// without the secret.
+func toClient(info oauth2.ClientInfo) Client {
+	return Client{
+		ID:     info.GetID(),
+		Domain: info.GetDomain(),
+		Public: info.IsPublic(),
+		UserID: info.GetUserID(),
+	}
+}
+
+// decodeJSON decodes the request body of at most maxBodySize bytes into v. It
+// writes the error response and returns false if the body is invalid.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(v)
+	if err == nil {
+		return true
+	}
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeError(w, http.StatusRequestEntityTooLarge, errors.New("request body too large"))
+		return false
+	}
+
+	writeError(w, http.StatusBadRequest, errors.New("invalid request body"))
+
+	return false
+}
+
+// writeStoreError writes the response of the error returned by a store.
+func writeStoreError(w http.ResponseWriter, err error) {
+	for _, e := range errorStatuses {
+		if errors.Is(err, e.err) {
+			writeError(w, e.status, e.err)
+			return
+		}
+	}
+
+	writeError(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
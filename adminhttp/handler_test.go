@@ -0,0 +1,132 @@
+package adminhttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	pgstore "github.com/gabor-boros/go-oauth2-pg"
+)
+
+// errDB is a database failing every statement with the error.
+type errDB struct {
+	pgstore.DB
+	err error
+}
+
+func (d *errDB) Exec(context.Context, string, ...any) (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, d.err
+}
+
+func newTestHandler(t *testing.T, db pgstore.DB, opts ...pgstore.ClientStoreOption) *Handler {
+	t.Helper()
+
+	clients, err := pgstore.NewClientStore(append([]pgstore.ClientStoreOption{pgstore.WithClientStoreDB(db)}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { clients.Close(context.Background()) })
+
+	return NewHandler(nil, clients)
+}
+
+func serve(h http.Handler, method string, target string, body string) (*httptest.ResponseRecorder, errorResponse) {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+
+	var resp errorResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+
+	return rec, resp
+}
+
+func TestHandlerCreateClientConflict(t *testing.T) {
+	h := newTestHandler(t, &errDB{err: &pgconn.PgError{Code: "23505", Message: "duplicate key value violates unique constraint"}})
+
+	rec, resp := serve(h, http.MethodPost, "/clients", `{"id":"client","secret":"secret"}`)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("got status %d, want %d", rec.Code, http.StatusConflict)
+	}
+
+	if resp.Error != pgstore.ErrUniqueViolation.Error() {
+		t.Fatalf("got error %q, want %q", resp.Error, pgstore.ErrUniqueViolation)
+	}
+}
+
+func TestHandlerHidesInternalErrors(t *testing.T) {
+	h := newTestHandler(t, &errDB{err: errors.New("connecting to postgres://admin:hunter2@db failed")})
+
+	rec, resp := serve(h, http.MethodPost, "/clients", `{"id":"client"}`)
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("got status %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+
+	if strings.Contains(resp.Error, "hunter2") || resp.Error == "" {
+		t.Fatalf("got error %q, want a generic message", resp.Error)
+	}
+}
+
+func TestHandlerRejectsInvalidBodies(t *testing.T) {
+	h := newTestHandler(t, &errDB{err: errors.New("unused")})
+
+	tests := []struct {
+		name   string
+		target string
+		body   string
+		want   int
+	}{
+		{"invalid client", "/clients", `{"id":`, http.StatusBadRequest},
+		{"missing client id", "/clients", `{}`, http.StatusBadRequest},
+		{"client too large", "/clients", fmt.Sprintf(`{"id":%q}`, strings.Repeat("a", maxBodySize)), http.StatusRequestEntityTooLarge},
+		{"revoke too large", "/tokens/revoke", fmt.Sprintf(`{"token":%q}`, strings.Repeat("a", maxBodySize)), http.StatusRequestEntityTooLarge},
+		{"missing token", "/tokens/revoke", `{}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec, _ := serve(h, http.MethodPost, tt.target, tt.body); rec.Code != tt.want {
+				t.Fatalf("got status %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandlerDeleteClientReadOnly(t *testing.T) {
+	h := newTestHandler(t, &errDB{err: errors.New("unused")}, pgstore.WithClientStoreReadOnly(true))
+
+	rec, resp := serve(h, http.MethodDelete, "/clients/client", "")
+	if rec.Code != http.StatusForbidden || resp.Error != pgstore.ErrReadOnly.Error() {
+		t.Fatalf("got status %d and error %q, want %d and %q", rec.Code, resp.Error, http.StatusForbidden, pgstore.ErrReadOnly)
+	}
+}
+
+func TestWriteStoreError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{pgstore.ErrClientNotFound, http.StatusNotFound},
+		{pgstore.ErrClientDisabled, http.StatusForbidden},
+		{fmt.Errorf("getting client: %w", pgstore.ErrClientDisabled), http.StatusForbidden},
+		{pgstore.ErrClientRealmMismatch, http.StatusConflict},
+		{pgstore.ErrStoreUnavailable, http.StatusServiceUnavailable},
+		{errors.New("internal"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		writeStoreError(rec, tt.err)
+
+		if rec.Code != tt.want {
+			t.Errorf("%v: got status %d, want %d", tt.err, rec.Code, tt.want)
+		}
+	}
+}
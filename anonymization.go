@@ -0,0 +1,97 @@
+package pgstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"time"
+)
+
+// WithTokenStoreAnonymization configures the cleanup to anonymize the tokens
+// expired for longer than the given time instead of deleting them, so the
+// rows are kept for analytics. The user ID is replaced by its HMAC-SHA256
+// keyed with the key, so distinct users can still be counted, but the user
+// IDs cannot be recovered by hashing every possible ID without the key. The
+// client IP address and user agent are cleared, and the token values and
+// data are replaced by an empty token. The client, scope, audience and
+// timestamps are kept, so the rows are pseudonymized rather than free of
+// personal data. The anonymized rows are never removed by the store.
+// Anonymization cannot be combined with archiving, partitioning by expiry,
+// hypertables or generated columns, which remove or derive the rows
+// otherwise.
+func WithTokenStoreAnonymization(after time.Duration, key []byte) TokenStoreOption {
+	return func(s *TokenStore) error {
+		if after <= 0 || len(key) == 0 {
+			return ErrInvalidAnonymization
+		}
+
+		s.anonymizeAfter = after
+		s.anonymizationKey = key
+
+		return nil
+	}
+}
+
+// hmacPads returns the inner and outer padded keys of HMAC-SHA256, so the
+// HMAC can be computed by the database with its sha256 function as
+// sha256(outer || sha256(inner || message)), without requiring pgcrypto.
+func hmacPads(key []byte) ([]byte, []byte) {
+	if len(key) > sha256.BlockSize {
+		sum := sha256.Sum256(key)
+		key = sum[:]
+	}
+
+	inner := make([]byte, sha256.BlockSize)
+	outer := make([]byte, sha256.BlockSize)
+	copy(inner, key)
+	copy(outer, key)
+
+	for i := range inner {
+		inner[i] ^= 0x36
+		outer[i] ^= 0x5c
+	}
+
+	return inner, outer
+}
+
+// validateAnonymization returns ErrInvalidAnonymization if anonymization is
+// configured with a table layout removing or deriving the rows.
+func (s *TokenStore) validateAnonymization() error {
+	if s.anonymizeAfter == 0 {
+		return nil
+	}
+
+	if s.archiveTable != "" || s.partitionInterval > 0 || s.hypertableChunkInterval > 0 || s.generatedColumns {
+		return ErrInvalidAnonymization
+	}
+
+	return nil
+}
+
+// anonymizeExpiredTokens anonymizes the expired rows of the kind not yet
+// anonymized.
+func (s *TokenStore) anonymizeExpiredTokens(ctx context.Context, now time.Time, kind cleanupKind) (int64, error) {
+	data, err := s.encodeTokenInfo(s.newTokenInfo())
+	if err != nil {
+		return 0, err
+	}
+
+	inner, outer := hmacPads(s.anonymizationKey)
+
+	tag, err := s.pool.Exec(ctx, fmt.Sprintf(`
+		UPDATE %[1]s SET
+			user_id = CASE WHEN user_id = '' THEN '' ELSE encode(sha256($4::BYTEA || sha256($3::BYTEA || convert_to(user_id, 'UTF8'))), 'hex') END,
+			client_ip = NULL,
+			user_agent = '',
+			%[2]s = '',
+			%[3]s = '',
+			%[4]s = '',
+			%[5]s = %[6]s%[8]s,
+			anonymized_at = $1
+		WHERE %[7]s`,
+		s.table, s.columns.Code, s.columns.Access, s.columns.Refresh, s.columns.Data, s.encryptData("$2"),
+		s.expiredCondition(s.table, kind), keyIDAssignment(s.encryptionSetting),
+	), now, data, inner, outer)
+
+	return tag.RowsAffected(), err
+}
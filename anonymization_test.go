@@ -0,0 +1,32 @@
+package pgstore
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithTokenStoreAnonymizationRequiresKey(t *testing.T) {
+	if err := WithTokenStoreAnonymization(time.Hour, nil)(new(TokenStore)); !errors.Is(err, ErrInvalidAnonymization) {
+		t.Fatalf("got %v, want %v", err, ErrInvalidAnonymization)
+	}
+}
+
+func TestHMACPads(t *testing.T) {
+	for _, key := range [][]byte{[]byte("key"), []byte(strings.Repeat("k", 100))} {
+		inner, outer := hmacPads(key)
+
+		innerSum := sha256.Sum256(append(inner, "user"...))
+		got := sha256.Sum256(append(outer, innerSum[:]...))
+
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte("user"))
+
+		if !hmac.Equal(got[:], mac.Sum(nil)) {
+			t.Fatalf("padded keys of a %d byte key do not compute the HMAC", len(key))
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package pgstore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultTokenArchiveTable is the default table for archiving expired
+	// tokens.
+	DefaultTokenArchiveTable = "oauth2_tokens_archive" // nolint: gosec
+)
+
+// WithTokenStoreArchive configures the cleanup to move expired tokens into
+// the archive table instead of deleting them. Archived tokens are deleted
+// after the retention period, or kept forever if the retention is zero.
+func WithTokenStoreArchive(table string, retention time.Duration) TokenStoreOption {
+	return func(s *TokenStore) error {
+		if table == "" {
+			return ErrNoTable
+		}
+
+		if retention < 0 {
+			return ErrInvalidRetention
+		}
+
+		s.archiveTable = table
+		s.archiveRetention = retention
+
+		return nil
+	}
+}
+
+// archiveTableSQL returns the DDL of the archive table. The archive table has
+// the columns of the token table and the time of archiving. Archive tables
+// created by earlier versions get the columns added to the token table since.
+func (s *TokenStore) archiveTableSQL() string {
+	return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %[1]s (
+			LIKE %[2]s,
+			archived_at TIMESTAMPTZ NOT NULL
+		);
+%[4]s
+
+		CREATE INDEX IF NOT EXISTS idx_%[3]s_archived_idx ON %[1]s (archived_at);`,
+		s.archiveTable, s.table, unqualifiedName(s.archiveTable), s.upgradeColumnsSQL(s.archiveTable),
+	)
+}
+
+// copyColumns returns the columns of the token table, for copying tokens
+// between tables. The columns are listed by name, as their order differs
+// between the tables once columns are added by upgrades.
+func (s *TokenStore) copyColumns() string {
+	columns := s.schemaTables()[0].columns
+
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = column.name
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// archiveExpiredTokens moves the expired rows of the kind into the archive
+// table in a single statement.
+func (s *TokenStore) archiveExpiredTokens(ctx context.Context, now time.Time, kind cleanupKind) (int64, error) {
+	tag, err := s.pool.Exec(ctx, fmt.Sprintf(`
+		WITH expired AS (
+			DELETE FROM %[1]s WHERE %[2]s RETURNING %[4]s
+		)
+		INSERT INTO %[3]s (%[4]s, archived_at) SELECT %[4]s, $1 FROM expired`,
+		s.table, s.expiredCondition(s.table, kind), s.archiveTable, s.copyColumns(),
+	), now)
+
+	return tag.RowsAffected(), err
+}
+
+// purgeArchivedTokens deletes the archived tokens older than the retention.
+func (s *TokenStore) purgeArchivedTokens(ctx context.Context, now time.Time) error {
+	if s.archiveRetention <= 0 {
+		return nil
+	}
+
+	purged, err := s.pool.Exec(ctx, fmt.Sprintf(
+		"DELETE FROM %s WHERE archived_at <= $1",
+		s.archiveTable,
+	), now.Add(-s.archiveRetention))
+
+	s.logger.Log(ctx, LogLevelDebug, "purging archived tokens", "removed", purged.RowsAffected(), "err", err)
+
+	return err
+}
@@ -0,0 +1,42 @@
+package pgstore
+
+import (
+	"context"
+	"testing"
+)
+
+func TestTokenStoreArchiveMatchesColumnsByName(t *testing.T) {
+	store := newTestTokenStore(t, WithTokenStoreArchive(DefaultTokenArchiveTable, 0))
+	ctx := context.Background()
+
+	// Re-adding a column moves it to the end of the token table, so its
+	// position no longer matches the archive table.
+	if _, err := store.pool.Exec(ctx, "ALTER TABLE "+store.table+" DROP COLUMN user_agent"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.InitTable(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.CreateWithMetadata(ctx, newTestToken("client", "user", "1"), TokenMetadata{UserAgent: "agent"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.pool.Exec(ctx, "UPDATE "+store.table+" SET "+store.columns.ExpiresAt+" = now() - interval '1 hour'"); err != nil {
+		t.Fatal(err)
+	}
+
+	if removed, err := store.cleanExpiredTokens(ctx); err != nil || removed != 1 {
+		t.Fatalf("got %d archived tokens and error %v, want 1", removed, err)
+	}
+
+	var clientID, userID, userAgent string
+	if err := store.pool.QueryRow(ctx, "SELECT client_id, user_id, user_agent FROM "+store.archiveTable).Scan(&clientID, &userID, &userAgent); err != nil {
+		t.Fatal(err)
+	}
+
+	if clientID != "client" || userID != "user" || userAgent != "agent" {
+		t.Fatalf("got archived client %q, user %q and user agent %q", clientID, userID, userAgent)
+	}
+}
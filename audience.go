@@ -0,0 +1,39 @@
+package pgstore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-oauth2/oauth2/v4"
+)
+
+// AudienceTokenInfo is implemented by the token types carrying the audience
+// of the token, the resource servers the token is intended for. The audience
+// of these tokens is stored in its own column, so the tokens of a resource
+// server can be listed with ListByAudience.
+type AudienceTokenInfo interface {
+	oauth2.TokenInfo
+	// GetAudience returns the audience of the token.
+	GetAudience() []string
+}
+
+// tokenAudience returns the audience of the token, or an empty audience if
+// the token type does not carry one.
+func tokenAudience(info oauth2.TokenInfo) []string {
+	if info, ok := info.(AudienceTokenInfo); ok && info.GetAudience() != nil {
+		return info.GetAudience()
+	}
+
+	return []string{}
+}
+
+// ListByAudience returns the active tokens issued for the audience, most
+// recent first. It can be used to report or revoke the tokens of a resource
+// server.
+func (s *TokenStore) ListByAudience(ctx context.Context, aud string) ([]TokenRecord, error) {
+	s.logger.Log(ctx, LogLevelDebug, "listing tokens by audience", "audience", aud)
+	return s.queryTokenRecords(ctx, fmt.Sprintf(
+		"SELECT %s FROM %s WHERE audience @> ARRAY[$1::TEXT] AND %s > $2 AND revoked_at IS NULL%s ORDER BY %s DESC, id DESC",
+		s.selectColumns(), s.table, s.columns.ExpiresAt, s.andTenant(), s.columns.CreatedAt,
+	), aud, s.clock.Now())
+}
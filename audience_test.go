@@ -0,0 +1,71 @@
+package pgstore
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+)
+
+// audienceToken is a token carrying its audience.
+type audienceToken struct {
+	*models.Token
+	audience []string
+}
+
+func (t *audienceToken) GetAudience() []string {
+	return t.audience
+}
+
+func TestTokenAudience(t *testing.T) {
+	if got := tokenAudience(&models.Token{}); got == nil || len(got) != 0 {
+		t.Fatalf("got audience %#v, want an empty audience", got)
+	}
+
+	if got := tokenAudience(&audienceToken{Token: &models.Token{}}); got == nil || len(got) != 0 {
+		t.Fatalf("got audience %#v for a nil audience, want an empty audience", got)
+	}
+
+	want := []string{"api", "billing"}
+	if got := tokenAudience(&audienceToken{Token: &models.Token{}, audience: want}); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got audience %v, want %v", got, want)
+	}
+}
+
+func TestTokenStoreListByAudience(t *testing.T) {
+	store := newTestTokenStore(t)
+	ctx := context.Background()
+
+	for suffix, audience := range map[string][]string{
+		"a": {"api"},
+		"b": {"api", "billing"},
+		"c": {"billing"},
+		"d": nil,
+	} {
+		if err := store.Create(ctx, &audienceToken{Token: newTestToken("client", "user", suffix), audience: audience}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := store.RemoveByAccess(ctx, "access-a"); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := store.ListByAudience(ctx, "api")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 1 || records[0].Info.GetAccess() != "access-b" {
+		t.Fatalf("got %d tokens, want the active token of the audience", len(records))
+	}
+
+	if records, err = store.ListByAudience(ctx, "billing"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("got %d tokens, want 2", len(records))
+	}
+}
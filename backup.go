@@ -0,0 +1,368 @@
+package pgstore
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// backupTable is a table copied by Backup and Restore.
+type backupTable struct {
+	name string
+	// serial is true if the table has a BIGSERIAL or identity id column,
+	// whose sequence is advanced past the restored rows.
+	serial bool
+	// encryptionSetting is the encryption setting of the table with a key ID
+	// column, whose key ID is recorded for the rows of older backups.
+	encryptionSetting string
+}
+
+// backupTables returns the tables of the stores in the order they are
+// restored, the referenced tables first.
+func (s *Stores) backupTables() []backupTable {
+	return []backupTable{
+		{name: s.ClientStore.table, encryptionSetting: s.ClientStore.encryptionSetting},
+		{name: s.ClientStore.scopeTable},
+		{name: s.ClientStore.secretTable, serial: true},
+		{name: s.ClientStore.redirectURITable},
+		{name: s.ClientStore.clientScopeTable},
+		{name: s.TokenStore.table, serial: s.TokenStore.hasIDSequence(), encryptionSetting: s.TokenStore.encryptionSetting},
+		{name: s.ConsentStore.table},
+	}
+}
+
+// maxFrameSize is the largest frame written and read, so a malformed backup
+// cannot make Restore allocate an arbitrary amount of memory.
+const maxFrameSize = 1 << 20
+
+// writeFrame writes the length of the data followed by the data. An empty
+// frame ends the data of a table.
+func writeFrame(w io.Writer, data []byte) error {
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(data)))
+
+	if _, err := w.Write(size[:]); err != nil {
+		return err
+	}
+
+	_, err := w.Write(data)
+
+	return err
+}
+
+// readFrame reads a frame written by writeFrame.
+func readFrame(r io.Reader) ([]byte, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return nil, err
+	}
+
+	n := binary.BigEndian.Uint32(size[:])
+	if n > maxFrameSize {
+		return nil, ErrInvalidBackup
+	}
+
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// frameWriter writes the data of a table as frames.
+type frameWriter struct {
+	w io.Writer
+}
+
+// Write writes the data as frames of at most maxFrameSize bytes.
+func (w frameWriter) Write(p []byte) (int, error) {
+	written := 0
+
+	for written < len(p) {
+		n := len(p) - written
+		if n > maxFrameSize {
+			n = maxFrameSize
+		}
+
+		if err := writeFrame(w.w, p[written:written+n]); err != nil {
+			return written, err
+		}
+
+		written += n
+	}
+
+	return written, nil
+}
+
+// tableColumnsSQL selects the names of the columns of the table, given as the
+// first parameter, which are generated, or are not if the second parameter is
+// false, in the order of the table.
+const tableColumnsSQL = `
+	SELECT column_name::TEXT FROM information_schema.columns
+	WHERE to_regclass(quote_ident(table_schema) || '.' || quote_ident(table_name)) = to_regclass($1)
+		AND (is_generated <> 'NEVER') = $2
+	ORDER BY ordinal_position`
+
+// tableColumns returns the names of the generated or the stored columns of
+// the table.
+func tableColumns(ctx context.Context, q querier, table string, generated bool) ([]string, error) {
+	rows, err := q.Query(ctx, tableColumnsSQL, table, generated)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+// sanitizeColumns returns the quoted column names.
+func sanitizeColumns(columns []string) string {
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = pgx.Identifier{column}.Sanitize()
+	}
+
+	return strings.Join(quoted, ", ")
+}
+
+// frameReader reads the data of a table until its empty frame.
+type frameReader struct {
+	r    io.Reader
+	data []byte
+	done bool
+}
+
+// Read reads the data of the frames, returning io.EOF at the empty frame.
+func (r *frameReader) Read(p []byte) (int, error) {
+	for len(r.data) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+
+		data, err := readFrame(r.r)
+		if err != nil {
+			return 0, unexpectedEOF(err)
+		}
+
+		r.data = data
+		r.done = len(data) == 0
+	}
+
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+
+	return n, nil
+}
+
+// unexpectedEOF turns the end of the backup into ErrInvalidBackup, as the
+// backup ends after the data of every table.
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrInvalidBackup
+	}
+
+	return err
+}
+
+// Backup writes the rows of the client, token and consent tables to the
+// writer using the COPY protocol, so the OAuth state can be snapshotted
+// without pg_dump access. The tables are copied in a single read-only
+// transaction, so the backup is consistent. The rows of every realm are
+// copied, with the encrypted data left encrypted. The generated columns are
+// not copied, as they are computed again on restore. The COPY protocol needs a
+// *pgxpool.Pool, so it returns ErrUnsupportedDB with other databases.
+func (s *Stores) Backup(ctx context.Context, w io.Writer) error {
+	s.TokenStore.logger.Log(ctx, LogLevelInfo, "backing up stores")
+
+	if _, ok := nativePool(s.pool); !ok {
+		return ErrUnsupportedDB
+	}
+
+	err := pgx.BeginTxFunc(ctx, s.pool, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly}, func(tx pgx.Tx) error {
+		for _, table := range s.backupTables() {
+			if err := writeFrame(w, []byte(table.name)); err != nil {
+				return err
+			}
+
+			columns, err := tableColumns(ctx, tx, table.name, false)
+			if err != nil {
+				return err
+			}
+
+			if _, err = tx.Conn().PgConn().CopyTo(ctx, frameWriter{w}, fmt.Sprintf(
+				"COPY %s (%s) TO STDOUT WITH (FORMAT csv, HEADER)",
+				table.name, sanitizeColumns(columns),
+			)); err != nil {
+				return err
+			}
+
+			if err := writeFrame(w, nil); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		s.TokenStore.logger.Log(ctx, LogLevelError, err.Error())
+		return wrapDatabaseError(err)
+	}
+
+	return nil
+}
+
+// Restore reads a backup written by Backup into the tables using the COPY
+// protocol. The tables must exist, and should be empty, as restored rows
+// conflicting with existing ones fail the restore. The columns are matched by
+// name, so the tables may have been upgraded since the backup, and the
+// columns which became generated are computed again. The tables are
+// restored in a single transaction. It returns ErrInvalidBackup if the backup
+// is malformed or was taken of other tables, and ErrUnsupportedDB if the
+// stores do not use a *pgxpool.Pool.
+func (s *Stores) Restore(ctx context.Context, r io.Reader) error {
+	s.TokenStore.logger.Log(ctx, LogLevelInfo, "restoring stores")
+
+	if s.TokenStore.readOnly {
+		return ErrReadOnly
+	}
+
+	if _, ok := nativePool(s.pool); !ok {
+		return ErrUnsupportedDB
+	}
+
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		for _, table := range s.backupTables() {
+			if err := s.restoreTable(ctx, tx, table, r); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		s.TokenStore.logger.Log(ctx, LogLevelError, err.Error())
+		return wrapDatabaseError(err)
+	}
+
+	return nil
+}
+
+// restoreTable reads the data of the table from the backup.
+func (s *Stores) restoreTable(ctx context.Context, tx pgx.Tx, table backupTable, r io.Reader) error {
+	name, err := readFrame(r)
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+
+	if string(name) != table.name {
+		return ErrInvalidBackup
+	}
+
+	data := bufio.NewReader(&frameReader{r: r})
+
+	header, err := data.ReadString('\n')
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+
+	columns, err := csv.NewReader(strings.NewReader(header)).Read()
+	if err != nil {
+		return ErrInvalidBackup
+	}
+
+	for _, column := range columns {
+		if !columnNamePattern.MatchString(column) {
+			return ErrInvalidBackup
+		}
+	}
+
+	generated, err := tableColumns(ctx, tx, table.name, true)
+	if err != nil {
+		return err
+	}
+
+	skipped := make(map[string]bool, len(generated))
+	for _, column := range generated {
+		skipped[column] = true
+	}
+
+	stored := make([]string, 0, len(columns))
+	for _, column := range columns {
+		if !skipped[column] {
+			stored = append(stored, column)
+		}
+	}
+
+	if len(stored) == len(columns) {
+		_, err = tx.Conn().PgConn().CopyFrom(ctx, data, fmt.Sprintf(
+			"COPY %s (%s) FROM STDIN WITH (FORMAT csv)",
+			table.name, sanitizeColumns(columns),
+		))
+	} else {
+		err = restoreStaged(ctx, tx, table.name, columns, stored, data)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// Drain the end of the table in case COPY stopped reading early.
+	if _, err = io.Copy(io.Discard, data); err != nil {
+		return err
+	}
+
+	// The rows of backups taken before the key IDs were recorded have no key
+	// ID, and were encrypted with the current key of the connection.
+	if table.encryptionSetting != "" {
+		if _, err = tx.Exec(ctx, keyIDBackfill(table.name, table.encryptionSetting)+" WHERE key_id = ''"); err != nil {
+			return err
+		}
+	}
+
+	if table.serial {
+		_, err = tx.Exec(ctx, fmt.Sprintf(
+			"SELECT setval(pg_get_serial_sequence('%[1]s', 'id'), COALESCE(MAX(id), 0) + 1, false) FROM %[1]s",
+			table.name,
+		))
+	}
+
+	return err
+}
+
+// restoreStaged copies the data of the table into a temporary table without
+// the generated columns and inserts its stored columns into the table, as
+// COPY cannot skip the columns of the data which are generated in the table.
+func restoreStaged(ctx context.Context, tx pgx.Tx, table string, columns []string, stored []string, data io.Reader) error {
+	staging := pgx.Identifier{unqualifiedName(table) + "_restore"}.Sanitize()
+
+	if _, err := tx.Exec(ctx, fmt.Sprintf("CREATE TEMPORARY TABLE %s (LIKE %s) ON COMMIT DROP", staging, table)); err != nil {
+		return err
+	}
+
+	if _, err := tx.Conn().PgConn().CopyFrom(ctx, data, fmt.Sprintf(
+		"COPY %s (%s) FROM STDIN WITH (FORMAT csv)",
+		staging, sanitizeColumns(columns),
+	)); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, fmt.Sprintf(
+		"INSERT INTO %[1]s (%[3]s) OVERRIDING SYSTEM VALUE SELECT %[3]s FROM %[2]s",
+		table, staging, sanitizeColumns(stored),
+	)); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(ctx, "DROP TABLE "+staging)
+
+	return err
+}
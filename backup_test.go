@@ -0,0 +1,101 @@
+package pgstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+)
+
+func TestReadFrameTooLarge(t *testing.T) {
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], maxFrameSize+1)
+
+	if _, err := readFrame(bytes.NewReader(size[:])); !errors.Is(err, ErrInvalidBackup) {
+		t.Fatalf("got error %v, want %v", err, ErrInvalidBackup)
+	}
+}
+
+func TestUnexpectedEOF(t *testing.T) {
+	for _, err := range []error{io.EOF, io.ErrUnexpectedEOF, fmt.Errorf("read frame: %w", io.ErrUnexpectedEOF)} {
+		if got := unexpectedEOF(err); !errors.Is(got, ErrInvalidBackup) {
+			t.Errorf("got error %v for %v, want %v", got, err, ErrInvalidBackup)
+		}
+	}
+}
+
+func TestFrameWriterSplitsLargeWrites(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 2*maxFrameSize+1)
+
+	var buf bytes.Buffer
+	if _, err := (frameWriter{&buf}).Write(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writeFrame(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := io.ReadAll(&frameReader{r: &buf})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(read, data) {
+		t.Fatalf("got %d bytes, want %d", len(read), len(data))
+	}
+}
+
+func TestStoresBackupRestoreGeneratedColumns(t *testing.T) {
+	for name, backupOpts := range map[string][]TokenStoreOption{
+		"generated": {WithTokenStoreGeneratedColumns()},
+		"stored":    nil,
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			source := newTestStores(t, backupOpts...)
+			if err := source.TokenStore.Create(ctx, newTestToken("client", "user", "a")); err != nil {
+				t.Fatal(err)
+			}
+
+			var backup bytes.Buffer
+			if err := source.Backup(ctx, &backup); err != nil {
+				t.Fatal(err)
+			}
+
+			target := newTestStores(t, WithTokenStoreGeneratedColumns())
+			if err := target.Restore(ctx, &backup); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := target.TokenStore.GetByAccess(ctx, "access-a"); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// newTestStores creates the stores with their tables in a schema of their own,
+// or skips the test if no test database is configured.
+func newTestStores(t *testing.T, opts ...TokenStoreOption) *Stores {
+	t.Helper()
+
+	ctx := context.Background()
+
+	stores, err := NewStores(ctx, WithStoresDSN(newTestDSN(t)), WithStoresTokenStoreOptions(opts...))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { stores.Close(ctx) })
+
+	if err = stores.InitTables(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	return stores
+}
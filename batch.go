@@ -0,0 +1,86 @@
+package pgstore
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"github.com/jackc/pgx/v5"
+)
+
+// CreateBatch creates the tokens in the store using the COPY protocol. It is
+// meant for seeding a large number of tokens, therefore the rate limit is not
+// applied and a single event is published for the whole batch.
+func (s *TokenStore) CreateBatch(ctx context.Context, infos []oauth2.TokenInfo) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	s.logger.Log(ctx, LogLevelDebug, "creating token batch", "count", len(infos))
+
+	var columns []string
+	rows := make([][]any, 0, len(infos))
+	for _, info := range infos {
+		if err := s.hooks.beforeCreate(ctx, info); err != nil {
+			s.logger.Log(ctx, LogLevelError, err.Error())
+			return err
+		}
+
+		item, err := s.newTokenStoreItem(info)
+		if err != nil {
+			s.logger.Log(ctx, LogLevelError, err.Error())
+			return err
+		}
+
+		var values []any
+		columns, values = s.insertColumns(item)
+		rows = append(rows, values)
+	}
+
+	s.markWrite()
+
+	copied, err := s.copyRows(ctx, columns, rows)
+
+	for _, info := range infos {
+		s.hooks.afterCreate(ctx, info, err)
+	}
+
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return err
+	}
+
+	s.publishEvent(ctx, Event{Type: EventTokenCreated, Count: copied})
+	s.logger.Log(ctx, LogLevelDebug, "token batch created", "count", copied)
+
+	return nil
+}
+
+// copyRows copies the rows into the token table. The COPY protocol cannot
+// encrypt the data, so the rows are inserted in a transaction instead if
+// encryption is configured.
+func (s *TokenStore) copyRows(ctx context.Context, columns []string, rows [][]any) (int64, error) {
+	if s.encryptionSetting != "" {
+		err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+			for _, row := range rows {
+				if _, err := tx.Exec(ctx, s.insertSQL(columns), row...); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+
+		return int64(len(rows)), nil
+	}
+
+	return s.pool.CopyFrom(
+		ctx,
+		pgx.Identifier(strings.Split(s.table, ".")),
+		columns,
+		pgx.CopyFromRows(rows),
+	)
+}
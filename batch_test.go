@@ -0,0 +1,93 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestTokenStoreCreateBatchBeforeCreate(t *testing.T) {
+	errRejected := errors.New("rejected")
+
+	store, err := newTokenStore(WithTokenStoreDB(unusedDB{}), WithTokenStoreHooks(Hooks{
+		BeforeCreate: func(_ context.Context, info oauth2.TokenInfo) error {
+			if info.GetAccess() == "access-b" {
+				return errRejected
+			}
+
+			return nil
+		},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	infos := []oauth2.TokenInfo{newTestToken("client", "user", "a"), newTestToken("client", "user", "b")}
+	if err = store.CreateBatch(context.Background(), infos); !errors.Is(err, errRejected) {
+		t.Fatalf("got error %v, want %v", err, errRejected)
+	}
+}
+
+func TestTokenStoreCreateBatch(t *testing.T) {
+	testTokenStoreCreateBatch(t, newTestTokenStore(t))
+}
+
+func TestTokenStoreCreateBatchEncrypted(t *testing.T) {
+	ctx := context.Background()
+
+	config, err := pgxpool.ParseConfig(newTestDSN(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config.AfterConnect = SetEncryptionKey("pgstore.key", "key")
+
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	store, err := newTokenStore(WithTokenStoreConnPool(pool), WithTokenStoreEncryption("pgstore.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = store.InitTable(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	testTokenStoreCreateBatch(t, store)
+}
+
+// testTokenStoreCreateBatch creates a batch of tokens in the store and reads
+// every token back.
+func testTokenStoreCreateBatch(t *testing.T, store *TokenStore) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	infos := make([]oauth2.TokenInfo, 0, 3)
+	for i := 0; i < 3; i++ {
+		infos = append(infos, newTestToken("client", "user", fmt.Sprint(i)))
+	}
+
+	if err := store.CreateBatch(ctx, infos); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, info := range infos {
+		got, err := store.GetByAccess(ctx, info.GetAccess())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got.GetRefresh() != info.GetRefresh() || got.GetClientID() != "client" {
+			t.Errorf("got refresh token %q of client %q, want %q", got.GetRefresh(), got.GetClientID(), info.GetRefresh())
+		}
+	}
+}
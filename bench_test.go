@@ -0,0 +1,130 @@
+package pgstore
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"github.com/go-oauth2/oauth2/v4/models"
+)
+
+// newBenchTokenStore creates a token store with a table of its own in the
+// database of the PGSTORE_TEST_DSN environment variable, or skips the
+// benchmark if it is not set.
+func newBenchTokenStore(b *testing.B) *TokenStore {
+	b.Helper()
+
+	dsn := os.Getenv("PGSTORE_TEST_DSN")
+	if dsn == "" {
+		b.Skip("PGSTORE_TEST_DSN is not set")
+	}
+
+	ctx := context.Background()
+	table := fmt.Sprintf("bench_tokens_%d", time.Now().UnixNano())
+
+	store, err := NewTokenStore(WithTokenStoreDSN(dsn), WithTokenStoreTable(table))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	if err = store.InitTable(ctx); err != nil {
+		b.Fatal(err)
+	}
+
+	b.Cleanup(func() {
+		_, _ = store.pool.Exec(ctx, "DROP TABLE IF EXISTS "+table)
+		store.Close(ctx)
+	})
+
+	return store
+}
+
+// newBenchToken returns an access token with the given suffix.
+func newBenchToken(suffix string) *models.Token {
+	return &models.Token{
+		ClientID:         "bench-client",
+		UserID:           "bench-user",
+		Scope:            "read write",
+		Access:           "access-" + suffix,
+		AccessCreateAt:   time.Now(),
+		AccessExpiresIn:  time.Hour,
+		Refresh:          "refresh-" + suffix,
+		RefreshCreateAt:  time.Now(),
+		RefreshExpiresIn: 24 * time.Hour,
+	}
+}
+
+func BenchmarkTokenStoreCreate(b *testing.B) {
+	store := newBenchTokenStore(b)
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := store.Create(ctx, newBenchToken(strconv.Itoa(i))); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTokenStoreGetByAccess(b *testing.B) {
+	store := newBenchTokenStore(b)
+	ctx := context.Background()
+
+	if err := store.Create(ctx, newBenchToken("get")); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := store.GetByAccess(ctx, "access-get"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTokenStoreRemoveByAccess(b *testing.B) {
+	store := newBenchTokenStore(b)
+	ctx := context.Background()
+
+	for i := 0; i < b.N; i++ {
+		if err := store.Create(ctx, newBenchToken(strconv.Itoa(i))); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := store.RemoveByAccess(ctx, "access-"+strconv.Itoa(i)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTokenDataScanBytes(b *testing.B) {
+	store := &TokenStore{codec: new(JSONCodec), newTokenInfo: func() oauth2.TokenInfo { return models.NewToken() }}
+
+	data, err := store.encodeTokenInfo(newBenchToken("scan"))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		d := tokenData{store: store}
+		if err = d.ScanBytes(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
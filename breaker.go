@@ -0,0 +1,144 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// circuitBreaker rejects operations after consecutive database failures until
+// the cool-down period elapses, then lets a single probe operation through.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	coolDown  time.Duration
+	clock     Clock
+	failures  int
+	openedAt  time.Time
+	probing   bool
+}
+
+// newCircuitBreaker creates a new circuit breaker or returns nil if the
+// threshold is not set.
+func newCircuitBreaker(threshold int, coolDown time.Duration, clock Clock) *circuitBreaker {
+	if threshold <= 0 {
+		return nil
+	}
+
+	return &circuitBreaker{threshold: threshold, coolDown: coolDown, clock: clock}
+}
+
+// validateCircuitBreaker validates the circuit breaker configuration.
+func validateCircuitBreaker(threshold int, coolDown time.Duration) error {
+	if threshold <= 0 || coolDown <= 0 {
+		return ErrInvalidCircuitBreaker
+	}
+
+	return nil
+}
+
+// allow returns ErrStoreUnavailable if the circuit is open.
+func (b *circuitBreaker) allow() error {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return nil
+	}
+
+	if b.probing || b.clock.Now().Sub(b.openedAt) < b.coolDown {
+		return ErrStoreUnavailable
+	}
+
+	b.probing = true
+
+	return nil
+}
+
+// record records the result of an allowed operation.
+func (b *circuitBreaker) record(err error) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+
+	if !isDatabaseFailure(err) {
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openedAt = b.clock.Now()
+	}
+}
+
+// isDatabaseFailure returns true if the error means the database could not be
+// used, as opposed to the database rejecting or not finding the data.
+func isDatabaseFailure(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, pgx.ErrNoRows) {
+		return false
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// Connection exceptions, insufficient resources and operator
+		// interventions mean the database is not usable.
+		return strings.HasPrefix(pgErr.Code, "08") ||
+			strings.HasPrefix(pgErr.Code, "53") ||
+			strings.HasPrefix(pgErr.Code, "57P")
+	}
+
+	for _, sentinel := range []error{ErrTokenNotFound, ErrClientNotFound, ErrRateLimited, ErrQuotaExceeded, ErrDuplicateToken, ErrRefreshTokenReused, ErrTokenRevoked} {
+		if errors.Is(err, sentinel) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// WithTokenStoreCircuitBreaker configures a circuit breaker failing the token
+// operations fast with ErrStoreUnavailable after the given number of
+// consecutive database failures, until the cool-down period elapses.
+func WithTokenStoreCircuitBreaker(threshold int, coolDown time.Duration) TokenStoreOption {
+	return func(s *TokenStore) error {
+		if err := validateCircuitBreaker(threshold, coolDown); err != nil {
+			return err
+		}
+
+		s.breakerThreshold = threshold
+		s.breakerCoolDown = coolDown
+
+		return nil
+	}
+}
+
+// WithClientStoreCircuitBreaker configures a circuit breaker failing the
+// client operations fast with ErrStoreUnavailable after the given number of
+// consecutive database failures, until the cool-down period elapses.
+func WithClientStoreCircuitBreaker(threshold int, coolDown time.Duration) ClientStoreOption {
+	return func(s *ClientStore) error {
+		if err := validateCircuitBreaker(threshold, coolDown); err != nil {
+			return err
+		}
+
+		s.breakerThreshold = threshold
+		s.breakerCoolDown = coolDown
+
+		return nil
+	}
+}
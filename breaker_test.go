@@ -0,0 +1,146 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	breaker := newCircuitBreaker(0, time.Minute, &fixedClock{})
+	if breaker != nil {
+		t.Fatal("got a circuit breaker without a threshold")
+	}
+
+	breaker.record(ErrConnection)
+
+	if err := breaker.allow(); err != nil {
+		t.Fatalf("got %v from a disabled circuit breaker", err)
+	}
+}
+
+func TestCircuitBreakerTripsAtThreshold(t *testing.T) {
+	breaker := newCircuitBreaker(3, time.Minute, &fixedClock{now: time.Unix(0, 0)})
+	failure := &pgconn.PgError{Code: "08006"}
+
+	for i := 0; i < 2; i++ {
+		if err := breaker.allow(); err != nil {
+			t.Fatalf("got %v after %d failures", err, i)
+		}
+
+		breaker.record(failure)
+	}
+
+	// A success resets the consecutive failures.
+	breaker.record(nil)
+
+	for i := 0; i < 3; i++ {
+		if err := breaker.allow(); err != nil {
+			t.Fatalf("got %v after %d failures", err, i)
+		}
+
+		breaker.record(failure)
+	}
+
+	if err := breaker.allow(); !errors.Is(err, ErrStoreUnavailable) {
+		t.Fatalf("got %v, want %v", err, ErrStoreUnavailable)
+	}
+}
+
+func TestCircuitBreakerHalfOpenProbe(t *testing.T) {
+	clock := &fixedClock{now: time.Unix(0, 0)}
+	breaker := newCircuitBreaker(1, time.Minute, clock)
+
+	breaker.record(ErrConnection)
+
+	clock.now = clock.now.Add(time.Minute - time.Second)
+	if err := breaker.allow(); !errors.Is(err, ErrStoreUnavailable) {
+		t.Fatalf("got %v before the cool-down elapsed, want %v", err, ErrStoreUnavailable)
+	}
+
+	clock.now = clock.now.Add(time.Second)
+	if err := breaker.allow(); err != nil {
+		t.Fatalf("got %v for the probe, want nil", err)
+	}
+
+	if err := breaker.allow(); !errors.Is(err, ErrStoreUnavailable) {
+		t.Fatalf("got %v while probing, want %v", err, ErrStoreUnavailable)
+	}
+
+	// A failed probe opens the circuit for another cool-down period.
+	breaker.record(ErrConnection)
+
+	if err := breaker.allow(); !errors.Is(err, ErrStoreUnavailable) {
+		t.Fatalf("got %v after a failed probe, want %v", err, ErrStoreUnavailable)
+	}
+
+	clock.now = clock.now.Add(time.Minute)
+	if err := breaker.allow(); err != nil {
+		t.Fatalf("got %v for the probe, want nil", err)
+	}
+
+	// A successful probe closes the circuit.
+	breaker.record(nil)
+
+	for i := 0; i < 2; i++ {
+		if err := breaker.allow(); err != nil {
+			t.Fatalf("got %v after a successful probe, want nil", err)
+		}
+	}
+}
+
+func TestIsDatabaseFailure(t *testing.T) {
+	for name, tc := range map[string]struct {
+		err  error
+		want bool
+	}{
+		"nil":                  {err: nil, want: false},
+		"canceled":             {err: context.Canceled, want: false},
+		"no rows":              {err: pgx.ErrNoRows, want: false},
+		"token not found":      {err: ErrTokenNotFound, want: false},
+		"client not found":     {err: fmt.Errorf("get client: %w", ErrClientNotFound), want: false},
+		"rate limited":         {err: ErrRateLimited, want: false},
+		"quota exceeded":       {err: ErrQuotaExceeded, want: false},
+		"duplicate token":      {err: ErrDuplicateToken, want: false},
+		"refresh token reused": {err: ErrRefreshTokenReused, want: false},
+		"token revoked":        {err: ErrTokenRevoked, want: false},
+		"unique violation":     {err: &pgconn.PgError{Code: "23505"}, want: false},
+		"connection failure":   {err: &pgconn.PgError{Code: "08006"}, want: true},
+		"too many connections": {err: &pgconn.PgError{Code: "53300"}, want: true},
+		"admin shutdown":       {err: &pgconn.PgError{Code: "57P01"}, want: true},
+		"unknown":              {err: errors.New("connection refused"), want: true},
+	} {
+		if got := isDatabaseFailure(tc.err); got != tc.want {
+			t.Errorf("%s: got %t, want %t", name, got, tc.want)
+		}
+	}
+}
+
+func TestClientStoreCircuitBreakerFailsFast(t *testing.T) {
+	db := &failingDB{err: &pgconn.PgError{Code: "08006"}}
+
+	store, err := NewClientStore(WithClientStoreDB(db), WithClientStoreCircuitBreaker(2, time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := store.GetByID(ctx, "client"); errors.Is(err, ErrStoreUnavailable) {
+			t.Fatalf("got %v after %d failures", err, i)
+		}
+	}
+
+	// The failing database is not used once the circuit is open.
+	store.pool = unusedDB{}
+
+	if _, err := store.GetByID(ctx, "client"); !errors.Is(err, ErrStoreUnavailable) {
+		t.Fatalf("got %v, want %v", err, ErrStoreUnavailable)
+	}
+}
@@ -0,0 +1,363 @@
+package pgstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ChangeTokenCreated = ChangeType("created") // token row inserted
+	ChangeTokenDeleted = ChangeType("deleted") // token row deleted
+)
+
+// changeFeedBatchSize is the maximum number of changes read from the slot at
+// once.
+const changeFeedBatchSize = 1000
+
+// ChangeType is the type of a token table change.
+type ChangeType string
+
+// TokenChange is a change of the token table read from a logical replication
+// slot.
+type TokenChange struct {
+	Type     ChangeType
+	ID       int64
+	Key      string // ID of tables with UUID keys
+	ClientID string
+	UserID   string
+	// LSN is the position of the change in the write-ahead log.
+	LSN string
+}
+
+// wal2jsonColumn is a column of a change in the wal2json format version 2.
+type wal2jsonColumn struct {
+	Name  string `json:"name"`
+	Value any    `json:"value"`
+}
+
+// wal2jsonAction is the action of a change in the wal2json format version 2.
+type wal2jsonAction struct {
+	Action string `json:"action"`
+}
+
+// wal2jsonChange is a change in the wal2json format version 2.
+type wal2jsonChange struct {
+	Action   string           `json:"action"`
+	Columns  []wal2jsonColumn `json:"columns"`
+	Identity []wal2jsonColumn `json:"identity"`
+}
+
+// tokenChange returns the token change of the wal2json change.
+func tokenChange(lsn string, data []byte) (TokenChange, error) {
+	var raw wal2jsonChange
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	if err := dec.Decode(&raw); err != nil {
+		return TokenChange{}, err
+	}
+
+	change := TokenChange{LSN: lsn}
+	columns := raw.Columns
+
+	switch raw.Action {
+	case "I":
+		change.Type = ChangeTokenCreated
+	case "D":
+		change.Type = ChangeTokenDeleted
+		columns = raw.Identity
+	default:
+		return TokenChange{}, fmt.Errorf("unexpected change action %q", raw.Action)
+	}
+
+	for _, column := range columns {
+		switch value := column.Value.(type) {
+		case json.Number:
+			if column.Name == "id" {
+				change.ID, _ = value.Int64()
+			}
+		case string:
+			switch column.Name {
+			case "id":
+				change.Key = value
+			case "client_id":
+				change.ClientID = value
+			case "user_id":
+				change.UserID = value
+			}
+		}
+	}
+
+	return change, nil
+}
+
+// validateSlot returns ErrInvalidSlot if the slot name is not a valid
+// replication slot name.
+func validateSlot(slot string) error {
+	if !columnNamePattern.MatchString(slot) {
+		return ErrInvalidSlot
+	}
+
+	return nil
+}
+
+// CreateChangeFeedSlot creates the logical replication slot read by
+// ChangeFeed, using the wal2json output plugin, which must be installed on
+// the server. The replica identity of the token table is set to full, so the
+// deleted rows carry their client and user IDs.
+func (s *TokenStore) CreateChangeFeedSlot(ctx context.Context, slot string) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	if err := validateSlot(slot); err != nil {
+		return err
+	}
+
+	s.logger.Log(ctx, LogLevelDebug, "creating change feed slot", "slot", slot)
+
+	if _, err := s.pool.Exec(ctx, fmt.Sprintf("ALTER TABLE %s REPLICA IDENTITY FULL", s.table)); err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return err
+	}
+
+	if _, err := s.pool.Exec(ctx, "SELECT pg_create_logical_replication_slot($1, 'wal2json')", slot); err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// DropChangeFeedSlot drops the logical replication slot, so the server stops
+// retaining the write-ahead log for it.
+func (s *TokenStore) DropChangeFeedSlot(ctx context.Context, slot string) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	if err := validateSlot(slot); err != nil {
+		return err
+	}
+
+	s.logger.Log(ctx, LogLevelDebug, "dropping change feed slot", "slot", slot)
+
+	if _, err := s.pool.Exec(ctx, "SELECT pg_drop_replication_slot($1)", slot); err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// changeFeedTables returns the wal2json filter of the token table.
+func (s *TokenStore) changeFeedTables() string {
+	if strings.Contains(s.table, ".") {
+		return s.table
+	}
+
+	return "*." + s.table
+}
+
+// changeFeeds tracks the running change feeds, so closing the store stops
+// them.
+type changeFeeds struct {
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+	cancels []context.CancelFunc
+	closed  bool
+}
+
+// start registers a change feed and returns its context, cancelled when the
+// store is closed. It returns ErrStoreClosed if the store is closed.
+func (f *changeFeeds) start(ctx context.Context) (context.Context, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return nil, ErrStoreClosed
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	f.cancels = append(f.cancels, cancel)
+	f.wg.Add(1)
+
+	return ctx, nil
+}
+
+// stop cancels the change feeds and waits for them to finish.
+func (f *changeFeeds) stop(ctx context.Context, logger Logger) {
+	f.mu.Lock()
+	f.closed = true
+	cancels := f.cancels
+	f.cancels = nil
+	f.mu.Unlock()
+
+	if len(cancels) == 0 {
+		return
+	}
+
+	logger.Log(ctx, LogLevelDebug, "stopping change feeds")
+
+	for _, cancel := range cancels {
+		cancel()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		f.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		logger.Log(ctx, LogLevelWarn, "waiting for change feeds to finish aborted", "error", ctx.Err())
+	}
+}
+
+// readChanges returns the next changes of the token table in the slot
+// without consuming them, and the position to advance the slot to once the
+// changes were delivered, and whether the slot has more changes. The slot is
+// read up to the current end of the
+// write-ahead log in whole transactions and advanced to the end of the last
+// read commit, or to the end of the log if the slot was read completely, so
+// the transactions of other tables do not make the server retain the log.
+func (s *TokenStore) readChanges(ctx context.Context, slot string) ([]TokenChange, string, bool, error) {
+	var upto string
+	if err := s.pool.QueryRow(ctx, "SELECT pg_current_wal_lsn()::TEXT").Scan(&upto); err != nil {
+		return nil, "", false, err
+	}
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT lsn::TEXT, data FROM pg_logical_slot_peek_changes($1, $2::PG_LSN, $3,
+			'format-version', '2', 'include-transaction', 'true',
+			'actions', 'insert,delete', 'add-tables', $4)`,
+		slot, upto, changeFeedBatchSize, s.changeFeedTables(),
+	)
+	if err != nil {
+		return nil, "", false, err
+	}
+	defer rows.Close()
+
+	var changes []TokenChange
+	var commit string
+	var read int
+
+	for rows.Next() {
+		var lsn, data string
+		if err = rows.Scan(&lsn, &data); err != nil {
+			return nil, "", false, err
+		}
+
+		read++
+
+		var action wal2jsonAction
+		if err = json.Unmarshal([]byte(data), &action); err == nil {
+			switch action.Action {
+			case "B":
+				continue
+			case "C":
+				commit = lsn
+				continue
+			}
+		}
+
+		change, err := tokenChange(lsn, []byte(data))
+		if err != nil {
+			s.logger.Log(ctx, LogLevelWarn, "invalid change", "lsn", lsn, "err", err)
+			continue
+		}
+
+		changes = append(changes, change)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, "", false, err
+	}
+
+	if read < changeFeedBatchSize {
+		return changes, upto, false, nil
+	}
+
+	return changes, commit, true, nil
+}
+
+// ChangeFeed reads the changes of the token table from the logical
+// replication slot created by CreateChangeFeedSlot and returns them as token
+// changes, so downstream systems can be kept in sync without polling the
+// token table. The slot is read through the SQL interface every interval and
+// advanced once the changes were received, so the changes are delivered at
+// least once, also across restarts. The returned channel is closed when the
+// context is cancelled, the store is closed or reading the slot fails.
+func (s *TokenStore) ChangeFeed(ctx context.Context, slot string, interval time.Duration) (<-chan TokenChange, error) {
+	if err := validateSlot(slot); err != nil {
+		return nil, err
+	}
+
+	if interval <= 0 {
+		return nil, ErrInvalidPollInterval
+	}
+
+	ctx, err := s.changeFeeds.start(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Log(ctx, LogLevelDebug, "reading change feed", "slot", slot)
+
+	changes := make(chan TokenChange)
+
+	go func() {
+		defer s.changeFeeds.wg.Done()
+		defer close(changes)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			batch, lsn, more, err := s.readChanges(ctx, slot)
+			if err != nil {
+				if ctx.Err() == nil {
+					s.logger.Log(ctx, LogLevelError, err.Error())
+				}
+
+				return
+			}
+
+			for _, change := range batch {
+				select {
+				case changes <- change:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			if lsn != "" {
+				if _, err = s.pool.Exec(ctx, "SELECT pg_replication_slot_advance($1, $2::PG_LSN)", slot, lsn); err != nil {
+					s.logger.Log(ctx, LogLevelError, err.Error())
+					return
+				}
+			}
+
+			// Read the next batch right away if the slot has more changes.
+			if more {
+				continue
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return changes, nil
+}
@@ -0,0 +1,132 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestChangeFeedClosedStore(t *testing.T) {
+	store, err := NewTokenStore(WithTokenStoreDB(unusedDB{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store.Close(context.Background())
+
+	if _, err = store.ChangeFeed(context.Background(), "slot", time.Second); !errors.Is(err, ErrStoreClosed) {
+		t.Fatalf("got error %v, want %v", err, ErrStoreClosed)
+	}
+}
+
+func TestChangeFeedsStop(t *testing.T) {
+	var feeds changeFeeds
+
+	ctx, err := feeds.start(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		feeds.wg.Done()
+	}()
+
+	feeds.stop(context.Background(), new(NoopLogger))
+
+	if ctx.Err() == nil {
+		t.Fatal("change feed not cancelled")
+	}
+}
+
+func TestTokenStoreChangeFeed(t *testing.T) {
+	store := newTestTokenStore(t)
+	ctx := context.Background()
+	slot := fmt.Sprintf("pgstore_test_%d", time.Now().UnixNano())
+
+	if err := store.CreateChangeFeedSlot(ctx, slot); err != nil {
+		t.Skipf("creating the slot failed, wal2json may not be installed: %v", err)
+	}
+
+	// The slot is dropped through a pool of its own, as the test closes the
+	// store.
+	t.Cleanup(func() {
+		pool, err := pgxpool.New(ctx, os.Getenv("PGSTORE_TEST_DSN"))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		defer pool.Close()
+
+		if _, err = pool.Exec(ctx, "SELECT pg_drop_replication_slot($1)", slot); err != nil {
+			t.Error(err)
+		}
+	})
+
+	if err := store.Create(ctx, newTestToken("client", "user", "a")); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := store.ChangeFeed(ctx, slot, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case change := <-changes:
+		if change.Type != ChangeTokenCreated || change.UserID != "user" {
+			t.Fatalf("got change %+v, want the created token", change)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change received")
+	}
+
+	// The change of the committed transaction is not delivered again.
+	select {
+	case change := <-changes:
+		t.Fatalf("got change %+v delivered again", change)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Changes of other tables advance the slot.
+	if _, err = store.pool.Exec(ctx, "CREATE TABLE changefeed_other (id INT); INSERT INTO changefeed_other VALUES (1)"); err != nil {
+		t.Fatal(err)
+	}
+
+	var lsn string
+	if err = store.pool.QueryRow(ctx, "SELECT pg_current_wal_lsn()::TEXT").Scan(&lsn); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var advanced bool
+		if err = store.pool.QueryRow(ctx,
+			"SELECT confirmed_flush_lsn >= $2::PG_LSN FROM pg_replication_slots WHERE slot_name = $1", slot, lsn,
+		).Scan(&advanced); err != nil {
+			t.Fatal(err)
+		}
+
+		if advanced {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("slot not advanced past the changes of other tables")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	store.Close(ctx)
+
+	if _, ok := <-changes; ok {
+		t.Fatal("change feed not closed with the store")
+	}
+}
@@ -0,0 +1,71 @@
+package pgstore
+
+import "fmt"
+
+// WithTokenStoreCitus configures InitTable to create the token table as a
+// Citus distributed table, distributed by the shard key of
+// WithTokenStoreShards. Getting and removing a token by its access token is
+// routed to a single shard, the other queries run on every shard. Citus
+// cannot be combined with sharding or partitioning by expiry.
+func WithTokenStoreCitus() TokenStoreOption {
+	return func(s *TokenStore) error {
+		s.citus = true
+		return nil
+	}
+}
+
+// WithClientStoreCitus configures InitTable to create the client tables as
+// Citus reference tables, replicated to every node, so the client lookups and
+// the joins with the distributed token table are local to each node.
+func WithClientStoreCitus() ClientStoreOption {
+	return func(s *ClientStore) error {
+		s.citus = true
+		return nil
+	}
+}
+
+// WithStoresCitus configures InitTable to create the token table as a Citus
+// distributed table and the client tables as Citus reference tables.
+func WithStoresCitus() StoresOption {
+	return func(c *storesConfig) error {
+		c.tokenOpts = append(c.tokenOpts, WithTokenStoreCitus())
+		c.clientOpts = append(c.clientOpts, WithClientStoreCitus())
+
+		return nil
+	}
+}
+
+// hasShardKey returns true if the token table has a shard key column.
+func (s *TokenStore) hasShardKey() bool {
+	return s.shards > 0 || s.citus
+}
+
+// citusDistributedSQL returns the statement distributing the table by the
+// shard key, or an empty string if Citus is disabled. Tables already
+// distributed are skipped.
+func (s *TokenStore) citusDistributedSQL(table string) string {
+	if !s.citus {
+		return ""
+	}
+
+	return fmt.Sprintf(`
+		SELECT create_distributed_table('%[1]s', '%[2]s')
+		WHERE NOT EXISTS (SELECT 1 FROM pg_dist_partition WHERE logicalrelid = '%[1]s'::regclass);`,
+		table, shardKeyColumn,
+	)
+}
+
+// citusReferenceSQL returns the statement creating a reference table from
+// the table, or an empty string if Citus is disabled. Reference tables are
+// skipped.
+func (s *ClientStore) citusReferenceSQL(table string) string {
+	if !s.citus {
+		return ""
+	}
+
+	return fmt.Sprintf(`
+		SELECT create_reference_table('%[1]s')
+		WHERE NOT EXISTS (SELECT 1 FROM pg_dist_partition WHERE logicalrelid = '%[1]s'::regclass);`,
+		table,
+	)
+}
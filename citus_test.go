@@ -0,0 +1,66 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenStoreCitusInvalid(t *testing.T) {
+	for name, opt := range map[string]TokenStoreOption{
+		"shards":       WithTokenStoreShards(4),
+		"partitioning": WithTokenStorePartitioning(24*time.Hour, 2),
+	} {
+		if _, err := newTokenStore(WithTokenStoreDB(unusedDB{}), WithTokenStoreCitus(), opt); !errors.Is(err, ErrInvalidCitus) {
+			t.Errorf("%s: got error %v, want %v", name, err, ErrInvalidCitus)
+		}
+	}
+}
+
+func TestStoresCitusSQL(t *testing.T) {
+	ctx := context.Background()
+
+	stores, err := NewStores(ctx, WithStoresDB(unusedDB{}), WithStoresCitus())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stores.Close(ctx)
+
+	if !stores.TokenStore.hasShardKey() {
+		t.Fatal("got a distributed token table without a shard key")
+	}
+
+	ddl := stores.TokenStore.InitTableSQL()
+	if !strings.Contains(ddl, shardKeyColumn+" ") || !strings.Contains(ddl, "create_distributed_table('"+stores.TokenStore.table+"', '"+shardKeyColumn+"')") {
+		t.Fatalf("got token DDL %s, want a table distributed by the shard key", ddl)
+	}
+
+	ddl = stores.ClientStore.InitTableSQL()
+	for _, table := range []string{
+		stores.ClientStore.table,
+		stores.ClientStore.secretTable,
+		stores.ClientStore.redirectURITable,
+		stores.ClientStore.scopeTable,
+		stores.ClientStore.clientScopeTable,
+	} {
+		if !strings.Contains(ddl, "create_reference_table('"+table+"')") {
+			t.Errorf("got no reference table of %s", table)
+		}
+	}
+}
+
+func TestStoresWithoutCitusSQL(t *testing.T) {
+	ctx := context.Background()
+
+	stores, err := NewStores(ctx, WithStoresDB(unusedDB{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stores.Close(ctx)
+
+	if ddl := stores.InitTablesSQL(); strings.Contains(ddl, "pg_dist_partition") {
+		t.Fatalf("got Citus statements in DDL %s", ddl)
+	}
+}
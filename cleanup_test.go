@@ -0,0 +1,251 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// blockingDB is a database blocking every statement until its context is
+// done.
+type blockingDB struct {
+	DB
+
+	start    sync.Once
+	started  chan struct{}
+	ret      sync.Once
+	returned chan struct{}
+}
+
+func newBlockingDB() *blockingDB {
+	return &blockingDB{started: make(chan struct{}), returned: make(chan struct{})}
+}
+
+func (d *blockingDB) Exec(ctx context.Context, _ string, _ ...any) (pgconn.CommandTag, error) {
+	d.start.Do(func() { close(d.started) })
+	<-ctx.Done()
+	d.ret.Do(func() { close(d.returned) })
+
+	return pgconn.CommandTag{}, ctx.Err()
+}
+
+func TestTokenStoreCloseCancelsCleanup(t *testing.T) {
+	db := newBlockingDB()
+
+	store, err := newTokenStore(WithTokenStoreDB(db), WithTokenStoreCleanupInterval(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store.InitCleanup(context.Background())
+	<-db.started
+
+	store.Close(context.Background())
+
+	select {
+	case <-db.returned:
+	default:
+		t.Fatal("store closed before the in-flight cleanup returned")
+	}
+}
+
+func TestTokenStoreCleanupStopsWithContext(t *testing.T) {
+	db := newBlockingDB()
+
+	store, err := newTokenStore(WithTokenStoreDB(db), WithTokenStoreCleanupInterval(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	store.InitCleanup(ctx)
+	<-db.started
+
+	cancel()
+
+	select {
+	case <-store.cleanupDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("cleanup not stopped with its context")
+	}
+}
+
+// batchDB is a database deleting the given number of rows per statement.
+type batchDB struct {
+	DB
+
+	removed []int64
+	calls   int
+}
+
+func (d *batchDB) Exec(context.Context, string, ...any) (pgconn.CommandTag, error) {
+	var n int64
+	if d.calls < len(d.removed) {
+		n = d.removed[d.calls]
+	}
+
+	d.calls++
+
+	return pgconn.NewCommandTag(fmt.Sprintf("DELETE %d", n)), nil
+}
+
+func TestWithTokenStoreCleanupBatchSizeInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		size  int
+		delay time.Duration
+	}{{size: 0}, {size: -1}, {size: 10, delay: -time.Second}} {
+		if err := WithTokenStoreCleanupBatchSize(tt.size, tt.delay)(&TokenStore{}); !errors.Is(err, ErrInvalidCleanupBatch) {
+			t.Errorf("size %d, delay %s: got error %v, want %v", tt.size, tt.delay, err, ErrInvalidCleanupBatch)
+		}
+	}
+}
+
+func TestTokenStoreRemoveExpiredTokensInBatches(t *testing.T) {
+	db := &batchDB{removed: []int64{2, 2, 1}}
+
+	store, err := newTokenStore(WithTokenStoreDB(db), WithTokenStoreCleanupBatchSize(2, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cond := store.expiredCondition(store.table, cleanupAll); !strings.Contains(cond, "LIMIT 2") {
+		t.Fatalf("got condition %q, want a batch of 2", cond)
+	}
+
+	removed, err := store.removeExpiredTokens(context.Background(), time.Now(), cleanupAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if removed != 5 || db.calls != 3 {
+		t.Fatalf("got %d tokens removed in %d batches, want 5 in 3", removed, db.calls)
+	}
+}
+
+func TestTokenStoreCleanupBatchDelayStopsWithContext(t *testing.T) {
+	db := &batchDB{removed: []int64{2, 2}}
+
+	store, err := newTokenStore(WithTokenStoreDB(db), WithTokenStoreCleanupBatchSize(2, time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	removed, err := store.removeExpiredTokens(ctx, time.Now(), cleanupAll)
+	if !errors.Is(err, context.DeadlineExceeded) || removed != 2 || db.calls != 1 {
+		t.Fatalf("got %d tokens removed in %d batches and error %v, want the first batch only", removed, db.calls, err)
+	}
+}
+
+func TestTokenStoreCleanupInBatches(t *testing.T) {
+	store := newTestTokenStore(t, WithTokenStoreCleanupBatchSize(2, 0))
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		if err := store.CreateWithExpiry(ctx, newTestToken("client", "user", fmt.Sprint(i)), time.Now().Add(-time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := store.cleanExpiredTokens(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if removed != 5 {
+		t.Fatalf("got %d tokens removed, want 5", removed)
+	}
+}
+
+func TestWithTokenStoreCleanupTimeoutInvalid(t *testing.T) {
+	if err := WithTokenStoreCleanupTimeout(0)(&TokenStore{}); !errors.Is(err, ErrInvalidCleanupTimeout) {
+		t.Fatalf("got error %v, want %v", err, ErrInvalidCleanupTimeout)
+	}
+}
+
+func TestTokenStoreCleanupTimeout(t *testing.T) {
+	db := newBlockingDB()
+
+	store, err := newTokenStore(WithTokenStoreDB(db), WithTokenStoreCleanupInterval(time.Millisecond), WithTokenStoreCleanupTimeout(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store.InitCleanup(context.Background())
+	defer store.StopCleanup()
+
+	select {
+	case <-db.returned:
+	case <-time.After(5 * time.Second):
+		t.Fatal("cleanup not stopped by its timeout")
+	}
+}
+
+func TestTokenStoreStopCleanup(t *testing.T) {
+	db := newBlockingDB()
+
+	store, err := newTokenStore(WithTokenStoreDB(db), WithTokenStoreCleanupInterval(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store.InitCleanup(context.Background())
+	<-db.started
+
+	store.StopCleanup()
+
+	select {
+	case <-store.cleanupDone:
+	default:
+		t.Fatal("cleanup still running after StopCleanup")
+	}
+
+	// Stopping again is a no-op.
+	store.StopCleanup()
+}
+
+func TestTokenStorePurgeExpired(t *testing.T) {
+	store := newTestTokenStore(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	if err := store.CreateWithExpiry(ctx, newTestToken("client", "user", "expired"), now.Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.CreateWithExpiry(ctx, newTestToken("client", "user", "active"), now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := store.PurgeExpired(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if removed != 1 {
+		t.Fatalf("got %d tokens purged, want 1", removed)
+	}
+
+	if _, err = store.GetByAccess(ctx, "access-active"); err != nil {
+		t.Fatalf("got error %v for the active token", err)
+	}
+}
+
+func TestTokenStorePurgeExpiredReadOnly(t *testing.T) {
+	store, err := newTokenStore(WithTokenStoreDB(unusedDB{}), WithTokenStoreReadOnly(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = store.PurgeExpired(context.Background()); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("got error %v, want %v", err, ErrReadOnly)
+	}
+}
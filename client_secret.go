@@ -0,0 +1,230 @@
+package pgstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// DefaultClientSecretTable is the default table for storing client
+	// secrets.
+	DefaultClientSecretTable = "oauth2_client_secrets" // nolint: gosec
+)
+
+// WithClientStoreSecretTable configures the client secret table.
+func WithClientStoreSecretTable(table string) ClientStoreOption {
+	return func(s *ClientStore) error {
+		if table == "" {
+			return ErrNoTable
+		}
+
+		s.secretTable = table
+
+		return nil
+	}
+}
+
+// dummySecretHash is compared with the presented secret of the clients
+// without a plaintext secret, so the time taken does not reveal whether the
+// client exists or has a plaintext secret.
+var dummySecretHash = []byte(hashSecret(""))
+
+// hashSecret returns the hex encoded SHA-256 hash of the secret.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// secretTableSQL returns the DDL of the client secret table.
+func (s *ClientStore) secretTableSQL() string {
+	return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %[1]s (
+			id          %[5]s PRIMARY KEY NOT NULL,
+			client_id   VARCHAR(255)          NOT NULL REFERENCES %[2]s (id) ON DELETE CASCADE,
+			secret_hash TEXT                  NOT NULL,
+			created_at  TIMESTAMPTZ           NOT NULL,
+			expires_at  TIMESTAMPTZ,
+			revoked_at  TIMESTAMPTZ
+		);
+%[4]s
+
+		CREATE INDEX IF NOT EXISTS idx_%[3]s_client_hash_idx ON %[1]s (client_id, secret_hash);`,
+		s.secretTable, s.table, unqualifiedName(s.secretTable), s.citusReferenceSQL(s.secretTable), s.secretIDColumnType(),
+	)
+}
+
+// AddSecret adds a new secret to the client and returns its ID. The secret is
+// stored hashed. The secret never expires if the expiry is zero. Existing
+// secrets stay valid, so clients can be migrated to the new secret before
+// the old one is revoked. It returns ErrClientNotFound if the client does not
+// exist in the realm of the store.
+func (s *ClientStore) AddSecret(ctx context.Context, clientID string, secret string, expiresAt time.Time) (int64, error) {
+	if s.readOnly {
+		return 0, ErrReadOnly
+	}
+
+	s.logger.Log(ctx, LogLevelDebug, "adding client secret", "client_id", clientID)
+
+	var expiry *time.Time
+	if !expiresAt.IsZero() {
+		expiry = &expiresAt
+	}
+
+	var id int64
+	err := s.pool.QueryRow(ctx, fmt.Sprintf(`
+		INSERT INTO %s (client_id, secret_hash, created_at, expires_at)
+		SELECT id, $2, $3, $4 FROM %s WHERE id = $1%s
+		RETURNING id`,
+		s.secretTable, s.table, andRealm(s.realm),
+	), clientID, hashSecret(secret), s.clock.Now(), expiry).Scan(&id)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrClientNotFound
+	}
+
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return 0, wrapDatabaseError(err)
+	}
+
+	s.logger.Log(ctx, LogLevelDebug, "client secret added", "client_id", clientID, "id", id)
+
+	return id, nil
+}
+
+// RevokeSecret revokes the secret of the client. It returns
+// ErrClientSecretNotFound if the secret does not exist or the client does not
+// exist in the realm of the store.
+func (s *ClientStore) RevokeSecret(ctx context.Context, clientID string, id int64) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	s.logger.Log(ctx, LogLevelDebug, "revoking client secret", "client_id", clientID, "id", id)
+
+	tag, err := s.pool.Exec(ctx, fmt.Sprintf(
+		"UPDATE %s s SET revoked_at = $1 FROM %s c WHERE c.id = s.client_id AND s.client_id = $2 AND s.id = $3 AND s.revoked_at IS NULL%s",
+		s.secretTable, s.table, andRealm(s.realm),
+	), s.clock.Now(), clientID, id)
+
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return wrapDatabaseError(err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrClientSecretNotFound
+	}
+
+	s.logger.Log(ctx, LogLevelInfo, "client secret revoked", "client_id", clientID, "id", id)
+
+	return nil
+}
+
+// VerifySecret returns true if the secret is one of the not revoked and not
+// expired secrets of the client. The secrets of disabled clients are never
+// valid.
+func (s *ClientStore) VerifySecret(ctx context.Context, clientID string, secret string) (bool, error) {
+	s.logger.Log(ctx, LogLevelDebug, "verifying client secret", "client_id", clientID)
+
+	var valid bool
+	err := s.pool.QueryRow(ctx, fmt.Sprintf(`
+		SELECT EXISTS (
+			SELECT 1 FROM %s s JOIN %s c ON c.id = s.client_id
+			WHERE s.client_id = $1 AND s.secret_hash = $2 AND s.revoked_at IS NULL
+			AND (s.expires_at IS NULL OR s.expires_at > $3) AND c.is_enabled%s
+		)`,
+		s.secretTable, s.table, andRealm(s.realm),
+	), clientID, hashSecret(secret), s.clock.Now()).Scan(&valid)
+
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return false, wrapDatabaseError(err)
+	}
+
+	return valid, nil
+}
+
+// secretHashes returns the hashes of the not revoked and not expired secrets
+// of the enabled client.
+func (s *ClientStore) secretHashes(ctx context.Context, id string) ([]string, error) {
+	rows, err := s.pool.Query(ctx, fmt.Sprintf(`
+		SELECT s.secret_hash FROM %s s JOIN %s c ON c.id = s.client_id
+		WHERE s.client_id = $1 AND s.revoked_at IS NULL
+		AND (s.expires_at IS NULL OR s.expires_at > $2) AND c.is_enabled%s`,
+		s.secretTable, s.table, andRealm(s.realm),
+	), id, s.clock.Now())
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return nil, wrapDatabaseError(err)
+	}
+
+	hashes, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return nil, wrapDatabaseError(err)
+	}
+
+	return hashes, nil
+}
+
+// GetByIDAndVerifySecret returns the client if the secret matches either the
+// plaintext secret stored with the client or one of its not revoked and not
+// expired hashed secrets.
+//
+// It returns ErrClientNotFound if the client does not exist in the realm of
+// the store, ErrClientDisabled if the client is disabled and
+// ErrInvalidClientSecret if the secret does not match. Unknown and disabled
+// clients are read from the database, bypassing the negative cache, and their
+// secret is compared with a dummy hash, so the time taken does not reveal
+// whether the client exists or is enabled.
+func (s *ClientStore) GetByIDAndVerifySecret(ctx context.Context, id string, secret string) (oauth2.ClientInfo, error) {
+	hashes, err := s.secretHashes(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := s.queryClient(ctx, id)
+	if errors.Is(err, ErrClientNotFound) {
+		info = nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	// The plaintext secret is compared by its hash, as comparing values of
+	// different length would reveal its length. Every candidate is compared,
+	// so the time taken does not reveal which one matched.
+	stored, hasStored := dummySecretHash, 0
+	if info != nil && info.GetSecret() != "" {
+		stored, hasStored = []byte(hashSecret(info.GetSecret())), 1
+	}
+
+	hash := []byte(hashSecret(secret))
+	match := subtle.ConstantTimeCompare(stored, hash) & hasStored
+
+	for _, h := range hashes {
+		match |= subtle.ConstantTimeCompare([]byte(h), hash)
+	}
+
+	switch {
+	case info == nil:
+		s.logger.Log(ctx, LogLevelWarn, "client not found", "client_id", id)
+		return nil, ErrClientNotFound
+	case !info.Enabled:
+		s.logger.Log(ctx, LogLevelWarn, "client is disabled", "client_id", id)
+		return nil, ErrClientDisabled
+	case match != 1:
+		s.logger.Log(ctx, LogLevelWarn, "invalid client secret", "client_id", id)
+		return nil, ErrInvalidClientSecret
+	}
+
+	return info, nil
+}
@@ -0,0 +1,171 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// emptyRows is a result without rows.
+type emptyRows struct {
+	pgx.Rows
+}
+
+func (r emptyRows) Next() bool { return false }
+func (r emptyRows) Err() error { return nil }
+func (r emptyRows) Close()     {}
+
+// emptyRow is a missing row.
+type emptyRow struct{}
+
+func (emptyRow) Scan(...any) error { return pgx.ErrNoRows }
+
+// emptyDB is a database returning no rows for every query and counting the
+// queries.
+type emptyDB struct {
+	DB
+	queried int
+}
+
+func (d *emptyDB) Query(context.Context, string, ...any) (pgx.Rows, error) {
+	d.queried++
+	return emptyRows{}, nil
+}
+
+func (d *emptyDB) QueryRow(context.Context, string, ...any) pgx.Row {
+	d.queried++
+	return emptyRow{}
+}
+
+func TestGetByIDAndVerifySecretMissingClient(t *testing.T) {
+	db := new(emptyDB)
+
+	store, err := NewClientStore(WithClientStoreDB(db), WithClientStoreNegativeCache(time.Minute, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store.rememberMissing("client", ErrClientNotFound)
+
+	if _, err = store.GetByIDAndVerifySecret(context.Background(), "client", "secret"); !errors.Is(err, ErrClientNotFound) {
+		t.Fatalf("got error %v, want %v", err, ErrClientNotFound)
+	}
+
+	if db.queried != 2 {
+		t.Fatalf("got %d queries, want the client and its secrets queried despite the negative cache", db.queried)
+	}
+}
+
+func TestClientStoreGetByIDAndVerifySecret(t *testing.T) {
+	store := newTestClientStore(t)
+	ctx := context.Background()
+
+	// The plaintext secret of clients stored before the hashed secrets.
+	if err := store.Upsert(ctx, &models.Client{ID: "legacy", Secret: "plain"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Upsert(ctx, &models.Client{ID: "hashed"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.AddSecret(ctx, "hashed", "rotated", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		id     string
+		secret string
+		want   error
+	}{
+		"plaintext secret":   {id: "legacy", secret: "plain"},
+		"hashed secret":      {id: "hashed", secret: "rotated"},
+		"invalid plaintext":  {id: "legacy", secret: "other", want: ErrInvalidClientSecret},
+		"invalid hashed":     {id: "hashed", secret: "other", want: ErrInvalidClientSecret},
+		"empty secret":       {id: "hashed", secret: "", want: ErrInvalidClientSecret},
+		"unknown client":     {id: "unknown", secret: "plain", want: ErrClientNotFound},
+		"secret of other id": {id: "legacy", secret: "rotated", want: ErrInvalidClientSecret},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			info, err := store.GetByIDAndVerifySecret(ctx, tt.id, tt.secret)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("got error %v, want %v", err, tt.want)
+			}
+
+			if tt.want == nil && info.GetID() != tt.id {
+				t.Fatalf("got client %q, want %q", info.GetID(), tt.id)
+			}
+		})
+	}
+}
+
+func TestClientStoreGetByIDAndVerifySecretRealm(t *testing.T) {
+	stores := newTestRealmClientStores(t, "a", "b")
+	ctx := context.Background()
+
+	if err := stores["a"].Upsert(ctx, &models.Client{ID: "client"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := stores["a"].AddSecret(ctx, "client", "secret", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := stores["a"].GetByIDAndVerifySecret(ctx, "client", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := stores["b"].GetByIDAndVerifySecret(ctx, "client", "secret"); !errors.Is(err, ErrClientNotFound) {
+		t.Fatalf("got error %v, want %v", err, ErrClientNotFound)
+	}
+
+	if valid, err := stores["b"].VerifySecret(ctx, "client", "secret"); err != nil || valid {
+		t.Fatalf("got secret valid %t and error %v in another realm, want it invalid", valid, err)
+	}
+}
+
+func TestClientStoreVerifySecretDisabledClient(t *testing.T) {
+	store := newTestClientStore(t)
+	ctx := context.Background()
+
+	if err := store.Upsert(ctx, &models.Client{ID: "client"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.AddSecret(ctx, "client", "secret", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Disable(ctx, "client"); err != nil {
+		t.Fatal(err)
+	}
+
+	if valid, err := store.VerifySecret(ctx, "client", "secret"); err != nil || valid {
+		t.Fatalf("got secret valid %t and error %v for a disabled client, want it invalid", valid, err)
+	}
+}
+
+func TestClientStoreGetByIDAndVerifySecretDisabledClient(t *testing.T) {
+	store := newTestClientStore(t)
+	ctx := context.Background()
+
+	if err := store.Upsert(ctx, &models.Client{ID: "client", Secret: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Disable(ctx, "client"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, secret := range []string{"secret", "other"} {
+		if _, err := store.GetByIDAndVerifySecret(ctx, "client", secret); !errors.Is(err, ErrClientDisabled) {
+			t.Fatalf("got error %v, want %v", err, ErrClientDisabled)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package pgstore
+
+import (
+	"context"
+	"fmt"
+)
+
+// setEnabled enables or disables the client.
+func (s *ClientStore) setEnabled(ctx context.Context, id string, enabled bool) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	tag, err := s.pool.Exec(ctx, fmt.Sprintf(
+		"UPDATE %s SET is_enabled = $2, version = version + 1 WHERE id = $1 AND is_enabled <> $2%s",
+		s.table, andRealm(s.realm),
+	), id, enabled)
+
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return wrapDatabaseError(err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		var exists bool
+		if err = s.pool.QueryRow(ctx, fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s WHERE id = $1%s)", s.table, andRealm(s.realm)), id).Scan(&exists); err != nil {
+			s.logger.Log(ctx, LogLevelError, err.Error())
+			return wrapDatabaseError(err)
+		}
+
+		if !exists {
+			return ErrClientNotFound
+		}
+	}
+
+	s.logger.Log(ctx, LogLevelInfo, "client status changed", "id", id, "enabled", enabled)
+
+	return nil
+}
+
+// Disable disables the client until it is enabled again, so GetByID returns
+// ErrClientDisabled for it, and GetByIDs, GetByDomain, ListByDomain and List
+// omit it. The client and its tokens are kept, giving operators a kill switch
+// without deleting the client. It returns ErrClientNotFound if the client does
+// not exist.
+func (s *ClientStore) Disable(ctx context.Context, id string) error {
+	s.logger.Log(ctx, LogLevelDebug, "disabling client", "id", id)
+	return s.setEnabled(ctx, id, false)
+}
+
+// Enable enables the disabled client. It returns ErrClientNotFound if the
+// client does not exist.
+func (s *ClientStore) Enable(ctx context.Context, id string) error {
+	s.logger.Log(ctx, LogLevelDebug, "enabling client", "id", id)
+	return s.setEnabled(ctx, id, true)
+}
@@ -2,8 +2,9 @@ package pgstore
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/go-oauth2/oauth2/v4"
@@ -33,6 +34,29 @@ func WithClientStoreTable(table string) ClientStoreOption {
 	}
 }
 
+// WithClientStoreTablePrefix configures the prefix added to the name of every
+// table of the store and, as a consequence, to the name of every index.
+func WithClientStoreTablePrefix(prefix string) ClientStoreOption {
+	return func(s *ClientStore) error {
+		s.tablePrefix = prefix
+		return nil
+	}
+}
+
+// WithClientStoreDB configures the database the store runs its queries on,
+// for example a pgx v4 pool adapted by the pgxv4 module.
+func WithClientStoreDB(db DB) ClientStoreOption {
+	return func(s *ClientStore) error {
+		if db == nil {
+			return ErrNoConnPool
+		}
+
+		s.pool = db
+
+		return nil
+	}
+}
+
 // WithClientStoreConnPool configures the connection pool.
 func WithClientStoreConnPool(pool *pgxpool.Pool) ClientStoreOption {
 	return func(s *ClientStore) error {
@@ -46,6 +70,36 @@ func WithClientStoreConnPool(pool *pgxpool.Pool) ClientStoreOption {
 	}
 }
 
+// WithClientStoreDSN configures the store to create a connection pool from the
+// connection string. The store owns the pool and closes it on Close. The DSN
+// is not used if a connection pool is configured.
+func WithClientStoreDSN(dsn string) ClientStoreOption {
+	return func(s *ClientStore) error {
+		if dsn == "" {
+			return ErrNoDSN
+		}
+
+		s.poolOpts.dsn = dsn
+
+		return nil
+	}
+}
+
+// WithClientStorePoolSize configures the minimum and maximum number of
+// connections of the connection pool created from the DSN.
+func WithClientStorePoolSize(minConns, maxConns int32) ClientStoreOption {
+	return func(s *ClientStore) error {
+		if err := validatePoolSize(minConns, maxConns); err != nil {
+			return err
+		}
+
+		s.poolOpts.minConns = minConns
+		s.poolOpts.maxConns = maxConns
+
+		return nil
+	}
+}
+
 // WithClientStoreLogger configures the logger.
 func WithClientStoreLogger(logger Logger) ClientStoreOption {
 	return func(s *ClientStore) error {
@@ -59,62 +113,167 @@ func WithClientStoreLogger(logger Logger) ClientStoreOption {
 	}
 }
 
+// WithClientStoreClock configures the clock used for creation times.
+func WithClientStoreClock(clock Clock) ClientStoreOption {
+	return func(s *ClientStore) error {
+		if clock == nil {
+			return ErrNoClock
+		}
+
+		s.clock = clock
+
+		return nil
+	}
+}
+
+// WithClientStoreCodec configures the codec used to encode the client data
+// column.
+func WithClientStoreCodec(codec Codec) ClientStoreOption {
+	return func(s *ClientStore) error {
+		if codec == nil {
+			return ErrNoCodec
+		}
+
+		s.codec = codec
+
+		return nil
+	}
+}
+
 // ClientStoreItem data item
 type ClientStoreItem struct {
 	ID        string    `db:"id"`
 	Secret    string    `db:"secret"`
 	Domain    string    `db:"domain"`
+	IsPublic  bool      `db:"is_public"`
 	Data      []byte    `db:"data"`
 	CreatedAt time.Time `db:"created_at"`
+	Version   int64     `db:"version"`
+	IsEnabled bool      `db:"is_enabled"`
+}
+
+// Client is the client information returned by the store.
+type Client struct {
+	models.Client
+	// CreatedAt is the time the client was stored.
+	CreatedAt time.Time
+	// Version is incremented on every change of the client and used by
+	// Update to detect concurrent changes.
+	Version int64
+	// Enabled is false if the client was disabled with Disable.
+	Enabled bool
 }
 
 // ClientStore is a data struct that stores oauth2 client information.
 type ClientStore struct {
-	pool   *pgxpool.Pool
-	table  string
-	logger Logger
+	pool             DB
+	poolOpts         poolOptions
+	ownsPool         bool
+	table            string
+	tablePrefix      string
+	secretTable      string
+	redirectURITable string
+	scopeTable       string
+	clientScopeTable string
+	logger           Logger
+	clock            Clock
+	codec            Codec
+	breakerThreshold int
+	breakerCoolDown  time.Duration
+	breaker          *circuitBreaker
+
+	encryptionSetting string
+	observer          queryObserver
+	realm             string
+	readOnly          bool
+	citus             bool
+	identityColumns   bool
+	missing           *negativeCache
 }
 
 // scanToClientInfo scans a row into an oauth2.ClientInfo.
 func (s *ClientStore) scanToClientInfo(ctx context.Context, row pgx.Row) (oauth2.ClientInfo, error) {
 	var item ClientStoreItem
-	err := row.Scan(&item.ID, &item.Secret, &item.Domain, &item.Data, &item.CreatedAt)
+	err := row.Scan(&item.ID, &item.Secret, &item.Domain, &item.IsPublic, &item.Data, &item.CreatedAt, &item.Version, &item.IsEnabled)
+	if errors.Is(err, pgx.ErrNoRows) {
+		s.logger.Log(ctx, LogLevelDebug, "client not found")
+		return nil, ErrClientNotFound
+	}
+
 	if err != nil {
-		return nil, err
+		return nil, wrapDatabaseError(err)
 	}
 
-	var info models.Client
-	err = json.Unmarshal(item.Data, &info)
+	info := &Client{CreatedAt: item.CreatedAt, Version: item.Version, Enabled: item.IsEnabled}
+	err = s.codec.Unmarshal(item.Data, &info.Client)
 	if err != nil {
 		return nil, err
 	}
 
+	// The column is the source of truth, so it can be changed without
+	// rewriting the data.
+	info.Public = item.IsPublic
+
 	s.logger.Log(ctx, LogLevelDebug, "client found", "id", item.ID)
 
-	return &info, nil
+	return info, nil
 }
 
-// InitTable initializes the client store table if it does not exist and
-// creates the indexes.
-func (s *ClientStore) InitTable(ctx context.Context) error {
-	s.logger.Log(ctx, LogLevelDebug, "initializing client store table", "table", s.table)
+// dataColumnType returns the type of the client data column.
+func (s *ClientStore) dataColumnType() string {
+	if !isJSONCodec(s.codec) || s.encryptionSetting != "" {
+		return "BYTEA"
+	}
+
+	return "JSONB"
+}
 
-	_, err := s.pool.Exec(ctx, fmt.Sprintf(`
+// InitTableSQL returns the DDL statements executed by InitTable without
+// executing them, so schema changes can be reviewed and applied separately.
+func (s *ClientStore) InitTableSQL() string {
+	ddl := encryptionSQL(s.encryptionSetting) + fmt.Sprintf(`
 		CREATE TABLE IF NOT EXISTS %[1]s (
 			    id         VARCHAR(255) PRIMARY KEY,
-				secret     VARCHAR(255) NOT NULL,
+				secret     TEXT         NOT NULL,
 				domain     VARCHAR(255) NOT NULL,
-				data       JSONB        NOT NULL,
-				created_at TIMESTAMPTZ  NOT NULL
+				is_public  BOOLEAN      NOT NULL DEFAULT FALSE,
+				is_enabled BOOLEAN      NOT NULL DEFAULT TRUE,
+				data       %[2]s        NOT NULL,
+				created_at TIMESTAMPTZ  NOT NULL,
+				version    BIGINT       NOT NULL DEFAULT 1,
+				key_id     TEXT         NOT NULL DEFAULT '',
+				realm      TEXT         NOT NULL DEFAULT '',
+
+				grant_types                TEXT[] NOT NULL DEFAULT '{}',
+				token_endpoint_auth_method TEXT   NOT NULL DEFAULT '',
+				software_statement         TEXT   NOT NULL DEFAULT '',
+				registration_token_hash    TEXT   NOT NULL DEFAULT '',
+
+				access_token_ttl  INTERVAL,
+				refresh_token_ttl INTERVAL
 		);
+%[5]s%[4]s
 
-		CREATE INDEX IF NOT EXISTS %[1]s_domain_idx ON %[1]s (domain);`,
-		s.table,
-	))
+		CREATE INDEX IF NOT EXISTS %[3]s_domain_idx ON %[1]s (domain);`,
+		s.table, s.dataColumnType(), unqualifiedName(s.table), s.upgradeSQL(), s.citusReferenceSQL(s.table),
+	)
 
-	if err != nil {
+	return strings.Join([]string{ddl, s.secretTableSQL(), s.redirectURITableSQL(), s.scopeTablesSQL()}, "\n")
+}
+
+// InitTable initializes the client store table and the tables of the client
+// secrets, redirect URIs and scopes if they do not exist, adds the columns
+// missing from tables created by earlier versions and creates the indexes.
+func (s *ClientStore) InitTable(ctx context.Context) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	s.logger.Log(ctx, LogLevelDebug, "initializing client store table", "table", s.table)
+
+	if _, err := s.pool.Exec(ctx, s.InitTableSQL()); err != nil {
 		s.logger.Log(ctx, LogLevelError, err.Error())
-		return err
+		return wrapDatabaseError(err)
 	}
 
 	return nil
@@ -122,40 +281,365 @@ func (s *ClientStore) InitTable(ctx context.Context) error {
 
 // Create creates a new client in the store.
 func (s *ClientStore) Create(info oauth2.ClientInfo) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
 	s.logger.Log(context.Background(), LogLevelDebug, "creating client", "id", info.GetID())
-	data, err := json.Marshal(info)
+	data, err := s.codec.Marshal(info)
 	if err != nil {
 		return err
 	}
 
-	_, err = s.pool.Exec(context.Background(), fmt.Sprintf(`
-		INSERT INTO %[1]s (id, secret, domain, data, created_at)
-		VALUES ($1, $2, $3, $4, $5)`,
-		s.table,
-	), info.GetID(), info.GetSecret(), info.GetDomain(), data, time.Now())
+	if err = s.breaker.allow(); err != nil {
+		return err
+	}
+
+	start := s.clock.Now()
+	_, err = s.pool.Exec(context.Background(), s.observer.query("Create", fmt.Sprintf(`
+		INSERT INTO %[1]s (id, secret, domain, is_public, data, created_at, realm, key_id)
+		VALUES ($1, %[2]s, $3, $4, %[3]s, $6, $7, %[4]s)`,
+		s.table, s.encryptSecret("$2"), s.encryptData("$5"), s.keyIDValue(),
+	)), info.GetID(), info.GetSecret(), info.GetDomain(), info.IsPublic(), data, start, s.realm)
+	s.breaker.record(err)
 
 	if err != nil {
-		s.logger.Log(context.Background(), LogLevelError, "creating client failed", "info", info)
-		return err
+		s.logger.Log(context.Background(), LogLevelError, "creating client failed", "id", info.GetID())
+		return wrapDatabaseError(err)
 	}
 
+	s.forgetMissing(info.GetID())
 	s.logger.Log(context.Background(), LogLevelDebug, "client created")
 
 	return nil
 }
 
+// Upsert creates a new client in the store or updates the secret, domain,
+// public flag and data of the client if one with the same ID already exists.
+// If the info is a *Client read from the store, the update is only applied if
+// the stored client is still at its version, otherwise ErrConflict is
+// returned. ErrClientRealmMismatch is returned if the client exists in another
+// realm.
+func (s *ClientStore) Upsert(ctx context.Context, info oauth2.ClientInfo) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	var version int64
+	if client, ok := info.(*Client); ok {
+		version = client.Version
+	}
+
+	s.logger.Log(ctx, LogLevelDebug, "upserting client", "id", info.GetID(), "version", version)
+	data, err := s.codec.Marshal(info)
+	if err != nil {
+		return err
+	}
+
+	if err = s.breaker.allow(); err != nil {
+		return err
+	}
+
+	start := s.clock.Now()
+	tag, err := s.pool.Exec(ctx, s.observer.query("Upsert", fmt.Sprintf(`
+		INSERT INTO %[1]s AS c (id, secret, domain, is_public, data, created_at, realm, key_id)
+		VALUES ($1, %[2]s, $3, $4, %[3]s, $6, $7, %[4]s)
+		ON CONFLICT (id) DO UPDATE
+		SET secret = EXCLUDED.secret, domain = EXCLUDED.domain, is_public = EXCLUDED.is_public, data = EXCLUDED.data,
+			key_id = EXCLUDED.key_id, version = c.version + 1
+		WHERE c.realm = EXCLUDED.realm AND ($8::BIGINT = 0 OR c.version = $8)`,
+		s.table, s.encryptSecret("$2"), s.encryptData("$5"), s.keyIDValue(),
+	)), info.GetID(), info.GetSecret(), info.GetDomain(), info.IsPublic(), data, start, s.realm, version)
+	s.breaker.record(err)
+
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, "upserting client failed", "id", info.GetID())
+		return wrapDatabaseError(err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return s.upsertConflict(ctx, info.GetID(), version)
+	}
+
+	s.forgetMissing(info.GetID())
+	s.logger.Log(ctx, LogLevelDebug, "client upserted")
+
+	return nil
+}
+
+// upsertConflict returns the reason the upsert of the existing client was not
+// applied: the client exists in another realm or was changed since the
+// version was read.
+func (s *ClientStore) upsertConflict(ctx context.Context, id string, version int64) error {
+	var realm string
+	err := s.pool.QueryRow(ctx, fmt.Sprintf("SELECT realm FROM %s WHERE id = $1", s.table), id).Scan(&realm)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return wrapDatabaseError(err)
+	}
+
+	if err == nil && realm != s.realm {
+		s.logger.Log(ctx, LogLevelWarn, "client exists in another realm", "id", id)
+		return ErrClientRealmMismatch
+	}
+
+	s.logger.Log(ctx, LogLevelWarn, "client upsert conflict", "id", id, "version", version)
+	return ErrConflict
+}
+
+// Update replaces the client information if the stored client is still at
+// the given version, as read from Client.Version, and returns the new
+// version. It returns ErrConflict if the client was changed since, and
+// ErrClientNotFound if it does not exist.
+func (s *ClientStore) Update(ctx context.Context, info oauth2.ClientInfo, version int64) (int64, error) {
+	if s.readOnly {
+		return 0, ErrReadOnly
+	}
+
+	s.logger.Log(ctx, LogLevelDebug, "updating client", "id", info.GetID(), "version", version)
+	data, err := s.codec.Marshal(info)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = s.breaker.allow(); err != nil {
+		return 0, err
+	}
+
+	var newVersion int64
+	err = s.pool.QueryRow(ctx, s.observer.query("Update", fmt.Sprintf(`
+		UPDATE %s SET secret = %s, domain = $3, is_public = $4, data = %s%s, version = version + 1
+		WHERE id = $1 AND version = $6%s
+		RETURNING version`,
+		s.table, s.encryptSecret("$2"), s.encryptData("$5"), keyIDAssignment(s.encryptionSetting), andRealm(s.realm),
+	)), info.GetID(), info.GetSecret(), info.GetDomain(), info.IsPublic(), data, version).Scan(&newVersion)
+	s.breaker.record(err)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		var exists bool
+		err = s.pool.QueryRow(ctx, fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s WHERE id = $1%s)", s.table, andRealm(s.realm)), info.GetID()).Scan(&exists)
+		if err != nil {
+			s.logger.Log(ctx, LogLevelError, err.Error())
+			return 0, wrapDatabaseError(err)
+		}
+
+		if !exists {
+			return 0, ErrClientNotFound
+		}
+
+		s.logger.Log(ctx, LogLevelWarn, "client update conflict", "id", info.GetID(), "version", version)
+		return 0, ErrConflict
+	}
+
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, "updating client failed", "id", info.GetID())
+		return 0, wrapDatabaseError(err)
+	}
+
+	s.logger.Log(ctx, LogLevelDebug, "client updated", "version", newVersion)
+
+	return newVersion, nil
+}
+
+// lockClient locks the client in the transaction, so its child rows can be
+// replaced, returning ErrClientNotFound if the client does not exist in the
+// realm of the store.
+func (s *ClientStore) lockClient(ctx context.Context, tx pgx.Tx, id string) error {
+	var locked int
+	err := tx.QueryRow(ctx, fmt.Sprintf("SELECT 1 FROM %s WHERE id = $1%s FOR KEY SHARE", s.table, andRealm(s.realm)), id).Scan(&locked)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrClientNotFound
+	}
+
+	return err
+}
+
 // GetByID returns the client information by key from the store.
 func (s *ClientStore) GetByID(ctx context.Context, id string) (oauth2.ClientInfo, error) {
 	s.logger.Log(ctx, LogLevelDebug, "getting client by id", "id", id)
-	row := s.pool.QueryRow(ctx, fmt.Sprintf("SELECT * FROM %s WHERE id = $1", s.table), id)
-	return s.scanToClientInfo(ctx, row)
+
+	if s.isMissing(id) {
+		s.logger.Log(ctx, LogLevelDebug, "client remembered as missing", "id", id)
+		return nil, ErrClientNotFound
+	}
+
+	info, err := s.queryClient(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.Enabled {
+		s.logger.Log(ctx, LogLevelWarn, "client is disabled", "id", id)
+		return nil, ErrClientDisabled
+	}
+
+	return info, nil
+}
+
+// queryClient reads the client from the database, whether it is enabled or
+// not, bypassing the negative cache.
+func (s *ClientStore) queryClient(ctx context.Context, id string) (*Client, error) {
+	if err := s.breaker.allow(); err != nil {
+		return nil, err
+	}
+
+	row := s.pool.QueryRow(ctx, s.observer.query("GetByID", fmt.Sprintf("SELECT %s FROM %s WHERE id = $1%s", s.selectColumns(), s.table, andRealm(s.realm))), id)
+	info, err := s.scanToClientInfo(ctx, row)
+	s.breaker.record(err)
+	s.rememberMissing(id, err)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return info.(*Client), nil
+}
+
+// queryClients runs the query of the operation and scans every returned row.
+func (s *ClientStore) queryClients(ctx context.Context, op string, sql string, args ...any) ([]oauth2.ClientInfo, error) {
+	if err := s.breaker.allow(); err != nil {
+		return nil, err
+	}
+
+	clients, err := s.scanClients(ctx, s.observer.query(op, sql), args...)
+	s.breaker.record(err)
+
+	return clients, err
+}
+
+// scanClients runs the query and scans every returned row.
+func (s *ClientStore) scanClients(ctx context.Context, sql string, args ...any) ([]oauth2.ClientInfo, error) {
+	rows, err := s.pool.Query(ctx, sql, args...)
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return nil, wrapDatabaseError(err)
+	}
+	defer rows.Close()
+
+	var clients []oauth2.ClientInfo
+	for rows.Next() {
+		info, err := s.scanToClientInfo(ctx, rows)
+		if err != nil {
+			s.logger.Log(ctx, LogLevelError, err.Error())
+			return nil, err
+		}
+
+		clients = append(clients, info)
+	}
+
+	if err = rows.Err(); err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return nil, wrapDatabaseError(err)
+	}
+
+	return clients, nil
+}
+
+// GetByIDs returns the client information for the given IDs from the store
+// using a single query. IDs without a stored client and disabled clients are
+// omitted.
+func (s *ClientStore) GetByIDs(ctx context.Context, ids []string) (map[string]oauth2.ClientInfo, error) {
+	s.logger.Log(ctx, LogLevelDebug, "getting clients by ids", "ids", ids)
+
+	clients, err := s.queryClients(ctx, "GetByIDs", fmt.Sprintf(
+		"SELECT %s FROM %s WHERE id = ANY($1) AND is_enabled%s",
+		s.selectColumns(), s.table, andRealm(s.realm),
+	), ids)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make(map[string]oauth2.ClientInfo, len(clients))
+	for _, info := range clients {
+		infos[info.GetID()] = info
+	}
+
+	return infos, nil
+}
+
+// GetByDomain returns the first registered enabled client for the domain from
+// the store.
+func (s *ClientStore) GetByDomain(ctx context.Context, domain string) (oauth2.ClientInfo, error) {
+	s.logger.Log(ctx, LogLevelDebug, "getting client by domain", "domain", domain)
+
+	if err := s.breaker.allow(); err != nil {
+		return nil, err
+	}
+
+	row := s.pool.QueryRow(ctx, s.observer.query("GetByDomain", fmt.Sprintf(
+		"SELECT %s FROM %s WHERE domain = $1 AND is_enabled%s ORDER BY created_at, id LIMIT 1",
+		s.selectColumns(), s.table, andRealm(s.realm),
+	)), domain)
+	info, err := s.scanToClientInfo(ctx, row)
+	s.breaker.record(err)
+
+	return info, err
+}
+
+// ListByDomain returns every enabled client registered for the domain from
+// the store in registration order.
+func (s *ClientStore) ListByDomain(ctx context.Context, domain string) ([]oauth2.ClientInfo, error) {
+	s.logger.Log(ctx, LogLevelDebug, "listing clients by domain", "domain", domain)
+	return s.queryClients(ctx, "ListByDomain", fmt.Sprintf(
+		"SELECT %s FROM %s WHERE domain = $1 AND is_enabled%s ORDER BY created_at, id",
+		s.selectColumns(), s.table, andRealm(s.realm),
+	), domain)
+}
+
+// List returns every enabled client from the store in registration order.
+func (s *ClientStore) List(ctx context.Context) ([]oauth2.ClientInfo, error) {
+	s.logger.Log(ctx, LogLevelDebug, "listing clients")
+	return s.queryClients(ctx, "List", fmt.Sprintf(
+		"SELECT %s FROM %s WHERE is_enabled%s ORDER BY created_at, id",
+		s.selectColumns(), s.table, andRealm(s.realm),
+	))
+}
+
+// RemoveByID deletes the client and, through the foreign keys, its secrets,
+// redirect URIs and allowed scopes. Removing a client that does not exist is
+// not an error.
+func (s *ClientStore) RemoveByID(ctx context.Context, id string) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	s.logger.Log(ctx, LogLevelDebug, "removing client by id", "id", id)
+
+	if _, err := s.pool.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE id = $1%s", s.table, andRealm(s.realm)), id); err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return wrapDatabaseError(err)
+	}
+
+	s.logger.Log(ctx, LogLevelInfo, "client removed", "id", id)
+
+	return nil
+}
+
+// Close closes the store and releases any resources.
+func (s *ClientStore) Close(ctx context.Context) {
+	s.logger.Log(ctx, LogLevelDebug, "closing client store")
+
+	if s.ownsPool {
+		s.logger.Log(ctx, LogLevelDebug, "closing connection pool")
+		if pool, ok := nativePool(s.pool); ok {
+			pool.Close()
+		}
+	}
+
+	s.logger.Log(ctx, LogLevelDebug, "client store closed")
 }
 
 // NewClientStore creates a new ClientStore.
 func NewClientStore(opts ...ClientStoreOption) (*ClientStore, error) {
 	s := &ClientStore{
-		table:  DefaultClientStoreTable,
-		logger: new(NoopLogger),
+		table:            DefaultClientStoreTable,
+		secretTable:      DefaultClientSecretTable,
+		redirectURITable: DefaultClientRedirectURITable,
+		scopeTable:       DefaultScopeTable,
+		clientScopeTable: DefaultClientScopeTable,
+		logger:           new(NoopLogger),
+		clock:            new(SystemClock),
+		codec:            new(JSONCodec),
 	}
 
 	for _, o := range opts {
@@ -164,9 +648,31 @@ func NewClientStore(opts ...ClientStoreOption) (*ClientStore, error) {
 		}
 	}
 
+	s.breaker = newCircuitBreaker(s.breakerThreshold, s.breakerCoolDown, s.clock)
+
+	if s.tablePrefix != "" {
+		s.table = prefixedName(s.tablePrefix, s.table)
+		s.secretTable = prefixedName(s.tablePrefix, s.secretTable)
+		s.redirectURITable = prefixedName(s.tablePrefix, s.redirectURITable)
+		s.scopeTable = prefixedName(s.tablePrefix, s.scopeTable)
+		s.clientScopeTable = prefixedName(s.tablePrefix, s.clientScopeTable)
+	}
+
+	if s.pool == nil && s.poolOpts.configured() {
+		pool, err := s.poolOpts.newPool(context.Background())
+		if err != nil {
+			return nil, err
+		}
+
+		s.pool = pool
+		s.ownsPool = true
+	}
+
 	if s.pool == nil {
 		return nil, ErrNoConnPool
 	}
 
+	s.pool = s.observer.wrap(s.pool, s.logger, s.clock)
+
 	return s, nil
 }
@@ -0,0 +1,259 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"github.com/go-oauth2/oauth2/v4/models"
+)
+
+// unusedDB is a database failing the test by panicking if it is used.
+type unusedDB struct {
+	DB
+}
+
+func TestClientStoreUpdateUsesCircuitBreaker(t *testing.T) {
+	store, err := NewClientStore(WithClientStoreDB(unusedDB{}), WithClientStoreCircuitBreaker(1, time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store.breaker.record(ErrConnection)
+
+	if _, err := store.Update(context.Background(), &models.Client{ID: "client"}, 1); !errors.Is(err, ErrStoreUnavailable) {
+		t.Fatalf("got %v, want %v", err, ErrStoreUnavailable)
+	}
+}
+
+func TestClientStoreUpsertChecksVersion(t *testing.T) {
+	store := newTestClientStore(t)
+	ctx := context.Background()
+
+	if err := store.Upsert(ctx, &models.Client{ID: "client", Secret: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := store.GetByID(ctx, "client")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := info.(*Client)
+	client.Domain = "https://example.com"
+
+	if err = store.Upsert(ctx, client); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = store.Upsert(ctx, client); !errors.Is(err, ErrConflict) {
+		t.Fatalf("got %v, want %v", err, ErrConflict)
+	}
+}
+
+func TestClientStoreUpsertRealmMismatch(t *testing.T) {
+	stores := newTestRealmClientStores(t, "a", "b")
+	ctx := context.Background()
+
+	if err := stores["a"].Upsert(ctx, &models.Client{ID: "client", Secret: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := stores["b"].Upsert(ctx, &models.Client{ID: "client", Secret: "other"}); !errors.Is(err, ErrClientRealmMismatch) {
+		t.Fatalf("got %v, want %v", err, ErrClientRealmMismatch)
+	}
+}
+
+func TestClientStoreFailuresDoNotLogSecret(t *testing.T) {
+	ctx := context.Background()
+	logger := new(recordingLogger)
+
+	store, err := NewClientStore(WithClientStoreDB(&failingDB{err: errors.New("failed")}), WithClientStoreLogger(logger))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &models.Client{ID: "client", Secret: "secret"}
+
+	if err = store.Create(client); err == nil {
+		t.Fatal("create did not fail")
+	}
+
+	if err = store.Upsert(ctx, client); err == nil {
+		t.Fatal("upsert did not fail")
+	}
+
+	if _, err = store.Update(ctx, client, 1); err == nil {
+		t.Fatal("update did not fail")
+	}
+
+	for _, arg := range logger.args {
+		if _, ok := arg.(*models.Client); ok {
+			t.Fatalf("got the client logged with its secret, want only its ID")
+		}
+	}
+}
+
+func TestClientStoreLookupsUseCircuitBreaker(t *testing.T) {
+	store, err := NewClientStore(WithClientStoreDB(unusedDB{}), WithClientStoreCircuitBreaker(1, time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store.breaker.record(ErrConnection)
+	ctx := context.Background()
+
+	for name, call := range map[string]func() error{
+		"GetByDomain": func() error {
+			_, err := store.GetByDomain(ctx, "https://example.com")
+			return err
+		},
+		"ListByDomain": func() error {
+			_, err := store.ListByDomain(ctx, "https://example.com")
+			return err
+		},
+		"GetByIDs": func() error {
+			_, err := store.GetByIDs(ctx, []string{"client"})
+			return err
+		},
+		"List": func() error {
+			_, err := store.List(ctx)
+			return err
+		},
+	} {
+		if err := call(); !errors.Is(err, ErrStoreUnavailable) {
+			t.Errorf("%s: got %v, want %v", name, err, ErrStoreUnavailable)
+		}
+	}
+}
+
+func TestClientStoreLookupsOmitDisabledClients(t *testing.T) {
+	store := newTestClientStore(t)
+	ctx := context.Background()
+
+	for _, id := range []string{"disabled", "enabled"} {
+		if err := store.Upsert(ctx, &models.Client{ID: id, Domain: "https://example.com"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := store.Disable(ctx, "disabled"); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := store.GetByDomain(ctx, "https://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.GetID() != "enabled" {
+		t.Fatalf("got client %q by domain, want %q", info.GetID(), "enabled")
+	}
+
+	byDomain, err := store.ListByDomain(ctx, "https://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := store.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, clients := range map[string][]oauth2.ClientInfo{"ListByDomain": byDomain, "List": all} {
+		if len(clients) != 1 || clients[0].GetID() != "enabled" {
+			t.Errorf("%s: got %d clients, want only the enabled one", name, len(clients))
+		}
+	}
+
+	byID, err := store.GetByIDs(ctx, []string{"disabled", "enabled"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := byID["disabled"]; ok || len(byID) != 1 {
+		t.Fatalf("got clients %v by ID, want only the enabled one", byID)
+	}
+
+	if err = store.Disable(ctx, "enabled"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = store.GetByDomain(ctx, "https://example.com"); !errors.Is(err, ErrClientNotFound) {
+		t.Fatalf("got %v for a domain of disabled clients, want %v", err, ErrClientNotFound)
+	}
+}
+
+func TestClientStoreGetByIDs(t *testing.T) {
+	stores := newTestRealmClientStores(t, "a", "b")
+	ctx := context.Background()
+
+	for _, id := range []string{"first", "second"} {
+		if err := stores["a"].Upsert(ctx, &models.Client{ID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := stores["b"].Upsert(ctx, &models.Client{ID: "other"}); err != nil {
+		t.Fatal(err)
+	}
+
+	infos, err := stores["a"].GetByIDs(ctx, []string{"first", "second", "other", "missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(infos) != 2 || infos["first"].GetID() != "first" || infos["second"].GetID() != "second" {
+		t.Fatalf("got clients %v, want the stored clients of the realm", infos)
+	}
+
+	if infos, err = stores["a"].GetByIDs(ctx, nil); err != nil || len(infos) != 0 {
+		t.Fatalf("got clients %v and error %v for no IDs, want none", infos, err)
+	}
+}
+
+func TestClientStoreByDomain(t *testing.T) {
+	clock := &fixedClock{now: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)}
+	store := newTestClientStore(t, WithClientStoreClock(clock))
+	ctx := context.Background()
+
+	for _, client := range []*models.Client{
+		{ID: "b", Domain: "https://example.com"},
+		{ID: "a", Domain: "https://example.com"},
+		{ID: "c", Domain: "https://other.example.com"},
+	} {
+		if err := store.Upsert(ctx, client); err != nil {
+			t.Fatal(err)
+		}
+
+		clock.now = clock.now.Add(time.Second)
+	}
+
+	info, err := store.GetByDomain(ctx, "https://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.GetID() != "b" {
+		t.Fatalf("got client %q, want the first registered client %q", info.GetID(), "b")
+	}
+
+	clients, err := store.ListByDomain(ctx, "https://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(clients) != 2 || clients[0].GetID() != "b" || clients[1].GetID() != "a" {
+		t.Fatalf("got %d clients, want the clients of the domain in registration order", len(clients))
+	}
+
+	if _, err = store.GetByDomain(ctx, "https://missing.example.com"); !errors.Is(err, ErrClientNotFound) {
+		t.Fatalf("got %v, want %v", err, ErrClientNotFound)
+	}
+
+	if clients, err = store.ListByDomain(ctx, "https://missing.example.com"); err != nil || len(clients) != 0 {
+		t.Fatalf("got %d clients and error %v for an unknown domain, want none", len(clients), err)
+	}
+}
@@ -0,0 +1,47 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWithClockRequiresClock(t *testing.T) {
+	if err := WithTokenStoreClock(nil)(&TokenStore{}); !errors.Is(err, ErrNoClock) {
+		t.Fatalf("got %v, want %v", err, ErrNoClock)
+	}
+
+	if err := WithClientStoreClock(nil)(&ClientStore{}); !errors.Is(err, ErrNoClock) {
+		t.Fatalf("got %v, want %v", err, ErrNoClock)
+	}
+}
+
+func TestTokenStoreUsesClock(t *testing.T) {
+	clock := &fixedClock{now: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)}
+	store := newTestTokenStore(t, WithTokenStoreClock(clock))
+	ctx := context.Background()
+
+	if err := store.CreateWithExpiry(ctx, newTestToken("client", "user", "a"), clock.now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := store.ListTokens(ctx, TokenFilter{State: TokenStateActive}, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(page.Tokens) != 1 || !page.Tokens[0].CreatedAt.Equal(clock.now) {
+		t.Fatalf("got %d active tokens, want the token created at the time of the clock", len(page.Tokens))
+	}
+
+	clock.now = clock.now.Add(2 * time.Hour)
+
+	if page, err = store.ListTokens(ctx, TokenFilter{State: TokenStateExpired}, nil, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(page.Tokens) != 1 {
+		t.Fatalf("got %d expired tokens, want the token expired by the time of the clock", len(page.Tokens))
+	}
+}
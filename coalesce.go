@@ -0,0 +1,67 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/sync/singleflight"
+)
+
+// WithTokenStoreCoalescing configures GetByAccess to coalesce the concurrent
+// lookups of the same access token into a single query, so bursts of requests
+// carrying the same token do not flood the database. Every caller decodes its
+// own token information from the shared row. The lookup fails for every
+// coalesced caller if the context of the caller running the query is
+// canceled.
+func WithTokenStoreCoalescing() TokenStoreOption {
+	return func(s *TokenStore) error {
+		s.lookups = new(singleflight.Group)
+		return nil
+	}
+}
+
+// coalescedRow is a token row shared by coalesced lookups.
+type coalescedRow struct {
+	id   rowKey
+	data []byte
+}
+
+// getCoalesced runs the query of the columns returned by getColumns with the
+// key as $1, sharing the row with the concurrent lookups of the same key, and
+// decodes the token information.
+func (s *TokenStore) getCoalesced(ctx context.Context, query string, key string) (oauth2.TokenInfo, error) {
+	v, err, shared := s.lookups.Do(key, func() (any, error) {
+		var row coalescedRow
+		err := s.reader().QueryRow(ctx, query, key).Scan(&row.id, &row.data)
+
+		return row, err
+	})
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			s.logger.Log(ctx, LogLevelDebug, "token not found", "shared", shared)
+			return nil, ErrTokenNotFound
+		}
+
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return nil, wrapDatabaseError(err)
+	}
+
+	row := v.(coalescedRow)
+
+	info, err := s.decodeTokenInfo(row.data)
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return nil, err
+	}
+
+	s.logger.Log(ctx, LogLevelDebug, "token found", "id", row.id, "shared", shared)
+
+	if s.usage != nil {
+		s.usage.touch(row.id, s.clock.Now())
+	}
+
+	return info, nil
+}
@@ -0,0 +1,105 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// coalescingDB is a database returning the same token row for every query
+// once released, and counting the queries.
+type coalescingDB struct {
+	DB
+	data    []byte
+	release chan struct{}
+	queried int32
+}
+
+func (d *coalescingDB) QueryRow(context.Context, string, ...any) pgx.Row {
+	atomic.AddInt32(&d.queried, 1)
+	return coalescingRow{d}
+}
+
+// coalescingRow is a token row blocking until the database is released.
+type coalescingRow struct {
+	db *coalescingDB
+}
+
+func (r coalescingRow) Scan(dest ...any) error {
+	<-r.db.release
+
+	*dest[0].(*rowKey) = rowKey{ID: 1, Valid: true}
+	*dest[1].(*[]byte) = r.db.data
+
+	return nil
+}
+
+func TestTokenStoreCoalescesLookups(t *testing.T) {
+	db := &coalescingDB{release: make(chan struct{})}
+
+	store, err := newTokenStore(WithTokenStoreDB(db), WithTokenStoreCoalescing())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if db.data, err = store.encodeTokenInfo(newTestToken("client", "user", "a")); err != nil {
+		t.Fatal(err)
+	}
+
+	const callers = 8
+
+	var started, done sync.WaitGroup
+	infos := make(chan string, callers)
+
+	for i := 0; i < callers; i++ {
+		started.Add(1)
+		done.Add(1)
+
+		go func() {
+			defer done.Done()
+			started.Done()
+
+			info, err := store.GetByAccess(context.Background(), "access-a")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			infos <- info.GetAccess()
+		}()
+	}
+
+	// Give the callers time to join the running lookup before the row is
+	// returned.
+	started.Wait()
+	time.Sleep(50 * time.Millisecond)
+	close(db.release)
+	done.Wait()
+	close(infos)
+
+	for access := range infos {
+		if access != "access-a" {
+			t.Errorf("got access token %q, want %q", access, "access-a")
+		}
+	}
+
+	if queried := atomic.LoadInt32(&db.queried); queried != 1 {
+		t.Fatalf("got %d queries for %d concurrent lookups, want 1", queried, callers)
+	}
+}
+
+func TestTokenStoreCoalescedLookupNotFound(t *testing.T) {
+	store, err := newTokenStore(WithTokenStoreDB(new(emptyDB)), WithTokenStoreCoalescing())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = store.GetByAccess(context.Background(), "access"); !errors.Is(err, ErrTokenNotFound) {
+		t.Fatalf("got error %v, want %v", err, ErrTokenNotFound)
+	}
+}
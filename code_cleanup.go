@@ -0,0 +1,119 @@
+package pgstore
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// cleanupKind selects the rows removed by a cleanup.
+type cleanupKind int
+
+const (
+	// cleanupAll removes the authorization codes and the tokens.
+	cleanupAll cleanupKind = iota
+	// cleanupCodes removes the authorization codes.
+	cleanupCodes
+	// cleanupTokens removes the access and refresh tokens.
+	cleanupTokens
+)
+
+// WithTokenStoreCodeCleanup configures a cleanup of the authorization codes
+// separate from the cleanup of the access and refresh tokens, running every
+// interval and removing the codes expired for longer than the retention.
+// Authorization codes expire within minutes, so they can be removed far more
+// often than the tokens. The periodic cleanup only removes the tokens then.
+func WithTokenStoreCodeCleanup(interval, retention time.Duration) TokenStoreOption {
+	return func(s *TokenStore) error {
+		if interval <= 0 || retention < 0 {
+			return ErrInvalidCodeCleanup
+		}
+
+		s.codeCleanupInterval = interval
+		s.codeRetention = retention
+
+		return nil
+	}
+}
+
+// WithTokenStoreCleanupRetention configures the cleanup to keep the expired
+// tokens for the retention after their expiry, for example to keep
+// answering introspection requests of recently expired tokens. It applies to
+// the authorization codes too, unless they have a cleanup of their own.
+func WithTokenStoreCleanupRetention(retention time.Duration) TokenStoreOption {
+	return func(s *TokenStore) error {
+		if retention < 0 {
+			return ErrInvalidRetention
+		}
+
+		s.cleanupRetention = retention
+
+		return nil
+	}
+}
+
+// tokenCleanupKind returns the rows removed by the periodic cleanup.
+func (s *TokenStore) tokenCleanupKind() cleanupKind {
+	if s.codeCleanupInterval > 0 {
+		return cleanupTokens
+	}
+
+	return cleanupAll
+}
+
+// kindCondition returns the condition restricting a cleanup to its rows.
+func (s *TokenStore) kindCondition(kind cleanupKind) string {
+	switch kind {
+	case cleanupCodes:
+		return " AND " + s.columns.Code + " <> ''"
+	case cleanupTokens:
+		return " AND " + s.columns.Code + " = ''"
+	default:
+		return ""
+	}
+}
+
+// retention returns the time the rows removed by the cleanup are kept after
+// their expiry.
+func (s *TokenStore) retention(kind cleanupKind) time.Duration {
+	if s.anonymizeAfter > 0 {
+		return s.anonymizeAfter
+	}
+
+	if kind == cleanupCodes {
+		return s.codeRetention
+	}
+
+	return s.cleanupRetention
+}
+
+// longestRetention returns the longest time any row is kept after its expiry.
+func (s *TokenStore) longestRetention() time.Duration {
+	if s.codeCleanupInterval > 0 && s.codeRetention > s.cleanupRetention {
+		return s.codeRetention
+	}
+
+	return s.cleanupRetention
+}
+
+// expiryCutoff returns the expression of the time before which the rows
+// removed by the cleanup expired, given the current time as $1.
+func (s *TokenStore) expiryCutoff(kind cleanupKind) string {
+	retention := s.retention(kind)
+	if retention <= 0 {
+		return "$1"
+	}
+
+	return fmt.Sprintf("$1::TIMESTAMPTZ - INTERVAL '%d microseconds'", retention.Microseconds())
+}
+
+// cleanExpiredCodes removes the expired authorization codes from the store,
+// archiving them if an archive table is configured, and returns the number of
+// removed codes.
+func (s *TokenStore) cleanExpiredCodes(ctx context.Context) (int64, error) {
+	start := s.clock.Now()
+	removed, err := s.removeExpiredTokens(ctx, start, cleanupCodes)
+	s.reportCleanup(ctx, "cleaning expired authorization codes", start, removed, err)
+
+	return removed, err
+}
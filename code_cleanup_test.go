@@ -0,0 +1,101 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+)
+
+func TestWithTokenStoreCodeCleanupInvalid(t *testing.T) {
+	for _, tt := range []struct{ interval, retention time.Duration }{{interval: 0}, {interval: time.Minute, retention: -time.Minute}} {
+		if err := WithTokenStoreCodeCleanup(tt.interval, tt.retention)(&TokenStore{}); !errors.Is(err, ErrInvalidCodeCleanup) {
+			t.Errorf("interval %s, retention %s: got error %v, want %v", tt.interval, tt.retention, err, ErrInvalidCodeCleanup)
+		}
+	}
+
+	if err := WithTokenStoreCleanupRetention(-time.Minute)(&TokenStore{}); !errors.Is(err, ErrInvalidRetention) {
+		t.Fatalf("got error %v, want %v", err, ErrInvalidRetention)
+	}
+}
+
+func TestTokenStoreCodeCleanupConditions(t *testing.T) {
+	store, err := newTokenStore(
+		WithTokenStoreDB(unusedDB{}),
+		WithTokenStoreCodeCleanup(time.Minute, time.Second),
+		WithTokenStoreCleanupRetention(time.Hour),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if kind := store.tokenCleanupKind(); kind != cleanupTokens {
+		t.Fatalf("got periodic cleanup of kind %d, want the tokens only", kind)
+	}
+
+	if got := store.longestRetention(); got != time.Hour {
+		t.Fatalf("got longest retention %s, want %s", got, time.Hour)
+	}
+
+	for kind, want := range map[cleanupKind]string{
+		cleanupCodes:  "expires_at <= $1::TIMESTAMPTZ - INTERVAL '1000000 microseconds' AND code <> ''",
+		cleanupTokens: "expires_at <= $1::TIMESTAMPTZ - INTERVAL '3600000000 microseconds' AND code = ''",
+	} {
+		if got := store.expiredCondition(store.table, kind); got != want {
+			t.Errorf("got condition %q, want %q", got, want)
+		}
+	}
+
+	store, err = newTokenStore(WithTokenStoreDB(unusedDB{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if kind := store.tokenCleanupKind(); kind != cleanupAll {
+		t.Fatalf("got periodic cleanup of kind %d, want the codes and tokens", kind)
+	}
+
+	if got := store.expiredCondition(store.table, cleanupAll); got != "expires_at <= $1" {
+		t.Fatalf("got condition %q", got)
+	}
+}
+
+func TestTokenStoreCleanExpiredCodes(t *testing.T) {
+	store := newTestTokenStore(t, WithTokenStoreCodeCleanup(time.Minute, 0))
+	ctx := context.Background()
+
+	expired := time.Now().Add(-time.Hour)
+
+	code := &models.Token{ClientID: "client", UserID: "user", Code: "code", CodeCreateAt: expired, CodeExpiresIn: time.Minute}
+	if err := store.Create(ctx, code); err != nil {
+		t.Fatal(err)
+	}
+
+	access := &models.Token{ClientID: "client", UserID: "user", Access: "access", AccessCreateAt: expired, AccessExpiresIn: time.Minute}
+	if err := store.Create(ctx, access); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := store.cleanExpiredCodes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if removed != 1 {
+		t.Fatalf("got %d rows removed, want the expired authorization code only", removed)
+	}
+
+	if _, err = store.GetByCode(ctx, "code"); !errors.Is(err, ErrTokenNotFound) {
+		t.Fatalf("got error %v for a removed code, want %v", err, ErrTokenNotFound)
+	}
+
+	if _, err = store.GetByAccess(ctx, "access"); err != nil {
+		t.Fatalf("got error %v for an expired token left to the token cleanup", err)
+	}
+
+	if removed, err = store.cleanExpiredTokens(ctx); err != nil || removed != 1 {
+		t.Fatalf("got %d tokens removed and error %v, want the expired token removed", removed, err)
+	}
+}
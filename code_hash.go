@@ -0,0 +1,115 @@
+package pgstore
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"github.com/go-oauth2/oauth2/v4/models"
+)
+
+// WithTokenStoreCodeHashing configures the store to store the HMAC-SHA256 of
+// the authorization codes keyed with the key instead of the codes, so the
+// codes of a leaked backup cannot be replayed. The code is removed from the
+// stored token data too. GetByCode and RemoveByCode hash the code before the
+// lookup, and GetByCode restores the code in the returned token. The codes
+// are not logged either. Code hashing
+// cannot be combined with generated columns, and the codes stored before it
+// was enabled cannot be found anymore.
+func WithTokenStoreCodeHashing(key []byte) TokenStoreOption {
+	return func(s *TokenStore) error {
+		if len(key) == 0 {
+			return ErrInvalidCodeHashing
+		}
+
+		s.codeHashKey = key
+
+		return nil
+	}
+}
+
+// hashCode returns the stored value of the authorization code.
+func (s *TokenStore) hashCode(code string) string {
+	if s.codeHashKey == nil || code == "" {
+		return code
+	}
+
+	mac := hmac.New(sha256.New, s.codeHashKey)
+	mac.Write([]byte(code))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// hashCodes returns the stored values of the authorization codes.
+func (s *TokenStore) hashCodes(codes []string) []string {
+	if s.codeHashKey == nil {
+		return codes
+	}
+
+	hashed := make([]string, len(codes))
+	for i, code := range codes {
+		hashed[i] = s.hashCode(code)
+	}
+
+	return hashed
+}
+
+// loggedCode returns the authorization code as logged, its stored value if
+// code hashing is enabled.
+func (s *TokenStore) loggedCode(code string) string {
+	return s.hashCode(code)
+}
+
+// withoutCode returns a copy of the token information without the
+// authorization code if code hashing is enabled, leaving the token of the
+// caller untouched. Tokens of other types than models.Token are copied
+// through the codec.
+func (s *TokenStore) withoutCode(info oauth2.TokenInfo) (oauth2.TokenInfo, error) {
+	if s.codeHashKey == nil || info.GetCode() == "" {
+		return info, nil
+	}
+
+	if token, ok := info.(*models.Token); ok {
+		clone := *token
+		clone.Code = ""
+
+		return &clone, nil
+	}
+
+	data, err := s.codec.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+
+	clone := s.newTokenInfo()
+	if err = s.codec.Unmarshal(data, clone); err != nil {
+		return nil, err
+	}
+
+	clone.SetCode("")
+
+	return clone, nil
+}
+
+// loggedInfo returns the token information as logged, without the
+// authorization code if code hashing is enabled.
+func (s *TokenStore) loggedInfo(info oauth2.TokenInfo) oauth2.TokenInfo {
+	logged, err := s.withoutCode(info)
+	if err != nil {
+		return nil
+	}
+
+	return logged
+}
+
+// encodeStoredTokenInfo encodes the token information for the data column,
+// without the authorization code if code hashing is enabled.
+func (s *TokenStore) encodeStoredTokenInfo(info oauth2.TokenInfo) ([]byte, error) {
+	stored, err := s.withoutCode(info)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.encodeTokenInfo(stored)
+}
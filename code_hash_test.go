@@ -0,0 +1,158 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"github.com/go-oauth2/oauth2/v4/models"
+)
+
+// newTestCode returns an authorization code token.
+func newTestCode(code string) *models.Token {
+	return &models.Token{
+		ClientID:      "client",
+		UserID:        "user",
+		Code:          code,
+		CodeCreateAt:  time.Now(),
+		CodeExpiresIn: time.Minute,
+	}
+}
+
+func TestEncodeStoredTokenInfoKeepsCode(t *testing.T) {
+	store, err := NewTokenStore(WithTokenStoreDB(unusedDB{}), WithTokenStoreCodeHashing([]byte("key")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, info := range map[string]oauth2.TokenInfo{
+		"token":        newTestCode("secret-code"),
+		"custom token": &grantIDToken{Token: newTestCode("secret-code"), grantID: "grant"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			data, err := store.encodeStoredTokenInfo(info)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if strings.Contains(string(data), "secret-code") {
+				t.Fatalf("got stored data %s with the code", data)
+			}
+
+			if info.GetCode() != "secret-code" {
+				t.Fatalf("got code %q of the caller, want it untouched", info.GetCode())
+			}
+		})
+	}
+}
+
+func TestCodeHashingRedactsLogs(t *testing.T) {
+	logger := new(recordingLogger)
+	hookErr := errors.New("rejected")
+
+	store, err := NewTokenStore(
+		WithTokenStoreDB(unusedDB{}),
+		WithTokenStoreLogger(logger),
+		WithTokenStoreCodeHashing([]byte("key")),
+		WithTokenStoreHooks(Hooks{BeforeCreate: func(context.Context, oauth2.TokenInfo) error { return hookErr }}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = store.Create(context.Background(), newTestCode("secret-code")); !errors.Is(err, hookErr) {
+		t.Fatalf("got error %v, want %v", err, hookErr)
+	}
+
+	if logged := fmt.Sprint(logger.args...); strings.Contains(logged, "secret-code") {
+		t.Fatalf("got the code logged: %s", logged)
+	}
+
+	if got := store.loggedCode("secret-code"); got != store.hashCode("secret-code") {
+		t.Fatalf("got logged code %q, want its hash", got)
+	}
+}
+
+func TestTokenStoreCodeHashing(t *testing.T) {
+	store := newTestTokenStore(t, WithTokenStoreCodeHashing([]byte("key")))
+	ctx := context.Background()
+
+	if err := store.Create(ctx, newTestCode("code-a")); err != nil {
+		t.Fatal(err)
+	}
+
+	var stored string
+	if err := store.pool.QueryRow(ctx, "SELECT code FROM "+store.table).Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+
+	if stored == "code-a" {
+		t.Fatal("got the code stored in plain text")
+	}
+
+	info, err := store.GetByCode(ctx, "code-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info == nil || info.GetCode() != "code-a" {
+		t.Fatalf("got token %+v, want the code restored", info)
+	}
+
+	if err = store.RemoveByCode(ctx, "code-a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if info, err = store.GetByCode(ctx, "code-a"); err == nil && info != nil {
+		t.Fatal("got the removed code")
+	}
+}
+
+func TestSplitTokenStoreCodeHashing(t *testing.T) {
+	ctx := context.Background()
+
+	store, err := NewSplitTokenStore(WithTokenStoreDSN(newTestDSN(t)), WithTokenStoreCodeHashing([]byte("key")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { store.Close(ctx) })
+
+	if err = store.InitTable(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = store.Create(ctx, newTestCode("code-a")); err != nil {
+		t.Fatal(err)
+	}
+
+	var stored string
+	if err = store.store.pool.QueryRow(ctx, "SELECT code FROM "+store.codeTable).Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+
+	if stored == "code-a" {
+		t.Fatal("got the code stored in plain text")
+	}
+
+	info, err := store.GetByCode(ctx, "code-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.GetCode() != "code-a" {
+		t.Fatalf("got code %q, want it restored", info.GetCode())
+	}
+
+	if err = store.RemoveByCode(ctx, "code-a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = store.GetByCode(ctx, "code-a"); !errors.Is(err, ErrTokenNotFound) {
+		t.Fatalf("got error %v, want %v", err, ErrTokenNotFound)
+	}
+}
@@ -0,0 +1,84 @@
+package pgstore
+
+import "encoding/json"
+
+// Codec encodes and decodes the stored token and client information.
+type Codec interface {
+	// Marshal encodes the value.
+	Marshal(v any) ([]byte, error)
+	// Unmarshal decodes the data into the value.
+	Unmarshal(data []byte, v any) error
+}
+
+// JSONCodec is a codec using encoding/json, or the JSON functions given to
+// NewJSONCodec. Data encoded with a JSONCodec is stored in a JSONB column,
+// while data of any other codec is stored as BYTEA.
+type JSONCodec struct {
+	marshal   func(v any) ([]byte, error)
+	unmarshal func(data []byte, v any) error
+}
+
+// NewJSONCodec creates a new JSONCodec using the given JSON functions, such as
+// the ones of jsoniter or go-json, which must be compatible with
+// encoding/json.
+func NewJSONCodec(marshal func(v any) ([]byte, error), unmarshal func(data []byte, v any) error) (*JSONCodec, error) {
+	if marshal == nil || unmarshal == nil {
+		return nil, ErrNoCodec
+	}
+
+	return &JSONCodec{marshal: marshal, unmarshal: unmarshal}, nil
+}
+
+// Marshal encodes the value as JSON.
+func (c *JSONCodec) Marshal(v any) ([]byte, error) {
+	if c.marshal != nil {
+		return c.marshal(v)
+	}
+
+	return json.Marshal(v)
+}
+
+// Unmarshal decodes the JSON data into the value.
+func (c *JSONCodec) Unmarshal(data []byte, v any) error {
+	if c.unmarshal != nil {
+		return c.unmarshal(data, v)
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// WithTokenStoreJSON configures the JSON functions used to encode the token
+// data column, which is still stored as JSONB.
+func WithTokenStoreJSON(marshal func(v any) ([]byte, error), unmarshal func(data []byte, v any) error) TokenStoreOption {
+	return func(s *TokenStore) error {
+		codec, err := NewJSONCodec(marshal, unmarshal)
+		if err != nil {
+			return err
+		}
+
+		s.codec = codec
+
+		return nil
+	}
+}
+
+// WithClientStoreJSON configures the JSON functions used to encode the client
+// data column, which is still stored as JSONB.
+func WithClientStoreJSON(marshal func(v any) ([]byte, error), unmarshal func(data []byte, v any) error) ClientStoreOption {
+	return func(s *ClientStore) error {
+		codec, err := NewJSONCodec(marshal, unmarshal)
+		if err != nil {
+			return err
+		}
+
+		s.codec = codec
+
+		return nil
+	}
+}
+
+// isJSONCodec returns true if the codec produces JSON.
+func isJSONCodec(codec Codec) bool {
+	_, ok := codec.(*JSONCodec)
+	return ok
+}
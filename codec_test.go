@@ -0,0 +1,149 @@
+package pgstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+)
+
+// gobCodec is a codec producing binary data.
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(v)
+
+	return buf.Bytes(), err
+}
+
+func (gobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+func TestCodecOptionsRequireCodec(t *testing.T) {
+	for name, err := range map[string]error{
+		"token store codec":      WithTokenStoreCodec(nil)(&TokenStore{}),
+		"client store codec":     WithClientStoreCodec(nil)(&ClientStore{}),
+		"token store marshal":    WithTokenStoreJSON(nil, json.Unmarshal)(&TokenStore{}),
+		"client store unmarshal": WithClientStoreJSON(json.Marshal, nil)(&ClientStore{}),
+	} {
+		if !errors.Is(err, ErrNoCodec) {
+			t.Errorf("%s: got error %v, want %v", name, err, ErrNoCodec)
+		}
+	}
+}
+
+func TestJSONCodecUsesFunctions(t *testing.T) {
+	var marshaled, unmarshaled bool
+
+	codec, err := NewJSONCodec(
+		func(v any) ([]byte, error) {
+			marshaled = true
+			return json.Marshal(v)
+		},
+		func(data []byte, v any) error {
+			unmarshaled = true
+			return json.Unmarshal(data, v)
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := codec.Marshal(map[string]string{"key": "value"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]string
+	if err = codec.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if !marshaled || !unmarshaled || got["key"] != "value" {
+		t.Fatalf("got %v, want the value encoded by the given functions", got)
+	}
+}
+
+func TestDataColumnTypeOfCodec(t *testing.T) {
+	if got := (&TokenStore{codec: gobCodec{}}).dataColumnType(); got != "BYTEA" {
+		t.Errorf("token store: got %s, want BYTEA", got)
+	}
+
+	if got := (&ClientStore{codec: gobCodec{}}).dataColumnType(); got != "BYTEA" {
+		t.Errorf("client store: got %s, want BYTEA", got)
+	}
+
+	if got := (&ClientStore{codec: new(JSONCodec)}).dataColumnType(); got != "JSONB" {
+		t.Errorf("client store: got %s, want JSONB", got)
+	}
+}
+
+func TestStoresCustomCodec(t *testing.T) {
+	tokens := newTestTokenStore(t, WithTokenStoreCodec(gobCodec{}))
+	clients := newTestClientStore(t, WithClientStoreCodec(gobCodec{}))
+	ctx := context.Background()
+
+	if err := tokens.Create(ctx, newTestToken("client", "user", "a")); err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := tokens.GetByAccess(ctx, "access-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if token.GetRefresh() != "refresh-a" {
+		t.Errorf("got refresh token %q, want %q", token.GetRefresh(), "refresh-a")
+	}
+
+	if err = clients.Create(&models.Client{ID: "client", Secret: "secret", Domain: "https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := clients.GetByID(ctx, "client")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if client.GetDomain() != "https://example.com" {
+		t.Errorf("got domain %q, want %q", client.GetDomain(), "https://example.com")
+	}
+}
+
+func TestTokenStoreJSONFunctions(t *testing.T) {
+	var marshaled, unmarshaled int
+
+	store := newTestTokenStore(t, WithTokenStoreJSON(
+		func(v any) ([]byte, error) {
+			marshaled++
+			return json.Marshal(v)
+		},
+		func(data []byte, v any) error {
+			unmarshaled++
+			return json.Unmarshal(data, v)
+		},
+	))
+	ctx := context.Background()
+
+	if got := store.dataColumnType(); got != "JSONB" {
+		t.Fatalf("got %s data column, want JSONB", got)
+	}
+
+	if err := store.Create(ctx, newTestToken("client", "user", "a")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.GetByAccess(ctx, "access-a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if marshaled != 1 || unmarshaled != 1 {
+		t.Fatalf("got %d marshals and %d unmarshals, want the JSON functions used once each", marshaled, unmarshaled)
+	}
+}
@@ -0,0 +1,85 @@
+package pgstore
+
+import "regexp"
+
+// columnNamePattern matches the valid unquoted column names.
+var columnNamePattern = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// ColumnMap maps the token data to the columns of the token table, so the
+// store can be used with existing tables whose columns are named differently.
+// Empty fields use the names of DefaultColumnMap.
+type ColumnMap struct {
+	Code      string
+	Access    string
+	Refresh   string
+	Data      string
+	CreatedAt string
+	ExpiresAt string
+}
+
+// DefaultColumnMap is the column map of the tables created by InitTable.
+var DefaultColumnMap = ColumnMap{
+	Code:      "code",
+	Access:    "access_token",
+	Refresh:   "refresh_token",
+	Data:      "data",
+	CreatedAt: "created_at",
+	ExpiresAt: "expires_at",
+}
+
+// withDefaults returns the column map with the empty fields set to the
+// default names.
+func (m ColumnMap) withDefaults() ColumnMap {
+	for _, c := range []struct {
+		name *string
+		def  string
+	}{
+		{&m.Code, DefaultColumnMap.Code},
+		{&m.Access, DefaultColumnMap.Access},
+		{&m.Refresh, DefaultColumnMap.Refresh},
+		{&m.Data, DefaultColumnMap.Data},
+		{&m.CreatedAt, DefaultColumnMap.CreatedAt},
+		{&m.ExpiresAt, DefaultColumnMap.ExpiresAt},
+	} {
+		if *c.name == "" {
+			*c.name = c.def
+		}
+	}
+
+	return m
+}
+
+// validate returns ErrInvalidColumnMap if a column name is not a valid
+// unquoted identifier.
+func (m ColumnMap) validate() error {
+	for _, name := range []string{m.Code, m.Access, m.Refresh, m.Data, m.CreatedAt, m.ExpiresAt} {
+		if !columnNamePattern.MatchString(name) {
+			return ErrInvalidColumnMap
+		}
+	}
+
+	return nil
+}
+
+// tokenColumns returns the columns of the authorization code, access token
+// and refresh token.
+func (s *TokenStore) tokenColumns() string {
+	return s.columns.Code + ", " + s.columns.Access + ", " + s.columns.Refresh
+}
+
+// WithTokenStoreColumns configures the names of the token table columns, for
+// example ColumnMap{Access: "access", Refresh: "refresh"} for the tables of the
+// v3 pg adapter. InitTable adds the other columns of the token table to the
+// existing table.
+func WithTokenStoreColumns(columns ColumnMap) TokenStoreOption {
+	return func(s *TokenStore) error {
+		columns = columns.withDefaults()
+		if err := columns.validate(); err != nil {
+			return err
+		}
+
+		s.columns = columns
+
+		return nil
+	}
+}
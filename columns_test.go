@@ -0,0 +1,65 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestWithTokenStoreColumns(t *testing.T) {
+	store := &TokenStore{}
+	if err := WithTokenStoreColumns(ColumnMap{Access: "access", Refresh: "refresh"})(store); err != nil {
+		t.Fatal(err)
+	}
+
+	want := DefaultColumnMap
+	want.Access = "access"
+	want.Refresh = "refresh"
+
+	if store.columns != want {
+		t.Fatalf("got columns %+v, want %+v", store.columns, want)
+	}
+
+	for _, name := range []string{"Access", "access token", "access;DROP TABLE oauth2_tokens", "1access"} {
+		if err := WithTokenStoreColumns(ColumnMap{Access: name})(&TokenStore{}); !errors.Is(err, ErrInvalidColumnMap) {
+			t.Errorf("%q: got error %v, want %v", name, err, ErrInvalidColumnMap)
+		}
+	}
+}
+
+func TestTokenStoreColumns(t *testing.T) {
+	store := newTestTokenStore(t, WithTokenStoreColumns(ColumnMap{Code: "auth_code", Access: "access", Refresh: "refresh", ExpiresAt: "expiry"}))
+	ctx := context.Background()
+
+	token := newTestToken("client", "user", "a")
+	token.Code = "code-a"
+
+	if err := store.Create(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, lookup := range map[string]func() error{
+		"GetByCode":    func() error { _, err := store.GetByCode(ctx, "code-a"); return err },
+		"GetByAccess":  func() error { _, err := store.GetByAccess(ctx, "access-a"); return err },
+		"GetByRefresh": func() error { _, err := store.GetByRefresh(ctx, "refresh-a"); return err },
+		"ListTokens": func() error {
+			page, err := store.ListTokens(ctx, TokenFilter{ClientID: "client"}, nil, 0)
+			if err == nil && len(page.Tokens) != 1 {
+				err = errors.New("token not listed")
+			}
+			return err
+		},
+	} {
+		if err := lookup(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+
+	if err := store.RemoveByAccess(ctx, "access-a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.GetByRefresh(ctx, "refresh-a"); !errors.Is(err, ErrTokenNotFound) {
+		t.Fatalf("got error %v for a removed token, want %v", err, ErrTokenNotFound)
+	}
+}
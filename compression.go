@@ -0,0 +1,96 @@
+package pgstore
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+
+	"github.com/go-oauth2/oauth2/v4"
+)
+
+const (
+	CompressionNone = Compression("")     // store the data as is
+	CompressionGzip = Compression("gzip") // gzip compress the data
+)
+
+const (
+	// dataFormatGzip marks gzip compressed data.
+	dataFormatGzip byte = 0x01
+)
+
+// Compression is the compression algorithm of the token data column.
+type Compression string
+
+// WithTokenStoreCompression configures the compression of the token data
+// column. If compression is enabled, InitTable creates the data column as
+// BYTEA instead of JSONB. Uncompressed data is still read transparently, so
+// compression can be enabled for an existing BYTEA table.
+func WithTokenStoreCompression(compression Compression) TokenStoreOption {
+	return func(s *TokenStore) error {
+		switch compression {
+		case CompressionNone, CompressionGzip:
+			s.compression = compression
+			return nil
+		default:
+			return ErrUnknownCompression
+		}
+	}
+}
+
+// dataColumnType returns the type of the token data column.
+func (s *TokenStore) dataColumnType() string {
+	if s.compression != CompressionNone || !isJSONCodec(s.codec) || s.encryptionSetting != "" {
+		return "BYTEA"
+	}
+
+	return "JSONB"
+}
+
+// encodeTokenInfo encodes the token information for the data column.
+func (s *TokenStore) encodeTokenInfo(info oauth2.TokenInfo) ([]byte, error) {
+	data, err := s.codec.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.compression != CompressionGzip {
+		return data, nil
+	}
+
+	buf := bytes.NewBuffer([]byte{dataFormatGzip})
+	w := gzip.NewWriter(buf)
+
+	if _, err = w.Write(data); err != nil {
+		return nil, err
+	}
+
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// decodeTokenInfo decodes the token information read from the data column.
+// The format is detected from the first byte, so both compressed and
+// uncompressed data can be decoded regardless of the configuration.
+func (s *TokenStore) decodeTokenInfo(data []byte) (oauth2.TokenInfo, error) {
+	if len(data) > 0 && data[0] == dataFormatGzip {
+		r, err := gzip.NewReader(bytes.NewReader(data[1:]))
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = r.Close() }()
+
+		if data, err = io.ReadAll(r); err != nil {
+			return nil, err
+		}
+	}
+
+	info := s.newTokenInfo()
+	if err := s.codec.Unmarshal(data, info); err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
@@ -0,0 +1,76 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestWithTokenStoreCompressionUnknown(t *testing.T) {
+	if err := WithTokenStoreCompression("zstd")(&TokenStore{}); !errors.Is(err, ErrUnknownCompression) {
+		t.Fatalf("got error %v, want %v", err, ErrUnknownCompression)
+	}
+}
+
+func TestTokenStoreDataColumnType(t *testing.T) {
+	tests := map[string]struct {
+		store *TokenStore
+		want  string
+	}{
+		"json":       {store: &TokenStore{codec: new(JSONCodec)}, want: "JSONB"},
+		"compressed": {store: &TokenStore{codec: new(JSONCodec), compression: CompressionGzip}, want: "BYTEA"},
+		"encrypted":  {store: &TokenStore{codec: new(JSONCodec), encryptionSetting: "pgstore.key"}, want: "BYTEA"},
+	}
+
+	for name, tt := range tests {
+		if got := tt.store.dataColumnType(); got != tt.want {
+			t.Errorf("%s: got %s, want %s", name, got, tt.want)
+		}
+	}
+}
+
+func TestTokenStoreDecodesUncompressedData(t *testing.T) {
+	plain := newFuzzTokenStore(CompressionNone)
+	compressed := newFuzzTokenStore(CompressionGzip)
+	token := newTestToken("client", "user", "a")
+
+	data, err := plain.encodeTokenInfo(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := compressed.decodeTokenInfo(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.GetAccess() != token.Access {
+		t.Fatalf("got access token %q, want %q", info.GetAccess(), token.Access)
+	}
+
+	if data, err = compressed.encodeTokenInfo(token); err != nil {
+		t.Fatal(err)
+	}
+
+	if data[0] != dataFormatGzip {
+		t.Fatalf("got format %#x, want %#x", data[0], dataFormatGzip)
+	}
+}
+
+func TestTokenStoreCompression(t *testing.T) {
+	store := newTestTokenStore(t, WithTokenStoreCompression(CompressionGzip))
+	ctx := context.Background()
+
+	if err := store.Create(ctx, newTestToken("client", "user", "a")); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := store.GetByAccess(ctx, "access-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.GetRefresh() != "refresh-a" {
+		t.Fatalf("got refresh token %q, want %q", info.GetRefresh(), "refresh-a")
+	}
+}
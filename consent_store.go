@@ -0,0 +1,317 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// DefaultConsentStoreTable is the default table for storing consents.
+	DefaultConsentStoreTable = "oauth2_consents"
+)
+
+// ConsentStoreOption is a function that configures the ConsentStore.
+type ConsentStoreOption func(*ConsentStore) error
+
+// WithConsentStoreTable configures the consent table.
+func WithConsentStoreTable(table string) ConsentStoreOption {
+	return func(s *ConsentStore) error {
+		if table == "" {
+			return ErrNoTable
+		}
+
+		s.table = table
+
+		return nil
+	}
+}
+
+// WithConsentStoreTablePrefix configures the prefix added to the name of the
+// table of the store and, as a consequence, to the name of every index.
+func WithConsentStoreTablePrefix(prefix string) ConsentStoreOption {
+	return func(s *ConsentStore) error {
+		s.tablePrefix = prefix
+		return nil
+	}
+}
+
+// WithConsentStoreDB configures the database the store runs its queries on,
+// for example a pgx v4 pool adapted by the pgxv4 module.
+func WithConsentStoreDB(db DB) ConsentStoreOption {
+	return func(s *ConsentStore) error {
+		if db == nil {
+			return ErrNoConnPool
+		}
+
+		s.pool = db
+
+		return nil
+	}
+}
+
+// WithConsentStoreConnPool configures the connection pool.
+func WithConsentStoreConnPool(pool *pgxpool.Pool) ConsentStoreOption {
+	return func(s *ConsentStore) error {
+		if pool == nil {
+			return ErrNoConnPool
+		}
+
+		s.pool = pool
+
+		return nil
+	}
+}
+
+// WithConsentStoreLogger configures the logger.
+func WithConsentStoreLogger(logger Logger) ConsentStoreOption {
+	return func(s *ConsentStore) error {
+		if logger == nil {
+			return ErrNoLogger
+		}
+
+		s.logger = logger
+
+		return nil
+	}
+}
+
+// WithConsentStoreClock configures the clock used for grant times and expiry
+// checks.
+func WithConsentStoreClock(clock Clock) ConsentStoreOption {
+	return func(s *ConsentStore) error {
+		if clock == nil {
+			return ErrNoClock
+		}
+
+		s.clock = clock
+
+		return nil
+	}
+}
+
+// Consent is the consent of a user given to a client for a set of scopes.
+type Consent struct {
+	UserID    string
+	ClientID  string
+	Scopes    []string
+	GrantedAt time.Time
+	// ExpiresAt is the expiry of the consent or nil if it never expires.
+	ExpiresAt *time.Time
+}
+
+// ConsentStore is a data struct that stores the scopes users consented to.
+type ConsentStore struct {
+	pool        DB
+	table       string
+	tablePrefix string
+	logger      Logger
+	clock       Clock
+	readOnly    bool
+}
+
+// InitTableSQL returns the DDL statements executed by InitTable without
+// executing them, so schema changes can be reviewed and applied separately.
+func (s *ConsentStore) InitTableSQL() string {
+	return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %[1]s (
+			user_id    VARCHAR(255) NOT NULL,
+			client_id  VARCHAR(255) NOT NULL,
+			scopes     TEXT[]       NOT NULL,
+			granted_at TIMESTAMPTZ  NOT NULL,
+			expires_at TIMESTAMPTZ,
+			PRIMARY KEY (user_id, client_id)
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_%[2]s_client_idx ON %[1]s (client_id);`,
+		s.table, unqualifiedName(s.table),
+	)
+}
+
+// InitTable initializes the consent store table if it does not exist and
+// creates the indexes.
+func (s *ConsentStore) InitTable(ctx context.Context) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	s.logger.Log(ctx, LogLevelDebug, "initializing consent store table", "table", s.table)
+
+	if _, err := s.pool.Exec(ctx, s.InitTableSQL()); err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return wrapDatabaseError(err)
+	}
+
+	return nil
+}
+
+// Grant stores the consent of the user to the client for the scopes. The
+// scopes are added to the previously granted scopes and the expiry is
+// replaced. The scopes of an expired consent are replaced instead, so they
+// are not granted again under the new expiry. The consent never expires if
+// the expiry is zero.
+func (s *ConsentStore) Grant(ctx context.Context, userID string, clientID string, scopes []string, expiresAt time.Time) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	s.logger.Log(ctx, LogLevelDebug, "granting consent", "user_id", userID, "client_id", clientID, "scopes", scopes)
+
+	var expiry *time.Time
+	if !expiresAt.IsZero() {
+		expiry = &expiresAt
+	}
+
+	_, err := s.pool.Exec(ctx, fmt.Sprintf(`
+		INSERT INTO %[1]s AS c (user_id, client_id, scopes, granted_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, client_id) DO UPDATE SET
+			scopes = CASE
+				WHEN c.expires_at IS NOT NULL AND c.expires_at <= EXCLUDED.granted_at THEN EXCLUDED.scopes
+				ELSE ARRAY(SELECT DISTINCT unnest(c.scopes || EXCLUDED.scopes) ORDER BY 1)
+			END,
+			granted_at = EXCLUDED.granted_at,
+			expires_at = EXCLUDED.expires_at`,
+		s.table,
+	), userID, clientID, scopes, s.clock.Now(), expiry)
+
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return wrapDatabaseError(err)
+	}
+
+	s.logger.Log(ctx, LogLevelDebug, "consent granted")
+
+	return nil
+}
+
+// Revoke removes the consent of the user given to the client.
+func (s *ConsentStore) Revoke(ctx context.Context, userID string, clientID string) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	s.logger.Log(ctx, LogLevelDebug, "revoking consent", "user_id", userID, "client_id", clientID)
+
+	_, err := s.pool.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE user_id = $1 AND client_id = $2", s.table), userID, clientID)
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return wrapDatabaseError(err)
+	}
+
+	s.logger.Log(ctx, LogLevelInfo, "consent revoked")
+
+	return nil
+}
+
+// HasConsent returns true if the user has a not expired consent given to the
+// client covering every scope.
+func (s *ConsentStore) HasConsent(ctx context.Context, userID string, clientID string, scopes []string) (bool, error) {
+	s.logger.Log(ctx, LogLevelDebug, "checking consent", "user_id", userID, "client_id", clientID, "scopes", scopes)
+
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	var ok bool
+	err := s.pool.QueryRow(ctx, fmt.Sprintf(`
+		SELECT EXISTS (
+			SELECT 1 FROM %s
+			WHERE user_id = $1 AND client_id = $2 AND scopes @> $3::TEXT[]
+			AND (expires_at IS NULL OR expires_at > $4)
+		)`,
+		s.table,
+	), userID, clientID, scopes, s.clock.Now()).Scan(&ok)
+
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return false, wrapDatabaseError(err)
+	}
+
+	return ok, nil
+}
+
+// Get returns the consent of the user given to the client.
+func (s *ConsentStore) Get(ctx context.Context, userID string, clientID string) (*Consent, error) {
+	s.logger.Log(ctx, LogLevelDebug, "getting consent", "user_id", userID, "client_id", clientID)
+
+	consent := new(Consent)
+	err := s.pool.QueryRow(ctx, fmt.Sprintf(
+		"SELECT user_id, client_id, scopes, granted_at, expires_at FROM %s WHERE user_id = $1 AND client_id = $2",
+		s.table,
+	), userID, clientID).Scan(&consent.UserID, &consent.ClientID, &consent.Scopes, &consent.GrantedAt, &consent.ExpiresAt)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrConsentNotFound
+	}
+
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return nil, wrapDatabaseError(err)
+	}
+
+	return consent, nil
+}
+
+// ListByUserID returns the consents of the user, including the expired ones,
+// ordered by the time they were granted.
+func (s *ConsentStore) ListByUserID(ctx context.Context, userID string) ([]Consent, error) {
+	s.logger.Log(ctx, LogLevelDebug, "listing consents by user id", "user_id", userID)
+
+	rows, err := s.pool.Query(ctx, fmt.Sprintf(
+		"SELECT user_id, client_id, scopes, granted_at, expires_at FROM %s WHERE user_id = $1 ORDER BY granted_at, client_id",
+		s.table,
+	), userID)
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return nil, wrapDatabaseError(err)
+	}
+
+	consents, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Consent, error) {
+		var consent Consent
+		err := row.Scan(&consent.UserID, &consent.ClientID, &consent.Scopes, &consent.GrantedAt, &consent.ExpiresAt)
+		return consent, err
+	})
+
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return nil, wrapDatabaseError(err)
+	}
+
+	return consents, nil
+}
+
+// Close closes the consent store. The connection pool of the store is
+// configured by the caller, so it is left open.
+func (s *ConsentStore) Close(ctx context.Context) {
+	s.logger.Log(ctx, LogLevelDebug, "consent store closed")
+}
+
+// NewConsentStore creates a new ConsentStore.
+func NewConsentStore(opts ...ConsentStoreOption) (*ConsentStore, error) {
+	s := &ConsentStore{
+		table:  DefaultConsentStoreTable,
+		logger: new(NoopLogger),
+		clock:  new(SystemClock),
+	}
+
+	for _, o := range opts {
+		if err := o(s); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.tablePrefix != "" {
+		s.table = prefixedName(s.tablePrefix, s.table)
+	}
+
+	if s.pool == nil {
+		return nil, ErrNoConnPool
+	}
+
+	return s, nil
+}
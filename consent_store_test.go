@@ -0,0 +1,51 @@
+package pgstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestConsentStoreGrantDropsExpiredScopes(t *testing.T) {
+	ctx := context.Background()
+	clock := &fixedClock{now: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	pool, err := pgxpool.New(ctx, newTestDSN(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(pool.Close)
+
+	store, err := NewConsentStore(WithConsentStoreConnPool(pool), WithConsentStoreClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = store.InitTable(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = store.Grant(ctx, "user", "client", []string{"admin"}, clock.now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	clock.now = clock.now.Add(2 * time.Hour)
+
+	if err = store.Grant(ctx, "user", "client", []string{"read"}, clock.now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	for scope, want := range map[string]bool{"admin": false, "read": true} {
+		ok, err := store.HasConsent(ctx, "user", "client", []string{scope})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ok != want {
+			t.Errorf("got consent %t for scope %s, want %t", ok, scope, want)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package pgstore
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStoresInitTablesSQL(t *testing.T) {
+	ctx := context.Background()
+
+	stores, err := NewStores(ctx, WithStoresDB(unusedDB{}), WithStoresTokenStoreOptions(WithTokenStoreArchive(DefaultTokenArchiveTable, time.Hour)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stores.Close(ctx)
+
+	ddl := stores.InitTablesSQL()
+
+	for _, table := range []string{
+		DefaultClientStoreTable,
+		DefaultClientSecretTable,
+		DefaultClientRedirectURITable,
+		DefaultScopeTable,
+		DefaultClientScopeTable,
+		DefaultTokenStoreTable,
+		DefaultTokenArchiveTable,
+		DefaultConsentStoreTable,
+	} {
+		if !strings.Contains(ddl, "CREATE TABLE IF NOT EXISTS "+table+" (") {
+			t.Errorf("got no DDL of the %s table", table)
+		}
+	}
+}
+
+func TestStoresInitTablesSQLIsIdempotent(t *testing.T) {
+	stores := newTestStores(t)
+	ctx := context.Background()
+
+	pool, _ := nativePool(stores.TokenStore.pool)
+	if _, err := pool.Exec(ctx, stores.InitTablesSQL()); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff, err := stores.VerifySchema(ctx); err != nil {
+		t.Fatalf("got schema differences %v after applying the DDL: %v", diff, err)
+	}
+}
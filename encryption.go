@@ -0,0 +1,158 @@
+package pgstore
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// encryptionSettingPattern matches the valid names of custom configuration
+// parameters holding the encryption key.
+var encryptionSettingPattern = regexp.MustCompile(`^[a-z_][a-z0-9_]*\.[a-z_][a-z0-9_]*$`)
+
+// SetEncryptionKey returns a function setting the encryption key in the
+// custom configuration parameter of every new connection. It is meant to be
+// used as the AfterConnect function of the pgxpool configuration, so the key
+// never appears in the statements of the stores.
+func SetEncryptionKey(setting string, key string) func(ctx context.Context, conn *pgx.Conn) error {
+	return func(ctx context.Context, conn *pgx.Conn) error {
+		_, err := conn.Exec(ctx, "SELECT set_config($1, $2, false)", setting, key)
+		return err
+	}
+}
+
+// validateEncryptionSetting validates the name of the custom configuration
+// parameter holding the encryption key.
+func validateEncryptionSetting(setting string) error {
+	if !encryptionSettingPattern.MatchString(setting) {
+		return ErrInvalidEncryptionSetting
+	}
+
+	return nil
+}
+
+// encryptionKeyExpr returns the expression reading the encryption key.
+func encryptionKeyExpr(setting string) string {
+	return fmt.Sprintf("current_setting('%s')", setting)
+}
+
+// rowKeyExpr returns the expression reading the key the row was encrypted
+// with, by the ID in its key ID column. The rows without a key ID were
+// encrypted with the key set by SetEncryptionKey, so the key of the
+// connection is used for them.
+func rowKeyExpr(setting string) string {
+	return fmt.Sprintf(
+		"CASE WHEN key_id = '' THEN %s ELSE current_setting('%s' || encode(convert_to(key_id, 'UTF8'), 'hex')) END",
+		encryptionKeyExpr(setting), keySettingPrefix(setting),
+	)
+}
+
+// WithTokenStoreEncryption configures the store to encrypt the data column
+// with pgcrypto, using the key stored in the custom configuration parameter
+// of the connection, for example "pgstore.key". Use SetEncryptionKey to set
+// the key on every connection of the pool. The token columns are not
+// encrypted, as the tokens are looked up by them.
+func WithTokenStoreEncryption(setting string) TokenStoreOption {
+	return func(s *TokenStore) error {
+		if err := validateEncryptionSetting(setting); err != nil {
+			return err
+		}
+
+		s.encryptionSetting = setting
+
+		return nil
+	}
+}
+
+// WithClientStoreEncryption configures the store to encrypt the secret and
+// data columns with pgcrypto, using the key stored in the custom configuration
+// parameter of the connection, for example "pgstore.key". Use
+// SetEncryptionKey to set the key on every connection of the pool.
+func WithClientStoreEncryption(setting string) ClientStoreOption {
+	return func(s *ClientStore) error {
+		if err := validateEncryptionSetting(setting); err != nil {
+			return err
+		}
+
+		s.encryptionSetting = setting
+
+		return nil
+	}
+}
+
+// encryptData returns the expression encrypting the data parameter.
+func (s *TokenStore) encryptData(param string) string {
+	if s.encryptionSetting == "" {
+		return param
+	}
+
+	return fmt.Sprintf("pgp_sym_encrypt_bytea(%s, %s)", param, encryptionKeyExpr(s.encryptionSetting))
+}
+
+// decryptData returns the expression decrypting the data column with the key
+// the row was encrypted with.
+func (s *TokenStore) decryptData() string {
+	return s.decryptDataWith(rowKeyExpr(s.encryptionSetting))
+}
+
+// decryptDataWith returns the expression decrypting the data column with the
+// key expression.
+func (s *TokenStore) decryptDataWith(key string) string {
+	if s.encryptionSetting == "" {
+		return s.columns.Data
+	}
+
+	return fmt.Sprintf("pgp_sym_decrypt_bytea(%[1]s, %[2]s) AS %[1]s", s.columns.Data, key)
+}
+
+// selectColumns returns the list of columns selected when reading tokens.
+func (s *TokenStore) selectColumns() string {
+	return fmt.Sprintf(
+		"id, %s, client_id, user_id, %s, %s, %s",
+		s.tokenColumns(), s.decryptData(), s.columns.CreatedAt, s.columns.ExpiresAt,
+	)
+}
+
+// encryptionSQL returns the DDL enabling pgcrypto if encryption is used.
+func encryptionSQL(setting string) string {
+	if setting == "" {
+		return ""
+	}
+
+	return "CREATE EXTENSION IF NOT EXISTS pgcrypto;\n"
+}
+
+// encryptSecret returns the expression encrypting the secret parameter. The
+// encrypted secret is stored base64 encoded in the text column.
+func (s *ClientStore) encryptSecret(param string) string {
+	if s.encryptionSetting == "" {
+		return param
+	}
+
+	return fmt.Sprintf("encode(pgp_sym_encrypt(%s, %s), 'base64')", param, encryptionKeyExpr(s.encryptionSetting))
+}
+
+// encryptData returns the expression encrypting the data parameter.
+func (s *ClientStore) encryptData(param string) string {
+	if s.encryptionSetting == "" {
+		return param
+	}
+
+	return fmt.Sprintf("pgp_sym_encrypt_bytea(%s, %s)", param, encryptionKeyExpr(s.encryptionSetting))
+}
+
+// selectColumns returns the list of columns selected when reading clients.
+func (s *ClientStore) selectColumns() string {
+	secret, data := "secret", "data"
+
+	if s.encryptionSetting != "" {
+		key := rowKeyExpr(s.encryptionSetting)
+		secret = fmt.Sprintf("pgp_sym_decrypt(decode(secret, 'base64'), %s) AS secret", key)
+		data = fmt.Sprintf("pgp_sym_decrypt_bytea(data, %s) AS data", key)
+	}
+
+	return strings.Join([]string{"id", secret, "domain", "is_public", data, "created_at", "version", "is_enabled"}, ", ")
+}
@@ -0,0 +1,81 @@
+package pgstore
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestClientStoreSelectColumns(t *testing.T) {
+	store := &ClientStore{}
+	if got, want := store.selectColumns(), "id, secret, domain, is_public, data, created_at, version, is_enabled"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	store.encryptionSetting = "pgstore.key"
+
+	key := "CASE WHEN key_id = '' THEN current_setting('pgstore.key') " +
+		"ELSE current_setting('pgstore.key_k' || encode(convert_to(key_id, 'UTF8'), 'hex')) END"
+	want := "id, pgp_sym_decrypt(decode(secret, 'base64'), " + key + ") AS secret, domain, is_public, " +
+		"pgp_sym_decrypt_bytea(data, " + key + ") AS data, created_at, version, is_enabled"
+	if got := store.selectColumns(); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestClientStoreInitTableSQLWidensSecret(t *testing.T) {
+	store := &ClientStore{table: DefaultClientStoreTable, codec: new(JSONCodec)}
+
+	ddl := store.InitTableSQL()
+	if !strings.Contains(ddl, "secret     TEXT         NOT NULL") {
+		t.Fatal("secret column not created as TEXT")
+	}
+
+	if !strings.Contains(ddl, "ALTER TABLE oauth2_clients ALTER COLUMN secret TYPE TEXT") {
+		t.Fatal("secret column of existing tables not widened")
+	}
+}
+
+func TestClientStoreEncryptedLongSecret(t *testing.T) {
+	ctx := context.Background()
+
+	config, err := pgxpool.ParseConfig(newTestDSN(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config.AfterConnect = SetEncryptionKey("pgstore.key", "key")
+
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	store, err := NewClientStore(WithClientStoreConnPool(pool), WithClientStoreEncryption("pgstore.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = store.InitTable(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// The base64 encoded ciphertext of the secret is longer than 255 bytes.
+	secret := strings.Repeat("s", 200)
+	if err = store.Create(&models.Client{ID: "client", Secret: secret}); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := store.GetByID(ctx, "client")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.GetSecret() != secret {
+		t.Fatalf("got secret %q, want %q", info.GetSecret(), secret)
+	}
+}
@@ -0,0 +1,120 @@
+package pgstore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErasureReport is the compliance record of an erasure, the number of rows
+// deleted or anonymized in each table by table name.
+type ErasureReport struct {
+	UserID string
+	Counts map[string]int64
+}
+
+// erasureQuery is a statement erasing the data of the user, given as the
+// first argument, from the table.
+type erasureQuery struct {
+	table string
+	sql   string
+}
+
+// eraseUserData executes the erasure queries in a single transaction and
+// returns the number of affected rows per table.
+func eraseUserData(ctx context.Context, db DB, logger Logger, userID string, queries []erasureQuery) (*ErasureReport, error) {
+	report := &ErasureReport{UserID: userID, Counts: make(map[string]int64, len(queries))}
+
+	err := pgx.BeginFunc(ctx, db, func(tx pgx.Tx) error {
+		for _, q := range queries {
+			tag, err := tx.Exec(ctx, q.sql, userID)
+			if err != nil {
+				return err
+			}
+
+			report.Counts[q.table] = tag.RowsAffected()
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		logger.Log(ctx, LogLevelError, err.Error())
+		return nil, wrapDatabaseError(err)
+	}
+
+	logger.Log(ctx, LogLevelInfo, "user data erased", "user_id", userID, "counts", report.Counts)
+
+	return report, nil
+}
+
+// EraseUserData deletes the tokens, the archived tokens and the consents of
+// the user in a single transaction, so a right to erasure request is either
+// fulfilled completely or not at all. It returns the number of deleted rows
+// per table. The subjects of the JTI store and the tokens of the split token
+// store are not managed by the stores and are erased by JTIStore.EraseSubject
+// and SplitTokenStore.EraseUserData.
+func (s *Stores) EraseUserData(ctx context.Context, userID string) (*ErasureReport, error) {
+	s.TokenStore.logger.Log(ctx, LogLevelDebug, "erasing user data", "user_id", userID)
+
+	if s.TokenStore.readOnly {
+		return nil, ErrReadOnly
+	}
+
+	if userID == "" {
+		return nil, ErrNoUserID
+	}
+
+	queries := []erasureQuery{
+		{s.TokenStore.table, fmt.Sprintf("DELETE FROM %s WHERE user_id = $1%s", s.TokenStore.table, s.TokenStore.andTenant())},
+		{s.ConsentStore.table, fmt.Sprintf("DELETE FROM %s WHERE user_id = $1", s.ConsentStore.table)},
+	}
+
+	if archive := s.TokenStore.archiveTable; archive != "" {
+		queries = append(queries, erasureQuery{archive, fmt.Sprintf("DELETE FROM %s WHERE user_id = $1%s", archive, s.TokenStore.andTenant())})
+	}
+
+	return eraseUserData(ctx, s.pool, s.TokenStore.logger, userID, queries)
+}
+
+// EraseUserData deletes the authorization codes, access tokens and refresh
+// tokens of the user in a single transaction. It returns the number of
+// deleted rows per table.
+func (s *SplitTokenStore) EraseUserData(ctx context.Context, userID string) (*ErasureReport, error) {
+	s.store.logger.Log(ctx, LogLevelDebug, "erasing user data", "user_id", userID)
+
+	if s.store.readOnly {
+		return nil, ErrReadOnly
+	}
+
+	if userID == "" {
+		return nil, ErrNoUserID
+	}
+
+	queries := make([]erasureQuery, 0, 3)
+	for _, table := range []string{s.codeTable, s.accessTable, s.refreshTable} {
+		queries = append(queries, erasureQuery{table, fmt.Sprintf("DELETE FROM %s WHERE user_id = $1%s", table, s.store.andTenant())})
+	}
+
+	return eraseUserData(ctx, s.store.pool, s.store.logger, userID, queries)
+}
+
+// EraseSubject removes the subject from the JTIs of the user. The JTIs are
+// kept until they expire, so erasing the user does not allow replaying their
+// tokens. It returns the number of anonymized rows.
+func (s *JTIStore) EraseSubject(ctx context.Context, subject string) (*ErasureReport, error) {
+	s.logger.Log(ctx, LogLevelDebug, "erasing jti subject", "subject", subject)
+
+	if s.readOnly {
+		return nil, ErrReadOnly
+	}
+
+	if subject == "" {
+		return nil, ErrNoUserID
+	}
+
+	return eraseUserData(ctx, s.pool, s.logger, subject, []erasureQuery{
+		{s.table, fmt.Sprintf("UPDATE %s SET subject = '' WHERE subject = $1", s.table)},
+	})
+}
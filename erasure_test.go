@@ -0,0 +1,107 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestEraseUserDataNoUserID(t *testing.T) {
+	ctx := context.Background()
+
+	split, err := NewSplitTokenStore(WithTokenStoreDB(unusedDB{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jtis, err := NewJTIStore(WithJTIStoreDB(unusedDB{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = split.EraseUserData(ctx, ""); !errors.Is(err, ErrNoUserID) {
+		t.Fatalf("got error %v, want %v", err, ErrNoUserID)
+	}
+
+	if _, err = jtis.EraseSubject(ctx, ""); !errors.Is(err, ErrNoUserID) {
+		t.Fatalf("got error %v, want %v", err, ErrNoUserID)
+	}
+}
+
+func TestSplitTokenStoreEraseUserData(t *testing.T) {
+	ctx := context.Background()
+
+	store, err := NewSplitTokenStore(WithTokenStoreDSN(newTestDSN(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { store.Close(ctx) })
+
+	if err = store.InitTable(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, token := range []struct{ userID, suffix string }{{"user", "a"}, {"other", "b"}} {
+		if err = store.Create(ctx, newTestToken("client", token.userID, token.suffix)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := store.EraseUserData(ctx, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Counts[store.accessTable] != 1 || report.Counts[store.refreshTable] != 1 {
+		t.Fatalf("got counts %v, want one access and refresh token", report.Counts)
+	}
+
+	if _, err = store.GetByAccess(ctx, "access-a"); !errors.Is(err, ErrTokenNotFound) {
+		t.Fatalf("got error %v, want %v", err, ErrTokenNotFound)
+	}
+
+	if _, err = store.GetByAccess(ctx, "access-b"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJTIStoreEraseSubject(t *testing.T) {
+	ctx := context.Background()
+
+	pool, err := pgxpool.New(ctx, newTestDSN(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(pool.Close)
+
+	store, err := NewJTIStore(WithJTIStoreConnPool(pool))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = store.InitTable(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = store.Create(ctx, "jti", newTestToken("client", "user", "a")); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := store.EraseSubject(ctx, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Counts[store.table] != 1 {
+		t.Fatalf("got counts %v, want one jti", report.Counts)
+	}
+
+	// The jti is kept, so the token cannot be replayed.
+	if _, err = store.IsRevoked(ctx, "jti"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,163 @@
+package pgstore
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	EventTokenCreated       = EventType("created")         // token created
+	EventTokenRevoked       = EventType("revoked")         // token removed
+	EventTokenExpiredPurged = EventType("expired_cleaned") // expired tokens cleaned up
+)
+
+// EventType is the type of token lifecycle event.
+type EventType string
+
+// Event is a token lifecycle event published using NOTIFY.
+type Event struct {
+	Type      EventType `json:"type"`
+	ClientID  string    `json:"client_id,omitempty"`
+	UserID    string    `json:"user_id,omitempty"`
+	Count     int64     `json:"count,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// WithTokenStoreEventChannel configures the channel the token lifecycle events
+// are published on. Events are not published unless a channel is set.
+func WithTokenStoreEventChannel(channel string) TokenStoreOption {
+	return func(s *TokenStore) error {
+		if channel == "" {
+			return ErrNoEventChannel
+		}
+
+		s.eventChannel = channel
+
+		return nil
+	}
+}
+
+// newTokenEvent creates an event for the given token.
+func newTokenEvent(eventType EventType, info oauth2.TokenInfo) Event {
+	return Event{
+		Type:     eventType,
+		ClientID: info.GetClientID(),
+		UserID:   info.GetUserID(),
+		Count:    1,
+	}
+}
+
+// newRemovedEvent creates one event for the tokens removed by an operation.
+// The client and user IDs are only set if every removed token shares them.
+func newRemovedEvent(removed []oauth2.TokenInfo) Event {
+	event := Event{Type: EventTokenRevoked, Count: int64(len(removed))}
+
+	for i, info := range removed {
+		if i == 0 {
+			event.ClientID, event.UserID = info.GetClientID(), info.GetUserID()
+			continue
+		}
+
+		if info.GetClientID() != event.ClientID {
+			event.ClientID = ""
+		}
+
+		if info.GetUserID() != event.UserID {
+			event.UserID = ""
+		}
+	}
+
+	return event
+}
+
+// publishEvent publishes the event on the configured channel. Failing to
+// publish an event does not fail the operation that triggered it.
+func (s *TokenStore) publishEvent(ctx context.Context, event Event) {
+	if s.eventChannel == "" {
+		return
+	}
+
+	if event.Timestamp.IsZero() {
+		event.Timestamp = s.clock.Now()
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return
+	}
+
+	if _, err = s.pool.Exec(ctx, "SELECT pg_notify($1, $2)", s.eventChannel, string(payload)); err != nil {
+		s.logger.Log(ctx, LogLevelError, "publishing event failed", "event", event, "err", err)
+	}
+}
+
+// SubscribeEvents listens on the configured channel and returns the received
+// token lifecycle events. The returned channel is closed when the context is
+// cancelled or the listening connection fails. It returns ErrUnsupportedDB if
+// the store does not use a *pgxpool.Pool.
+func (s *TokenStore) SubscribeEvents(ctx context.Context) (<-chan Event, error) {
+	if s.eventChannel == "" {
+		return nil, ErrNoEventChannel
+	}
+
+	pool, ok := nativePool(s.pool)
+	if !ok {
+		return nil, ErrUnsupportedDB
+	}
+
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return nil, err
+	}
+
+	channel := pgx.Identifier{s.eventChannel}.Sanitize()
+	if _, err = conn.Exec(ctx, "LISTEN "+channel); err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		conn.Release()
+
+		return nil, err
+	}
+
+	s.logger.Log(ctx, LogLevelDebug, "subscribed to events", "channel", s.eventChannel)
+
+	events := make(chan Event)
+
+	go func() {
+		defer close(events)
+		defer func() {
+			_, _ = conn.Exec(context.Background(), "UNLISTEN "+channel)
+			conn.Release()
+		}()
+
+		for {
+			notification, err := conn.Conn().WaitForNotification(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					s.logger.Log(ctx, LogLevelError, err.Error())
+				}
+
+				return
+			}
+
+			var event Event
+			if err := json.Unmarshal([]byte(notification.Payload), &event); err != nil {
+				s.logger.Log(ctx, LogLevelWarn, "invalid event payload", "payload", notification.Payload)
+				continue
+			}
+
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
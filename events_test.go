@@ -0,0 +1,91 @@
+package pgstore
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// notifyDB is a database recording the published events.
+type notifyDB struct {
+	DB
+	events []Event
+}
+
+func (d *notifyDB) Exec(_ context.Context, _ string, args ...any) (pgconn.CommandTag, error) {
+	var event Event
+	if err := json.Unmarshal([]byte(args[1].(string)), &event); err != nil {
+		return pgconn.CommandTag{}, err
+	}
+
+	d.events = append(d.events, event)
+
+	return pgconn.NewCommandTag("SELECT 1"), nil
+}
+
+func TestNewRemovedEvent(t *testing.T) {
+	tests := map[string]struct {
+		removed []oauth2.TokenInfo
+		want    Event
+	}{
+		"one token": {
+			removed: []oauth2.TokenInfo{newTestToken("client", "user", "a")},
+			want:    Event{Type: EventTokenRevoked, ClientID: "client", UserID: "user", Count: 1},
+		},
+		"tokens of the user": {
+			removed: []oauth2.TokenInfo{newTestToken("a", "user", "a"), newTestToken("b", "user", "b")},
+			want:    Event{Type: EventTokenRevoked, UserID: "user", Count: 2},
+		},
+		"tokens of the client": {
+			removed: []oauth2.TokenInfo{newTestToken("client", "a", "a"), newTestToken("client", "b", "b")},
+			want:    Event{Type: EventTokenRevoked, ClientID: "client", Count: 2},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := newRemovedEvent(tt.removed); got != tt.want {
+				t.Fatalf("got event %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenStoreNotifyRemovedPublishesOneEvent(t *testing.T) {
+	db := new(notifyDB)
+
+	store, err := NewTokenStore(WithTokenStoreDB(db), WithTokenStoreEventChannel("events"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close(context.Background())
+
+	var removed [][]byte
+	for _, suffix := range []string{"a", "b", "c"} {
+		data, err := store.encodeTokenInfo(newTestToken("client", "user", suffix))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		removed = append(removed, data)
+	}
+
+	store.notifyRemoved(context.Background(), removed)
+
+	if len(db.events) != 1 {
+		t.Fatalf("got %d events, want one for the operation", len(db.events))
+	}
+
+	if got := db.events[0]; got.Type != EventTokenRevoked || got.Count != 3 || got.ClientID != "client" || got.UserID != "user" {
+		t.Fatalf("got event %+v, want the three removed tokens", got)
+	}
+
+	store.notifyRemoved(context.Background(), nil)
+
+	if len(db.events) != 1 {
+		t.Fatalf("got %d events, want none published without removed tokens", len(db.events)-1)
+	}
+}
@@ -0,0 +1,112 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+)
+
+func TestTokenStoreCreateWithExpiryRequiresExpiry(t *testing.T) {
+	store, err := newTokenStore(WithTokenStoreDB(unusedDB{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = store.CreateWithExpiry(context.Background(), newTestToken("client", "user", "a"), time.Time{}); !errors.Is(err, ErrInvalidExpiry) {
+		t.Fatalf("got error %v, want %v", err, ErrInvalidExpiry)
+	}
+}
+
+func TestTokenStoreCreateWithExpiry(t *testing.T) {
+	store := newTestTokenStore(t, WithTokenStoreExpiryFiltering())
+	ctx := context.Background()
+	expiresAt := time.Now().Add(10 * time.Minute).Truncate(time.Microsecond)
+
+	// The expiry overrides the 24 hours of the refresh token.
+	if err := store.CreateWithExpiry(ctx, newTestToken("client", "user", "a"), expiresAt); err != nil {
+		t.Fatal(err)
+	}
+
+	var got time.Time
+	if err := store.pool.QueryRow(ctx, "SELECT expires_at FROM "+store.table+" WHERE access_token = 'access-a'").Scan(&got); err != nil {
+		t.Fatal(err)
+	}
+
+	if !got.Equal(expiresAt) {
+		t.Fatalf("got expiry %v, want %v", got, expiresAt)
+	}
+
+	if err := store.CreateWithExpiry(ctx, newTestToken("client", "user", "b"), time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.GetByAccess(ctx, "access-b"); !errors.Is(err, ErrTokenNotFound) {
+		t.Fatalf("got error %v for an expired token, want %v", err, ErrTokenNotFound)
+	}
+}
+
+func TestTokenStoreAndUnexpired(t *testing.T) {
+	store := &TokenStore{columns: DefaultColumnMap}
+	if got := store.andUnexpired(); got != "" {
+		t.Fatalf("got condition %q without expiry filtering", got)
+	}
+
+	if err := WithTokenStoreExpiryFiltering()(store); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := store.andUnexpired(); got != " AND expires_at > now()" {
+		t.Fatalf("got condition %q", got)
+	}
+}
+
+func TestTokenStoreExpiryFiltering(t *testing.T) {
+	dsn := newTestDSN(t)
+	ctx := context.Background()
+
+	stores := make([]*TokenStore, 2)
+	for i, opts := range [][]TokenStoreOption{nil, {WithTokenStoreExpiryFiltering()}} {
+		store, err := NewTokenStore(append([]TokenStoreOption{WithTokenStoreDSN(dsn)}, opts...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		t.Cleanup(func() { store.Close(ctx) })
+
+		if err = store.InitTable(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		stores[i] = store
+	}
+
+	code := newTestCode("code")
+	code.CodeCreateAt = time.Now().Add(-time.Hour)
+
+	if err := stores[0].Create(ctx, code); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := stores[0].CreateWithExpiry(ctx, newTestToken("client", "user", "a"), time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, store := range stores {
+		for name, get := range map[string]func() (oauth2.TokenInfo, error){
+			"code":    func() (oauth2.TokenInfo, error) { return store.GetByCode(ctx, "code") },
+			"access":  func() (oauth2.TokenInfo, error) { return store.GetByAccess(ctx, "access-a") },
+			"refresh": func() (oauth2.TokenInfo, error) { return store.GetByRefresh(ctx, "refresh-a") },
+		} {
+			_, err := get()
+
+			// Without filtering, the expired tokens are returned until the
+			// cleanup removes them.
+			if filtered := i == 1; filtered != errors.Is(err, ErrTokenNotFound) {
+				t.Errorf("%s with filtering %t: got error %v", name, filtered, err)
+			}
+		}
+	}
+}
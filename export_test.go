@@ -0,0 +1,87 @@
+package pgstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStoresExportUserDataRequiresUserID(t *testing.T) {
+	ctx := context.Background()
+
+	stores, err := NewStores(ctx, WithStoresDB(unusedDB{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stores.Close(ctx)
+
+	if err = stores.ExportUserData(ctx, "", io.Discard); !errors.Is(err, ErrNoUserID) {
+		t.Fatalf("got error %v, want %v", err, ErrNoUserID)
+	}
+}
+
+func TestStoresExportUserData(t *testing.T) {
+	stores := newTestStores(t)
+	ctx := context.Background()
+
+	meta := TokenMetadata{ClientIP: netip.MustParseAddr("192.0.2.1"), UserAgent: "curl/8.0"}
+	if err := stores.TokenStore.CreateWithMetadata(ctx, newTestToken("client", "user", "a"), meta); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := stores.TokenStore.Create(ctx, newTestToken("client", "other", "b")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := stores.ConsentStore.Grant(ctx, "user", "client", []string{"read"}, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := stores.ExportUserData(ctx, "user", &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// The token values are redacted.
+	if strings.Contains(buf.String(), "access-a") || strings.Contains(buf.String(), "refresh-a") {
+		t.Fatalf("got token values in the export %s", buf.String())
+	}
+
+	var export UserDataExport
+	if err := json.Unmarshal(buf.Bytes(), &export); err != nil {
+		t.Fatal(err)
+	}
+
+	if export.UserID != "user" || len(export.Tokens) != 1 || len(export.Consents) != 1 {
+		t.Fatalf("got export of %q with %d tokens and %d consents, want the token and consent of the user", export.UserID, len(export.Tokens), len(export.Consents))
+	}
+
+	token := export.Tokens[0]
+	if token.ClientID != "client" || token.ClientIP != "192.0.2.1" || token.UserAgent != "curl/8.0" {
+		t.Fatalf("got token %+v", token)
+	}
+
+	if want := []string{string(TokenKindAccess), string(TokenKindRefresh)}; !reflect.DeepEqual(token.Kinds, want) {
+		t.Fatalf("got token kinds %v, want %v", token.Kinds, want)
+	}
+
+	if consent := export.Consents[0]; consent.ClientID != "client" || !reflect.DeepEqual(consent.Scopes, []string{"read"}) {
+		t.Fatalf("got consent %+v", consent)
+	}
+
+	buf.Reset()
+	if err := stores.ExportUserData(ctx, "nobody", &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(buf.String(), `"tokens":[]`) || !strings.Contains(buf.String(), `"consents":[]`) {
+		t.Fatalf("got export %s, want empty lists for a user without data", buf.String())
+	}
+}
@@ -0,0 +1,39 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-oauth2/oauth2/v4"
+)
+
+func TestWithTokenStoreTokenFactoryRequiresFactory(t *testing.T) {
+	if err := WithTokenStoreTokenFactory(nil)(&TokenStore{}); !errors.Is(err, ErrNoTokenFactory) {
+		t.Fatalf("got error %v, want %v", err, ErrNoTokenFactory)
+	}
+}
+
+func TestTokenStoreTokenFactory(t *testing.T) {
+	store := newTestTokenStore(t, WithTokenStoreTokenFactory(func() oauth2.TokenInfo { return new(fuzzToken) }))
+	ctx := context.Background()
+
+	token := &fuzzToken{Token: *newTestToken("client", "user", "a"), Extension: map[string]string{"tenant": "acme"}}
+	if err := store.Create(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := store.GetByAccess(ctx, "access-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, ok := info.(*fuzzToken)
+	if !ok {
+		t.Fatalf("got %T, want %T", info, token)
+	}
+
+	if got.Extension["tenant"] != "acme" {
+		t.Fatalf("got extension %v, want the stored one", got.Extension)
+	}
+}
@@ -0,0 +1,105 @@
+package pgstore
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// FamilyToken is a token of a refresh token family with its lineage.
+type FamilyToken struct {
+	TokenRecord
+	FamilyID string
+	// ParentID is the ID of the token rotated into this one, or nil for the
+	// first token of the family.
+	ParentID *int64
+	// ParentKey is the parent ID of tables with UUID keys.
+	ParentKey *string
+	RotatedAt *time.Time
+}
+
+// familyCondition returns the condition matching the tokens of the family of
+// the refresh token passed as $1.
+func (s *TokenStore) familyCondition() string {
+	return fmt.Sprintf(
+		"family_id IN (SELECT family_id FROM %s WHERE %s = $1 AND family_id <> ''%s)",
+		s.table, s.columns.Refresh, s.andTenant(),
+	)
+}
+
+// GetFamily returns every token of the family of the refresh token in
+// rotation order, including the rotated and revoked ones, so the chain of a
+// refresh token can be traced. It returns ErrTokenNotFound if the refresh
+// token does not exist.
+func (s *TokenStore) GetFamily(ctx context.Context, refresh string) ([]FamilyToken, error) {
+	s.logger.Log(ctx, LogLevelDebug, "getting token family", "refresh", refresh)
+
+	rows, err := s.reader().Query(ctx, fmt.Sprintf(
+		"SELECT %s, family_id, parent_id, rotated_at FROM %s WHERE %s%s ORDER BY %s, id",
+		s.selectColumns(), s.table, s.familyCondition(), s.andTenant(), s.columns.CreatedAt,
+	), refresh)
+
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return nil, wrapDatabaseError(err)
+	}
+	defer rows.Close()
+
+	var family []FamilyToken
+	for rows.Next() {
+		var item TokenStoreItem
+		var key, parent rowKey
+		var rotatedAt *time.Time
+
+		err = rows.Scan(
+			&key, &item.Code, &item.Access, &item.Refresh, &item.ClientID, &item.UserID, &item.Data,
+			&item.CreatedAt, &item.ExpiresAt, &item.FamilyID, &parent, &rotatedAt,
+		)
+		if err != nil {
+			s.logger.Log(ctx, LogLevelError, err.Error())
+			return nil, wrapDatabaseError(err)
+		}
+
+		item.ID, item.Key = key.ID, key.Key
+		item.ParentID, item.ParentKey = parent.parentID()
+
+		info, err := s.decodeTokenInfo(item.Data)
+		if err != nil {
+			s.logger.Log(ctx, LogLevelError, err.Error())
+			return nil, err
+		}
+
+		family = append(family, FamilyToken{
+			TokenRecord: newTokenRecord(item, info),
+			FamilyID:    item.FamilyID,
+			ParentID:    item.ParentID,
+			ParentKey:   item.ParentKey,
+			RotatedAt:   rotatedAt,
+		})
+	}
+
+	if err = rows.Err(); err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return nil, wrapDatabaseError(err)
+	}
+
+	if len(family) == 0 {
+		return nil, ErrTokenNotFound
+	}
+
+	return family, nil
+}
+
+// RemoveFamily deletes, or revokes if soft revocation is enabled, every token
+// of the family of the refresh token, revoking its whole lineage, and returns
+// the number of removed tokens.
+func (s *TokenStore) RemoveFamily(ctx context.Context, refresh string) (int64, error) {
+	s.logger.Log(ctx, LogLevelDebug, "removing token family", "refresh", refresh)
+
+	if refresh == "" {
+		s.logger.Log(ctx, LogLevelWarn, "no refresh token was provided")
+		return 0, nil
+	}
+
+	return s.removeWhere(ctx, s.familyCondition(), refresh)
+}
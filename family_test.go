@@ -0,0 +1,70 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestTokenStoreFamily(t *testing.T) {
+	store := newTestTokenStore(t)
+	ctx := context.Background()
+
+	if err := store.Create(ctx, newTestToken("client", "user", "a")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.RotateRefresh(ctx, "refresh-a", newTestToken("client", "user", "b")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.RotateRefresh(ctx, "refresh-b", newTestToken("client", "user", "c")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Create(ctx, newTestToken("client", "user", "other")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Any refresh token of the family returns the whole lineage.
+	family, err := store.GetFamily(ctx, "refresh-c")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(family) != 3 {
+		t.Fatalf("got %d tokens in the family, want 3", len(family))
+	}
+
+	for i, want := range []string{"refresh-a", "refresh-b", "refresh-c"} {
+		token := family[i]
+		if token.Info.GetRefresh() != want || token.FamilyID != family[0].FamilyID {
+			t.Fatalf("got token %d %q of family %q, want %q of family %q", i, token.Info.GetRefresh(), token.FamilyID, want, family[0].FamilyID)
+		}
+
+		if i == 0 && token.ParentID != nil || i > 0 && (token.ParentID == nil || *token.ParentID != family[i-1].ID) {
+			t.Errorf("token %d: got parent %v, want the previous token", i, token.ParentID)
+		}
+
+		if rotated := token.RotatedAt != nil; rotated != (i < 2) {
+			t.Errorf("token %d: got rotated %t", i, rotated)
+		}
+	}
+
+	removed, err := store.RemoveFamily(ctx, "refresh-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if removed != 3 {
+		t.Fatalf("got %d tokens removed, want the 3 tokens of the family", removed)
+	}
+
+	if _, err = store.GetFamily(ctx, "refresh-c"); !errors.Is(err, ErrTokenNotFound) {
+		t.Fatalf("got error %v for a removed family, want %v", err, ErrTokenNotFound)
+	}
+
+	if _, err = store.GetByRefresh(ctx, "refresh-other"); err != nil {
+		t.Fatalf("got error %v for a token of another family", err)
+	}
+}
@@ -0,0 +1,92 @@
+package pgstore
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"github.com/go-oauth2/oauth2/v4/models"
+)
+
+// fuzzToken is a custom token type carrying extension fields.
+type fuzzToken struct {
+	models.Token
+	Extension map[string]string
+}
+
+// newFuzzTokenStore returns a token store decoding fuzzToken values, without
+// a connection pool.
+func newFuzzTokenStore(compression Compression) *TokenStore {
+	return &TokenStore{
+		codec:        new(JSONCodec),
+		compression:  compression,
+		newTokenInfo: func() oauth2.TokenInfo { return new(fuzzToken) },
+	}
+}
+
+func FuzzTokenDataRoundTrip(f *testing.F) {
+	f.Add("access", "read write", "user", "key", "value", 1)
+	f.Add("", "", "", "", "", 0)
+	f.Add("ÄÖÜ-令牌-🔑", "scope:ünïcödé", "ユーザー", "​", "  ", 3)
+	f.Add("a\"b\\c", "<script>", "'; DROP TABLE oauth2_tokens; --", "\t\n", "{}", 1000)
+
+	f.Fuzz(func(t *testing.T, access string, scope string, userID string, key string, value string, repeat int) {
+		for _, s := range []string{access, scope, userID, key, value} {
+			// JSON replaces invalid UTF-8 with the replacement character.
+			if !utf8.ValidString(s) {
+				t.Skip()
+			}
+		}
+
+		if repeat < 0 || repeat > 10000 {
+			t.Skip()
+		}
+
+		token := &fuzzToken{
+			Token: models.Token{
+				ClientID:        "client",
+				UserID:          userID,
+				Scope:           scope,
+				Access:          access,
+				AccessCreateAt:  time.Date(2023, 1, 2, 3, 4, 5, 6, time.UTC),
+				AccessExpiresIn: time.Hour,
+			},
+			Extension: map[string]string{key: strings.Repeat(value, repeat)},
+		}
+
+		for _, compression := range []Compression{CompressionNone, CompressionGzip} {
+			store := newFuzzTokenStore(compression)
+
+			data, err := store.encodeTokenInfo(token)
+			if err != nil {
+				t.Fatalf("encoding with %q compression: %v", compression, err)
+			}
+
+			decoded := tokenData{store: store}
+			if err = decoded.ScanBytes(data); err != nil {
+				t.Fatalf("decoding with %q compression: %v", compression, err)
+			}
+
+			if !reflect.DeepEqual(decoded.info, token) {
+				t.Fatalf("round trip with %q compression: got %#v, want %#v", compression, decoded.info, token)
+			}
+		}
+	})
+}
+
+func FuzzDecodeTokenInfo(f *testing.F) {
+	f.Add([]byte(`{"Access":"access"}`))
+	f.Add([]byte{dataFormatGzip})
+	f.Add([]byte{dataFormatGzip, 0x1f, 0x8b})
+	f.Add([]byte(`null`))
+
+	store := newFuzzTokenStore(CompressionNone)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// Arbitrary data must fail to decode without panicking.
+		_, _ = store.decodeTokenInfo(data)
+	})
+}
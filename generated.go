@@ -0,0 +1,160 @@
+package pgstore
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WithTokenStoreGeneratedColumns configures the store to derive the
+// authorization code, access token, refresh token and expiry columns from the
+// JSONB data column as generated columns, so they can never drift from the
+// stored token. It requires the default JSON codec with a token type
+// marshalling to the layout of models.Token, and cannot be combined with
+// compression, encryption or partitioning. The expiry of the tokens cannot
+// be overridden with CreateWithExpiry.
+func WithTokenStoreGeneratedColumns() TokenStoreOption {
+	return func(s *TokenStore) error {
+		s.generatedColumns = true
+		return nil
+	}
+}
+
+// validateGeneratedColumns returns ErrInvalidGeneratedColumns if generated
+// columns are configured with a data column that is not JSONB or with
+// partitioning, as generated columns cannot be partition keys.
+func (s *TokenStore) validateGeneratedColumns() error {
+	if !s.generatedColumns {
+		return nil
+	}
+
+	if s.dataColumnType() != "JSONB" || s.partitionInterval > 0 {
+		return ErrInvalidGeneratedColumns
+	}
+
+	return nil
+}
+
+// expiresAtFunction returns the name of the function computing the expiry of
+// a token from its data.
+func (s *TokenStore) expiresAtFunction() string {
+	return s.table + "_expires_at"
+}
+
+// parseTimeFunction returns the name of the function parsing a time of the
+// token data.
+func (s *TokenStore) parseTimeFunction() string {
+	return s.table + "_parse_time"
+}
+
+// generatedFunctionSQL returns the DDL of the functions computing the expiry
+// of a token from its data. The times are marshalled in RFC 3339 format and
+// the durations in nanoseconds. Generated columns require immutable
+// functions, so the times are parsed field by field instead of being cast to
+// TIMESTAMPTZ, whose result depends on the time zone and date style settings
+// of the session.
+func (s *TokenStore) generatedFunctionSQL() string {
+	if !s.generatedColumns {
+		return ""
+	}
+
+	return fmt.Sprintf(`
+		CREATE OR REPLACE FUNCTION %[1]s(t TEXT) RETURNS TIMESTAMPTZ
+		LANGUAGE SQL IMMUTABLE AS $$
+			SELECT timezone('UTC', make_timestamp(
+				substr(t, 1, 4)::INT, substr(t, 6, 2)::INT, substr(t, 9, 2)::INT,
+				substr(t, 12, 2)::INT, substr(t, 15, 2)::INT,
+				substring(t FROM '^.{17}([0-9]{2}(\.[0-9]+)?)')::DOUBLE PRECISION
+			)) - CASE
+				WHEN right(t, 1) = 'Z' THEN INTERVAL '0'
+				WHEN substr(t, length(t) - 5, 1) = '-' THEN
+					-make_interval(hours => substr(t, length(t) - 4, 2)::INT, mins => right(t, 2)::INT)
+				ELSE
+					make_interval(hours => substr(t, length(t) - 4, 2)::INT, mins => right(t, 2)::INT)
+			END
+		$$;
+
+		CREATE OR REPLACE FUNCTION %[2]s(data JSONB) RETURNS TIMESTAMPTZ
+		LANGUAGE SQL IMMUTABLE AS $$
+			SELECT CASE
+				WHEN data->>'Code' <> '' THEN
+					%[1]s(data->>'CodeCreateAt') + (data->>'CodeExpiresIn')::BIGINT / 1000 * INTERVAL '1 microsecond'
+				WHEN data->>'Refresh' <> '' THEN
+					%[1]s(data->>'RefreshCreateAt') + (data->>'RefreshExpiresIn')::BIGINT / 1000 * INTERVAL '1 microsecond'
+				ELSE
+					%[1]s(data->>'AccessCreateAt') + (data->>'AccessExpiresIn')::BIGINT / 1000 * INTERVAL '1 microsecond'
+			END
+		$$;
+`,
+		s.parseTimeFunction(), s.expiresAtFunction(),
+	)
+}
+
+// generatedAs returns the generation clause of the column holding the given
+// field of the data, or an empty string if generated columns are disabled.
+func (s *TokenStore) generatedAs(field string) string {
+	if !s.generatedColumns {
+		return ""
+	}
+
+	return fmt.Sprintf(" GENERATED ALWAYS AS (COALESCE(%s->>'%s', '')) STORED", s.columns.Data, field)
+}
+
+// generatedExpiresAt returns the generation clause of the expiry column, or an
+// empty string if generated columns are disabled.
+func (s *TokenStore) generatedExpiresAt() string {
+	if !s.generatedColumns {
+		return ""
+	}
+
+	return fmt.Sprintf(" GENERATED ALWAYS AS (%s(%s)) STORED", s.expiresAtFunction(), s.columns.Data)
+}
+
+// insertColumns returns the columns set when inserting the token and their
+// values in the same order. Generated columns are not set.
+func (s *TokenStore) insertColumns(item TokenStoreItem) ([]string, []any) {
+	columns := []string{"client_id", "user_id", "scope", "grant_type", "family_id", "parent_id", "realm", "issuer", "audience", "client_ip", "user_agent", s.columns.Data, s.columns.CreatedAt}
+	values := []any{item.ClientID, item.UserID, item.Scope, item.GrantType, item.FamilyID, s.parentValue(item), s.realm, s.issuer, item.Audience, item.ClientIP, item.UserAgent, item.Data, item.CreatedAt}
+
+	if !s.generatedColumns {
+		columns = append(columns, s.columns.Code, s.columns.Access, s.columns.Refresh, s.columns.ExpiresAt)
+		values = append(values, item.Code, item.Access, item.Refresh, item.ExpiresAt)
+	}
+
+	if s.idGenerator != nil {
+		columns = append(columns, "id")
+		values = append(values, item.ID)
+	}
+
+	if s.uuidGen != nil {
+		columns = append(columns, "id")
+		values = append(values, item.Key)
+	}
+
+	if s.hasShardKey() {
+		columns = append(columns, shardKeyColumn)
+		values = append(values, shardKey(item))
+	}
+
+	return columns, values
+}
+
+// insertSQL returns the statement inserting a token into the columns.
+func (s *TokenStore) insertSQL(columns []string) string {
+	params := make([]string, len(columns))
+	for i, column := range columns {
+		params[i] = fmt.Sprintf("$%d", i+1)
+		if column == s.columns.Data {
+			params[i] = s.encryptData(params[i])
+		}
+	}
+
+	if s.encryptionSetting != "" {
+		columns = append(columns[:len(columns):len(columns)], "key_id")
+		params = append(params, encryptionKeyIDExpr(s.encryptionSetting))
+	}
+
+	return fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES (%s)",
+		s.table, strings.Join(columns, ", "), strings.Join(params, ", "),
+	)
+}
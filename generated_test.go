@@ -0,0 +1,47 @@
+package pgstore
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+)
+
+func TestTokenStoreGeneratedFunctionSQLIsImmutable(t *testing.T) {
+	store := &TokenStore{table: DefaultTokenStoreTable, generatedColumns: true}
+
+	if sql := store.generatedFunctionSQL(); strings.Contains(sql, "::TIMESTAMPTZ") {
+		t.Fatalf("got a time zone dependent cast in %s", sql)
+	}
+}
+
+func TestTokenStoreGeneratedExpiresAt(t *testing.T) {
+	store := newTestTokenStore(t, WithTokenStoreGeneratedColumns())
+	ctx := context.Background()
+
+	if _, err := store.pool.Exec(ctx, "SET TIME ZONE 'America/New_York'"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, createdAt := range []time.Time{
+		time.Date(2023, 3, 1, 12, 30, 15, 123456000, time.UTC),
+		time.Date(2023, 3, 1, 12, 30, 15, 0, time.FixedZone("", -5*60*60-30*60)),
+		time.Date(2023, 3, 1, 12, 30, 15, 500000000, time.FixedZone("", 9*60*60)),
+	} {
+		data, err := store.codec.Marshal(&models.Token{Access: "access", AccessCreateAt: createdAt, AccessExpiresIn: time.Hour})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var expiresAt time.Time
+		if err = store.pool.QueryRow(ctx, "SELECT "+store.expiresAtFunction()+"($1::JSONB)", data).Scan(&expiresAt); err != nil {
+			t.Fatal(err)
+		}
+
+		if want := createdAt.Add(time.Hour); !expiresAt.Equal(want) {
+			t.Errorf("got expiry %s, want %s", expiresAt, want)
+		}
+	}
+}
@@ -5,6 +5,7 @@ go 1.19
 require (
 	github.com/go-oauth2/oauth2/v4 v4.5.2
 	github.com/jackc/pgx/v5 v5.3.1
+	golang.org/x/sync v0.3.0
 )
 
 require (
@@ -12,7 +13,6 @@ require (
 	github.com/jackc/pgservicefile v0.0.0-20221227161230-091c0ba34f0a // indirect
 	github.com/jackc/puddle/v2 v2.2.0 // indirect
 	github.com/stretchr/testify v1.8.2 // indirect
-	golang.org/x/crypto v0.7.0 // indirect
-	golang.org/x/sync v0.1.0 // indirect
-	golang.org/x/text v0.8.0 // indirect
+	golang.org/x/crypto v0.11.0 // indirect
+	golang.org/x/text v0.11.0 // indirect
 )
@@ -0,0 +1,90 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"github.com/jackc/pgx/v5"
+)
+
+// GrantTypeTokenInfo is implemented by the token types carrying the grant
+// type the token was issued for. The grant type of these tokens is stored in
+// its own column, so the token statistics can be grouped by grant type.
+type GrantTypeTokenInfo interface {
+	oauth2.TokenInfo
+	// GetGrantType returns the grant type the token was issued for.
+	GetGrantType() oauth2.GrantType
+}
+
+// tokenGrantType returns the grant type the token was issued for. Codes are
+// always issued for the authorization code grant; the grant type of other
+// tokens is empty if the token type does not carry one.
+func tokenGrantType(info oauth2.TokenInfo) oauth2.GrantType {
+	if info, ok := info.(GrantTypeTokenInfo); ok && info.GetGrantType() != "" {
+		return info.GetGrantType()
+	}
+
+	if info.GetCode() != "" {
+		return oauth2.AuthorizationCode
+	}
+
+	return ""
+}
+
+// SetGrantTypes replaces the grant types the client is allowed to use. The
+// grant types are stored in the grant types of the registration metadata.
+func (s *ClientStore) SetGrantTypes(ctx context.Context, clientID string, grants []oauth2.GrantType) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	s.logger.Log(ctx, LogLevelDebug, "setting client grant types", "id", clientID, "grants", grants)
+
+	grantTypes := make([]string, len(grants))
+	for i, grant := range grants {
+		grantTypes[i] = grant.String()
+	}
+
+	tag, err := s.pool.Exec(ctx, fmt.Sprintf(
+		"UPDATE %s SET grant_types = $2 WHERE id = $1%s",
+		s.table, andRealm(s.realm),
+	), clientID, grantTypes)
+
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return wrapDatabaseError(err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrClientNotFound
+	}
+
+	return nil
+}
+
+// IsGrantAllowed returns true if the client is allowed to use the grant type,
+// so the grant type policy can be enforced from the database. Clients without
+// grant types are not allowed to use any. It returns ErrClientNotFound if the
+// client does not exist.
+func (s *ClientStore) IsGrantAllowed(ctx context.Context, clientID string, grant oauth2.GrantType) (bool, error) {
+	s.logger.Log(ctx, LogLevelDebug, "checking client grant type", "id", clientID, "grant", grant)
+
+	var allowed bool
+	err := s.pool.QueryRow(ctx, fmt.Sprintf(
+		"SELECT $2 = ANY(grant_types) FROM %s WHERE id = $1%s",
+		s.table, andRealm(s.realm),
+	), clientID, grant.String()).Scan(&allowed)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, ErrClientNotFound
+	}
+
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return false, wrapDatabaseError(err)
+	}
+
+	return allowed, nil
+}
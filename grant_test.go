@@ -0,0 +1,70 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"github.com/go-oauth2/oauth2/v4/models"
+)
+
+func TestClientStoreSetGrantTypesReadOnly(t *testing.T) {
+	store, err := NewClientStore(WithClientStoreDB(unusedDB{}), WithClientStoreReadOnly(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = store.SetGrantTypes(context.Background(), "client", []oauth2.GrantType{oauth2.AuthorizationCode}); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("got error %v, want %v", err, ErrReadOnly)
+	}
+}
+
+func TestClientStoreIsGrantAllowedMissingClient(t *testing.T) {
+	store, err := NewClientStore(WithClientStoreDB(new(emptyDB)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = store.IsGrantAllowed(context.Background(), "client", oauth2.AuthorizationCode); !errors.Is(err, ErrClientNotFound) {
+		t.Fatalf("got error %v, want %v", err, ErrClientNotFound)
+	}
+}
+
+func TestClientStoreGrantTypes(t *testing.T) {
+	store := newTestClientStore(t)
+	ctx := context.Background()
+
+	if err := store.Create(&models.Client{ID: "client", Domain: "https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Clients without grant types are not allowed to use any.
+	if allowed, err := store.IsGrantAllowed(ctx, "client", oauth2.AuthorizationCode); err != nil || allowed {
+		t.Fatalf("got allowed %t and error %v for a client without grant types", allowed, err)
+	}
+
+	grants := []oauth2.GrantType{oauth2.AuthorizationCode, oauth2.Refreshing}
+	if err := store.SetGrantTypes(ctx, "client", grants); err != nil {
+		t.Fatal(err)
+	}
+
+	for grant, want := range map[oauth2.GrantType]bool{
+		oauth2.AuthorizationCode: true,
+		oauth2.Refreshing:        true,
+		oauth2.ClientCredentials: false,
+	} {
+		allowed, err := store.IsGrantAllowed(ctx, "client", grant)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if allowed != want {
+			t.Errorf("%s: got allowed %t, want %t", grant, allowed, want)
+		}
+	}
+
+	if err := store.SetGrantTypes(ctx, "missing", grants); !errors.Is(err, ErrClientNotFound) {
+		t.Fatalf("got error %v for a missing client, want %v", err, ErrClientNotFound)
+	}
+}
@@ -0,0 +1,56 @@
+package pgstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+)
+
+// Hooks are callbacks invoked around token store operations. Any of the
+// callbacks can be left nil.
+type Hooks struct {
+	// BeforeCreate is called before a token is stored. Returning an error
+	// aborts the creation and the error is returned to the caller.
+	BeforeCreate func(ctx context.Context, info oauth2.TokenInfo) error
+	// AfterCreate is called after a token was stored or failed to be stored.
+	AfterCreate func(ctx context.Context, info oauth2.TokenInfo, err error)
+	// AfterRemove is called after a token was removed or failed to be
+	// removed. The info is nil if the token could not be read.
+	AfterRemove func(ctx context.Context, info oauth2.TokenInfo, err error)
+	// AfterCleanup is called after every cleanup run with its result.
+	AfterCleanup func(ctx context.Context, result CleanupResult)
+}
+
+// CleanupResult is the outcome of a cleanup run.
+type CleanupResult struct {
+	Removed  int64         // number of removed tokens
+	Duration time.Duration // time spent cleaning up
+	Err      error         // error stopping the cleanup, if any
+}
+
+func (h *Hooks) beforeCreate(ctx context.Context, info oauth2.TokenInfo) error {
+	if h.BeforeCreate == nil {
+		return nil
+	}
+
+	return h.BeforeCreate(ctx, info)
+}
+
+func (h *Hooks) afterCreate(ctx context.Context, info oauth2.TokenInfo, err error) {
+	if h.AfterCreate != nil {
+		h.AfterCreate(ctx, info, err)
+	}
+}
+
+func (h *Hooks) afterRemove(ctx context.Context, info oauth2.TokenInfo, err error) {
+	if h.AfterRemove != nil {
+		h.AfterRemove(ctx, info, err)
+	}
+}
+
+func (h *Hooks) afterCleanup(ctx context.Context, result CleanupResult) {
+	if h.AfterCleanup != nil {
+		h.AfterCleanup(ctx, result)
+	}
+}
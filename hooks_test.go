@@ -0,0 +1,36 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fixedClock is a clock always returning the same time.
+type fixedClock struct {
+	now time.Time
+}
+
+func (c *fixedClock) Now() time.Time { return c.now }
+
+func TestTokenStoreReportCleanupCallsAfterCleanup(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	cleanupErr := errors.New("cleanup failed")
+
+	var results []CleanupResult
+	store := &TokenStore{
+		logger: new(NoopLogger),
+		clock:  &fixedClock{now: start.Add(time.Second)},
+		hooks: Hooks{AfterCleanup: func(_ context.Context, result CleanupResult) {
+			results = append(results, result)
+		}},
+	}
+
+	store.reportCleanup(context.Background(), "cleaning expired tokens", start, 3, cleanupErr)
+
+	want := CleanupResult{Removed: 3, Duration: time.Second, Err: cleanupErr}
+	if len(results) != 1 || results[0] != want {
+		t.Fatalf("got results %+v, want %+v", results, want)
+	}
+}
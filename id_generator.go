@@ -0,0 +1,236 @@
+package pgstore
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+const (
+	// snowflakeNodeBits is the number of bits of the node of a snowflake ID.
+	snowflakeNodeBits = 10
+	// snowflakeSequenceBits is the number of bits of the sequence of a
+	// snowflake ID.
+	snowflakeSequenceBits = 12
+	// MaxSnowflakeNode is the largest node of a SnowflakeGenerator.
+	MaxSnowflakeNode = 1<<snowflakeNodeBits - 1
+)
+
+// snowflakeEpoch is the epoch of the snowflake IDs.
+var snowflakeEpoch = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// IDGenerator generates the IDs of the token rows in the application instead
+// of the database sequence.
+type IDGenerator interface {
+	// NewID returns a new unique ID.
+	NewID() int64
+}
+
+// WithTokenStoreIDGenerator configures the store to generate the IDs of the
+// token rows with the generator instead of a BIGSERIAL sequence, which avoids
+// the contention on the sequence and keeps the IDs unique across shards and
+// databases. The IDs must fit in a BIGINT; 128-bit identifiers such as UUIDv7
+// or ULID are generated by a UUIDGenerator for tables with UUID keys. Existing
+// tables keep their sequence as the
+// default of the ID column, which is not used anymore.
+func WithTokenStoreIDGenerator(generator IDGenerator) TokenStoreOption {
+	return func(s *TokenStore) error {
+		if generator == nil {
+			return ErrNoIDGenerator
+		}
+
+		s.idGenerator = generator
+
+		return nil
+	}
+}
+
+// idColumnType returns the type of the ID column of the token table.
+func (s *TokenStore) idColumnType() string {
+	if s.uuidKeys != 0 {
+		return "UUID DEFAULT " + s.uuidDefault()
+	}
+
+	if s.idGenerator != nil {
+		return "BIGINT"
+	}
+
+	if s.identityColumns {
+		return identityColumnType
+	}
+
+	return "BIGSERIAL"
+}
+
+// hasIDSequence returns true if the database generates the BIGINT IDs of the
+// token table from a sequence.
+func (s *TokenStore) hasIDSequence() bool {
+	return s.idGenerator == nil && s.uuidKeys == 0
+}
+
+// newID returns the ID of a new token row, or zero if the database assigns
+// it.
+func (s *TokenStore) newID() int64 {
+	if s.idGenerator == nil {
+		return 0
+	}
+
+	return s.idGenerator.NewID()
+}
+
+// UUIDGenerator generates the 128-bit keys of the token rows of tables with
+// UUID keys in the application instead of the database.
+type UUIDGenerator interface {
+	// NewUUID returns a new unique 128-bit key.
+	NewUUID() [16]byte
+}
+
+// WithTokenStoreUUIDGenerator configures the store to generate the UUID keys
+// of the token rows with the generator instead of the default of the ID
+// column, so UUIDv7 keys can be used before PostgreSQL 18, or ULIDs can be
+// stored in the UUID column. It requires WithTokenStoreUUIDKeys.
+func WithTokenStoreUUIDGenerator(generator UUIDGenerator) TokenStoreOption {
+	return func(s *TokenStore) error {
+		if generator == nil {
+			return ErrNoIDGenerator
+		}
+
+		s.uuidGen = generator
+
+		return nil
+	}
+}
+
+// newKey returns the UUID key of a new token row, or an empty string if the
+// database assigns it.
+func (s *TokenStore) newKey() string {
+	if s.uuidGen == nil {
+		return ""
+	}
+
+	id := s.uuidGen.NewUUID()
+	b := make([]byte, 36)
+
+	hex.Encode(b[0:8], id[0:4])
+	hex.Encode(b[9:13], id[4:6])
+	hex.Encode(b[14:18], id[6:8])
+	hex.Encode(b[19:23], id[8:10])
+	hex.Encode(b[24:], id[10:])
+	b[8], b[13], b[18], b[23] = '-', '-', '-', '-'
+
+	return string(b)
+}
+
+// monotonicGenerator generates 128-bit keys made of the milliseconds since the
+// Unix epoch followed by random bits, split into a high and a low part. The
+// random bits of the keys of the same millisecond are incremented instead, so
+// the keys of a generator keep increasing.
+type monotonicGenerator struct {
+	hiBits uint
+	loBits uint
+	layout func(ms, hi, lo uint64) [16]byte
+
+	mu sync.Mutex
+	ms uint64
+	hi uint64
+	lo uint64
+}
+
+// NewUUIDv7Generator creates a generator of time-ordered version 7 UUIDs.
+func NewUUIDv7Generator() UUIDGenerator {
+	return &monotonicGenerator{hiBits: 12, loBits: 62, layout: func(ms, hi, lo uint64) [16]byte {
+		var id [16]byte
+		binary.BigEndian.PutUint64(id[:8], ms<<16|0x7000|hi)
+		binary.BigEndian.PutUint64(id[8:], 1<<63|lo)
+
+		return id
+	}}
+}
+
+// NewULIDGenerator creates a generator of monotonic ULIDs, stored as the 16
+// bytes of the UUID key.
+func NewULIDGenerator() UUIDGenerator {
+	return &monotonicGenerator{hiBits: 16, loBits: 64, layout: func(ms, hi, lo uint64) [16]byte {
+		var id [16]byte
+		binary.BigEndian.PutUint64(id[:8], ms<<16|hi)
+		binary.BigEndian.PutUint64(id[8:], lo)
+
+		return id
+	}}
+}
+
+// NewUUID returns a new key. The random bits overflowing borrow the following
+// millisecond. It panics if the random bits cannot be read.
+func (g *monotonicGenerator) NewUUID() [16]byte {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if now := uint64(time.Now().UnixMilli()); now > g.ms {
+		var b [16]byte
+		if _, err := rand.Read(b[:]); err != nil {
+			panic(err)
+		}
+
+		g.ms = now
+		g.hi = binary.BigEndian.Uint64(b[:8]) & (1<<g.hiBits - 1)
+		g.lo = binary.BigEndian.Uint64(b[8:]) & (1<<g.loBits - 1)
+	} else {
+		g.lo = (g.lo + 1) & (1<<g.loBits - 1)
+		if g.lo == 0 {
+			g.hi = (g.hi + 1) & (1<<g.hiBits - 1)
+			if g.hi == 0 {
+				g.ms++
+			}
+		}
+	}
+
+	return g.layout(g.ms, g.hi, g.lo)
+}
+
+// SnowflakeGenerator generates time-ordered snowflake IDs made of the
+// milliseconds since 2023, the node and a sequence number, so the generators
+// of up to 1024 nodes generate unique IDs without coordination.
+type SnowflakeGenerator struct {
+	mu       sync.Mutex
+	node     int64
+	last     int64
+	sequence int64
+}
+
+// NewSnowflakeGenerator creates a snowflake ID generator for the node, which
+// must be unique among the nodes generating IDs for the same table.
+func NewSnowflakeGenerator(node int64) (*SnowflakeGenerator, error) {
+	if node < 0 || node > MaxSnowflakeNode {
+		return nil, ErrInvalidSnowflakeNode
+	}
+
+	return &SnowflakeGenerator{node: node}, nil
+}
+
+// NewID returns a new snowflake ID. The IDs keep increasing if the clock goes
+// backwards or the sequence of a millisecond is exhausted, by borrowing the
+// following milliseconds.
+func (g *SnowflakeGenerator) NewID() int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Since(snowflakeEpoch).Milliseconds()
+	if now < g.last {
+		now = g.last
+	}
+
+	if now == g.last {
+		g.sequence = (g.sequence + 1) & (1<<snowflakeSequenceBits - 1)
+		if g.sequence == 0 {
+			now++
+		}
+	} else {
+		g.sequence = 0
+	}
+
+	g.last = now
+
+	return now<<(snowflakeNodeBits+snowflakeSequenceBits) | g.node<<snowflakeSequenceBits | g.sequence
+}
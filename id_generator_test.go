@@ -0,0 +1,135 @@
+package pgstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSnowflakeGeneratorMonotonic(t *testing.T) {
+	g, err := NewSnowflakeGenerator(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	last := g.NewID()
+	for i := 0; i < 10000; i++ {
+		id := g.NewID()
+		if id <= last {
+			t.Fatalf("got id %d after %d, want increasing ids", id, last)
+		}
+
+		if node := id >> snowflakeSequenceBits & MaxSnowflakeNode; node != 3 {
+			t.Fatalf("got node %d, want 3", node)
+		}
+
+		last = id
+	}
+}
+
+func TestSnowflakeGeneratorSequenceRollover(t *testing.T) {
+	g, err := NewSnowflakeGenerator(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The clock is behind the last ID and the sequence is exhausted.
+	future := time.Since(snowflakeEpoch).Milliseconds() + time.Hour.Milliseconds()
+	g.last = future
+	g.sequence = 1<<snowflakeSequenceBits - 1
+
+	id := g.NewID()
+
+	if ms := id >> (snowflakeNodeBits + snowflakeSequenceBits); ms != future+1 {
+		t.Fatalf("got millisecond %d, want %d", ms, future+1)
+	}
+
+	if sequence := id & (1<<snowflakeSequenceBits - 1); sequence != 0 {
+		t.Fatalf("got sequence %d, want 0", sequence)
+	}
+}
+
+func TestUUIDGenerators(t *testing.T) {
+	for name, newGenerator := range map[string]func() UUIDGenerator{
+		"uuidv7": NewUUIDv7Generator,
+		"ulid":   NewULIDGenerator,
+	} {
+		t.Run(name, func(t *testing.T) {
+			g := newGenerator()
+
+			last := g.NewUUID()
+			for i := 0; i < 10000; i++ {
+				id := g.NewUUID()
+				if bytes.Compare(id[:], last[:]) <= 0 {
+					t.Fatalf("got key %x after %x, want increasing keys", id, last)
+				}
+
+				if name == "uuidv7" && (id[6]>>4 != 7 || id[8]>>6 != 2) {
+					t.Fatalf("got key %x, want version 7 and variant bits", id)
+				}
+
+				last = id
+			}
+		})
+	}
+}
+
+func TestUUIDGeneratorOverflow(t *testing.T) {
+	g := NewUUIDv7Generator().(*monotonicGenerator)
+
+	// The random bits of the millisecond are exhausted.
+	ms := uint64(time.Now().Add(time.Hour).UnixMilli())
+	g.ms, g.hi, g.lo = ms, 1<<g.hiBits-1, 1<<g.loBits-1
+	last := g.layout(g.ms, g.hi, g.lo)
+
+	id := g.NewUUID()
+	if bytes.Compare(id[:], last[:]) <= 0 || g.ms != ms+1 {
+		t.Fatalf("got key %x after %x, want the following millisecond", id, last)
+	}
+}
+
+func TestTokenStoreNewKey(t *testing.T) {
+	store := &TokenStore{uuidGen: fixedUUIDGenerator{0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef, 0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef}}
+
+	if got, want := store.newKey(), "01234567-89ab-cdef-0123-456789abcdef"; got != want {
+		t.Fatalf("got key %q, want %q", got, want)
+	}
+
+	if got := new(TokenStore).newKey(); got != "" {
+		t.Fatalf("got key %q without a generator, want none", got)
+	}
+}
+
+func TestWithTokenStoreUUIDGeneratorRequiresUUIDKeys(t *testing.T) {
+	_, err := NewTokenStore(WithTokenStoreDB(unusedDB{}), WithTokenStoreUUIDGenerator(NewULIDGenerator()))
+	if !errors.Is(err, ErrInvalidUUIDKeys) {
+		t.Fatalf("got error %v, want %v", err, ErrInvalidUUIDKeys)
+	}
+}
+
+func TestTokenStoreUUIDGenerator(t *testing.T) {
+	store := newTestTokenStore(t, WithTokenStoreUUIDKeys(UUIDv4), WithTokenStoreUUIDGenerator(NewUUIDv7Generator()))
+	ctx := context.Background()
+
+	if err := store.Create(ctx, newTestToken("client", "user", "a")); err != nil {
+		t.Fatal(err)
+	}
+
+	var version int
+	if err := store.pool.QueryRow(ctx, "SELECT get_byte(uuid_send(id), 6) >> 4 FROM "+store.table).Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+
+	if version != 7 {
+		t.Fatalf("got key version %d, want 7", version)
+	}
+}
+
+// fixedUUIDGenerator generates the same key.
+type fixedUUIDGenerator [16]byte
+
+func (g fixedUUIDGenerator) NewUUID() [16]byte {
+	return g
+}
@@ -0,0 +1,49 @@
+package pgstore
+
+// identityColumnType is the type of the ID columns generated as identity
+// columns.
+const identityColumnType = "BIGINT GENERATED ALWAYS AS IDENTITY"
+
+// WithTokenStoreIdentityColumns configures InitTable to create the ID column
+// of the token table as an identity column instead of a BIGSERIAL, for
+// deployments banning serial types by policy. Partitioned and sharded token
+// tables with identity columns require PostgreSQL 17. Existing tables are not
+// converted. Identity columns cannot be combined with an ID generator or UUID
+// keys.
+func WithTokenStoreIdentityColumns() TokenStoreOption {
+	return func(s *TokenStore) error {
+		s.identityColumns = true
+		return nil
+	}
+}
+
+// WithClientStoreIdentityColumns configures InitTable to create the ID column
+// of the client secret table as an identity column instead of a BIGSERIAL.
+// Existing tables are not converted.
+func WithClientStoreIdentityColumns() ClientStoreOption {
+	return func(s *ClientStore) error {
+		s.identityColumns = true
+		return nil
+	}
+}
+
+// WithStoresIdentityColumns configures InitTable to create the ID columns of
+// the token and client secret tables as identity columns.
+func WithStoresIdentityColumns() StoresOption {
+	return func(c *storesConfig) error {
+		c.tokenOpts = append(c.tokenOpts, WithTokenStoreIdentityColumns())
+		c.clientOpts = append(c.clientOpts, WithClientStoreIdentityColumns())
+
+		return nil
+	}
+}
+
+// secretIDColumnType returns the type of the ID column of the client secret
+// table.
+func (s *ClientStore) secretIDColumnType() string {
+	if s.identityColumns {
+		return identityColumnType
+	}
+
+	return "BIGSERIAL"
+}
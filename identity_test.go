@@ -0,0 +1,69 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWithTokenStoreIdentityColumnsIncompatibleOptions(t *testing.T) {
+	snowflake, err := NewSnowflakeGenerator(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, opt := range map[string]TokenStoreOption{
+		"id generator": WithTokenStoreIDGenerator(snowflake),
+		"uuid keys":    WithTokenStoreUUIDKeys(UUIDv4),
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewTokenStore(WithTokenStoreDB(unusedDB{}), WithTokenStoreIdentityColumns(), opt)
+			if !errors.Is(err, ErrInvalidIdentityColumns) {
+				t.Fatalf("got error %v, want %v", err, ErrInvalidIdentityColumns)
+			}
+		})
+	}
+}
+
+func TestIdentityColumnsDDL(t *testing.T) {
+	tokens, err := NewTokenStore(WithTokenStoreDB(unusedDB{}), WithTokenStoreIdentityColumns())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clients, err := NewClientStore(WithClientStoreDB(unusedDB{}), WithClientStoreIdentityColumns())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, ddl := range map[string]string{"token": tokens.InitTableSQL(), "client": clients.InitTableSQL()} {
+		if !strings.Contains(ddl, identityColumnType) || strings.Contains(ddl, "BIGSERIAL") {
+			t.Errorf("got %s DDL without identity columns:\n%s", name, ddl)
+		}
+	}
+
+	if !tokens.hasIDSequence() {
+		t.Error("got no ID sequence for identity columns")
+	}
+}
+
+func TestTokenStoreIdentityColumns(t *testing.T) {
+	store := newTestTokenStore(t, WithTokenStoreIdentityColumns())
+	ctx := context.Background()
+
+	for _, suffix := range []string{"a", "b"} {
+		if err := store.Create(ctx, newTestToken("client", "user", suffix)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	page, err := store.ListTokens(ctx, TokenFilter{UserID: "user"}, nil, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(page.Tokens) != 2 || page.Tokens[0].ID == 0 || page.Tokens[0].ID == page.Tokens[1].ID {
+		t.Fatalf("got tokens %+v, want two tokens with identity IDs", page.Tokens)
+	}
+}
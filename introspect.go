@@ -0,0 +1,105 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+)
+
+// Token type hints of the introspection result.
+const (
+	TokenTypeAccess  = "access_token"  // access token
+	TokenTypeRefresh = "refresh_token" // refresh token
+)
+
+// IntrospectionResult is the token introspection response defined by RFC
+// 7662. Only the active field is set for inactive tokens.
+type IntrospectionResult struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+}
+
+// introspectQuery is the lookup of the introspected token of a type.
+type introspectQuery struct {
+	tokenType string
+	where     string
+}
+
+// introspectQueries returns the lookups of the introspected token by type.
+// Revoked tokens and rotated refresh tokens are not found.
+func (s *TokenStore) introspectQueries() []introspectQuery {
+	return []introspectQuery{
+		{TokenTypeAccess, s.columns.Access + " = $1 AND revoked_at IS NULL"},
+		{TokenTypeRefresh, s.columns.Refresh + " = $1 AND rotated_at IS NULL AND revoked_at IS NULL"},
+	}
+}
+
+// Introspect looks up the token as an access token, then as a refresh token,
+// and returns its introspection result. Unknown, expired and revoked tokens
+// are reported as inactive without an error. The token is read from the
+// primary connection pool, so a token revoked or rotated by another instance
+// is not reported as active by a lagging replica.
+func (s *TokenStore) Introspect(ctx context.Context, token string) (*IntrospectionResult, error) {
+	s.logger.Log(ctx, LogLevelDebug, "introspecting token")
+
+	if token == "" {
+		return &IntrospectionResult{}, nil
+	}
+
+	for _, q := range s.introspectQueries() {
+		row := s.pool.QueryRow(ctx, fmt.Sprintf("SELECT %s FROM %s WHERE %s%s", s.selectColumns(), s.table, q.where, s.andTenant()), token)
+
+		_, info, err := s.scanToTokenStoreItem(ctx, row)
+		if errors.Is(err, ErrTokenNotFound) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		return s.introspectionResult(info, q.tokenType), nil
+	}
+
+	return &IntrospectionResult{}, nil
+}
+
+// introspectionResult returns the introspection result of the token of the
+// given type.
+func (s *TokenStore) introspectionResult(info oauth2.TokenInfo, tokenType string) *IntrospectionResult {
+	issuedAt, expiresIn := info.GetAccessCreateAt(), info.GetAccessExpiresIn()
+	if tokenType == TokenTypeRefresh {
+		issuedAt, expiresIn = info.GetRefreshCreateAt(), info.GetRefreshExpiresIn()
+	}
+
+	var expiresAt time.Time
+	if expiresIn > 0 {
+		expiresAt = issuedAt.Add(expiresIn)
+		if !expiresAt.After(s.clock.Now()) {
+			return &IntrospectionResult{}
+		}
+	}
+
+	result := &IntrospectionResult{
+		Active:    true,
+		Scope:     info.GetScope(),
+		ClientID:  info.GetClientID(),
+		Subject:   info.GetUserID(),
+		TokenType: tokenType,
+		IssuedAt:  issuedAt.Unix(),
+	}
+
+	if !expiresAt.IsZero() {
+		result.ExpiresAt = expiresAt.Unix()
+	}
+
+	return result
+}
@@ -0,0 +1,25 @@
+package pgstore
+
+import (
+	"context"
+	"testing"
+)
+
+func TestTokenStoreIntrospectReadsPrimary(t *testing.T) {
+	store := &TokenStore{
+		pool:     new(emptyDB),
+		readPool: unusedDB{},
+		columns:  DefaultColumnMap,
+		logger:   new(NoopLogger),
+		clock:    new(SystemClock),
+	}
+
+	result, err := store.Introspect(context.Background(), "access")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Active {
+		t.Fatal("unknown token reported as active")
+	}
+}
@@ -0,0 +1,73 @@
+package pgstore
+
+import "strings"
+
+// WithTokenStoreIssuer configures the issuer of the store. Every token is
+// stored with the issuer identifier and only the tokens of the issuer are read
+// and removed, so the tokens of issuers sharing a database, such as staging
+// and production, can never be confused. Unlike the realm, the issuer is kept
+// apart from the client tables. The cleanup removes the expired tokens of
+// every issuer.
+func WithTokenStoreIssuer(issuer string) TokenStoreOption {
+	return func(s *TokenStore) error {
+		if !realmPattern.MatchString(issuer) {
+			return ErrInvalidIssuer
+		}
+
+		s.issuer = issuer
+
+		return nil
+	}
+}
+
+// WithStoresIssuer configures the issuer of the token store.
+func WithStoresIssuer(issuer string) StoresOption {
+	return func(c *storesConfig) error {
+		if !realmPattern.MatchString(issuer) {
+			return ErrInvalidIssuer
+		}
+
+		c.tokenOpts = append(c.tokenOpts, WithTokenStoreIssuer(issuer))
+
+		return nil
+	}
+}
+
+// tenantConditions returns the conditions matching the tokens of the realm and
+// issuer of the store. The issuer is validated like the realm, so it is safe
+// to inline it as a literal.
+func (s *TokenStore) tenantConditions() []string {
+	var conds []string
+
+	if s.realm != "" {
+		conds = append(conds, realmCondition(s.realm))
+	}
+
+	if s.issuer != "" {
+		conds = append(conds, "issuer = '"+strings.ReplaceAll(s.issuer, "'", "''")+"'")
+	}
+
+	return conds
+}
+
+// andTenant returns the realm and issuer conditions appended to other
+// conditions.
+func (s *TokenStore) andTenant() string {
+	conds := s.tenantConditions()
+	if len(conds) == 0 {
+		return ""
+	}
+
+	return " AND " + strings.Join(conds, " AND ")
+}
+
+// whereTenant returns the realm and issuer conditions as the only conditions
+// of a query.
+func (s *TokenStore) whereTenant() string {
+	conds := s.tenantConditions()
+	if len(conds) == 0 {
+		return ""
+	}
+
+	return " WHERE " + strings.Join(conds, " AND ")
+}
@@ -0,0 +1,88 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestWithTokenStoreIssuerInvalid(t *testing.T) {
+	for _, issuer := range []string{"", "issuer'--", "two words"} {
+		if err := WithTokenStoreIssuer(issuer)(&TokenStore{}); !errors.Is(err, ErrInvalidIssuer) {
+			t.Errorf("%q: got error %v, want %v", issuer, err, ErrInvalidIssuer)
+		}
+
+		if err := WithStoresIssuer(issuer)(&storesConfig{}); !errors.Is(err, ErrInvalidIssuer) {
+			t.Errorf("%q: got error %v for the stores, want %v", issuer, err, ErrInvalidIssuer)
+		}
+	}
+}
+
+func TestTokenStoreTenantConditions(t *testing.T) {
+	store := &TokenStore{}
+	if store.andTenant() != "" || store.whereTenant() != "" {
+		t.Fatalf("got conditions %q and %q without realm and issuer", store.andTenant(), store.whereTenant())
+	}
+
+	store = &TokenStore{realm: "realm", issuer: "https://issuer.example.com"}
+
+	if got, want := store.andTenant(), " AND realm = 'realm' AND issuer = 'https://issuer.example.com'"; got != want {
+		t.Fatalf("got condition %q, want %q", got, want)
+	}
+
+	if got, want := store.whereTenant(), " WHERE realm = 'realm' AND issuer = 'https://issuer.example.com'"; got != want {
+		t.Fatalf("got condition %q, want %q", got, want)
+	}
+}
+
+func TestTokenStoreIssuerIsolation(t *testing.T) {
+	dsn := newTestDSN(t)
+	ctx := context.Background()
+
+	stores := make(map[string]*TokenStore)
+	for _, issuer := range []string{"staging", "production"} {
+		store, err := NewTokenStore(WithTokenStoreDSN(dsn), WithTokenStoreIssuer(issuer))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		t.Cleanup(func() { store.Close(ctx) })
+
+		if err = store.InitTable(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		stores[issuer] = store
+	}
+
+	if err := stores["staging"].Create(ctx, newTestToken("client", "user", "a")); err != nil {
+		t.Fatal(err)
+	}
+
+	other := stores["production"]
+
+	if _, err := other.GetByAccess(ctx, "access-a"); !errors.Is(err, ErrTokenNotFound) {
+		t.Fatalf("GetByAccess: got error %v, want %v", err, ErrTokenNotFound)
+	}
+
+	if _, err := other.GetByRefresh(ctx, "refresh-a"); !errors.Is(err, ErrTokenNotFound) {
+		t.Fatalf("GetByRefresh: got error %v, want %v", err, ErrTokenNotFound)
+	}
+
+	if err := other.RemoveByAccess(ctx, "access-a"); err != nil {
+		t.Fatal(err)
+	}
+
+	var issuer string
+	if err := other.pool.QueryRow(ctx, "SELECT issuer FROM "+other.table+" WHERE access_token = 'access-a'").Scan(&issuer); err != nil {
+		t.Fatalf("got error %v for a token removed by another issuer", err)
+	}
+
+	if issuer != "staging" {
+		t.Fatalf("got issuer %q, want %q", issuer, "staging")
+	}
+
+	if _, err := stores["staging"].GetByAccess(ctx, "access-a"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,260 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// DefaultJTIStoreTable is the default table for storing the identifiers
+	// of JWT access tokens.
+	DefaultJTIStoreTable = "oauth2_jtis"
+)
+
+// JTIStoreOption is a function that configures the JTIStore.
+type JTIStoreOption func(*JTIStore) error
+
+// WithJTIStoreTable configures the jti table.
+func WithJTIStoreTable(table string) JTIStoreOption {
+	return func(s *JTIStore) error {
+		if table == "" {
+			return ErrNoTable
+		}
+
+		s.table = table
+
+		return nil
+	}
+}
+
+// WithJTIStoreTablePrefix configures the prefix added to the name of the
+// table of the store and, as a consequence, to the name of every index.
+func WithJTIStoreTablePrefix(prefix string) JTIStoreOption {
+	return func(s *JTIStore) error {
+		s.tablePrefix = prefix
+		return nil
+	}
+}
+
+// WithJTIStoreDB configures the database the store runs its queries on,
+// for example a pgx v4 pool adapted by the pgxv4 module.
+func WithJTIStoreDB(db DB) JTIStoreOption {
+	return func(s *JTIStore) error {
+		if db == nil {
+			return ErrNoConnPool
+		}
+
+		s.pool = db
+
+		return nil
+	}
+}
+
+// WithJTIStoreConnPool configures the connection pool.
+func WithJTIStoreConnPool(pool *pgxpool.Pool) JTIStoreOption {
+	return func(s *JTIStore) error {
+		if pool == nil {
+			return ErrNoConnPool
+		}
+
+		s.pool = pool
+
+		return nil
+	}
+}
+
+// WithJTIStoreLogger configures the logger.
+func WithJTIStoreLogger(logger Logger) JTIStoreOption {
+	return func(s *JTIStore) error {
+		if logger == nil {
+			return ErrNoLogger
+		}
+
+		s.logger = logger
+
+		return nil
+	}
+}
+
+// WithJTIStoreClock configures the clock used for revocation times and expiry
+// checks.
+func WithJTIStoreClock(clock Clock) JTIStoreOption {
+	return func(s *JTIStore) error {
+		if clock == nil {
+			return ErrNoClock
+		}
+
+		s.clock = clock
+
+		return nil
+	}
+}
+
+// JTIStore is a data struct that stores the identifiers of stateless JWT
+// access tokens. Only the jti, the subject, the client, the expiry and the
+// revocation status are persisted, as the token itself carries the rest.
+type JTIStore struct {
+	pool        DB
+	table       string
+	tablePrefix string
+	logger      Logger
+	clock       Clock
+	readOnly    bool
+}
+
+// InitTableSQL returns the DDL statements executed by InitTable without
+// executing them, so schema changes can be reviewed and applied separately.
+func (s *JTIStore) InitTableSQL() string {
+	return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %[1]s (
+			jti        TEXT        PRIMARY KEY NOT NULL,
+			subject    TEXT        NOT NULL DEFAULT '',
+			client_id  TEXT        NOT NULL DEFAULT '',
+			expires_at TIMESTAMPTZ NOT NULL,
+			revoked_at TIMESTAMPTZ
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_%[2]s_expires_idx ON %[1]s (expires_at);`,
+		s.table, unqualifiedName(s.table),
+	)
+}
+
+// InitTable initializes the jti store table if it does not exist and creates
+// the indexes.
+func (s *JTIStore) InitTable(ctx context.Context) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	s.logger.Log(ctx, LogLevelDebug, "initializing jti store table", "table", s.table)
+
+	if _, err := s.pool.Exec(ctx, s.InitTableSQL()); err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return wrapDatabaseError(err)
+	}
+
+	return nil
+}
+
+// Create stores the jti of the JWT access token issued for the token
+// information.
+func (s *JTIStore) Create(ctx context.Context, jti string, info oauth2.TokenInfo) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	s.logger.Log(ctx, LogLevelDebug, "creating jti", "jti", jti)
+
+	expiresAt := info.GetAccessCreateAt().Add(info.GetAccessExpiresIn())
+
+	_, err := s.pool.Exec(ctx, fmt.Sprintf(
+		"INSERT INTO %s (jti, subject, client_id, expires_at) VALUES ($1, $2, $3, $4)",
+		s.table,
+	), jti, info.GetUserID(), info.GetClientID(), expiresAt)
+
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return wrapDatabaseError(err)
+	}
+
+	return nil
+}
+
+// Revoke marks the jti as revoked. Revoking an already revoked jti keeps the
+// original revocation time.
+func (s *JTIStore) Revoke(ctx context.Context, jti string) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	s.logger.Log(ctx, LogLevelDebug, "revoking jti", "jti", jti)
+
+	tag, err := s.pool.Exec(ctx, fmt.Sprintf(
+		"UPDATE %s SET revoked_at = COALESCE(revoked_at, $2) WHERE jti = $1",
+		s.table,
+	), jti, s.clock.Now())
+
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return wrapDatabaseError(err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrJTINotFound
+	}
+
+	s.logger.Log(ctx, LogLevelInfo, "jti revoked", "jti", jti)
+
+	return nil
+}
+
+// IsRevoked returns true if the jti was revoked. It returns ErrJTINotFound if
+// the jti is unknown, which callers should treat as a rejected token.
+func (s *JTIStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	s.logger.Log(ctx, LogLevelDebug, "checking jti revocation", "jti", jti)
+
+	var revoked bool
+	err := s.pool.QueryRow(ctx, fmt.Sprintf(
+		"SELECT revoked_at IS NOT NULL FROM %s WHERE jti = $1",
+		s.table,
+	), jti).Scan(&revoked)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, ErrJTINotFound
+	}
+
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return false, wrapDatabaseError(err)
+	}
+
+	return revoked, nil
+}
+
+// RemoveExpired deletes the jti of the expired tokens and returns the number
+// of removed rows.
+func (s *JTIStore) RemoveExpired(ctx context.Context) (int64, error) {
+	if s.readOnly {
+		return 0, ErrReadOnly
+	}
+
+	tag, err := s.pool.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE expires_at <= $1", s.table), s.clock.Now())
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return 0, wrapDatabaseError(err)
+	}
+
+	s.logger.Log(ctx, LogLevelDebug, "removed expired jti", "removed", tag.RowsAffected())
+
+	return tag.RowsAffected(), nil
+}
+
+// NewJTIStore creates a new JTIStore.
+func NewJTIStore(opts ...JTIStoreOption) (*JTIStore, error) {
+	s := &JTIStore{
+		table:  DefaultJTIStoreTable,
+		logger: new(NoopLogger),
+		clock:  new(SystemClock),
+	}
+
+	for _, o := range opts {
+		if err := o(s); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.tablePrefix != "" {
+		s.table = prefixedName(s.tablePrefix, s.table)
+	}
+
+	if s.pool == nil {
+		return nil, ErrNoConnPool
+	}
+
+	return s, nil
+}
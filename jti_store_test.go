@@ -0,0 +1,83 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestNewJTIStoreRequiresConnPool(t *testing.T) {
+	if _, err := NewJTIStore(); !errors.Is(err, ErrNoConnPool) {
+		t.Fatalf("got error %v, want %v", err, ErrNoConnPool)
+	}
+}
+
+func TestJTIStore(t *testing.T) {
+	ctx := context.Background()
+
+	pool, err := pgxpool.New(ctx, newTestDSN(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(pool.Close)
+
+	clock := &fixedClock{now: time.Now()}
+
+	store, err := NewJTIStore(WithJTIStoreConnPool(pool), WithJTIStoreClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = store.InitTable(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = store.Create(ctx, "jti", newTestToken("client", "user", "a")); err != nil {
+		t.Fatal(err)
+	}
+
+	if revoked, err := store.IsRevoked(ctx, "jti"); err != nil || revoked {
+		t.Fatalf("got revoked %t and error %v for a new jti", revoked, err)
+	}
+
+	if err = store.Revoke(ctx, "jti"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Revoking twice keeps the jti revoked.
+	if err = store.Revoke(ctx, "jti"); err != nil {
+		t.Fatal(err)
+	}
+
+	if revoked, err := store.IsRevoked(ctx, "jti"); err != nil || !revoked {
+		t.Fatalf("got revoked %t and error %v for a revoked jti", revoked, err)
+	}
+
+	for name, err := range map[string]error{
+		"Revoke": store.Revoke(ctx, "missing"),
+		"IsRevoked": func() error {
+			_, err := store.IsRevoked(ctx, "missing")
+			return err
+		}(),
+	} {
+		if !errors.Is(err, ErrJTINotFound) {
+			t.Errorf("%s: got error %v, want %v", name, err, ErrJTINotFound)
+		}
+	}
+
+	// The access token of the test token expires in an hour.
+	clock.now = clock.now.Add(2 * time.Hour)
+
+	removed, err := store.RemoveExpired(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if removed != 1 {
+		t.Fatalf("got %d jti removed, want 1", removed)
+	}
+}
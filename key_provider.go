@@ -0,0 +1,367 @@
+package pgstore
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Key is an encryption key with its ID.
+type Key struct {
+	ID       string
+	Material []byte
+}
+
+// KeyProvider provides the keys used by the at-rest encryption. Keys are
+// versioned by their ID, so data encrypted with an earlier key can be
+// re-encrypted with the current one.
+type KeyProvider interface {
+	// CurrentKey returns the key new data is encrypted with.
+	CurrentKey(ctx context.Context) (Key, error)
+	// KeyByID returns the key with the given ID.
+	KeyByID(ctx context.Context, id string) (Key, error)
+}
+
+// UnwrapFunc decrypts a wrapped data key, typically by calling a key
+// management service such as AWS KMS or HashiCorp Vault transit.
+type UnwrapFunc func(ctx context.Context, wrapped []byte) ([]byte, error)
+
+// EnvelopeKeyProvider is a KeyProvider of data keys wrapped by a key
+// management service, so the keys never live in plain text in the
+// application configuration. The unwrapped keys are cached in memory.
+type EnvelopeKeyProvider struct {
+	currentID string
+	wrapped   map[string][]byte
+	unwrap    UnwrapFunc
+
+	mu    sync.Mutex
+	cache map[string][]byte
+}
+
+// NewEnvelopeKeyProvider creates a new EnvelopeKeyProvider of the wrapped data
+// keys by ID, using the key with the current ID for new data.
+func NewEnvelopeKeyProvider(currentID string, wrapped map[string][]byte, unwrap UnwrapFunc) (*EnvelopeKeyProvider, error) {
+	if unwrap == nil {
+		return nil, ErrNoKeyProvider
+	}
+
+	if _, ok := wrapped[currentID]; !ok {
+		return nil, ErrKeyNotFound
+	}
+
+	return &EnvelopeKeyProvider{
+		currentID: currentID,
+		wrapped:   wrapped,
+		unwrap:    unwrap,
+		cache:     make(map[string][]byte),
+	}, nil
+}
+
+// CurrentKey returns the key new data is encrypted with.
+func (p *EnvelopeKeyProvider) CurrentKey(ctx context.Context) (Key, error) {
+	return p.KeyByID(ctx, p.currentID)
+}
+
+// KeyByID returns the key with the given ID.
+func (p *EnvelopeKeyProvider) KeyByID(ctx context.Context, id string) (Key, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if material, ok := p.cache[id]; ok {
+		return Key{ID: id, Material: material}, nil
+	}
+
+	wrapped, ok := p.wrapped[id]
+	if !ok {
+		return Key{}, ErrKeyNotFound
+	}
+
+	material, err := p.unwrap(ctx, wrapped)
+	if err != nil {
+		return Key{}, err
+	}
+
+	p.cache[id] = material
+
+	return Key{ID: id, Material: material}, nil
+}
+
+// keyText returns the text form of the key material passed to pgcrypto.
+func keyText(key Key) string {
+	return hex.EncodeToString(key.Material)
+}
+
+// KeyLister is implemented by the key providers able to list the IDs of
+// their keys, so every key can be made available to the connections.
+type KeyLister interface {
+	// KeyIDs returns the IDs of the keys of the provider.
+	KeyIDs() []string
+}
+
+// KeyIDs returns the IDs of the wrapped data keys.
+func (p *EnvelopeKeyProvider) KeyIDs() []string {
+	ids := make([]string, 0, len(p.wrapped))
+	for id := range p.wrapped {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+
+	return ids
+}
+
+// keySettingPrefix returns the prefix of the custom configuration parameters
+// holding the keys by their ID.
+func keySettingPrefix(setting string) string {
+	return setting + "_k"
+}
+
+// keySetting returns the name of the custom configuration parameter holding
+// the key with the given ID. The ID is hex encoded, as parameter names are
+// limited to identifier characters.
+func keySetting(setting string, id string) string {
+	return keySettingPrefix(setting) + hex.EncodeToString([]byte(id))
+}
+
+// SetEncryptionKeyFromProvider returns a function setting the current key of
+// the provider in the custom configuration parameter of every new
+// connection, and its ID in the parameter with the "_id" suffix. It is meant
+// to be used as the AfterConnect function of the pgxpool configuration.
+//
+// Every key of the provider is also set in a parameter of its own if the
+// provider implements KeyLister, otherwise only the current key is. The rows
+// are decrypted with the key of the ID recorded with them, so rows encrypted
+// with an earlier key stay readable during a rotation. As the keys are set
+// when the connection is opened, a new key has to be added to the provider
+// and the pool reset, for example with pgxpool.Pool.Reset, before it becomes
+// the current key or rows are re-encrypted with it; otherwise the
+// connections opened before cannot read the rows encrypted with it.
+func SetEncryptionKeyFromProvider(setting string, provider KeyProvider) func(ctx context.Context, conn *pgx.Conn) error {
+	return func(ctx context.Context, conn *pgx.Conn) error {
+		key, err := provider.CurrentKey(ctx)
+		if err != nil {
+			return err
+		}
+
+		keys := []Key{key}
+		if lister, ok := provider.(KeyLister); ok {
+			for _, id := range lister.KeyIDs() {
+				if id == key.ID {
+					continue
+				}
+
+				k, err := provider.KeyByID(ctx, id)
+				if err != nil {
+					return err
+				}
+
+				keys = append(keys, k)
+			}
+		}
+
+		batch := new(pgx.Batch)
+		batch.Queue("SELECT set_config($1, $2, false), set_config($3, $4, false)", setting, keyText(key), setting+"_id", key.ID)
+
+		for _, k := range keys {
+			batch.Queue("SELECT set_config($1, $2, false)", keySetting(setting, k.ID), keyText(k))
+		}
+
+		return conn.SendBatch(ctx, batch).Close()
+	}
+}
+
+// reencryptBatchSize is the number of rows re-encrypted by one statement.
+const reencryptBatchSize = 1000
+
+// encryptionKeyIDExpr returns the expression of the ID of the encryption key
+// of the connection, as set by SetEncryptionKeyFromProvider, or an empty
+// string if the key was set without an ID.
+func encryptionKeyIDExpr(setting string) string {
+	return fmt.Sprintf("COALESCE(current_setting('%s_id', true), '')", setting)
+}
+
+// keyIDAssignment returns the assignment recording the ID of the key the
+// updated row is encrypted with, or an empty string if encryption is
+// disabled.
+func keyIDAssignment(setting string) string {
+	if setting == "" {
+		return ""
+	}
+
+	return ", key_id = " + encryptionKeyIDExpr(setting)
+}
+
+// keyIDBackfill returns the statement recording the key of the connection as
+// the key of the existing rows when the key ID column is added. The rows were
+// encrypted with the key of the connection, as earlier versions re-encrypted
+// every row on key rotation.
+func keyIDBackfill(table string, setting string) string {
+	if setting == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("UPDATE %s SET key_id = %s", table, encryptionKeyIDExpr(setting))
+}
+
+// keyIDValue returns the value of the key ID column of a new client.
+func (s *ClientStore) keyIDValue() string {
+	if s.encryptionSetting == "" {
+		return "''"
+	}
+
+	return encryptionKeyIDExpr(s.encryptionSetting)
+}
+
+// reencryptionKeySettings returns the names of the transaction-local custom
+// configuration parameters holding the old and the new key while re-encrypting.
+func reencryptionKeySettings(setting string) (string, string) {
+	return setting + "_old", setting + "_new"
+}
+
+// reencrypt runs the statement re-encrypting a batch of rows until no row is
+// left, and returns the number of re-encrypted rows. Every batch is committed
+// on its own, so the rows are not locked for the whole rotation.
+func reencrypt(ctx context.Context, db DB, setting string, oldKey Key, newKey Key, sql string, args ...any) (int64, error) {
+	var total int64
+
+	for {
+		count, err := reencryptBatch(ctx, db, setting, oldKey, newKey, sql, args...)
+		total += count
+
+		if err != nil {
+			return total, err
+		}
+
+		if count < reencryptBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// reencryptBatch runs the statement re-encrypting a batch of rows in a
+// transaction setting the old and the new key in transaction-local
+// parameters, so the keys do not appear in the statement.
+func reencryptBatch(ctx context.Context, db DB, setting string, oldKey Key, newKey Key, sql string, args ...any) (int64, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	oldSetting, newSetting := reencryptionKeySettings(setting)
+	if _, err = tx.Exec(ctx, "SELECT set_config($1, $2, true), set_config($3, $4, true)", oldSetting, keyText(oldKey), newSetting, keyText(newKey)); err != nil {
+		return 0, err
+	}
+
+	tag, err := tx.Exec(ctx, sql, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// reencryptionKeys returns the old key and the current key of the provider,
+// or ErrKeyNotRotated if the old key is the current key.
+func reencryptionKeys(ctx context.Context, provider KeyProvider, oldKeyID string) (Key, Key, error) {
+	oldKey, err := provider.KeyByID(ctx, oldKeyID)
+	if err != nil {
+		return Key{}, Key{}, err
+	}
+
+	newKey, err := provider.CurrentKey(ctx)
+	if err != nil {
+		return Key{}, Key{}, err
+	}
+
+	if newKey.ID == oldKey.ID {
+		return Key{}, Key{}, ErrKeyNotRotated
+	}
+
+	return oldKey, newKey, nil
+}
+
+// ReencryptData re-encrypts the data column of the tokens encrypted with the
+// key of the given ID using the current key of the provider, and returns the
+// number of re-encrypted tokens. It is used to rotate the encryption key. The
+// tokens are re-encrypted in batches, so the rotation can be resumed if it
+// fails. Every connection of the pool must have the current key set, see
+// SetEncryptionKeyFromProvider.
+func (s *TokenStore) ReencryptData(ctx context.Context, provider KeyProvider, oldKeyID string) (int64, error) {
+	if s.readOnly {
+		return 0, ErrReadOnly
+	}
+
+	if s.encryptionSetting == "" {
+		return 0, ErrInvalidEncryptionSetting
+	}
+
+	oldKey, newKey, err := reencryptionKeys(ctx, provider, oldKeyID)
+	if err != nil {
+		return 0, err
+	}
+
+	oldSetting, newSetting := reencryptionKeySettings(s.encryptionSetting)
+	count, err := reencrypt(ctx, s.pool, s.encryptionSetting, oldKey, newKey, fmt.Sprintf(`
+		UPDATE %[1]s SET %[2]s = pgp_sym_encrypt_bytea(pgp_sym_decrypt_bytea(%[2]s, %[3]s), %[4]s), key_id = $2
+		WHERE id IN (SELECT id FROM %[1]s WHERE key_id = $1 LIMIT %[5]d)`,
+		s.table, s.columns.Data, encryptionKeyExpr(oldSetting), encryptionKeyExpr(newSetting), reencryptBatchSize,
+	), oldKey.ID, newKey.ID)
+
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return count, wrapDatabaseError(err)
+	}
+
+	s.logger.Log(ctx, LogLevelInfo, "token data re-encrypted", "old_key_id", oldKeyID, "key_id", newKey.ID, "count", count)
+
+	return count, nil
+}
+
+// ReencryptData re-encrypts the secret and data columns of the clients
+// encrypted with the key of the given ID using the current key of the
+// provider, and returns the number of re-encrypted clients. It is used to
+// rotate the encryption key. The clients are re-encrypted in batches, so the
+// rotation can be resumed if it fails. Every connection of the pool must have
+// the current key set, see SetEncryptionKeyFromProvider.
+func (s *ClientStore) ReencryptData(ctx context.Context, provider KeyProvider, oldKeyID string) (int64, error) {
+	if s.readOnly {
+		return 0, ErrReadOnly
+	}
+
+	if s.encryptionSetting == "" {
+		return 0, ErrInvalidEncryptionSetting
+	}
+
+	oldKey, newKey, err := reencryptionKeys(ctx, provider, oldKeyID)
+	if err != nil {
+		return 0, err
+	}
+
+	oldSetting, newSetting := reencryptionKeySettings(s.encryptionSetting)
+	count, err := reencrypt(ctx, s.pool, s.encryptionSetting, oldKey, newKey, fmt.Sprintf(`
+		UPDATE %[1]s SET
+			secret = encode(pgp_sym_encrypt(pgp_sym_decrypt(decode(secret, 'base64'), %[2]s), %[3]s), 'base64'),
+			data = pgp_sym_encrypt_bytea(pgp_sym_decrypt_bytea(data, %[2]s), %[3]s),
+			key_id = $2
+		WHERE id IN (SELECT id FROM %[1]s WHERE key_id = $1 LIMIT %[4]d)`,
+		s.table, encryptionKeyExpr(oldSetting), encryptionKeyExpr(newSetting), reencryptBatchSize,
+	), oldKey.ID, newKey.ID)
+
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return count, wrapDatabaseError(err)
+	}
+
+	s.logger.Log(ctx, LogLevelInfo, "client data re-encrypted", "old_key_id", oldKeyID, "key_id", newKey.ID, "count", count)
+
+	return count, nil
+}
@@ -0,0 +1,195 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// newTestKeyProvider returns a key provider of the keys with the given IDs
+// using the given current key.
+func newTestKeyProvider(t *testing.T, currentID string, ids ...string) *EnvelopeKeyProvider {
+	t.Helper()
+
+	wrapped := make(map[string][]byte, len(ids))
+	for _, id := range ids {
+		wrapped[id] = []byte("key-" + id)
+	}
+
+	provider, err := NewEnvelopeKeyProvider(currentID, wrapped, func(_ context.Context, wrapped []byte) ([]byte, error) {
+		return wrapped, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return provider
+}
+
+func TestReencryptDataRejectsCurrentKey(t *testing.T) {
+	store := &TokenStore{encryptionSetting: "pgstore.key", logger: new(NoopLogger)}
+
+	if _, err := store.ReencryptData(context.Background(), newTestKeyProvider(t, "v1", "v1"), "v1"); !errors.Is(err, ErrKeyNotRotated) {
+		t.Fatalf("got %v, want %v", err, ErrKeyNotRotated)
+	}
+}
+
+func TestKeySetting(t *testing.T) {
+	if got, want := keySetting("pgstore.key", "v1"), "pgstore.key_k7631"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestEnvelopeKeyProviderKeyIDs(t *testing.T) {
+	provider := newTestKeyProvider(t, "v2", "v2", "v1")
+
+	if got := strings.Join(provider.KeyIDs(), ","); got != "v1,v2" {
+		t.Fatalf("got key IDs %q, want %q", got, "v1,v2")
+	}
+}
+
+func TestTokenStoreInsertRecordsKeyID(t *testing.T) {
+	store := &TokenStore{table: DefaultTokenStoreTable, columns: DefaultColumnMap, encryptionSetting: "pgstore.key"}
+
+	sql := store.insertSQL([]string{store.columns.Data})
+	if !strings.Contains(sql, "(data, key_id)") || !strings.Contains(sql, "current_setting('pgstore.key_id', true)") {
+		t.Fatalf("got %q, want the key ID recorded", sql)
+	}
+}
+
+func TestClientStoreReencryptDataOnlyOldKey(t *testing.T) {
+	ctx := context.Background()
+	dsn := newTestDSN(t)
+
+	newStore := func(provider KeyProvider) *ClientStore {
+		config, err := pgxpool.ParseConfig(dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		config.AfterConnect = SetEncryptionKeyFromProvider("pgstore.key", provider)
+
+		pool, err := pgxpool.NewWithConfig(ctx, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		t.Cleanup(pool.Close)
+
+		store, err := NewClientStore(WithClientStoreConnPool(pool), WithClientStoreEncryption("pgstore.key"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = store.InitTable(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		return store
+	}
+
+	v1 := newStore(newTestKeyProvider(t, "v1", "v1"))
+	if err := v1.Create(&models.Client{ID: "old", Secret: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+
+	provider := newTestKeyProvider(t, "v2", "v1", "v2")
+	v2 := newStore(provider)
+	if err := v2.Create(&models.Client{ID: "new", Secret: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The rows are decrypted with the key they were encrypted with, so the
+	// rows of the old key stay readable until they are re-encrypted.
+	if _, err := v2.GetByID(ctx, "old"); err != nil {
+		t.Fatal(err)
+	}
+
+	count, err := v2.ReencryptData(ctx, provider, "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 1 {
+		t.Fatalf("got %d re-encrypted clients, want 1", count)
+	}
+
+	for _, id := range []string{"old", "new"} {
+		info, err := v2.GetByID(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if info.GetSecret() != "secret" {
+			t.Fatalf("got secret %q of client %s, want %q", info.GetSecret(), id, "secret")
+		}
+	}
+}
+
+// statementDB is a database recording the statements and arguments run in
+// its transactions.
+type statementDB struct {
+	DB
+	statements []string
+	args       []any
+}
+
+func (d *statementDB) Begin(context.Context) (pgx.Tx, error) {
+	return &statementTx{db: d}, nil
+}
+
+// statementTx is a transaction recording its statements in the database.
+type statementTx struct {
+	pgx.Tx
+	db *statementDB
+}
+
+func (t *statementTx) Exec(_ context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	t.db.statements = append(t.db.statements, sql)
+	t.db.args = append(t.db.args, args...)
+
+	return pgconn.NewCommandTag("UPDATE 1"), nil
+}
+
+func (t *statementTx) Commit(context.Context) error   { return nil }
+func (t *statementTx) Rollback(context.Context) error { return nil }
+
+func TestReencryptDataSetsKeysInTransaction(t *testing.T) {
+	provider := newTestKeyProvider(t, "v2", "v1", "v2")
+	db := new(statementDB)
+
+	store, err := newTokenStore(WithTokenStoreDB(db), WithTokenStoreEncryption("pgstore.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = store.ReencryptData(context.Background(), provider, "v1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(db.statements) != 2 || !strings.Contains(db.statements[0], "set_config($1, $2, true)") {
+		t.Fatalf("got statements %q, want the keys set in the transaction", db.statements)
+	}
+
+	for _, key := range []string{keyText(Key{Material: []byte("key-v1")}), keyText(Key{Material: []byte("key-v2")})} {
+		if strings.Contains(db.statements[1], key) {
+			t.Fatalf("got key %q in statement %q", key, db.statements[1])
+		}
+
+		if db.args[1] != key && db.args[3] != key {
+			t.Fatalf("got arguments %q, want key %q set in the transaction", db.args, key)
+		}
+
+		for _, arg := range db.args[4:] {
+			if arg == key {
+				t.Fatalf("got key %q in the arguments of the statement", key)
+			}
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// Package kmskeys provides a pgstore.UnwrapFunc decrypting data keys with the
+// AWS Key Management Service.
+package kmskeys
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	pgstore "github.com/gabor-boros/go-oauth2-pg"
+)
+
+// Credentials are the AWS credentials signing the requests.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is the token of temporary credentials, if any.
+	SessionToken string
+}
+
+// decryptRequest is the body of a KMS decrypt request.
+type decryptRequest struct {
+	CiphertextBlob []byte `json:"CiphertextBlob"`
+}
+
+// decryptResponse is the body of a KMS decrypt response.
+type decryptResponse struct {
+	Plaintext []byte `json:"Plaintext"`
+	Type      string `json:"__type"`
+	Message   string `json:"message"`
+}
+
+// NewUnwrapFunc returns a pgstore.UnwrapFunc decrypting the data keys, wrapped
+// as KMS ciphertext blobs, in the region. The data keys can be generated with
+// the GenerateDataKeyWithoutPlaintext operation. If the client is nil,
+// http.DefaultClient is used. If the endpoint is empty, the regional endpoint
+// of KMS is used.
+func NewUnwrapFunc(client *http.Client, endpoint string, region string, credentials Credentials) pgstore.UnwrapFunc {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://kms.%s.amazonaws.com/", region)
+	}
+
+	return func(ctx context.Context, wrapped []byte) ([]byte, error) {
+		body, err := json.Marshal(decryptRequest{CiphertextBlob: wrapped})
+		if err != nil {
+			return nil, err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+		req.Header.Set("X-Amz-Target", "TrentService.Decrypt")
+		sign(req, body, region, credentials, time.Now().UTC())
+
+		res, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer res.Body.Close()
+
+		var decrypted decryptResponse
+		if err = json.NewDecoder(res.Body).Decode(&decrypted); err != nil {
+			return nil, err
+		}
+
+		if res.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("kms decrypt failed with status %d: %s: %s", res.StatusCode, decrypted.Type, decrypted.Message)
+		}
+
+		return decrypted.Plaintext, nil
+	}
+}
+
+// sign signs the request with AWS Signature Version 4.
+func sign(req *http.Request, body []byte, region string, credentials Credentials, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if credentials.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", credentials.SessionToken)
+	}
+
+	names := []string{"content-type", "host", "x-amz-date", "x-amz-security-token", "x-amz-target"}
+
+	var headers strings.Builder
+	signed := make([]string, 0, len(names))
+
+	for _, name := range names {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.URL.Host
+		}
+
+		if value == "" {
+			continue
+		}
+
+		fmt.Fprintf(&headers, "%s:%s\n", name, strings.TrimSpace(value))
+		signed = append(signed, name)
+	}
+
+	signedHeaders := strings.Join(signed, ";")
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalPath(req.URL),
+		req.URL.RawQuery,
+		headers.String(),
+		signedHeaders,
+		hashHex(body),
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/kms/aws4_request", date, region)
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hashHex([]byte(canonicalRequest))}, "\n")
+
+	key := []byte("AWS4" + credentials.SecretAccessKey)
+	for _, part := range []string{date, region, "kms", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		credentials.AccessKeyID, scope, signedHeaders, hex.EncodeToString(hmacSHA256(key, stringToSign)),
+	))
+}
+
+// canonicalPath returns the path of the canonical request.
+func canonicalPath(u *url.URL) string {
+	if path := u.EscapedPath(); path != "" {
+		return path
+	}
+
+	return "/"
+}
+
+// hashHex returns the hex encoded SHA-256 hash of the data.
+func hashHex(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of the data with the key.
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+
+	return mac.Sum(nil)
+}
@@ -0,0 +1,89 @@
+package kmskeys
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewUnwrapFunc(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if target := r.Header.Get("X-Amz-Target"); target != "TrentService.Decrypt" {
+			t.Errorf("got target %q, want %q", target, "TrentService.Decrypt")
+		}
+
+		prefix := "AWS4-HMAC-SHA256 Credential=AKID/" + time.Now().UTC().Format("20060102") +
+			"/eu-central-1/kms/aws4_request, SignedHeaders=content-type;host;x-amz-date;x-amz-security-token;x-amz-target, Signature="
+		if auth := r.Header.Get("Authorization"); !strings.HasPrefix(auth, prefix) {
+			t.Errorf("got authorization %q, want prefix %q", auth, prefix)
+		}
+
+		var req decryptRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+
+		if string(req.CiphertextBlob) != "wrapped" {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(decryptResponse{Type: "InvalidCiphertextException", Message: "invalid ciphertext"})
+
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(decryptResponse{Plaintext: []byte("key")})
+	}))
+	defer server.Close()
+
+	unwrap := NewUnwrapFunc(server.Client(), server.URL, "eu-central-1", Credentials{
+		AccessKeyID:     "AKID",
+		SecretAccessKey: "secret",
+		SessionToken:    "session",
+	})
+
+	key, err := unwrap(context.Background(), []byte("wrapped"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(key) != "key" {
+		t.Fatalf("got key %q, want %q", key, "key")
+	}
+
+	if _, err = unwrap(context.Background(), []byte("other")); err == nil || !strings.Contains(err.Error(), "InvalidCiphertextException") {
+		t.Fatalf("got error %v, want the KMS error", err)
+	}
+}
+
+func TestSign(t *testing.T) {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, "https://kms.us-east-1.amazonaws.com", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "TrentService.Decrypt")
+
+	now := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	sign(req, []byte("{}"), "us-east-1", Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}, now)
+
+	if got := req.Header.Get("X-Amz-Date"); got != "20230102T030405Z" {
+		t.Fatalf("got date %q, want %q", got, "20230102T030405Z")
+	}
+
+	auth := req.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/20230102/us-east-1/kms/aws4_request, SignedHeaders=content-type;host;x-amz-date;x-amz-target, Signature=") {
+		t.Fatalf("got authorization %q", auth)
+	}
+
+	// The signature depends only on the request, the credentials and the time.
+	signature := auth[strings.LastIndex(auth, "=")+1:]
+	sign(req, []byte("{}"), "us-east-1", Credentials{AccessKeyID: "AKID", SecretAccessKey: "other"}, now)
+
+	if other := req.Header.Get("Authorization"); strings.HasSuffix(other, signature) || len(signature) != 64 {
+		t.Fatalf("got signature %q for both secrets", signature)
+	}
+}
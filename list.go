@@ -0,0 +1,248 @@
+package pgstore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// DefaultListLimit is the page size used when no positive limit is given.
+	DefaultListLimit = 100
+	// forEachBatchSize is the number of rows fetched from the cursor of
+	// ForEachToken at once.
+	forEachBatchSize = 1000
+)
+
+const (
+	TokenStateAny     = TokenState("")        // active and expired tokens
+	TokenStateActive  = TokenState("active")  // not yet expired tokens
+	TokenStateExpired = TokenState("expired") // expired tokens
+)
+
+// TokenState is the expiration state of a token.
+type TokenState string
+
+// TokenFilter filters the listed tokens. Empty fields do not filter.
+type TokenFilter struct {
+	ClientID string
+	UserID   string
+	Scope    string
+	State    TokenState
+}
+
+// TokenCursor is the keyset position the next page starts after.
+type TokenCursor struct {
+	ExpiresAt time.Time
+	ID        int64
+	// Key is the ID of tables with UUID keys.
+	Key string
+}
+
+// TokenRecord is a stored token with its metadata.
+type TokenRecord struct {
+	ID        int64
+	Key       string // ID of tables with UUID keys
+	ClientID  string
+	UserID    string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	Info      oauth2.TokenInfo
+}
+
+// TokenPage is a page of listed tokens.
+type TokenPage struct {
+	Tokens []TokenRecord
+	// Next is the cursor of the next page or nil if this is the last page.
+	Next *TokenCursor
+}
+
+// newTokenRecord creates a TokenRecord from the stored item.
+func newTokenRecord(item TokenStoreItem, info oauth2.TokenInfo) TokenRecord {
+	return TokenRecord{
+		ID:        item.ID,
+		Key:       item.Key,
+		ClientID:  item.ClientID,
+		UserID:    item.UserID,
+		CreatedAt: item.CreatedAt,
+		ExpiresAt: item.ExpiresAt,
+		Info:      info,
+	}
+}
+
+// where builds the WHERE clause of the filter, appending its arguments.
+func (f TokenFilter) where(columns ColumnMap, now time.Time, args []any) ([]string, []any) {
+	var conds []string
+
+	if f.ClientID != "" {
+		args = append(args, f.ClientID)
+		conds = append(conds, fmt.Sprintf("client_id = $%d", len(args)))
+	}
+
+	if f.UserID != "" {
+		args = append(args, f.UserID)
+		conds = append(conds, fmt.Sprintf("user_id = $%d", len(args)))
+	}
+
+	if f.Scope != "" {
+		args = append(args, f.Scope)
+		conds = append(conds, scopeCondition(len(args)))
+	}
+
+	switch f.State {
+	case TokenStateActive:
+		args = append(args, now)
+		conds = append(conds, fmt.Sprintf("%s > $%d", columns.ExpiresAt, len(args)))
+	case TokenStateExpired:
+		args = append(args, now)
+		conds = append(conds, fmt.Sprintf("%s <= $%d", columns.ExpiresAt, len(args)))
+	}
+
+	return conds, args
+}
+
+// queryTokenRecords runs the query and scans every returned row.
+func (s *TokenStore) queryTokenRecords(ctx context.Context, sql string, args ...any) ([]TokenRecord, error) {
+	rows, err := s.reader().Query(ctx, sql, args...)
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return nil, wrapDatabaseError(err)
+	}
+
+	return s.scanTokenRecords(ctx, rows)
+}
+
+// scanTokenRecords scans every row of the result.
+func (s *TokenStore) scanTokenRecords(ctx context.Context, rows pgx.Rows) ([]TokenRecord, error) {
+	defer rows.Close()
+
+	var records []TokenRecord
+	for rows.Next() {
+		item, info, err := s.scanToTokenStoreItem(ctx, rows)
+		if err != nil {
+			return nil, wrapDatabaseError(err)
+		}
+
+		records = append(records, newTokenRecord(item, info))
+	}
+
+	if err := rows.Err(); err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return nil, wrapDatabaseError(err)
+	}
+
+	return records, nil
+}
+
+// ListByUserID returns the active tokens of the user, most recent first. It
+// can be used to display the active sessions of a user.
+func (s *TokenStore) ListByUserID(ctx context.Context, userID string) ([]TokenRecord, error) {
+	s.logger.Log(ctx, LogLevelDebug, "listing tokens by user id", "user_id", userID)
+	return s.queryTokenRecords(ctx, fmt.Sprintf(
+		"SELECT %s FROM %s WHERE user_id = $1 AND %s%s ORDER BY %s DESC, id DESC",
+		s.selectColumns(), s.table, s.activePredicate("$2"), s.andTenant(), s.columns.CreatedAt,
+	), userID, s.clock.Now())
+}
+
+// ListTokens returns a page of tokens matching the filter ordered by expiry.
+// The page starts after the cursor, or at the beginning if it is nil.
+func (s *TokenStore) ListTokens(ctx context.Context, filter TokenFilter, cursor *TokenCursor, limit int) (*TokenPage, error) {
+	s.logger.Log(ctx, LogLevelDebug, "listing tokens", "filter", filter, "cursor", cursor, "limit", limit)
+
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+
+	conds, args := filter.where(s.columns, s.clock.Now(), nil)
+
+	conds = append(conds, s.tenantConditions()...)
+
+	if cursor != nil {
+		args = append(args, cursor.ExpiresAt, s.cursorValue(cursor))
+		conds = append(conds, fmt.Sprintf("(%s, id) > ($%d, $%d)", s.columns.ExpiresAt, len(args)-1, len(args)))
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s", s.selectColumns(), s.table)
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+
+	// Query one more row than requested to know if there is a next page.
+	args = append(args, limit+1)
+	query += fmt.Sprintf(" ORDER BY %s, id LIMIT $%d", s.columns.ExpiresAt, len(args))
+
+	records, err := s.queryTokenRecords(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &TokenPage{Tokens: records}
+	if len(page.Tokens) > limit {
+		page.Tokens = page.Tokens[:limit]
+		last := page.Tokens[limit-1]
+		page.Next = &TokenCursor{ExpiresAt: last.ExpiresAt, ID: last.ID, Key: last.Key}
+	}
+
+	return page, nil
+}
+
+// ForEachToken calls fn with every token matching the filter in the order of
+// their expiry. The tokens are read through a server-side cursor in batches,
+// so batch jobs such as re-encryption, audits or exports can stream millions
+// of tokens with bounded memory. The cursor is read in a single transaction,
+// so fn sees a consistent snapshot; it should not write to the token table
+// through the store. Iteration stops at the first error returned by fn.
+func (s *TokenStore) ForEachToken(ctx context.Context, filter TokenFilter, fn func(TokenRecord) error) error {
+	s.logger.Log(ctx, LogLevelDebug, "iterating over tokens", "filter", filter)
+
+	conds, args := filter.where(s.columns, s.clock.Now(), nil)
+	conds = append(conds, s.tenantConditions()...)
+
+	query := fmt.Sprintf("DECLARE tokens NO SCROLL CURSOR FOR SELECT %s FROM %s", s.selectColumns(), s.table)
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+
+	query += fmt.Sprintf(" ORDER BY %s, id", s.columns.ExpiresAt)
+
+	pool := s.pool
+	if s.readPool != nil {
+		pool = s.readPool
+	}
+
+	err := pgx.BeginTxFunc(ctx, pool, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly}, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, query, args...); err != nil {
+			s.logger.Log(ctx, LogLevelError, err.Error())
+			return wrapDatabaseError(err)
+		}
+
+		for {
+			rows, err := tx.Query(ctx, fmt.Sprintf("FETCH %d FROM tokens", forEachBatchSize))
+			if err != nil {
+				s.logger.Log(ctx, LogLevelError, err.Error())
+				return wrapDatabaseError(err)
+			}
+
+			records, err := s.scanTokenRecords(ctx, rows)
+			if err != nil {
+				return err
+			}
+
+			for _, record := range records {
+				if err = fn(record); err != nil {
+					return err
+				}
+			}
+
+			if len(records) < forEachBatchSize {
+				return nil
+			}
+		}
+	})
+
+	return wrapDatabaseError(err)
+}
@@ -0,0 +1,164 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenStoreListByUserIDSkipsRotatedTokens(t *testing.T) {
+	store := newTestTokenStore(t)
+	ctx := context.Background()
+
+	if err := store.Create(ctx, newTestToken("client", "user", "a")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.RotateRefresh(ctx, "refresh-a", newTestToken("client", "user", "b")); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := store.ListByUserID(ctx, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 1 || records[0].Info.GetRefresh() != "refresh-b" {
+		t.Fatalf("got %d tokens, want only the token the refresh token was rotated to", len(records))
+	}
+}
+
+func TestTokenFilterWhere(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	filter := TokenFilter{ClientID: "client", UserID: "user", Scope: "read", State: TokenStateExpired}
+
+	conds, args := filter.where(DefaultColumnMap, now, []any{"first"})
+
+	want := []string{"client_id = $2", "user_id = $3", scopeCondition(4), "expires_at <= $5"}
+	if strings.Join(conds, " AND ") != strings.Join(want, " AND ") {
+		t.Fatalf("got conditions %q, want %q", conds, want)
+	}
+
+	if len(args) != 5 || args[1] != "client" || args[2] != "user" || args[3] != "read" || args[4] != now {
+		t.Fatalf("got arguments %v", args)
+	}
+
+	if conds, args = (TokenFilter{}).where(DefaultColumnMap, now, nil); len(conds) != 0 || len(args) != 0 {
+		t.Fatalf("got conditions %q for an empty filter, want none", conds)
+	}
+}
+
+func TestTokenStoreListTokensPages(t *testing.T) {
+	store := newTestTokenStore(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	for i, suffix := range []string{"a", "b", "c", "d", "e"} {
+		clientID := "client"
+		if suffix == "e" {
+			clientID = "other"
+		}
+
+		if err := store.CreateWithExpiry(ctx, newTestToken(clientID, "user", suffix), now.Add(time.Duration(i+1)*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var (
+		cursor   *TokenCursor
+		accesses []string
+		pages    int
+	)
+
+	for {
+		page, err := store.ListTokens(ctx, TokenFilter{ClientID: "client"}, cursor, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pages++
+
+		for _, record := range page.Tokens {
+			accesses = append(accesses, record.Info.GetAccess())
+		}
+
+		if page.Next == nil {
+			break
+		}
+
+		cursor = page.Next
+	}
+
+	if got := strings.Join(accesses, ","); got != "access-a,access-b,access-c,access-d" || pages != 2 {
+		t.Fatalf("got tokens %q in %d pages, want the tokens of the client by expiry in 2 pages", got, pages)
+	}
+}
+
+func TestTokenStoreListTokensState(t *testing.T) {
+	store := newTestTokenStore(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	if err := store.CreateWithExpiry(ctx, newTestToken("client", "user", "expired"), now.Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.CreateWithExpiry(ctx, newTestToken("client", "user", "active"), now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	for state, want := range map[TokenState]string{TokenStateActive: "access-active", TokenStateExpired: "access-expired"} {
+		page, err := store.ListTokens(ctx, TokenFilter{State: state}, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(page.Tokens) != 1 || page.Tokens[0].Info.GetAccess() != want || page.Next != nil {
+			t.Errorf("%s: got %d tokens, want only %q", state, len(page.Tokens), want)
+		}
+	}
+}
+
+func TestTokenStoreForEachToken(t *testing.T) {
+	store := newTestTokenStore(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	for i, suffix := range []string{"a", "b", "c", "d"} {
+		clientID := "client"
+		if suffix == "d" {
+			clientID = "other"
+		}
+
+		if err := store.CreateWithExpiry(ctx, newTestToken(clientID, "user", suffix), now.Add(time.Duration(i+1)*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var accesses []string
+	err := store.ForEachToken(ctx, TokenFilter{ClientID: "client"}, func(record TokenRecord) error {
+		accesses = append(accesses, record.Info.GetAccess())
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Join(accesses, ","); got != "access-a,access-b,access-c" {
+		t.Fatalf("got tokens %q, want the tokens of the client by expiry", got)
+	}
+
+	// Iteration stops at the first error returned by the callback.
+	stop := errors.New("stop")
+	calls := 0
+
+	err = store.ForEachToken(ctx, TokenFilter{}, func(TokenRecord) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Fatalf("got error %v after %d calls, want %v after the first call", err, calls, stop)
+	}
+}
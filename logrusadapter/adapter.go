@@ -0,0 +1,57 @@
+// Package logrusadapter provides a pgstore.Logger backed by logrus.
+package logrusadapter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+
+	pgstore "github.com/gabor-boros/go-oauth2-pg"
+)
+
+// Logger is a pgstore.Logger that writes to a logrus.Logger.
+type Logger struct {
+	logger *logrus.Logger
+}
+
+// Log logs a message with the given level. The args are expected to be
+// key-value pairs.
+func (l *Logger) Log(ctx context.Context, level pgstore.LogLevel, msg string, args ...any) {
+	fields := make(logrus.Fields, (len(args)+1)/2)
+
+	for i := 0; i < len(args); i += 2 {
+		if i+1 == len(args) {
+			fields["!BADKEY"] = args[i]
+			break
+		}
+
+		key, ok := args[i].(string)
+		if !ok {
+			key = fmt.Sprint(args[i])
+		}
+
+		fields[key] = args[i+1]
+	}
+
+	l.logger.WithContext(ctx).WithFields(fields).Log(translateLevel(level), msg)
+}
+
+// translateLevel maps a pgstore.LogLevel to a logrus.Level.
+func translateLevel(level pgstore.LogLevel) logrus.Level {
+	switch level {
+	case pgstore.LogLevelDebug:
+		return logrus.DebugLevel
+	case pgstore.LogLevelWarn:
+		return logrus.WarnLevel
+	case pgstore.LogLevelError:
+		return logrus.ErrorLevel
+	default:
+		return logrus.InfoLevel
+	}
+}
+
+// NewLogger creates a new Logger wrapping the given logrus.Logger.
+func NewLogger(logger *logrus.Logger) *Logger {
+	return &Logger{logger: logger}
+}
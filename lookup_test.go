@@ -0,0 +1,44 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestLookupsReturnNotFound(t *testing.T) {
+	ctx := context.Background()
+
+	tokens, err := newTokenStore(WithTokenStoreDB(new(emptyDB)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clients, err := NewClientStore(WithClientStoreDB(new(emptyDB)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tt := range map[string]struct {
+		lookup func() error
+		want   error
+	}{
+		"code":    {lookup: func() error { _, err := tokens.GetByCode(ctx, "code"); return err }, want: ErrTokenNotFound},
+		"access":  {lookup: func() error { _, err := tokens.GetByAccess(ctx, "access"); return err }, want: ErrTokenNotFound},
+		"refresh": {lookup: func() error { _, err := tokens.GetByRefresh(ctx, "refresh"); return err }, want: ErrTokenNotFound},
+		"client":  {lookup: func() error { _, err := clients.GetByID(ctx, "client"); return err }, want: ErrClientNotFound},
+	} {
+		if err := tt.lookup(); !errors.Is(err, tt.want) {
+			t.Errorf("%s: got error %v, want %v", name, err, tt.want)
+		}
+	}
+}
+
+func TestTokenStoreRotateRefreshNotFound(t *testing.T) {
+	store := newTestTokenStore(t)
+	ctx := context.Background()
+
+	if err := store.RotateRefresh(ctx, "refresh-missing", newTestToken("client", "user", "new")); !errors.Is(err, ErrTokenNotFound) {
+		t.Fatalf("got error %v, want %v", err, ErrTokenNotFound)
+	}
+}
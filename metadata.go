@@ -0,0 +1,29 @@
+package pgstore
+
+import (
+	"context"
+	"net/netip"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+)
+
+// TokenMetadata is the metadata of the request a token was issued for. Zero
+// fields are not stored.
+type TokenMetadata struct {
+	// ClientIP is the IP address the request originated from.
+	ClientIP netip.Addr
+	// UserAgent is the user agent of the request.
+	UserAgent string
+	// GrantType is the grant type of the token request. It overrides the
+	// grant type carried by the token.
+	GrantType oauth2.GrantType
+}
+
+// CreateWithMetadata creates a new token in the store with the metadata of
+// the request it was issued for, so the tokens can be correlated with their
+// originating IP address and user agent when investigating anomalies, and
+// counted per grant type.
+func (s *TokenStore) CreateWithMetadata(ctx context.Context, info oauth2.TokenInfo, meta TokenMetadata) error {
+	return s.createWithExpiry(ctx, info, time.Time{}, meta)
+}
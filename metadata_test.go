@@ -0,0 +1,49 @@
+package pgstore
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+)
+
+func TestTokenStoreCreateWithMetadata(t *testing.T) {
+	store := newTestTokenStore(t)
+	ctx := context.Background()
+
+	meta := TokenMetadata{ClientIP: netip.MustParseAddr("2001:db8::1"), UserAgent: "curl/8.0"}
+	if err := store.CreateWithMetadata(ctx, newTestToken("client", "user", "a"), meta); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Create(ctx, newTestToken("client", "user", "b")); err != nil {
+		t.Fatal(err)
+	}
+
+	for access, want := range map[string]TokenMetadata{
+		"access-a": meta,
+		"access-b": {}, // Zero fields are not stored.
+	} {
+		var got TokenMetadata
+		err := store.pool.QueryRow(ctx, "SELECT client_ip, user_agent FROM "+store.table+" WHERE access_token = $1", access).Scan(&got.ClientIP, &got.UserAgent)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got != want {
+			t.Errorf("%s: got metadata %+v, want %+v", access, got, want)
+		}
+	}
+
+	var null bool
+	if err := store.pool.QueryRow(ctx, "SELECT client_ip IS NULL FROM "+store.table+" WHERE access_token = 'access-b'").Scan(&null); err != nil {
+		t.Fatal(err)
+	}
+
+	if !null {
+		t.Fatal("got a client IP stored for a token without one")
+	}
+
+	if _, err := store.GetByAccess(ctx, "access-a"); err != nil {
+		t.Fatal(err)
+	}
+}
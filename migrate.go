@@ -0,0 +1,114 @@
+package pgstore
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Migration is a schema migration of a store.
+type Migration struct {
+	// Name is the name of the migration used in the file names.
+	Name string
+	// Up is the SQL applying the migration.
+	Up string
+	// Down is the SQL reverting the migration.
+	Down string
+}
+
+// dropTablesSQL returns the statements dropping the tables in order.
+func dropTablesSQL(tables ...string) string {
+	stmts := make([]string, 0, len(tables))
+	for _, table := range tables {
+		stmts = append(stmts, fmt.Sprintf("DROP TABLE IF EXISTS %s;", table))
+	}
+
+	return strings.Join(stmts, "\n")
+}
+
+// Migration returns the migration creating the token store tables.
+func (s *TokenStore) Migration() Migration {
+	tables := []string{s.table}
+	if s.archiveTable != "" {
+		tables = append([]string{s.archiveTable}, tables...)
+	}
+
+	return Migration{
+		Name: "create_" + unqualifiedName(s.table),
+		Up:   s.InitTableSQL(),
+		Down: dropTablesSQL(tables...),
+	}
+}
+
+// Migration returns the migration creating the client store tables.
+func (s *ClientStore) Migration() Migration {
+	return Migration{
+		Name: "create_" + unqualifiedName(s.table),
+		Up:   s.InitTableSQL(),
+		Down: dropTablesSQL(s.clientScopeTable, s.scopeTable, s.redirectURITable, s.secretTable, s.table),
+	}
+}
+
+// Migration returns the migration creating the consent store table.
+func (s *ConsentStore) Migration() Migration {
+	return Migration{
+		Name: "create_" + unqualifiedName(s.table),
+		Up:   s.InitTableSQL(),
+		Down: dropTablesSQL(s.table),
+	}
+}
+
+// Migrations returns the migrations creating the tables of every store.
+func (s *Stores) Migrations() []Migration {
+	return []Migration{
+		s.ClientStore.Migration(),
+		s.TokenStore.Migration(),
+		s.ConsentStore.Migration(),
+	}
+}
+
+// dedent removes the common leading whitespace of the lines of the SQL.
+func dedent(sql string) string {
+	lines := strings.Split(strings.Trim(sql, "\n"), "\n")
+
+	indent := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		n := len(line) - len(strings.TrimLeft(line, " \t"))
+		if indent == -1 || n < indent {
+			indent = n
+		}
+	}
+
+	for i, line := range lines {
+		if len(line) >= indent && indent > 0 {
+			lines[i] = line[indent:]
+		}
+	}
+
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// WriteMigrations writes the migrations into the directory as numbered up and
+// down SQL files following the golang-migrate naming convention, for example
+// 000001_create_oauth2_tokens.up.sql. The first migration gets the given
+// version and the following ones are numbered sequentially.
+func WriteMigrations(dir string, version uint, migrations ...Migration) error {
+	for i, m := range migrations {
+		base := filepath.Join(dir, fmt.Sprintf("%06d_%s", version+uint(i), m.Name))
+
+		if err := os.WriteFile(base+".up.sql", []byte(dedent(m.Up)+"\n"), 0o644); err != nil { // nolint: gosec
+			return err
+		}
+
+		if err := os.WriteFile(base+".down.sql", []byte(dedent(m.Down)+"\n"), 0o644); err != nil { // nolint: gosec
+			return err
+		}
+	}
+
+	return nil
+}
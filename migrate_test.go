@@ -0,0 +1,109 @@
+package pgstore
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDedent(t *testing.T) {
+	tests := map[string]struct {
+		sql  string
+		want string
+	}{
+		"indented":     {sql: "\n\t\tCREATE TABLE t (\n\t\t\tid INT\n\t\t);\n\t", want: "CREATE TABLE t (\n\tid INT\n);"},
+		"blank lines":  {sql: "\n\t\tSELECT 1;\n\n\t\tSELECT 2;", want: "SELECT 1;\n\nSELECT 2;"},
+		"not indented": {sql: "SELECT 1;\n\tSELECT 2;", want: "SELECT 1;\n\tSELECT 2;"},
+	}
+
+	for name, tt := range tests {
+		if got := dedent(tt.sql); got != tt.want {
+			t.Errorf("%s: got %q, want %q", name, got, tt.want)
+		}
+	}
+}
+
+func TestClientStoreMigrationDropsTablesInOrder(t *testing.T) {
+	store := &ClientStore{
+		table:            "clients",
+		secretTable:      "secrets",
+		redirectURITable: "redirect_uris",
+		scopeTable:       "scopes",
+		clientScopeTable: "client_scopes",
+	}
+
+	m := store.Migration()
+
+	want := "DROP TABLE IF EXISTS client_scopes;\nDROP TABLE IF EXISTS scopes;\nDROP TABLE IF EXISTS redirect_uris;\n" +
+		"DROP TABLE IF EXISTS secrets;\nDROP TABLE IF EXISTS clients;"
+	if m.Name != "create_clients" || m.Down != want {
+		t.Fatalf("got migration %q down %q, want create_clients down %q", m.Name, m.Down, want)
+	}
+}
+
+func TestWriteMigrations(t *testing.T) {
+	dir := t.TempDir()
+
+	err := WriteMigrations(dir, 3,
+		Migration{Name: "create_a", Up: "\n\t\tCREATE TABLE a ();\n", Down: "DROP TABLE a;"},
+		Migration{Name: "create_b", Up: "CREATE TABLE b ();", Down: "DROP TABLE b;"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]string{
+		"000003_create_a.up.sql":   "CREATE TABLE a ();\n",
+		"000003_create_a.down.sql": "DROP TABLE a;\n",
+		"000004_create_b.up.sql":   "CREATE TABLE b ();\n",
+		"000004_create_b.down.sql": "DROP TABLE b;\n",
+	} {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(got) != want {
+			t.Errorf("%s: got %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestStoresMigrationsApplyAndRevert(t *testing.T) {
+	ctx := context.Background()
+
+	stores, err := NewStores(ctx, WithStoresDSN(newTestDSN(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stores.Close(ctx)
+
+	pool, _ := nativePool(stores.TokenStore.pool)
+	migrations := stores.Migrations()
+
+	for _, m := range migrations {
+		if _, err = pool.Exec(ctx, m.Up); err != nil {
+			t.Fatalf("%s up: %v", m.Name, err)
+		}
+	}
+
+	if diff, err := stores.VerifySchema(ctx); err != nil {
+		t.Fatalf("got schema differences %v after the migrations: %v", diff, err)
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		if _, err = pool.Exec(ctx, migrations[i].Down); err != nil {
+			t.Fatalf("%s down: %v", migrations[i].Name, err)
+		}
+	}
+
+	var remaining int
+	if err = pool.QueryRow(ctx, "SELECT count(*) FROM pg_tables WHERE schemaname = current_schema()").Scan(&remaining); err != nil {
+		t.Fatal(err)
+	}
+
+	if remaining != 0 {
+		t.Fatalf("got %d tables after reverting the migrations, want none", remaining)
+	}
+}
@@ -0,0 +1,124 @@
+package pgstore
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+	"time"
+)
+
+// negativeCache remembers the IDs of missing clients until they expire. The
+// number of remembered IDs is bounded, so lookups of random IDs cannot grow
+// it without limit. The IDs are kept in the order they were added, which is
+// also the order they expire, so the oldest ID is evicted when the cache is
+// full.
+type negativeCache struct {
+	ttl  time.Duration
+	size int
+
+	mu      sync.Mutex
+	order   *list.List
+	missing map[string]*list.Element
+}
+
+// negativeCacheEntry is an ID remembered by the negative cache.
+type negativeCacheEntry struct {
+	id        string
+	expiresAt time.Time
+}
+
+// newNegativeCache creates a new negative cache.
+func newNegativeCache(ttl time.Duration, size int) *negativeCache {
+	return &negativeCache{
+		ttl:     ttl,
+		size:    size,
+		order:   list.New(),
+		missing: make(map[string]*list.Element, size),
+	}
+}
+
+// contains returns true if the ID is remembered as missing at the given time.
+func (c *negativeCache) contains(id string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.missing[id]
+	if ok && !now.Before(elem.Value.(*negativeCacheEntry).expiresAt) {
+		c.removeElement(elem)
+		return false
+	}
+
+	return ok
+}
+
+// add remembers the ID as missing. If the cache is full, the oldest ID is
+// evicted.
+func (c *negativeCache) add(id string, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.missing[id]; ok {
+		c.removeElement(elem)
+	}
+
+	if c.order.Len() >= c.size {
+		c.removeElement(c.order.Back())
+	}
+
+	c.missing[id] = c.order.PushFront(&negativeCacheEntry{id: id, expiresAt: now.Add(c.ttl)})
+}
+
+// remove forgets the ID.
+func (c *negativeCache) remove(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.missing[id]; ok {
+		c.removeElement(elem)
+	}
+}
+
+// removeElement removes the entry from the cache. The caller must hold the
+// lock.
+func (c *negativeCache) removeElement(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.missing, elem.Value.(*negativeCacheEntry).id)
+}
+
+// WithClientStoreNegativeCache configures GetByID to remember the IDs of the
+// missing clients for the TTL, up to size IDs, and return ErrClientNotFound
+// for them without querying the database, so repeated lookups of unknown
+// clients, common during credential stuffing attacks, do not reach Postgres.
+// Creating a client through the store forgets its ID, but a client created by
+// another instance is only found once the TTL elapsed.
+func WithClientStoreNegativeCache(ttl time.Duration, size int) ClientStoreOption {
+	return func(s *ClientStore) error {
+		if ttl <= 0 || size <= 0 {
+			return ErrInvalidNegativeCache
+		}
+
+		s.missing = newNegativeCache(ttl, size)
+
+		return nil
+	}
+}
+
+// isMissing returns true if the client is remembered as missing.
+func (s *ClientStore) isMissing(id string) bool {
+	return s.missing != nil && s.missing.contains(id, s.clock.Now())
+}
+
+// rememberMissing remembers the client as missing if the lookup did not find
+// it.
+func (s *ClientStore) rememberMissing(id string, err error) {
+	if s.missing != nil && errors.Is(err, ErrClientNotFound) {
+		s.missing.add(id, s.clock.Now())
+	}
+}
+
+// forgetMissing forgets that the client was missing.
+func (s *ClientStore) forgetMissing(id string) {
+	if s.missing != nil {
+		s.missing.remove(id)
+	}
+}
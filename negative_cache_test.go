@@ -0,0 +1,79 @@
+package pgstore
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestNegativeCache(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := newNegativeCache(time.Minute, 2)
+
+	cache.add("a", now)
+	cache.add("b", now.Add(time.Second))
+
+	if !cache.contains("a", now) || !cache.contains("b", now) {
+		t.Fatal("added IDs not remembered")
+	}
+
+	cache.add("c", now.Add(2*time.Second))
+
+	if cache.contains("a", now) {
+		t.Fatal("oldest ID not evicted when the cache is full")
+	}
+
+	if !cache.contains("b", now) || !cache.contains("c", now) {
+		t.Fatal("newer IDs evicted")
+	}
+
+	if cache.contains("b", now.Add(time.Minute+time.Second)) {
+		t.Fatal("expired ID remembered")
+	}
+
+	cache.remove("c")
+
+	if cache.contains("c", now) {
+		t.Fatal("removed ID remembered")
+	}
+
+	if cache.order.Len() != 0 || len(cache.missing) != 0 {
+		t.Fatalf("got %d entries, want none", len(cache.missing))
+	}
+}
+
+func TestNegativeCacheBounded(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := newNegativeCache(time.Hour, 10)
+
+	for i := 0; i < 1000; i++ {
+		cache.add(fmt.Sprintf("client-%d", i), now)
+		cache.add(fmt.Sprintf("client-%d", i), now)
+	}
+
+	if cache.order.Len() != 10 || len(cache.missing) != 10 {
+		t.Fatalf("got %d entries, want 10", len(cache.missing))
+	}
+
+	if !cache.contains("client-999", now) || cache.contains("client-989", now) {
+		t.Fatal("not the most recent IDs remembered")
+	}
+}
+
+func TestClientStoreRememberMissing(t *testing.T) {
+	store, err := NewClientStore(WithClientStoreDB(unusedDB{}), WithClientStoreNegativeCache(time.Minute, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store.rememberMissing("wrapped", fmt.Errorf("get client: %w", ErrClientNotFound))
+	store.rememberMissing("failed", ErrConnection)
+
+	if !store.isMissing("wrapped") {
+		t.Fatal("client not found by a wrapped error not remembered")
+	}
+
+	if store.isMissing("failed") {
+		t.Fatal("client failed to be read remembered as missing")
+	}
+}
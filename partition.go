@@ -0,0 +1,207 @@
+package pgstore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// partitionTimeFormat is the format of the lower bound in partition names.
+const partitionTimeFormat = "20060102150405"
+
+// WithTokenStorePartitioning configures the token table to be partitioned by
+// the expiry time, one partition per interval. The cleanup creates the
+// partitions for the given number of upcoming intervals and drops the fully
+// expired partitions instead of deleting their rows. Tokens expiring beyond
+// the created partitions are kept in a default partition and deleted row by
+// row. If an archive table or anonymization is configured, the expired tokens
+// are moved or anonymized row by row and no partition is dropped.
+func WithTokenStorePartitioning(interval time.Duration, ahead int) TokenStoreOption {
+	return func(s *TokenStore) error {
+		if interval <= 0 || ahead < 0 {
+			return ErrInvalidPartitioning
+		}
+
+		s.partitionInterval = interval
+		s.partitionsAhead = ahead
+
+		return nil
+	}
+}
+
+// primaryKeyColumns returns the primary key columns of the token table. The
+// partition key must be part of the primary key of a partitioned table.
+func (s *TokenStore) primaryKeyColumns() string {
+	switch {
+	case s.partitionInterval > 0 || s.hypertableChunkInterval > 0:
+		return "id, " + s.columns.ExpiresAt
+	case s.hasShardKey():
+		return "id, " + shardKeyColumn
+	default:
+		return "id"
+	}
+}
+
+// partitionClause returns the partitioning clause of the token table.
+func (s *TokenStore) partitionClause() string {
+	switch {
+	case s.partitionInterval > 0:
+		return " PARTITION BY RANGE (" + s.columns.ExpiresAt + ")"
+	case s.shards > 0:
+		return " PARTITION BY HASH (" + shardKeyColumn + ")"
+	default:
+		return ""
+	}
+}
+
+// defaultPartitionSQL returns the DDL of the default partition holding the
+// tokens not covered by any range partition.
+func (s *TokenStore) defaultPartitionSQL() string {
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %[1]s_default PARTITION OF %[1]s DEFAULT;", s.table)
+}
+
+// partitionName returns the name of the partition starting at the lower bound.
+func (s *TokenStore) partitionName(lower time.Time) string {
+	return fmt.Sprintf("%s_p%s", s.table, lower.UTC().Format(partitionTimeFormat))
+}
+
+// createPartitions creates the partition covering the given time and the
+// partitions of the upcoming intervals.
+func (s *TokenStore) createPartitions(ctx context.Context, now time.Time) error {
+	lower := now.UTC().Truncate(s.partitionInterval)
+
+	for i := 0; i <= s.partitionsAhead; i++ {
+		upper := lower.Add(s.partitionInterval)
+
+		if err := s.createPartition(ctx, lower, upper); err != nil {
+			return err
+		}
+
+		lower = upper
+	}
+
+	return nil
+}
+
+// createPartition creates the partition of the range. A partition cannot be
+// created while the default partition holds tokens in its range, which
+// happens when no partition was created ahead for longer than the created
+// partitions cover. In that case the default partition is detached, its
+// tokens in the range are moved into the new partition and it is attached
+// again, in a single transaction.
+func (s *TokenStore) createPartition(ctx context.Context, lower time.Time, upper time.Time) error {
+	partition := s.partitionName(lower)
+	bounds := fmt.Sprintf("FROM ('%s') TO ('%s')", lower.Format(time.RFC3339), upper.Format(time.RFC3339))
+
+	return pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", s.table+":partitions"); err != nil {
+			return err
+		}
+
+		var exists, misplaced bool
+		err := tx.QueryRow(ctx, fmt.Sprintf(
+			"SELECT to_regclass($1) IS NOT NULL, EXISTS (SELECT 1 FROM %s_default WHERE %s >= $2 AND %s < $3)",
+			s.table, s.columns.ExpiresAt, s.columns.ExpiresAt,
+		), partition, lower, upper).Scan(&exists, &misplaced)
+
+		if err != nil || exists {
+			return err
+		}
+
+		if !misplaced {
+			_, err = tx.Exec(ctx, fmt.Sprintf("CREATE TABLE %s PARTITION OF %s FOR VALUES %s", partition, s.table, bounds))
+			return err
+		}
+
+		s.logger.Log(ctx, LogLevelInfo, "moving tokens out of the default partition", "partition", partition)
+
+		columns := s.copyColumns()
+		for _, stmt := range []string{
+			fmt.Sprintf("ALTER TABLE %[1]s DETACH PARTITION %[1]s_default", s.table),
+			fmt.Sprintf("CREATE TABLE %s PARTITION OF %s FOR VALUES %s", partition, s.table, bounds),
+			fmt.Sprintf(`
+				WITH moved AS (
+					DELETE FROM %[1]s_default WHERE %[2]s >= '%[3]s' AND %[2]s < '%[4]s' RETURNING %[5]s
+				)
+				INSERT INTO %[1]s (%[5]s) OVERRIDING SYSTEM VALUE SELECT %[5]s FROM moved`,
+				s.table, s.columns.ExpiresAt, lower.Format(time.RFC3339), upper.Format(time.RFC3339), columns,
+			),
+			fmt.Sprintf("ALTER TABLE %[1]s ATTACH PARTITION %[1]s_default DEFAULT", s.table),
+		} {
+			if _, err = tx.Exec(ctx, stmt); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// dropExpiredPartitions detaches and drops the partitions whose every token
+// expired before the longest cleanup retention, returning the number of
+// dropped partitions.
+func (s *TokenStore) dropExpiredPartitions(ctx context.Context, now time.Time) (int, error) {
+	cutoff := now.Add(-s.longestRetention())
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT c.relname FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = $1::regclass`,
+		s.table,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return 0, err
+	}
+
+	prefix := unqualifiedName(s.table) + "_p"
+	dropped := 0
+
+	for _, name := range names {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		lower, err := time.Parse(partitionTimeFormat, strings.TrimPrefix(name, prefix))
+		if err != nil || lower.Add(s.partitionInterval).After(cutoff) {
+			continue
+		}
+
+		partition := s.partitionName(lower)
+
+		if _, err := s.pool.Exec(ctx, fmt.Sprintf("ALTER TABLE %s DETACH PARTITION %s", s.table, partition)); err != nil {
+			return dropped, err
+		}
+
+		if _, err := s.pool.Exec(ctx, fmt.Sprintf("DROP TABLE %s", partition)); err != nil {
+			return dropped, err
+		}
+
+		s.logger.Log(ctx, LogLevelDebug, "dropped expired partition", "partition", partition)
+		dropped++
+	}
+
+	return dropped, nil
+}
+
+// rotatePartitions drops the fully expired partitions, unless the expired
+// tokens are archived or anonymized, and creates the upcoming ones.
+func (s *TokenStore) rotatePartitions(ctx context.Context, now time.Time) error {
+	if s.archiveTable == "" && s.anonymizeAfter <= 0 {
+		dropped, err := s.dropExpiredPartitions(ctx, now)
+		s.logger.Log(ctx, LogLevelInfo, "dropping expired partitions", "dropped", dropped, "err", err)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.createPartitions(ctx, now)
+}
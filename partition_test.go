@@ -0,0 +1,76 @@
+package pgstore
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTokenStoreCreatePartitionMovesDefaultPartitionTokens(t *testing.T) {
+	store := newTestTokenStore(t, WithTokenStorePartitioning(time.Hour, 0))
+	ctx := context.Background()
+
+	// The refresh token expires beyond the created partition, so the token is
+	// kept in the default partition.
+	if err := store.Create(ctx, newTestToken("client", "user", "1")); err != nil {
+		t.Fatal(err)
+	}
+
+	var expiresAt time.Time
+	if err := store.pool.QueryRow(ctx, "SELECT "+store.columns.ExpiresAt+" FROM "+store.table+"_default").Scan(&expiresAt); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.createPartitions(ctx, expiresAt); err != nil {
+		t.Fatal(err)
+	}
+
+	var inDefault, inPartition int
+	if err := store.pool.QueryRow(ctx, "SELECT count(*) FROM "+store.table+"_default").Scan(&inDefault); err != nil {
+		t.Fatal(err)
+	}
+
+	partition := store.partitionName(expiresAt.UTC().Truncate(time.Hour))
+	if err := store.pool.QueryRow(ctx, "SELECT count(*) FROM "+partition).Scan(&inPartition); err != nil {
+		t.Fatal(err)
+	}
+
+	if inDefault != 0 || inPartition != 1 {
+		t.Fatalf("got %d tokens in the default partition and %d in %s, want 0 and 1", inDefault, inPartition, partition)
+	}
+
+	if _, err := store.GetByAccess(ctx, "access-1"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTokenStoreDropExpiredPartitionsKeepsRetention(t *testing.T) {
+	store := newTestTokenStore(t, WithTokenStorePartitioning(time.Hour, 0), WithTokenStoreCleanupRetention(2*time.Hour))
+	ctx := context.Background()
+
+	now := time.Now().UTC().Truncate(time.Hour)
+
+	for _, lower := range []time.Time{now.Add(-3 * time.Hour), now.Add(-2 * time.Hour)} {
+		if err := store.createPartitions(ctx, lower); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dropped, err := store.dropExpiredPartitions(ctx, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if dropped != 1 {
+		t.Fatalf("got %d dropped partitions, want 1", dropped)
+	}
+
+	var kept bool
+	if err := store.pool.QueryRow(ctx, "SELECT to_regclass($1) IS NOT NULL", store.partitionName(now.Add(-2*time.Hour))).Scan(&kept); err != nil {
+		t.Fatal(err)
+	}
+
+	if !kept {
+		t.Fatal("partition within the retention dropped")
+	}
+}
@@ -3,6 +3,12 @@ package pgstore
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 const (
@@ -17,10 +23,261 @@ var (
 	ErrNoTable = fmt.Errorf("no table provided")
 	// ErrNoConnPool is returned when no database was provided.
 	ErrNoConnPool = fmt.Errorf("no connection pool provided")
+	// ErrUnsupportedDB is returned when an operation needs a *pgxpool.Pool
+	// but the store was configured with another DB.
+	ErrUnsupportedDB = fmt.Errorf("operation not supported by the database")
+	// ErrNoDSN is returned when no connection string was provided.
+	ErrNoDSN = fmt.Errorf("no connection string provided")
+	// ErrNoSchema is returned when no schema was provided.
+	ErrNoSchema = fmt.Errorf("no schema provided")
+	// ErrNoPoolConfig is returned when no connection pool configuration was
+	// provided.
+	ErrNoPoolConfig = fmt.Errorf("no connection pool configuration provided")
+	// ErrInvalidPoolSize is returned when the connection pool size limits are
+	// invalid.
+	ErrInvalidPoolSize = fmt.Errorf("invalid connection pool size")
+	// ErrNoUserID is returned when no user id was provided.
+	ErrNoUserID = fmt.Errorf("no user id provided")
 	// ErrNoLogger is returned when no logger was provided.
 	ErrNoLogger = fmt.Errorf("no logger provided")
+	// ErrNoClock is returned when no clock was provided.
+	ErrNoClock = fmt.Errorf("no clock provided")
+	// ErrNoCodec is returned when no codec was provided.
+	ErrNoCodec = fmt.Errorf("no codec provided")
+	// ErrNoTokenFactory is returned when no token factory was provided.
+	ErrNoTokenFactory = fmt.Errorf("no token factory provided")
+	// ErrTokenNotFound is returned when the requested token does not exist.
+	ErrTokenNotFound = fmt.Errorf("token not found")
+	// ErrClientNotFound is returned when the requested client does not exist.
+	ErrClientNotFound = fmt.Errorf("client not found")
+	// ErrClientDisabled is returned when the requested client was disabled.
+	ErrClientDisabled = fmt.Errorf("client disabled")
+	// ErrConsentNotFound is returned when the requested consent does not
+	// exist.
+	ErrConsentNotFound = fmt.Errorf("consent not found")
+	// ErrJTINotFound is returned when the requested jti does not exist.
+	ErrJTINotFound = fmt.Errorf("jti not found")
+	// ErrClientSecretNotFound is returned when the requested client secret
+	// does not exist or is already revoked.
+	ErrClientSecretNotFound = fmt.Errorf("client secret not found")
+	// ErrStoreUnavailable is returned when the circuit breaker is open after
+	// consecutive database failures.
+	ErrStoreUnavailable = fmt.Errorf("store unavailable")
+	// ErrInvalidCircuitBreaker is returned when the circuit breaker threshold
+	// or cool-down is not positive.
+	ErrInvalidCircuitBreaker = fmt.Errorf("invalid circuit breaker configuration")
+	// ErrNoCleanupSchedule is returned when no cleanup schedule was provided.
+	ErrNoCleanupSchedule = fmt.Errorf("no cleanup schedule provided")
+	// ErrInvalidCronExpression is returned when a cron expression cannot be
+	// parsed.
+	ErrInvalidCronExpression = fmt.Errorf("invalid cron expression")
+	// ErrInvalidCleanupBatch is returned when the cleanup batch size is not
+	// positive or the delay between the batches is negative.
+	ErrInvalidCleanupBatch = fmt.Errorf("invalid cleanup batch configuration")
+	// ErrInvalidPartitioning is returned when the partition interval is not
+	// positive or the number of partitions created ahead is negative.
+	ErrInvalidPartitioning = fmt.Errorf("invalid partitioning configuration")
+	// ErrUniqueViolation is the failure class of the errors caused by a
+	// unique constraint violation.
+	ErrUniqueViolation = fmt.Errorf("unique violation")
+	// ErrSerializationFailure is the failure class of the errors caused by a
+	// serialization failure or a deadlock. The operation can be retried.
+	ErrSerializationFailure = fmt.Errorf("serialization failure")
+	// ErrConnection is the failure class of the errors caused by a failed or
+	// lost database connection.
+	ErrConnection = fmt.Errorf("connection failure")
+	// ErrConflict is returned when the client was changed since it was read.
+	ErrConflict = fmt.Errorf("conflicting client update")
+	// ErrInvalidEncryptionSetting is returned when the name of the
+	// configuration parameter holding the encryption key is not valid.
+	ErrInvalidEncryptionSetting = fmt.Errorf("invalid encryption setting")
+	// ErrNoKeyProvider is returned when no key provider or unwrap function
+	// was provided.
+	ErrNoKeyProvider = fmt.Errorf("no key provider provided")
+	// ErrKeyNotFound is returned when the requested encryption key does not
+	// exist.
+	ErrKeyNotFound = fmt.Errorf("encryption key not found")
+	// ErrInvalidSlowQueryThreshold is returned when the slow query threshold
+	// is not positive.
+	ErrInvalidSlowQueryThreshold = fmt.Errorf("invalid slow query threshold")
+	// ErrInvalidCleanupTimeout is returned when the cleanup timeout is not
+	// positive.
+	ErrInvalidCleanupTimeout = fmt.Errorf("invalid cleanup timeout")
+	// ErrInvalidExpiry is returned when the expiry of a token is not set.
+	ErrInvalidExpiry = fmt.Errorf("invalid expiry")
+	// ErrSoftRevocationDisabled is returned when checking the revocation of a
+	// token without soft revocation enabled.
+	ErrSoftRevocationDisabled = fmt.Errorf("soft revocation disabled")
+	// ErrInvalidRealm is returned when the realm name is invalid.
+	ErrInvalidRealm = fmt.Errorf("invalid realm")
+	// ErrInvalidColumnMap is returned when a column name is invalid.
+	ErrInvalidColumnMap = fmt.Errorf("invalid column map")
+	// ErrInvalidGeneratedColumns is returned when generated columns are
+	// configured without a JSONB data column or with partitioning.
+	ErrInvalidGeneratedColumns = fmt.Errorf("invalid generated columns")
+	// ErrInvalidSharding is returned when the number of shards is less than two
+	// or sharding is configured with partitioning by expiry.
+	ErrInvalidSharding = fmt.Errorf("invalid sharding configuration")
+	// ErrInvalidCitus is returned when Citus is configured with sharding or
+	// partitioning by expiry.
+	ErrInvalidCitus = fmt.Errorf("citus cannot be combined with sharding or partitioning")
+	// ErrInvalidHypertable is returned when the chunk interval of the
+	// hypertable is not positive, the retention is negative or the hypertable
+	// is configured with another table layout.
+	ErrInvalidHypertable = fmt.Errorf("invalid hypertable configuration")
+	// ErrInvalidAnonymization is returned when the anonymization delay is not
+	// positive, no anonymization key is provided or anonymization is
+	// configured with a table layout removing or deriving the rows.
+	ErrInvalidAnonymization = fmt.Errorf("invalid anonymization configuration")
+	// ErrUnloggedPartitioning is returned when an unlogged token table is
+	// configured with partitioning or sharding.
+	ErrUnloggedPartitioning = fmt.Errorf("partitioned token table cannot be unlogged")
+	// ErrInvalidTokenKind is returned when the token kind is unknown.
+	ErrInvalidTokenKind = fmt.Errorf("invalid token kind")
+	// ErrReadOnly is returned when writing to a read-only store.
+	ErrReadOnly = fmt.Errorf("store is read-only")
+	// ErrSchemaMismatch is returned when the schema of the database does not
+	// match the schema expected by the store.
+	ErrSchemaMismatch = fmt.Errorf("schema mismatch")
+	// ErrNoTracer is returned when no query tracer was provided.
+	ErrNoTracer = fmt.Errorf("no query tracer")
+	// ErrInvalidNegativeCache is returned when the TTL or size of the negative
+	// client cache is not positive.
+	ErrInvalidNegativeCache = fmt.Errorf("invalid negative cache configuration")
+	// ErrInvalidQuota is returned when the token quota is not positive or its
+	// policy is unknown.
+	ErrInvalidQuota = fmt.Errorf("invalid token quota")
+	// ErrQuotaExceeded is returned when creating a token would exceed a token
+	// quota rejecting new tokens.
+	ErrQuotaExceeded = fmt.Errorf("token quota exceeded")
+	// ErrInvalidUniqueTokens is returned when unique tokens are configured
+	// with partitioning, sharding, Citus or a hypertable.
+	ErrInvalidUniqueTokens = fmt.Errorf("unique tokens are not supported by the table layout")
+	// ErrDuplicateToken is returned when a created token collides with a
+	// stored one.
+	ErrDuplicateToken = fmt.Errorf("duplicate token")
+	// ErrInvalidTokenTTL is returned when a token lifetime is negative.
+	ErrInvalidTokenTTL = fmt.Errorf("invalid token lifetime")
+	// ErrInvalidIssuer is returned when the issuer contains invalid characters.
+	ErrInvalidIssuer = fmt.Errorf("invalid issuer")
+	// ErrInvalidBackup is returned when a backup is malformed or was taken of
+	// other tables.
+	ErrInvalidBackup = fmt.Errorf("invalid backup")
+	// ErrInvalidSlot is returned when the replication slot name contains
+	// invalid characters.
+	ErrInvalidSlot = fmt.Errorf("invalid replication slot")
+	// ErrInvalidPollInterval is returned when the poll interval is not
+	// positive.
+	ErrInvalidPollInterval = fmt.Errorf("invalid poll interval")
+	// ErrInvalidWebhook is returned when the webhook URL is not an HTTP URL,
+	// the signing secret is empty or the number of retries is negative.
+	ErrInvalidWebhook = fmt.Errorf("invalid webhook")
+	// ErrNoIDGenerator is returned when no ID generator was provided.
+	ErrNoIDGenerator = fmt.Errorf("no id generator provided")
+	// ErrInvalidSnowflakeNode is returned when the node of a snowflake ID
+	// generator is out of range.
+	ErrInvalidSnowflakeNode = fmt.Errorf("invalid snowflake node")
+	// ErrInvalidUUIDKeys is returned when the UUID version of the keys is not
+	// supported, UUID keys are configured with an ID generator or a UUID
+	// generator is configured without UUID keys.
+	ErrInvalidUUIDKeys = fmt.Errorf("invalid uuid keys")
+	// ErrInvalidIdentityColumns is returned when identity columns are
+	// configured with an ID generator or UUID keys.
+	ErrInvalidIdentityColumns = fmt.Errorf("invalid identity columns")
+	// ErrInvalidStorageParameters is returned when no storage parameters were
+	// provided, a parameter is invalid or the table is partitioned by expiry.
+	ErrInvalidStorageParameters = fmt.Errorf("invalid storage parameters")
+	// ErrInvalidCodeHashing is returned when the code hashing key is empty or
+	// code hashing is configured with generated columns.
+	ErrInvalidCodeHashing = fmt.Errorf("invalid code hashing configuration")
+	// ErrInvalidClientSecret is returned when the presented client secret
+	// does not match any secret of the client, or the client does not exist.
+	ErrInvalidClientSecret = fmt.Errorf("invalid client secret")
+	// ErrKeyNotRotated is returned when data is re-encrypted from the current
+	// key of the key provider.
+	ErrKeyNotRotated = fmt.Errorf("encryption key not rotated")
+	// ErrClientRealmMismatch is returned when a client with the same ID
+	// exists in another realm.
+	ErrClientRealmMismatch = fmt.Errorf("client exists in another realm")
+	// ErrUnsupportedSplitOption is returned when the split token store is
+	// configured with an option of the single table layout.
+	ErrUnsupportedSplitOption = fmt.Errorf("option not supported by the split token layout")
+	// ErrStoreClosed is returned when background work is started on a closed
+	// store.
+	ErrStoreClosed = fmt.Errorf("store closed")
+	// ErrNoEventChannel is returned when no event channel was provided.
+	ErrNoEventChannel = fmt.Errorf("no event channel provided")
+	// ErrInvalidRateLimit is returned when the rate limit or its window is not
+	// positive.
+	ErrInvalidRateLimit = fmt.Errorf("invalid rate limit")
+	// ErrRateLimited is returned when a client exceeded the token rate limit.
+	ErrRateLimited = fmt.Errorf("token rate limit exceeded")
+	// ErrInvalidFlushInterval is returned when the usage flush interval is not
+	// positive.
+	ErrInvalidFlushInterval = fmt.Errorf("invalid flush interval")
+	// ErrInvalidReadYourWritesWindow is returned when the read-your-writes
+	// window is not positive.
+	ErrInvalidReadYourWritesWindow = fmt.Errorf("invalid read-your-writes window")
+	// ErrInvalidCodeCleanup is returned when the interval of the authorization
+	// code cleanup is not positive or its retention is negative.
+	ErrInvalidCodeCleanup = fmt.Errorf("invalid authorization code cleanup")
+	// ErrInvalidRetention is returned when a retention period is negative.
+	ErrInvalidRetention = fmt.Errorf("invalid retention period")
+	// ErrUnknownCompression is returned when an unknown compression was
+	// provided.
+	ErrUnknownCompression = fmt.Errorf("unknown compression")
+	// ErrRefreshTokenReused is returned when an already rotated refresh token
+	// is presented again. The whole token family is revoked in this case.
+	ErrRefreshTokenReused = fmt.Errorf("refresh token reused")
+	// ErrTokenRevoked is returned when a revoked refresh token is presented
+	// for rotation.
+	ErrTokenRevoked = fmt.Errorf("token revoked")
 )
 
+// querier is implemented by both the connection pool and transactions.
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
+}
+
+// DB is the database the stores run their queries on. It is implemented by
+// *pgxpool.Pool; other drivers can be used through an adapter, such as the
+// one of the pgxv4 module for pgx v4 pools. Subscribing to events and backups
+// need a *pgxpool.Pool and return ErrUnsupportedDB otherwise.
+type DB interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Begin(ctx context.Context) (pgx.Tx, error)
+	BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error)
+	CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
+}
+
+// nativePool returns the pgx connection pool of the database, if it is one.
+func nativePool(db DB) (*pgxpool.Pool, bool) {
+	if observed, ok := db.(*observedDB); ok {
+		db = observed.DB
+	}
+
+	pool, ok := db.(*pgxpool.Pool)
+	return pool, ok
+}
+
+// unqualifiedName returns the table name without its schema. Index names
+// cannot be schema qualified, as indexes are always created in the schema of
+// their table.
+func unqualifiedName(table string) string {
+	return table[strings.LastIndex(table, ".")+1:]
+}
+
+// prefixedName returns the table name with the prefix added after the schema.
+func prefixedName(prefix, table string) string {
+	i := strings.LastIndex(table, ".") + 1
+	return table[:i] + prefix + table[i:]
+}
+
 // LogLevel is a log level.
 type LogLevel string
 
@@ -30,6 +287,20 @@ type Logger interface {
 	Log(ctx context.Context, level LogLevel, msg string, args ...any)
 }
 
+// Clock provides the current time to the stores.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+}
+
+// SystemClock is a clock that returns the system time.
+type SystemClock struct{}
+
+// Now returns the current system time.
+func (c *SystemClock) Now() time.Time {
+	return time.Now()
+}
+
 // NoopLogger is a logger that does nothing.
 type NoopLogger struct{}
 
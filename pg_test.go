@@ -0,0 +1,140 @@
+package pgstore
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// newTestDSN creates a schema of its own in the database of the
+// PGSTORE_TEST_DSN environment variable and returns a DSN using it, or skips
+// the test if the variable is not set. The schema is dropped when the test
+// finishes.
+func newTestDSN(t *testing.T) string {
+	t.Helper()
+
+	dsn := os.Getenv("PGSTORE_TEST_DSN")
+	if dsn == "" {
+		t.Skip("PGSTORE_TEST_DSN is not set")
+	}
+
+	ctx := context.Background()
+	schema := fmt.Sprintf("pgstore_test_%d", time.Now().UnixNano())
+
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = pool.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		pool.Close()
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		_, _ = pool.Exec(ctx, "DROP SCHEMA "+schema+" CASCADE")
+		pool.Close()
+	})
+
+	switch {
+	case !strings.Contains(dsn, "://"):
+		return dsn + " search_path=" + schema
+	case strings.Contains(dsn, "?"):
+		return dsn + "&search_path=" + schema
+	default:
+		return dsn + "?search_path=" + schema
+	}
+}
+
+// newTestTokenStore creates a token store with its tables in a schema of its
+// own, or skips the test if no test database is configured.
+func newTestTokenStore(t *testing.T, opts ...TokenStoreOption) *TokenStore {
+	t.Helper()
+
+	ctx := context.Background()
+
+	store, err := NewTokenStore(append([]TokenStoreOption{WithTokenStoreDSN(newTestDSN(t))}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { store.Close(ctx) })
+
+	if err = store.InitTable(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	return store
+}
+
+// newTestClientStore creates a client store with its tables in a schema of
+// its own, or skips the test if no test database is configured.
+func newTestClientStore(t *testing.T, opts ...ClientStoreOption) *ClientStore {
+	t.Helper()
+
+	ctx := context.Background()
+
+	store, err := NewClientStore(append([]ClientStoreOption{WithClientStoreDSN(newTestDSN(t))}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { store.Close(ctx) })
+
+	if err = store.InitTable(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	return store
+}
+
+// newTestRealmClientStores creates a client store per realm sharing one
+// test database, or skips the test if no test database is configured.
+func newTestRealmClientStores(t *testing.T, realms ...string) map[string]*ClientStore {
+	t.Helper()
+
+	dsn := newTestDSN(t)
+	ctx := context.Background()
+
+	stores := make(map[string]*ClientStore)
+	for _, realm := range realms {
+		store, err := NewClientStore(WithClientStoreDSN(dsn), WithClientStoreRealm(realm))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		t.Cleanup(func() { store.Close(ctx) })
+
+		if err = store.InitTable(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		stores[realm] = store
+	}
+
+	return stores
+}
+
+// newTestToken returns an access and refresh token of the client and user
+// with the given suffix.
+func newTestToken(clientID string, userID string, suffix string) *models.Token {
+	now := time.Now()
+
+	return &models.Token{
+		ClientID:         clientID,
+		UserID:           userID,
+		Scope:            "read",
+		Access:           "access-" + suffix,
+		AccessCreateAt:   now,
+		AccessExpiresIn:  time.Hour,
+		Refresh:          "refresh-" + suffix,
+		RefreshCreateAt:  now,
+		RefreshExpiresIn: 24 * time.Hour,
+	}
+}
@@ -0,0 +1,76 @@
+package pgstore
+
+import (
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// DatabaseError is a database error classified by its SQLSTATE, so callers
+// can branch on the failure class with errors.Is, for example against
+// ErrUniqueViolation, without importing pgx. The original error is available
+// through errors.Unwrap.
+type DatabaseError struct {
+	// Code is the SQLSTATE of the error or empty if the database did not
+	// return one, for example when the connection failed.
+	Code  string
+	class error
+	err   error
+}
+
+// Error returns the message of the original error.
+func (e *DatabaseError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the original error.
+func (e *DatabaseError) Unwrap() error {
+	return e.err
+}
+
+// Is returns true if the target is the failure class of the error.
+func (e *DatabaseError) Is(target error) bool {
+	return e.class != nil && target == e.class
+}
+
+// classifyPgError returns the failure class of the SQLSTATE or nil if the
+// code has no class.
+func classifyPgError(code string) error {
+	switch {
+	case code == "23505":
+		return ErrUniqueViolation
+	case code == "40001", code == "40P01":
+		return ErrSerializationFailure
+	case strings.HasPrefix(code, "08"):
+		return ErrConnection
+	default:
+		return nil
+	}
+}
+
+// wrapDatabaseError wraps the error returned by pgx into a DatabaseError.
+// Errors of the store, such as ErrTokenNotFound, are returned as is.
+func wrapDatabaseError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var dbErr *DatabaseError
+	if errors.As(err, &dbErr) {
+		return err
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return &DatabaseError{Code: pgErr.Code, class: classifyPgError(pgErr.Code), err: err}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return &DatabaseError{class: ErrConnection, err: err}
+	}
+
+	return err
+}
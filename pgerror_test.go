@@ -0,0 +1,101 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// errRow is a row failing to scan with the error.
+type errRow struct {
+	err error
+}
+
+func (r errRow) Scan(...any) error {
+	return r.err
+}
+
+// failingDB is a database failing every statement with the error.
+type failingDB struct {
+	DB
+	err error
+}
+
+func (d *failingDB) Exec(context.Context, string, ...any) (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, d.err
+}
+
+func (d *failingDB) Query(context.Context, string, ...any) (pgx.Rows, error) {
+	return nil, d.err
+}
+
+func (d *failingDB) QueryRow(context.Context, string, ...any) pgx.Row {
+	return errRow{err: d.err}
+}
+
+func (d *failingDB) Begin(context.Context) (pgx.Tx, error) {
+	return nil, d.err
+}
+
+func TestSideStoresClassifyDatabaseErrors(t *testing.T) {
+	ctx := context.Background()
+
+	for code, class := range map[string]error{
+		"23505": ErrUniqueViolation,
+		"40001": ErrSerializationFailure,
+		"08006": ErrConnection,
+	} {
+		db := &failingDB{err: &pgconn.PgError{Code: code}}
+
+		clients, err := NewClientStore(WithClientStoreDB(db))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		consents, err := NewConsentStore(WithConsentStoreDB(db))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		jtis, err := NewJTIStore(WithJTIStoreDB(db))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for name, call := range map[string]func() error{
+			"ClientStore.AddSecret": func() error {
+				_, err := clients.AddSecret(ctx, "client", "secret", time.Time{})
+				return err
+			},
+			"ClientStore.InitTable":       func() error { return clients.InitTable(ctx) },
+			"ClientStore.SetRedirectURIs": func() error { return clients.SetRedirectURIs(ctx, "client", nil) },
+			"ClientStore.ValidateScopes": func() error {
+				_, err := clients.ValidateScopes(ctx, "client", []string{"read"})
+				return err
+			},
+			"ClientStore.UpsertScope":  func() error { return clients.UpsertScope(ctx, Scope{Name: "read"}) },
+			"ClientStore.SetTokenTTLs": func() error { return clients.SetTokenTTLs(ctx, "client", TokenTTLs{}) },
+			"ClientStore.RemoveByID":   func() error { return clients.RemoveByID(ctx, "client") },
+			"ClientStore.Disable":      func() error { return clients.Disable(ctx, "client") },
+			"ConsentStore.Grant":       func() error { return consents.Grant(ctx, "user", "client", []string{"read"}, time.Time{}) },
+			"ConsentStore.Revoke":      func() error { return consents.Revoke(ctx, "user", "client") },
+			"JTIStore.Create":          func() error { return jtis.Create(ctx, "jti", &models.Token{}) },
+			"JTIStore.Revoke":          func() error { return jtis.Revoke(ctx, "jti") },
+		} {
+			err := call()
+
+			var dbErr *DatabaseError
+			if !errors.As(err, &dbErr) || !errors.Is(err, class) {
+				t.Errorf("%s with SQLSTATE %s: got %v, want a DatabaseError of %v", name, code, err, class)
+			}
+		}
+
+		clients.Close(ctx)
+		consents.Close(ctx)
+	}
+}
@@ -0,0 +1,450 @@
+// Package pgxv4 adapts pgx v4 connection pools to the pgstore.DB interface,
+// so the stores can be used by projects that cannot upgrade to pgx v5 yet.
+//
+// The queries run on the pgx v4 pool, while the result values are decoded
+// with the pgx v5 type map, so the stores scan the same values as with a pgx
+// v5 pool. Errors are converted to their pgx v5 counterparts, so the store
+// errors are reported the same way too. Subscribing to events and backups
+// need a pgx v5 pool and return pgstore.ErrUnsupportedDB.
+package pgxv4
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"sync"
+
+	pgconnv4 "github.com/jackc/pgconn"
+	pgxv4 "github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	pgstore "github.com/gabor-boros/go-oauth2-pg"
+)
+
+// querier is implemented by both pgx v4 connection pools and transactions.
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconnv4.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgxv4.Rows, error)
+	Begin(ctx context.Context) (pgxv4.Tx, error)
+	CopyFrom(ctx context.Context, tableName pgxv4.Identifier, columnNames []string, rowSrc pgxv4.CopyFromSource) (int64, error)
+}
+
+// Pool is implemented by the pgx v4 connection pool.
+type Pool interface {
+	querier
+	BeginTx(ctx context.Context, txOptions pgxv4.TxOptions) (pgxv4.Tx, error)
+}
+
+// DB is a pgstore.DB running the queries on a pgx v4 connection pool.
+type DB struct {
+	conn
+	pool Pool
+}
+
+var _ pgstore.DB = (*DB)(nil)
+
+// New returns a pgstore.DB running the queries on the pgx v4 pool. The pool
+// is not closed by the stores.
+func New(pool Pool) *DB {
+	return &DB{conn: conn{q: pool}, pool: pool}
+}
+
+// BeginTx starts a transaction with the options.
+func (db *DB) BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+	tx, err := db.pool.BeginTx(ctx, pgxv4.TxOptions{
+		IsoLevel:       pgxv4.TxIsoLevel(txOptions.IsoLevel),
+		AccessMode:     pgxv4.TxAccessMode(txOptions.AccessMode),
+		DeferrableMode: pgxv4.TxDeferrableMode(txOptions.DeferrableMode),
+	})
+	if err != nil {
+		return nil, convertError(err)
+	}
+
+	return &transaction{conn: conn{q: tx}, tx: tx}, nil
+}
+
+// typeMaps are the pgx v5 type maps decoding the values. Type maps are not
+// safe for concurrent use, so each query takes its own.
+var typeMaps = sync.Pool{New: func() any { return pgtype.NewMap() }}
+
+// conn runs the queries on a pgx v4 querier.
+type conn struct {
+	q querier
+}
+
+// Exec executes the statement.
+func (c *conn) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	tag, err := c.q.Exec(ctx, sql, convertArgs(args)...)
+	if err != nil {
+		return pgconn.CommandTag{}, convertError(err)
+	}
+
+	return pgconn.NewCommandTag(tag.String()), nil
+}
+
+// Query executes the query and returns its rows.
+func (c *conn) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	r, err := c.q.Query(ctx, sql, convertArgs(args)...)
+	if err != nil {
+		return nil, convertError(err)
+	}
+
+	return &rows{rows: r, typeMap: typeMaps.Get().(*pgtype.Map)}, nil
+}
+
+// QueryRow executes the query and returns its first row.
+func (c *conn) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	r, err := c.Query(ctx, sql, args...)
+	return &row{rows: r, err: err}
+}
+
+// Begin starts a transaction, or a savepoint in a transaction.
+func (c *conn) Begin(ctx context.Context) (pgx.Tx, error) {
+	tx, err := c.q.Begin(ctx)
+	if err != nil {
+		return nil, convertError(err)
+	}
+
+	return &transaction{conn: conn{q: tx}, tx: tx}, nil
+}
+
+// CopyFrom copies the rows of the source into the table.
+func (c *conn) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	n, err := c.q.CopyFrom(ctx, pgxv4.Identifier(tableName), columnNames, copyFromSource{rowSrc})
+	return n, convertError(err)
+}
+
+// transaction is a pgx v5 transaction running on a pgx v4 transaction.
+type transaction struct {
+	conn
+	tx pgxv4.Tx
+}
+
+// Commit commits the transaction.
+func (t *transaction) Commit(ctx context.Context) error {
+	return convertError(t.tx.Commit(ctx))
+}
+
+// Rollback rolls back the transaction.
+func (t *transaction) Rollback(ctx context.Context) error {
+	return convertError(t.tx.Rollback(ctx))
+}
+
+// SendBatch is not supported, as the queries of pgx v5 batches cannot be
+// read; the results return ErrBatchUnsupported.
+func (t *transaction) SendBatch(context.Context, *pgx.Batch) pgx.BatchResults {
+	return unsupportedBatchResults{}
+}
+
+// LargeObjects is not supported.
+func (t *transaction) LargeObjects() pgx.LargeObjects {
+	return pgx.LargeObjects{}
+}
+
+// Prepare is not supported and returns ErrPrepareUnsupported.
+func (t *transaction) Prepare(context.Context, string, string) (*pgconn.StatementDescription, error) {
+	return nil, ErrPrepareUnsupported
+}
+
+// Conn returns nil, as there is no pgx v5 connection.
+func (t *transaction) Conn() *pgx.Conn {
+	return nil
+}
+
+var (
+	// ErrBatchUnsupported is returned by the results of batches sent in a
+	// transaction.
+	ErrBatchUnsupported = errors.New("batches are not supported by the pgx v4 adapter")
+	// ErrPrepareUnsupported is returned when preparing a statement in a
+	// transaction.
+	ErrPrepareUnsupported = errors.New("prepared statements are not supported by the pgx v4 adapter")
+)
+
+// unsupportedBatchResults are the results of an unsupported batch.
+type unsupportedBatchResults struct{}
+
+func (unsupportedBatchResults) Exec() (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, ErrBatchUnsupported
+}
+
+func (unsupportedBatchResults) Query() (pgx.Rows, error) {
+	return nil, ErrBatchUnsupported
+}
+
+func (unsupportedBatchResults) QueryRow() pgx.Row {
+	return &row{err: ErrBatchUnsupported}
+}
+
+func (unsupportedBatchResults) Close() error {
+	return nil
+}
+
+// rows are pgx v5 rows reading the rows of a pgx v4 query. The values are
+// decoded by the pgx v5 type map.
+type rows struct {
+	rows    pgxv4.Rows
+	typeMap *pgtype.Map
+	err     error
+}
+
+// Close closes the rows.
+func (r *rows) Close() {
+	r.rows.Close()
+
+	if r.typeMap != nil {
+		typeMaps.Put(r.typeMap)
+		r.typeMap = nil
+	}
+}
+
+// Err returns the error of the query or of scanning the rows.
+func (r *rows) Err() error {
+	if r.err != nil {
+		return r.err
+	}
+
+	return convertError(r.rows.Err())
+}
+
+// CommandTag returns the command tag of the query.
+func (r *rows) CommandTag() pgconn.CommandTag {
+	return pgconn.NewCommandTag(r.rows.CommandTag().String())
+}
+
+// FieldDescriptions returns the descriptions of the columns.
+func (r *rows) FieldDescriptions() []pgconn.FieldDescription {
+	fields := r.rows.FieldDescriptions()
+	descriptions := make([]pgconn.FieldDescription, len(fields))
+
+	for i, field := range fields {
+		descriptions[i] = pgconn.FieldDescription{
+			Name:                 string(field.Name),
+			TableOID:             field.TableOID,
+			TableAttributeNumber: field.TableAttributeNumber,
+			DataTypeOID:          field.DataTypeOID,
+			DataTypeSize:         field.DataTypeSize,
+			TypeModifier:         field.TypeModifier,
+			Format:               field.Format,
+		}
+	}
+
+	return descriptions
+}
+
+// Next moves to the next row.
+func (r *rows) Next() bool {
+	if r.err != nil {
+		return false
+	}
+
+	if !r.rows.Next() {
+		r.Close()
+		return false
+	}
+
+	return true
+}
+
+// Scan decodes the values of the current row into the destinations.
+func (r *rows) Scan(dest ...any) error {
+	fields := r.rows.FieldDescriptions()
+	values := r.rows.RawValues()
+
+	if len(dest) != len(values) {
+		r.fail(fmt.Errorf("number of field descriptions must equal number of destinations, got %d and %d", len(values), len(dest)))
+		return r.err
+	}
+
+	for i, d := range dest {
+		if d == nil {
+			continue
+		}
+
+		if err := r.typeMap.Scan(fields[i].DataTypeOID, fields[i].Format, values[i], d); err != nil {
+			r.fail(pgx.ScanArgError{ColumnIndex: i, Err: err})
+			return r.err
+		}
+	}
+
+	return nil
+}
+
+// Values returns the decoded values of the current row.
+func (r *rows) Values() ([]any, error) {
+	fields := r.rows.FieldDescriptions()
+	raw := r.rows.RawValues()
+	values := make([]any, len(raw))
+
+	for i, src := range raw {
+		if src == nil {
+			continue
+		}
+
+		dt, ok := r.typeMap.TypeForOID(fields[i].DataTypeOID)
+		if !ok {
+			values[i] = string(src)
+			continue
+		}
+
+		value, err := dt.Codec.DecodeValue(r.typeMap, fields[i].DataTypeOID, fields[i].Format, src)
+		if err != nil {
+			r.fail(err)
+			return nil, err
+		}
+
+		values[i] = value
+	}
+
+	return values, nil
+}
+
+// RawValues returns the undecoded values of the current row.
+func (r *rows) RawValues() [][]byte {
+	return r.rows.RawValues()
+}
+
+// Conn returns nil, as there is no pgx v5 connection.
+func (r *rows) Conn() *pgx.Conn {
+	return nil
+}
+
+// fail records the error and closes the rows.
+func (r *rows) fail(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+
+	r.Close()
+}
+
+// row is the first row of a query.
+type row struct {
+	rows pgx.Rows
+	err  error
+}
+
+// Scan decodes the values of the row into the destinations. It returns
+// pgx.ErrNoRows if the query returned no rows.
+func (r *row) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+
+	defer r.rows.Close()
+
+	if !r.rows.Next() {
+		if err := r.rows.Err(); err != nil {
+			return err
+		}
+
+		return pgx.ErrNoRows
+	}
+
+	if err := r.rows.Scan(dest...); err != nil {
+		return err
+	}
+
+	r.rows.Close()
+
+	return r.rows.Err()
+}
+
+// copyFromSource is a pgx v4 copy source reading a pgx v5 copy source.
+type copyFromSource struct {
+	src pgx.CopyFromSource
+}
+
+func (s copyFromSource) Next() bool {
+	return s.src.Next()
+}
+
+func (s copyFromSource) Values() ([]any, error) {
+	values, err := s.src.Values()
+	return convertArgs(values), err
+}
+
+func (s copyFromSource) Err() error {
+	return s.src.Err()
+}
+
+// convertArgs converts the arguments pgx v4 cannot encode to equivalent
+// values it can. The arguments of the caller are not modified.
+func convertArgs(args []any) []any {
+	converted := args
+
+	for i, arg := range args {
+		value, ok := convertArg(arg)
+		if !ok {
+			continue
+		}
+
+		if &converted[0] == &args[0] {
+			converted = append([]any(nil), args...)
+		}
+
+		converted[i] = value
+	}
+
+	return converted
+}
+
+// convertArg returns the value pgx v4 can encode in place of the argument
+// and true if the argument needs to be converted.
+func convertArg(arg any) (any, bool) {
+	switch arg := arg.(type) {
+	case netip.Addr:
+		if !arg.IsValid() {
+			return nil, true
+		}
+
+		return arg.String(), true
+	case netip.Prefix:
+		if !arg.IsValid() {
+			return nil, true
+		}
+
+		return arg.String(), true
+	default:
+		return nil, false
+	}
+}
+
+// convertError converts the pgx v4 errors the stores check for to their pgx
+// v5 counterparts.
+func convertError(err error) error {
+	var pgErr *pgconnv4.PgError
+
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, pgxv4.ErrNoRows):
+		return pgx.ErrNoRows
+	case errors.Is(err, pgxv4.ErrTxClosed):
+		return pgx.ErrTxClosed
+	case errors.As(err, &pgErr):
+		return &pgconn.PgError{
+			Severity:         pgErr.Severity,
+			Code:             pgErr.Code,
+			Message:          pgErr.Message,
+			Detail:           pgErr.Detail,
+			Hint:             pgErr.Hint,
+			Position:         pgErr.Position,
+			InternalPosition: pgErr.InternalPosition,
+			InternalQuery:    pgErr.InternalQuery,
+			Where:            pgErr.Where,
+			SchemaName:       pgErr.SchemaName,
+			TableName:        pgErr.TableName,
+			ColumnName:       pgErr.ColumnName,
+			DataTypeName:     pgErr.DataTypeName,
+			ConstraintName:   pgErr.ConstraintName,
+			File:             pgErr.File,
+			Line:             pgErr.Line,
+			Routine:          pgErr.Routine,
+		}
+	default:
+		return err
+	}
+}
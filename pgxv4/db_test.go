@@ -0,0 +1,127 @@
+package pgxv4
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+
+	pgconnv4 "github.com/jackc/pgconn"
+	"github.com/jackc/pgproto3/v2"
+	pgxv4 "github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// fakeRows are pgx v4 rows returning text encoded values.
+type fakeRows struct {
+	pgxv4.Rows
+	fields []pgproto3.FieldDescription
+	values [][][]byte
+	next   int
+	closed bool
+}
+
+func (r *fakeRows) Next() bool {
+	r.next++
+	return r.next <= len(r.values)
+}
+
+func (r *fakeRows) FieldDescriptions() []pgproto3.FieldDescription { return r.fields }
+func (r *fakeRows) RawValues() [][]byte                            { return r.values[r.next-1] }
+func (r *fakeRows) Err() error                                     { return nil }
+func (r *fakeRows) Close()                                         { r.closed = true }
+
+// fakePool is a pgx v4 pool returning the rows or the error for every query.
+type fakePool struct {
+	Pool
+	rows *fakeRows
+	err  error
+	args []any
+}
+
+func (p *fakePool) Query(_ context.Context, _ string, args ...any) (pgxv4.Rows, error) {
+	p.args = args
+	return p.rows, p.err
+}
+
+func (p *fakePool) Exec(_ context.Context, _ string, args ...any) (pgconnv4.CommandTag, error) {
+	p.args = args
+	return pgconnv4.CommandTag("UPDATE 1"), p.err
+}
+
+func TestDBScansWithV5Types(t *testing.T) {
+	rows := &fakeRows{
+		fields: []pgproto3.FieldDescription{
+			{Name: []byte("client_ip"), DataTypeOID: pgtype.InetOID},
+			{Name: []byte("audience"), DataTypeOID: pgtype.TextArrayOID},
+			{Name: []byte("revoked_at"), DataTypeOID: pgtype.TimestamptzOID},
+		},
+		values: [][][]byte{{[]byte("192.0.2.1"), []byte("{a,b}"), nil}},
+	}
+
+	db := New(&fakePool{rows: rows})
+
+	var ip netip.Addr
+	var audience []string
+	var revokedAt *time.Time
+
+	if err := db.QueryRow(context.Background(), "SELECT").Scan(&ip, &audience, &revokedAt); err != nil {
+		t.Fatal(err)
+	}
+
+	if ip != netip.MustParseAddr("192.0.2.1") || !reflect.DeepEqual(audience, []string{"a", "b"}) || revokedAt != nil {
+		t.Fatalf("got %v, %v and %v", ip, audience, revokedAt)
+	}
+
+	if !rows.closed {
+		t.Fatal("rows not closed")
+	}
+}
+
+func TestDBQueryRowNoRows(t *testing.T) {
+	db := New(&fakePool{rows: &fakeRows{fields: []pgproto3.FieldDescription{{DataTypeOID: pgtype.TextOID}}}})
+
+	var value string
+	if err := db.QueryRow(context.Background(), "SELECT").Scan(&value); !errors.Is(err, pgx.ErrNoRows) {
+		t.Fatalf("got %v, want %v", err, pgx.ErrNoRows)
+	}
+}
+
+func TestDBConvertsErrors(t *testing.T) {
+	db := New(&fakePool{err: &pgconnv4.PgError{Code: "23505", ConstraintName: "unique_access"}})
+
+	_, err := db.Exec(context.Background(), "INSERT")
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "23505" || pgErr.ConstraintName != "unique_access" {
+		t.Fatalf("got %v, want a pgx v5 unique violation", err)
+	}
+
+	for v4Err, want := range map[error]error{pgxv4.ErrNoRows: pgx.ErrNoRows, pgxv4.ErrTxClosed: pgx.ErrTxClosed} {
+		if err := convertError(v4Err); !errors.Is(err, want) {
+			t.Errorf("got %v, want %v", err, want)
+		}
+	}
+}
+
+func TestDBConvertsArgs(t *testing.T) {
+	pool := &fakePool{}
+	db := New(pool)
+
+	args := []any{netip.MustParseAddr("2001:db8::1"), netip.Addr{}, "value"}
+	if _, err := db.Exec(context.Background(), "INSERT", args...); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []any{"2001:db8::1", nil, "value"}; !reflect.DeepEqual(pool.args, want) {
+		t.Fatalf("got %v, want %v", pool.args, want)
+	}
+
+	if _, ok := args[0].(netip.Addr); !ok {
+		t.Fatal("arguments of the caller modified")
+	}
+}
@@ -0,0 +1,205 @@
+package pgstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// defaultPoolMaxConns is the maximum number of connections of a pool
+	// created from a pool configuration. OAuth workloads run many short
+	// queries, so more connections than the pgxpool default are kept.
+	defaultPoolMaxConns = 20
+	// defaultPoolHealthCheckPeriod is the health check period of a pool
+	// created from a pool configuration, short enough to drop the
+	// connections to a failed over server quickly.
+	defaultPoolHealthCheckPeriod = 15 * time.Second
+	// defaultPoolMaxConnLifetime is the maximum connection lifetime of a pool
+	// created from a pool configuration, so the connections are rebalanced
+	// after a failover.
+	defaultPoolMaxConnLifetime = 30 * time.Minute
+	// defaultPoolMaxConnLifetimeJitter spreads the reconnections of the
+	// connections reaching their maximum lifetime.
+	defaultPoolMaxConnLifetimeJitter = 5 * time.Minute
+)
+
+// poolOptions configures a connection pool created and owned by a store.
+type poolOptions struct {
+	config    *pgxpool.Config
+	dsn       string
+	minConns  int32
+	maxConns  int32
+	pgBouncer bool
+	tracer    pgx.QueryTracer
+}
+
+// configured returns true if a connection pool should be created.
+func (o *poolOptions) configured() bool {
+	return o.config != nil || o.dsn != ""
+}
+
+// poolConfig returns a copy of the pool configuration with the defaults
+// applied, or the configuration parsed from the DSN.
+func (o *poolOptions) poolConfig() (*pgxpool.Config, error) {
+	if o.config == nil {
+		return pgxpool.ParseConfig(o.dsn)
+	}
+
+	cfg := o.config.Copy()
+	applyPoolDefaults(cfg)
+
+	return cfg, nil
+}
+
+// newPool creates a new connection pool using the options.
+func (o *poolOptions) newPool(ctx context.Context) (*pgxpool.Pool, error) {
+	cfg, err := o.poolConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if o.maxConns > 0 {
+		cfg.MaxConns = o.maxConns
+	}
+
+	if o.minConns > 0 {
+		cfg.MinConns = o.minConns
+	}
+
+	if o.pgBouncer {
+		ConfigurePgBouncer(cfg)
+	}
+
+	if o.tracer != nil {
+		cfg.ConnConfig.Tracer = o.tracer
+	}
+
+	return pgxpool.NewWithConfig(ctx, cfg)
+}
+
+// applyPoolDefaults applies the defaults for OAuth workloads to the maximum
+// number of connections, health check period and maximum connection lifetime
+// of the configuration left at the pgxpool defaults, so the settings of the
+// connection string are kept.
+func applyPoolDefaults(cfg *pgxpool.Config) {
+	base, err := pgxpool.ParseConfig("")
+	if err != nil {
+		return
+	}
+
+	if cfg.MaxConns == base.MaxConns {
+		cfg.MaxConns = defaultPoolMaxConns
+	}
+
+	if cfg.HealthCheckPeriod == base.HealthCheckPeriod {
+		cfg.HealthCheckPeriod = defaultPoolHealthCheckPeriod
+	}
+
+	if cfg.MaxConnLifetime == base.MaxConnLifetime {
+		cfg.MaxConnLifetime = defaultPoolMaxConnLifetime
+	}
+
+	if cfg.MaxConnLifetimeJitter == base.MaxConnLifetimeJitter {
+		cfg.MaxConnLifetimeJitter = defaultPoolMaxConnLifetimeJitter
+	}
+}
+
+// WithTokenStorePoolConfig configures the store to create a connection pool
+// from the configuration, which must be created by pgxpool.ParseConfig. The
+// store owns the pool and closes it on Close. The maximum number of
+// connections, health check period and maximum connection lifetime not set in
+// the connection string default to values suited for OAuth workloads. The
+// configuration takes precedence over the DSN and is not used if a connection
+// pool is configured.
+func WithTokenStorePoolConfig(cfg *pgxpool.Config) TokenStoreOption {
+	return func(s *TokenStore) error {
+		if cfg == nil {
+			return ErrNoPoolConfig
+		}
+
+		s.poolOpts.config = cfg
+
+		return nil
+	}
+}
+
+// WithClientStorePoolConfig configures the store to create a connection pool
+// from the configuration, which must be created by pgxpool.ParseConfig. The
+// defaults of WithTokenStorePoolConfig apply.
+func WithClientStorePoolConfig(cfg *pgxpool.Config) ClientStoreOption {
+	return func(s *ClientStore) error {
+		if cfg == nil {
+			return ErrNoPoolConfig
+		}
+
+		s.poolOpts.config = cfg
+
+		return nil
+	}
+}
+
+// WithStoresPoolConfig configures the stores to create a shared connection
+// pool from the configuration, which must be created by pgxpool.ParseConfig.
+// The defaults of WithTokenStorePoolConfig apply.
+func WithStoresPoolConfig(cfg *pgxpool.Config) StoresOption {
+	return func(c *storesConfig) error {
+		if cfg == nil {
+			return ErrNoPoolConfig
+		}
+
+		c.poolOpts.config = cfg
+
+		return nil
+	}
+}
+
+// ConfigurePgBouncer configures the connection pool to work behind PgBouncer
+// in transaction pooling mode. The queries are sent with the simple protocol,
+// so no prepared statement outlives the transaction of the server connection
+// it was prepared on. Session state, such as the encryption key set by
+// SetEncryptionKey, is not kept in transaction pooling mode either.
+func ConfigurePgBouncer(cfg *pgxpool.Config) {
+	cfg.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeSimpleProtocol
+}
+
+// WithTokenStorePgBouncer configures the connection pool created from the DSN
+// to work behind PgBouncer in transaction pooling mode. Use
+// ConfigurePgBouncer for a connection pool created by the application.
+func WithTokenStorePgBouncer() TokenStoreOption {
+	return func(s *TokenStore) error {
+		s.poolOpts.pgBouncer = true
+		return nil
+	}
+}
+
+// WithClientStorePgBouncer configures the connection pool created from the
+// DSN to work behind PgBouncer in transaction pooling mode. Use
+// ConfigurePgBouncer for a connection pool created by the application.
+func WithClientStorePgBouncer() ClientStoreOption {
+	return func(s *ClientStore) error {
+		s.poolOpts.pgBouncer = true
+		return nil
+	}
+}
+
+// WithStoresPgBouncer configures the connection pool created from the DSN to
+// work behind PgBouncer in transaction pooling mode. Use ConfigurePgBouncer
+// for a connection pool created by the application.
+func WithStoresPgBouncer() StoresOption {
+	return func(c *storesConfig) error {
+		c.poolOpts.pgBouncer = true
+		return nil
+	}
+}
+
+// validatePoolSize validates the connection pool size limits.
+func validatePoolSize(minConns, maxConns int32) error {
+	if minConns < 0 || maxConns <= 0 || minConns > maxConns {
+		return ErrInvalidPoolSize
+	}
+
+	return nil
+}
@@ -0,0 +1,188 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestStoreDSNOptionsInvalid(t *testing.T) {
+	for name, err := range map[string]error{
+		"token store empty DSN":        WithTokenStoreDSN("")(&TokenStore{}),
+		"client store empty DSN":       WithClientStoreDSN("")(&ClientStore{}),
+		"negative minimum connections": WithTokenStorePoolSize(-1, 5)(&TokenStore{}),
+		"no maximum connections":       WithTokenStorePoolSize(0, 0)(&TokenStore{}),
+		"minimum above maximum":        WithClientStorePoolSize(5, 2)(&ClientStore{}),
+	} {
+		if !errors.Is(err, ErrNoDSN) && !errors.Is(err, ErrInvalidPoolSize) {
+			t.Errorf("%s: got %v, want a configuration error", name, err)
+		}
+	}
+}
+
+func TestNewStoresOwnPoolFromDSN(t *testing.T) {
+	ctx := context.Background()
+	dsn := "postgres://user@localhost:5432/oauth2"
+
+	tokens, err := newTokenStore(WithTokenStoreDSN(dsn), WithTokenStorePoolSize(0, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clients, err := NewClientStore(WithClientStoreDSN(dsn), WithClientStorePoolSize(0, 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, store := range map[string]struct {
+		db       DB
+		ownsPool bool
+		maxConns int32
+		close    func(context.Context)
+	}{
+		"token store":  {db: tokens.pool, ownsPool: tokens.ownsPool, maxConns: 3, close: tokens.Close},
+		"client store": {db: clients.pool, ownsPool: clients.ownsPool, maxConns: 4, close: clients.Close},
+	} {
+		pool, ok := nativePool(store.db)
+		if !ok || !store.ownsPool {
+			t.Fatalf("%s: got no connection pool owned by the store", name)
+		}
+
+		if got := pool.Config().MaxConns; got != store.maxConns {
+			t.Errorf("%s: got %d maximum connections, want %d", name, got, store.maxConns)
+		}
+
+		store.close(ctx)
+
+		if _, err = pool.Acquire(ctx); err == nil {
+			t.Errorf("%s: connection pool not closed with the store", name)
+		}
+	}
+}
+
+func TestNewTokenStoreInvalidDSN(t *testing.T) {
+	if _, err := newTokenStore(WithTokenStoreDSN("postgres://localhost:port/oauth2")); err == nil {
+		t.Fatal("got a store for an invalid DSN")
+	}
+}
+
+func TestPgBouncerUsesSimpleProtocol(t *testing.T) {
+	ctx := context.Background()
+	dsn := "postgres://user@localhost:5432/oauth2"
+
+	tokens, err := newTokenStore(WithTokenStoreDSN(dsn), WithTokenStorePgBouncer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tokens.Close(ctx)
+
+	clients, err := NewClientStore(WithClientStoreDSN(dsn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clients.Close(ctx)
+
+	stores, err := NewStores(ctx, WithStoresDSN(dsn), WithStoresPgBouncer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stores.Close(ctx)
+
+	for name, tt := range map[string]struct {
+		db   DB
+		want bool
+	}{
+		"token store":  {db: tokens.pool, want: true},
+		"client store": {db: clients.pool, want: false},
+		"stores":       {db: stores.pool, want: true},
+	} {
+		pool, ok := nativePool(tt.db)
+		if !ok {
+			t.Fatalf("%s: got no connection pool", name)
+		}
+
+		if got := pool.Config().ConnConfig.DefaultQueryExecMode == pgx.QueryExecModeSimpleProtocol; got != tt.want {
+			t.Errorf("%s: got simple protocol %t, want %t", name, got, tt.want)
+		}
+	}
+}
+
+func TestPoolConfigDefaults(t *testing.T) {
+	for name, tt := range map[string]struct {
+		dsn             string
+		maxConns        int32
+		healthCheck     time.Duration
+		maxConnLifetime time.Duration
+	}{
+		"defaults": {
+			dsn:             "postgres://user@localhost:5432/oauth2",
+			maxConns:        defaultPoolMaxConns,
+			healthCheck:     defaultPoolHealthCheckPeriod,
+			maxConnLifetime: defaultPoolMaxConnLifetime,
+		},
+		"connection string": {
+			dsn:             "postgres://user@localhost:5432/oauth2?pool_max_conns=5&pool_health_check_period=30s&pool_max_conn_lifetime=2h",
+			maxConns:        5,
+			healthCheck:     30 * time.Second,
+			maxConnLifetime: 2 * time.Hour,
+		},
+	} {
+		cfg, err := pgxpool.ParseConfig(tt.dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		opts := poolOptions{config: cfg}
+
+		got, err := opts.poolConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got.MaxConns != tt.maxConns || got.HealthCheckPeriod != tt.healthCheck || got.MaxConnLifetime != tt.maxConnLifetime {
+			t.Errorf("%s: got %d connections, health check %s and lifetime %s", name, got.MaxConns, got.HealthCheckPeriod, got.MaxConnLifetime)
+		}
+
+		if got == cfg {
+			t.Errorf("%s: got the configuration of the application, want a copy", name)
+		}
+	}
+}
+
+func TestPoolConfigOptions(t *testing.T) {
+	ctx := context.Background()
+
+	for name, err := range map[string]error{
+		"token store":  WithTokenStorePoolConfig(nil)(&TokenStore{}),
+		"client store": WithClientStorePoolConfig(nil)(&ClientStore{}),
+		"stores":       WithStoresPoolConfig(nil)(&storesConfig{}),
+	} {
+		if !errors.Is(err, ErrNoPoolConfig) {
+			t.Errorf("%s: got error %v, want %v", name, err, ErrNoPoolConfig)
+		}
+	}
+
+	cfg, err := pgxpool.ParseConfig("postgres://user@localhost:5432/oauth2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := newTokenStore(WithTokenStorePoolConfig(cfg), WithTokenStorePoolSize(0, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close(ctx)
+
+	pool, ok := nativePool(store.pool)
+	if !ok || !store.ownsPool {
+		t.Fatal("got no connection pool owned by the store")
+	}
+
+	if got := pool.Config(); got.MaxConns != 3 || got.HealthCheckPeriod != defaultPoolHealthCheckPeriod {
+		t.Fatalf("got %d connections and health check %s, want the pool size and the defaults", got.MaxConns, got.HealthCheckPeriod)
+	}
+}
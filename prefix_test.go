@@ -0,0 +1,68 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestPrefixedName(t *testing.T) {
+	for table, want := range map[string]string{
+		"oauth2_tokens":      "app_oauth2_tokens",
+		"auth.oauth2_tokens": "auth.app_oauth2_tokens",
+	} {
+		if got := prefixedName("app_", table); got != want {
+			t.Errorf("%s: got %s, want %s", table, got, want)
+		}
+	}
+}
+
+func TestStoresTablePrefix(t *testing.T) {
+	ctx := context.Background()
+
+	stores, err := NewStores(ctx, WithStoresDB(unusedDB{}), WithStoresSchema("auth"), WithStoresTablePrefix("app_"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stores.Close(ctx)
+
+	for name, tt := range map[string]struct{ got, want string }{
+		"token":         {got: stores.TokenStore.table, want: "auth.app_" + DefaultTokenStoreTable},
+		"client":        {got: stores.ClientStore.table, want: "auth.app_" + DefaultClientStoreTable},
+		"client secret": {got: stores.ClientStore.secretTable, want: "auth.app_" + DefaultClientSecretTable},
+		"scope":         {got: stores.ClientStore.scopeTable, want: "auth.app_" + DefaultScopeTable},
+		"consent":       {got: stores.ConsentStore.table, want: "auth.app_" + DefaultConsentStoreTable},
+	} {
+		if tt.got != tt.want {
+			t.Errorf("%s: got table %s, want %s", name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestTokenStoresTablePrefixShareDatabase(t *testing.T) {
+	dsn := newTestDSN(t)
+	ctx := context.Background()
+
+	stores := make([]*TokenStore, 0, 2)
+	for _, prefix := range []string{"a_", "b_"} {
+		store, err := NewTokenStore(WithTokenStoreDSN(dsn), WithTokenStoreTablePrefix(prefix))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { store.Close(ctx) })
+
+		if err = store.InitTable(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		stores = append(stores, store)
+	}
+
+	if err := stores[0].Create(ctx, newTestToken("client", "user", "a")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := stores[1].GetByAccess(ctx, "access-a"); !errors.Is(err, ErrTokenNotFound) {
+		t.Fatalf("got error %v, want %v", err, ErrTokenNotFound)
+	}
+}
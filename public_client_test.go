@@ -0,0 +1,58 @@
+package pgstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+)
+
+func TestClientStorePublicClient(t *testing.T) {
+	now := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	store := newTestClientStore(t, WithClientStoreClock(&fixedClock{now: now}))
+	ctx := context.Background()
+
+	if err := store.Create(&models.Client{ID: "client", Domain: "https://example.com", Public: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := store.GetByID(ctx, "client")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, ok := info.(*Client)
+	if !ok {
+		t.Fatalf("got %T, want %T", info, client)
+	}
+
+	if !client.IsPublic() || !client.CreatedAt.Equal(now) {
+		t.Fatalf("got public %t created at %v, want a public client created at %v", client.IsPublic(), client.CreatedAt, now)
+	}
+
+	if err = store.Upsert(ctx, &models.Client{ID: "client", Domain: "https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if info, err = store.GetByID(ctx, "client"); err != nil {
+		t.Fatal(err)
+	}
+
+	if info.IsPublic() {
+		t.Fatal("got a public client after upserting a confidential one")
+	}
+
+	// The column takes precedence over the data.
+	if _, err = store.pool.Exec(ctx, "UPDATE "+store.table+" SET is_public = TRUE WHERE id = 'client'"); err != nil {
+		t.Fatal(err)
+	}
+
+	if info, err = store.GetByID(ctx, "client"); err != nil {
+		t.Fatal(err)
+	}
+
+	if !info.IsPublic() {
+		t.Fatal("got a confidential client, want the flag of the column")
+	}
+}
@@ -0,0 +1,234 @@
+package pgstore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// QuotaPolicy is the action taken when creating a token would exceed a token
+// quota.
+type QuotaPolicy int
+
+const (
+	// QuotaReject rejects the new token with ErrQuotaExceeded.
+	QuotaReject QuotaPolicy = iota
+	// QuotaEvictOldest removes the oldest active tokens, or revokes them if
+	// soft revocation is enabled, to make room for the new token.
+	QuotaEvictOldest
+)
+
+// quota limits the number of active tokens.
+type quota struct {
+	limit  int
+	policy QuotaPolicy
+}
+
+// WithTokenStoreMaxTokensPerClient limits the number of active tokens of a
+// client, so a buggy client cannot flood the token table. Once the client has
+// the given number of unexpired, unrevoked and unrotated tokens, Create either
+// rejects the new token or evicts the oldest ones, depending on the policy.
+// The quota is checked in the transaction inserting the token, serialized
+// per client. CreateBatch does not check the quota.
+func WithTokenStoreMaxTokensPerClient(limit int, policy QuotaPolicy) TokenStoreOption {
+	return func(s *TokenStore) error {
+		if limit <= 0 || (policy != QuotaReject && policy != QuotaEvictOldest) {
+			return ErrInvalidQuota
+		}
+
+		s.clientQuota = quota{limit: limit, policy: policy}
+
+		return nil
+	}
+}
+
+// WithTokenStoreMaxSessionsPerUser limits the number of concurrent sessions
+// of a user, counted as the active refresh tokens of the user. Creating a
+// token with a refresh token once the user has the given number of sessions
+// removes the tokens of the oldest sessions, or revokes them if soft
+// revocation is enabled, in the transaction inserting the token. Refresh
+// token rotation keeps the session of the rotated token.
+func WithTokenStoreMaxSessionsPerUser(limit int) TokenStoreOption {
+	return func(s *TokenStore) error {
+		if limit <= 0 {
+			return ErrInvalidQuota
+		}
+
+		s.sessionLimit = limit
+
+		return nil
+	}
+}
+
+// hasQuota returns true if a quota or the rate limit applies to the item.
+func (s *TokenStore) hasQuota(item TokenStoreItem) bool {
+	return ((s.clientQuota.limit > 0 || s.rateLimit > 0) && item.ClientID != "") ||
+		(s.sessionLimit > 0 && item.UserID != "" && item.Refresh != "")
+}
+
+// activeCondition returns the condition matching the active tokens whose
+// column equals $1 at the time given as $2.
+func (s *TokenStore) activeCondition(column string) string {
+	return fmt.Sprintf("%s = $1 AND %s%s", column, s.activePredicate("$2"), s.andTenant())
+}
+
+// lockQuota takes a transaction level advisory lock on the value of the
+// column, serializing the quota checks of the value.
+func (s *TokenStore) lockQuota(ctx context.Context, tx pgx.Tx, column string, value string) error {
+	_, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", s.table+":"+column+":"+value)
+	return err
+}
+
+// evict removes the tokens matching the condition on the values in the
+// transaction and returns their data.
+func (s *TokenStore) evict(ctx context.Context, tx pgx.Tx, cond string, values ...any) ([][]byte, error) {
+	query, args := s.removeQuery(cond, values...)
+
+	rows, err := tx.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowTo[[]byte])
+}
+
+// enforceRateLimit returns ErrRateLimited if the client was issued too many
+// tokens within the rate limit window.
+func (s *TokenStore) enforceRateLimit(ctx context.Context, tx pgx.Tx, item TokenStoreItem) error {
+	if s.rateLimit == 0 || item.ClientID == "" {
+		return nil
+	}
+
+	if err := s.lockQuota(ctx, tx, "client_id", item.ClientID); err != nil {
+		return err
+	}
+
+	var count int
+	err := tx.QueryRow(ctx, fmt.Sprintf(
+		"SELECT COUNT(*) FROM %s WHERE client_id = $1 AND %s > $2%s",
+		s.table, s.columns.CreatedAt, s.andTenant(),
+	), item.ClientID, item.CreatedAt.Add(-s.rateLimitWindow)).Scan(&count)
+
+	if err != nil {
+		return err
+	}
+
+	if count >= s.rateLimit {
+		s.logger.Log(ctx, LogLevelWarn, "token rate limit exceeded", "client_id", item.ClientID, "count", count)
+		return ErrRateLimited
+	}
+
+	return nil
+}
+
+// enforceClientQuota checks the client quota before the item is inserted in
+// the transaction, returning the data of the evicted tokens.
+func (s *TokenStore) enforceClientQuota(ctx context.Context, tx pgx.Tx, item TokenStoreItem) ([][]byte, error) {
+	if s.clientQuota.limit == 0 || item.ClientID == "" {
+		return nil, nil
+	}
+
+	if err := s.lockQuota(ctx, tx, "client_id", item.ClientID); err != nil {
+		return nil, err
+	}
+
+	cond := s.activeCondition("client_id")
+
+	var count int
+	if err := tx.QueryRow(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", s.table, cond), item.ClientID, item.CreatedAt).Scan(&count); err != nil {
+		return nil, err
+	}
+
+	if count < s.clientQuota.limit {
+		return nil, nil
+	}
+
+	if s.clientQuota.policy == QuotaReject {
+		s.logger.Log(ctx, LogLevelWarn, "client token quota exceeded", "client_id", item.ClientID, "count", count)
+		return nil, ErrQuotaExceeded
+	}
+
+	evicted, err := s.evict(ctx, tx, fmt.Sprintf(
+		"id IN (SELECT id FROM %s WHERE %s ORDER BY %s, id LIMIT %d)",
+		s.table, cond, s.columns.CreatedAt, count-s.clientQuota.limit+1,
+	), item.ClientID, item.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Log(ctx, LogLevelInfo, "evicted tokens over the client quota", "client_id", item.ClientID, "count", len(evicted))
+
+	return evicted, nil
+}
+
+// enforceSessionLimit checks the session limit of the user before the item is
+// inserted in the transaction, returning the data of the tokens of the
+// removed sessions.
+func (s *TokenStore) enforceSessionLimit(ctx context.Context, tx pgx.Tx, item TokenStoreItem) ([][]byte, error) {
+	if s.sessionLimit == 0 || item.UserID == "" || item.Refresh == "" {
+		return nil, nil
+	}
+
+	if err := s.lockQuota(ctx, tx, "user_id", item.UserID); err != nil {
+		return nil, err
+	}
+
+	cond := s.activeCondition("user_id") + " AND " + s.columns.Refresh + " <> ''"
+
+	var count int
+	if err := tx.QueryRow(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", s.table, cond), item.UserID, item.CreatedAt).Scan(&count); err != nil {
+		return nil, err
+	}
+
+	if count < s.sessionLimit {
+		return nil, nil
+	}
+
+	// The whole families of the oldest sessions are removed. The tokens created
+	// before the families were tracked have no family and are removed by ID.
+	oldest := fmt.Sprintf("FROM %s WHERE %s ORDER BY %s, id LIMIT %d", s.table, cond, s.columns.CreatedAt, count-s.sessionLimit+1)
+
+	evicted, err := s.evict(ctx, tx, fmt.Sprintf(
+		"user_id = $1 AND (id IN (SELECT id %[1]s) OR (family_id <> '' AND family_id IN (SELECT family_id %[1]s)))",
+		oldest,
+	), item.UserID, item.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Log(ctx, LogLevelInfo, "removed sessions over the user limit", "user_id", item.UserID, "count", len(evicted))
+
+	return evicted, nil
+}
+
+// insertWithQuota inserts the item after enforcing the rate limit and quota
+// of the client and the session limit of the user in the same transaction.
+func (s *TokenStore) insertWithQuota(ctx context.Context, item TokenStoreItem) error {
+	var evicted [][]byte
+
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		if err := s.enforceRateLimit(ctx, tx, item); err != nil {
+			return err
+		}
+
+		for _, enforce := range []func(context.Context, pgx.Tx, TokenStoreItem) ([][]byte, error){
+			s.enforceClientQuota, s.enforceSessionLimit,
+		} {
+			removed, err := enforce(ctx, tx, item)
+			if err != nil {
+				return err
+			}
+
+			evicted = append(evicted, removed...)
+		}
+
+		return s.insert(ctx, tx, item)
+	})
+
+	if err == nil && len(evicted) > 0 {
+		s.notifyRemoved(ctx, evicted)
+	}
+
+	return err
+}
@@ -0,0 +1,107 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTokenStoreRateLimitConcurrentCreate(t *testing.T) {
+	const limit = 5
+
+	store := newTestTokenStore(t, WithTokenStoreRateLimit(limit, time.Hour))
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 4*limit)
+
+	for i := 0; i < 4*limit; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+			errs <- store.Create(ctx, newTestToken("client", "user", strconv.Itoa(i)))
+		}(i)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	var created, limited int
+	for err := range errs {
+		switch {
+		case err == nil:
+			created++
+		case errors.Is(err, ErrRateLimited):
+			limited++
+		default:
+			t.Fatal(err)
+		}
+	}
+
+	if created != limit || limited != 3*limit {
+		t.Fatalf("got %d created and %d rate limited tokens, want %d and %d", created, limited, limit, 3*limit)
+	}
+}
+
+func TestTokenStoreMaxSessionsPerUserLegacyRows(t *testing.T) {
+	store := newTestTokenStore(t, WithTokenStoreMaxSessionsPerUser(2))
+	ctx := context.Background()
+
+	for i, token := range []struct{ userID, suffix string }{{"user", "a"}, {"user", "b"}, {"other", "c"}} {
+		created := newTestToken("client", token.userID, token.suffix)
+		created.AccessCreateAt = created.AccessCreateAt.Add(time.Duration(i) * time.Second)
+
+		if err := store.Create(ctx, created); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Rows created before the families were tracked have no family.
+	if _, err := store.pool.Exec(ctx, "UPDATE "+store.table+" SET family_id = ''"); err != nil {
+		t.Fatal(err)
+	}
+
+	created := newTestToken("client", "user", "d")
+	created.AccessCreateAt = created.AccessCreateAt.Add(time.Minute)
+
+	if err := store.Create(ctx, created); err != nil {
+		t.Fatal(err)
+	}
+
+	for access, want := range map[string]bool{"access-a": false, "access-b": true, "access-c": true, "access-d": true} {
+		info, err := store.GetByAccess(ctx, access)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := info != nil; got != want {
+			t.Errorf("got token %s stored %t, want %t", access, got, want)
+		}
+	}
+}
+
+func TestTokenStoreQuotaDoesNotOpenCircuitBreaker(t *testing.T) {
+	store := newTestTokenStore(t,
+		WithTokenStoreCircuitBreaker(1, time.Hour),
+		WithTokenStoreMaxTokensPerClient(1, QuotaReject),
+	)
+	ctx := context.Background()
+
+	if err := store.Create(ctx, newTestToken("client", "user", "a")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, suffix := range []string{"b", "c"} {
+		if err := store.Create(ctx, newTestToken("client", "user", suffix)); !errors.Is(err, ErrQuotaExceeded) {
+			t.Fatalf("got error %v, want %v", err, ErrQuotaExceeded)
+		}
+	}
+
+	if err := store.Create(ctx, newTestToken("other", "user", "d")); err != nil {
+		t.Fatalf("got error %v creating the token of another client", err)
+	}
+}
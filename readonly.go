@@ -0,0 +1,51 @@
+package pgstore
+
+// WithTokenStoreReadOnly configures the store to reject every write with
+// ErrReadOnly and not to start the cleanup and usage tracking, so it can be
+// used with read-only database credentials, for example by reporting
+// services.
+func WithTokenStoreReadOnly(readOnly bool) TokenStoreOption {
+	return func(s *TokenStore) error {
+		s.readOnly = readOnly
+		return nil
+	}
+}
+
+// WithClientStoreReadOnly configures the store to reject every write with
+// ErrReadOnly, so it can be used with read-only database credentials.
+func WithClientStoreReadOnly(readOnly bool) ClientStoreOption {
+	return func(s *ClientStore) error {
+		s.readOnly = readOnly
+		return nil
+	}
+}
+
+// WithConsentStoreReadOnly configures the store to reject every write with
+// ErrReadOnly, so it can be used with read-only database credentials.
+func WithConsentStoreReadOnly(readOnly bool) ConsentStoreOption {
+	return func(s *ConsentStore) error {
+		s.readOnly = readOnly
+		return nil
+	}
+}
+
+// WithJTIStoreReadOnly configures the store to reject every write with
+// ErrReadOnly, so it can be used with read-only database credentials.
+func WithJTIStoreReadOnly(readOnly bool) JTIStoreOption {
+	return func(s *JTIStore) error {
+		s.readOnly = readOnly
+		return nil
+	}
+}
+
+// WithStoresReadOnly configures the token, client and consent stores to
+// reject every write with ErrReadOnly.
+func WithStoresReadOnly(readOnly bool) StoresOption {
+	return func(c *storesConfig) error {
+		c.tokenOpts = append(c.tokenOpts, WithTokenStoreReadOnly(readOnly))
+		c.clientOpts = append(c.clientOpts, WithClientStoreReadOnly(readOnly))
+		c.consentOpts = append(c.consentOpts, WithConsentStoreReadOnly(readOnly))
+
+		return nil
+	}
+}
@@ -0,0 +1,65 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+)
+
+func TestReadOnlyStoresRejectWrites(t *testing.T) {
+	ctx := context.Background()
+
+	tokens, err := NewTokenStore(WithTokenStoreDB(unusedDB{}), WithTokenStoreReadOnly(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tokens.Close(ctx)
+
+	consents, err := NewConsentStore(WithConsentStoreDB(unusedDB{}), WithConsentStoreReadOnly(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jtis, err := NewJTIStore(WithJTIStoreDB(unusedDB{}), WithJTIStoreReadOnly(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, write := range map[string]func() error{
+		"CreateChangeFeedSlot":   func() error { return tokens.CreateChangeFeedSlot(ctx, "slot") },
+		"DropChangeFeedSlot":     func() error { return tokens.DropChangeFeedSlot(ctx, "slot") },
+		"ConsentStore.InitTable": func() error { return consents.InitTable(ctx) },
+		"ConsentStore.Grant": func() error {
+			return consents.Grant(ctx, "user", "client", []string{"read"}, time.Now().Add(time.Hour))
+		},
+		"ConsentStore.Revoke": func() error { return consents.Revoke(ctx, "user", "client") },
+		"JTIStore.InitTable":  func() error { return jtis.InitTable(ctx) },
+		"JTIStore.Create":     func() error { return jtis.Create(ctx, "jti", &models.Token{}) },
+		"JTIStore.Revoke":     func() error { return jtis.Revoke(ctx, "jti") },
+		"JTIStore.RemoveExpired": func() error {
+			_, err := jtis.RemoveExpired(ctx)
+			return err
+		},
+	} {
+		if err := write(); !errors.Is(err, ErrReadOnly) {
+			t.Errorf("%s: got %v, want %v", name, err, ErrReadOnly)
+		}
+	}
+}
+
+func TestNewStoresReadOnly(t *testing.T) {
+	ctx := context.Background()
+
+	stores, err := NewStores(ctx, WithStoresDB(unusedDB{}), WithStoresReadOnly(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stores.Close(ctx)
+
+	if err = stores.TokenStore.Create(ctx, &models.Token{}); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("got %v, want %v", err, ErrReadOnly)
+	}
+}
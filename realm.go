@@ -0,0 +1,94 @@
+package pgstore
+
+import (
+	"regexp"
+	"strings"
+)
+
+// realmPattern matches the valid realm names, such as issuer URLs.
+var realmPattern = regexp.MustCompile(`^[A-Za-z0-9._:/@-]+$`)
+
+// validateRealm validates the realm name.
+func validateRealm(realm string) error {
+	if !realmPattern.MatchString(realm) {
+		return ErrInvalidRealm
+	}
+
+	return nil
+}
+
+// realmCondition returns the condition matching the rows of the realm, or an
+// empty string if no realm is configured. The realm is validated, so it is
+// safe to inline it as a literal, which keeps the parameters of the queries
+// unchanged.
+func realmCondition(realm string) string {
+	if realm == "" {
+		return ""
+	}
+
+	return "realm = '" + strings.ReplaceAll(realm, "'", "''") + "'"
+}
+
+// andRealm returns the realm condition appended to other conditions.
+func andRealm(realm string) string {
+	if realm == "" {
+		return ""
+	}
+
+	return " AND " + realmCondition(realm)
+}
+
+// whereRealm returns the realm condition as the only condition of a query.
+func whereRealm(realm string) string {
+	if realm == "" {
+		return ""
+	}
+
+	return " WHERE " + realmCondition(realm)
+}
+
+// WithTokenStoreRealm configures the realm of the store, so one table can hold
+// the tokens of several OAuth issuers. Every token is stored with the realm
+// and only the tokens of the realm are read and removed. The cleanup removes
+// the expired tokens of every realm.
+func WithTokenStoreRealm(realm string) TokenStoreOption {
+	return func(s *TokenStore) error {
+		if err := validateRealm(realm); err != nil {
+			return err
+		}
+
+		s.realm = realm
+
+		return nil
+	}
+}
+
+// WithClientStoreRealm configures the realm of the store, so one table can
+// hold the clients of several OAuth issuers. Every client is stored with the
+// realm and only the clients of the realm are read and changed. The client
+// IDs must be unique across the realms.
+func WithClientStoreRealm(realm string) ClientStoreOption {
+	return func(s *ClientStore) error {
+		if err := validateRealm(realm); err != nil {
+			return err
+		}
+
+		s.realm = realm
+
+		return nil
+	}
+}
+
+// WithStoresRealm configures the realm of the token and client stores.
+func WithStoresRealm(realm string) StoresOption {
+	return func(c *storesConfig) error {
+		if err := validateRealm(realm); err != nil {
+			return err
+		}
+
+		c.tokenOpts = append(c.tokenOpts, WithTokenStoreRealm(realm))
+		c.clientOpts = append(c.clientOpts, WithClientStoreRealm(realm))
+
+		return nil
+	}
+}
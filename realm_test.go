@@ -0,0 +1,77 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+)
+
+func TestClientStoreChildTablesRealm(t *testing.T) {
+	stores := newTestRealmClientStores(t, "a", "b")
+	ctx := context.Background()
+
+	if err := stores["a"].Upsert(ctx, &models.Client{ID: "client"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := stores["a"].UpsertScope(ctx, Scope{Name: "read"}); err != nil {
+		t.Fatal(err)
+	}
+
+	secretID, err := stores["a"].AddSecret(ctx, "client", "secret", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = stores["a"].SetRedirectURIs(ctx, "client", []RedirectURI{{URI: "https://example.com/callback"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = stores["a"].SetAllowedScopes(ctx, "client", []string{"read"}); err != nil {
+		t.Fatal(err)
+	}
+
+	other := stores["b"]
+
+	if _, err = other.AddSecret(ctx, "client", "other", time.Time{}); !errors.Is(err, ErrClientNotFound) {
+		t.Fatalf("AddSecret: got %v, want %v", err, ErrClientNotFound)
+	}
+
+	if err = other.RevokeSecret(ctx, "client", secretID); !errors.Is(err, ErrClientSecretNotFound) {
+		t.Fatalf("RevokeSecret: got %v, want %v", err, ErrClientSecretNotFound)
+	}
+
+	if err = other.SetRedirectURIs(ctx, "client", []RedirectURI{{URI: "https://evil.com/"}}); !errors.Is(err, ErrClientNotFound) {
+		t.Fatalf("SetRedirectURIs: got %v, want %v", err, ErrClientNotFound)
+	}
+
+	if err = other.SetAllowedScopes(ctx, "client", nil); !errors.Is(err, ErrClientNotFound) {
+		t.Fatalf("SetAllowedScopes: got %v, want %v", err, ErrClientNotFound)
+	}
+
+	uris, err := other.GetRedirectURIs(ctx, "client")
+	if err != nil || len(uris) != 0 {
+		t.Fatalf("GetRedirectURIs: got %v and error %v, want none", uris, err)
+	}
+
+	valid, err := other.ValidateRedirectURI(ctx, "client", "https://example.com/callback")
+	if err != nil || valid {
+		t.Fatalf("ValidateRedirectURI: got %t and error %v, want it invalid", valid, err)
+	}
+
+	denied, err := other.ValidateScopes(ctx, "client", []string{"read"})
+	if err != nil || len(denied) != 1 {
+		t.Fatalf("ValidateScopes: got denied %v and error %v, want the scope denied", denied, err)
+	}
+
+	if _, err = stores["a"].GetByIDAndVerifySecret(ctx, "client", "secret"); err != nil {
+		t.Fatalf("the secret of the realm was changed from another realm: %v", err)
+	}
+
+	if valid, err = stores["a"].ValidateRedirectURI(ctx, "client", "https://example.com/callback"); err != nil || !valid {
+		t.Fatalf("the redirect URIs of the realm were changed from another realm: %t, %v", valid, err)
+	}
+}
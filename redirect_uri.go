@@ -0,0 +1,218 @@
+package pgstore
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// DefaultClientRedirectURITable is the default table for storing client
+	// redirect URIs.
+	DefaultClientRedirectURITable = "oauth2_client_redirect_uris"
+)
+
+// RedirectURI is a registered redirect URI of a client.
+type RedirectURI struct {
+	URI string
+	// Prefix allows every redirect URI starting with the URI instead of an
+	// exact match only.
+	Prefix bool
+}
+
+// WithClientStoreRedirectURITable configures the client redirect URI table.
+func WithClientStoreRedirectURITable(table string) ClientStoreOption {
+	return func(s *ClientStore) error {
+		if table == "" {
+			return ErrNoTable
+		}
+
+		s.redirectURITable = table
+
+		return nil
+	}
+}
+
+// redirectURITableSQL returns the DDL of the client redirect URI table.
+func (s *ClientStore) redirectURITableSQL() string {
+	return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %[1]s (
+			client_id VARCHAR(255) NOT NULL REFERENCES %[2]s (id) ON DELETE CASCADE,
+			uri       TEXT         NOT NULL,
+			prefix    BOOLEAN      NOT NULL DEFAULT FALSE,
+			PRIMARY KEY (client_id, uri)
+		);%[3]s
+%[4]s`,
+		s.redirectURITable, s.table, s.citusReferenceSQL(s.redirectURITable), s.redirectURIsUpgradeSQL(),
+	)
+}
+
+// SetRedirectURIs replaces the registered redirect URIs of the client. It
+// returns ErrClientNotFound if the client does not exist in the realm of the
+// store.
+func (s *ClientStore) SetRedirectURIs(ctx context.Context, clientID string, uris []RedirectURI) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	s.logger.Log(ctx, LogLevelDebug, "setting client redirect uris", "id", clientID, "uris", uris)
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return wrapDatabaseError(err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err = s.lockClient(ctx, tx, clientID); err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return wrapDatabaseError(err)
+	}
+
+	if err = s.replaceRedirectURIs(ctx, tx, clientID, uris); err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return wrapDatabaseError(err)
+	}
+
+	return wrapDatabaseError(tx.Commit(ctx))
+}
+
+// replaceRedirectURIs replaces the redirect URIs of the client locked in the
+// transaction.
+func (s *ClientStore) replaceRedirectURIs(ctx context.Context, tx pgx.Tx, clientID string, uris []RedirectURI) error {
+	if _, err := tx.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE client_id = $1", s.redirectURITable), clientID); err != nil {
+		return err
+	}
+
+	for _, uri := range uris {
+		_, err := tx.Exec(ctx, fmt.Sprintf(
+			"INSERT INTO %s (client_id, uri, prefix) VALUES ($1, $2, $3) ON CONFLICT (client_id, uri) DO UPDATE SET prefix = EXCLUDED.prefix",
+			s.redirectURITable,
+		), clientID, uri.URI, uri.Prefix)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetRedirectURIs returns the registered redirect URIs of the client. Clients
+// of other realms have no redirect URIs.
+func (s *ClientStore) GetRedirectURIs(ctx context.Context, clientID string) ([]RedirectURI, error) {
+	s.logger.Log(ctx, LogLevelDebug, "getting client redirect uris", "id", clientID)
+
+	rows, err := s.pool.Query(ctx, fmt.Sprintf(
+		"SELECT r.uri, r.prefix FROM %s r JOIN %s c ON c.id = r.client_id WHERE r.client_id = $1%s ORDER BY r.uri",
+		s.redirectURITable, s.table, andRealm(s.realm),
+	), clientID)
+
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return nil, wrapDatabaseError(err)
+	}
+	defer rows.Close()
+
+	var uris []RedirectURI
+	for rows.Next() {
+		var uri RedirectURI
+		if err = rows.Scan(&uri.URI, &uri.Prefix); err != nil {
+			s.logger.Log(ctx, LogLevelError, err.Error())
+			return nil, wrapDatabaseError(err)
+		}
+
+		uris = append(uris, uri)
+	}
+
+	return uris, wrapDatabaseError(rows.Err())
+}
+
+// ValidateRedirectURI returns true if the URI exactly matches a registered
+// redirect URI of the client or is below a registered prefix URI. Exact
+// matches are looked up by the database, which only returns the prefix URIs
+// of the client besides. The prefix URIs are matched in Go, as matching them
+// safely requires parsing both URIs.
+func (s *ClientStore) ValidateRedirectURI(ctx context.Context, clientID string, uri string) (bool, error) {
+	s.logger.Log(ctx, LogLevelDebug, "validating client redirect uri", "id", clientID, "uri", uri)
+
+	rows, err := s.pool.Query(ctx, fmt.Sprintf(`
+		SELECT r.uri, r.prefix FROM %s r JOIN %s c ON c.id = r.client_id
+		WHERE r.client_id = $1 AND (r.uri = $2 OR r.prefix)%s
+		ORDER BY r.uri = $2 DESC`,
+		s.redirectURITable, s.table, andRealm(s.realm),
+	), clientID, uri)
+
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return false, wrapDatabaseError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var registered RedirectURI
+		if err = rows.Scan(&registered.URI, &registered.Prefix); err != nil {
+			s.logger.Log(ctx, LogLevelError, err.Error())
+			return false, wrapDatabaseError(err)
+		}
+
+		if registered.matches(uri) {
+			return true, nil
+		}
+	}
+
+	return false, wrapDatabaseError(rows.Err())
+}
+
+// matches returns true if the URI equals the registered URI, or if the
+// registered URI is a prefix and the URI has the same scheme and host and a
+// path below the registered path. A prefix never matches in the middle of a
+// path segment, and URIs with user info, fragments or dot segments are
+// rejected, so a prefix cannot be used to redirect to another host or path.
+func (r RedirectURI) matches(uri string) bool {
+	if uri == r.URI {
+		return true
+	}
+
+	if !r.Prefix {
+		return false
+	}
+
+	registered, err := url.Parse(r.URI)
+	if err != nil || !registered.IsAbs() || registered.Host == "" {
+		return false
+	}
+
+	candidate, err := url.Parse(uri)
+	if err != nil || candidate.User != nil || candidate.Fragment != "" || candidate.RawFragment != "" {
+		return false
+	}
+
+	if candidate.Scheme != registered.Scheme || candidate.Host != registered.Host || candidate.Opaque != "" {
+		return false
+	}
+
+	for _, segment := range strings.Split(candidate.Path, "/") {
+		if segment == "." || segment == ".." {
+			return false
+		}
+	}
+
+	if registered.RawQuery != "" && candidate.RawQuery != registered.RawQuery {
+		return false
+	}
+
+	prefix := registered.Path
+	if candidate.Path == prefix {
+		return true
+	}
+
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	return strings.HasPrefix(candidate.Path, prefix)
+}
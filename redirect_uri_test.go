@@ -0,0 +1,115 @@
+package pgstore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+)
+
+func TestRedirectURIMatches(t *testing.T) {
+	exact := RedirectURI{URI: "https://example.com/callback"}
+	prefix := RedirectURI{URI: "https://example.com/callback", Prefix: true}
+
+	tests := []struct {
+		registered RedirectURI
+		uri        string
+		want       bool
+	}{
+		{exact, "https://example.com/callback", true},
+		{exact, "https://example.com/callback/app", false},
+		{prefix, "https://example.com/callback", true},
+		{prefix, "https://example.com/callback/app", true},
+		{prefix, "https://example.com/callback/app?state=1", true},
+		{prefix, "https://example.com/callbackevil", false},
+		{prefix, "https://example.com/callback.evil.com/", false},
+		{prefix, "https://example.com.evil.com/callback", false},
+		{prefix, "https://example.com@evil.com/callback", false},
+		{prefix, "https://user@example.com/callback/app", false},
+		{prefix, "http://example.com/callback/app", false},
+		{prefix, "https://example.com:8443/callback/app", false},
+		{prefix, "https://example.com/callback/../admin", false},
+		{prefix, "https://example.com/callback/%2e%2e/admin", false},
+		{prefix, "https://example.com/callback/app#fragment", false},
+		{RedirectURI{URI: "https://example.com/", Prefix: true}, "https://example.com/app", true},
+		{RedirectURI{URI: "https://example.com", Prefix: true}, "https://example.com/app", true},
+		{RedirectURI{URI: "/callback", Prefix: true}, "/callback/app", false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.registered.matches(tt.uri); got != tt.want {
+			t.Errorf("%+v matches %q: got %t, want %t", tt.registered, tt.uri, got, tt.want)
+		}
+	}
+}
+
+func TestClientStoreValidatesRegisteredRedirectURIs(t *testing.T) {
+	store := newTestClientStore(t)
+	ctx := context.Background()
+
+	if err := store.Upsert(ctx, &models.Client{ID: "client"}); err != nil {
+		t.Fatal(err)
+	}
+
+	meta := &RegistrationMetadata{RedirectURIs: []string{"https://example.com/callback"}}
+	if err := store.SetRegistrationMetadata(ctx, "client", meta); err != nil {
+		t.Fatal(err)
+	}
+
+	for uri, want := range map[string]bool{
+		"https://example.com/callback":     true,
+		"https://example.com/callback/app": false,
+	} {
+		ok, err := store.ValidateRedirectURI(ctx, "client", uri)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ok != want {
+			t.Errorf("got %t for %q, want %t", ok, uri, want)
+		}
+	}
+
+	if err := store.SetRedirectURIs(ctx, "client", []RedirectURI{{URI: "https://example.com/other", Prefix: true}}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := store.GetRegistrationMetadata(ctx, "client")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got.RedirectURIs) != 1 || got.RedirectURIs[0] != "https://example.com/other" {
+		t.Fatalf("got redirect URIs %q, want the URIs set by SetRedirectURIs", got.RedirectURIs)
+	}
+}
+
+func TestClientStoreUpgradeMovesRegisteredRedirectURIs(t *testing.T) {
+	store := newTestClientStore(t)
+	ctx := context.Background()
+
+	if err := store.Upsert(ctx, &models.Client{ID: "client"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.pool.Exec(ctx, "ALTER TABLE "+store.table+" ADD COLUMN redirect_uris TEXT[] NOT NULL DEFAULT '{}'"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.pool.Exec(ctx, "UPDATE "+store.table+" SET redirect_uris = '{https://example.com/callback}'"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.InitTable(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	ok, err := store.ValidateRedirectURI(ctx, "client", "https://example.com/callback")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !ok {
+		t.Fatal("redirect URI of the column not moved into the redirect URI table")
+	}
+}
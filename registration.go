@@ -0,0 +1,129 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// RegistrationMetadata is the dynamic client registration (RFC 7591) metadata
+// of a client.
+type RegistrationMetadata struct {
+	RedirectURIs            []string `json:"redirect_uris,omitempty"`
+	GrantTypes              []string `json:"grant_types,omitempty"`
+	TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method,omitempty"`
+	SoftwareStatement       string   `json:"software_statement,omitempty"`
+	// RegistrationAccessToken is only used for setting the token. The token
+	// is stored hashed, hence it is never returned by the store.
+	RegistrationAccessToken string `json:"-"`
+}
+
+// SetRegistrationMetadata stores the registration metadata of the client. The
+// registration access token is kept if no new token is provided. The redirect
+// URIs replace the registered redirect URIs of the client, as set by
+// SetRedirectURIs, so they are validated by ValidateRedirectURI.
+func (s *ClientStore) SetRegistrationMetadata(ctx context.Context, clientID string, meta *RegistrationMetadata) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	s.logger.Log(ctx, LogLevelDebug, "setting client registration metadata", "id", clientID)
+
+	var tokenHash string
+	if meta.RegistrationAccessToken != "" {
+		tokenHash = hashSecret(meta.RegistrationAccessToken)
+	}
+
+	redirectURIs := make([]RedirectURI, len(meta.RedirectURIs))
+	for i, uri := range meta.RedirectURIs {
+		redirectURIs[i] = RedirectURI{URI: uri}
+	}
+
+	grantTypes := meta.GrantTypes
+	if grantTypes == nil {
+		grantTypes = []string{}
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return wrapDatabaseError(err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	tag, err := tx.Exec(ctx, fmt.Sprintf(`
+		UPDATE %s SET
+			grant_types = $2,
+			token_endpoint_auth_method = $3,
+			software_statement = $4,
+			registration_token_hash = COALESCE(NULLIF($5, ''), registration_token_hash)
+		WHERE id = $1%s`,
+		s.table, andRealm(s.realm),
+	), clientID, grantTypes, meta.TokenEndpointAuthMethod, meta.SoftwareStatement, tokenHash)
+
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return wrapDatabaseError(err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrClientNotFound
+	}
+
+	if err = s.replaceRedirectURIs(ctx, tx, clientID, redirectURIs); err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return wrapDatabaseError(err)
+	}
+
+	return wrapDatabaseError(tx.Commit(ctx))
+}
+
+// GetRegistrationMetadata returns the registration metadata of the client.
+// The redirect URIs are the registered redirect URIs of the client.
+func (s *ClientStore) GetRegistrationMetadata(ctx context.Context, clientID string) (*RegistrationMetadata, error) {
+	s.logger.Log(ctx, LogLevelDebug, "getting client registration metadata", "id", clientID)
+
+	meta := new(RegistrationMetadata)
+	err := s.pool.QueryRow(ctx, fmt.Sprintf(`
+		SELECT ARRAY(SELECT uri FROM %s WHERE client_id = c.id ORDER BY uri),
+			grant_types, token_endpoint_auth_method, software_statement
+		FROM %s c WHERE id = $1%s`,
+		s.redirectURITable, s.table, andRealm(s.realm),
+	), clientID).Scan(&meta.RedirectURIs, &meta.GrantTypes, &meta.TokenEndpointAuthMethod, &meta.SoftwareStatement)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrClientNotFound
+	}
+
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return nil, wrapDatabaseError(err)
+	}
+
+	return meta, nil
+}
+
+// VerifyRegistrationAccessToken returns true if the token is the registration
+// access token of the client.
+func (s *ClientStore) VerifyRegistrationAccessToken(ctx context.Context, clientID string, token string) (bool, error) {
+	s.logger.Log(ctx, LogLevelDebug, "verifying registration access token", "id", clientID)
+
+	if token == "" {
+		return false, nil
+	}
+
+	var valid bool
+	err := s.pool.QueryRow(ctx, fmt.Sprintf(
+		"SELECT EXISTS (SELECT 1 FROM %s WHERE id = $1 AND registration_token_hash = $2%s)",
+		s.table, andRealm(s.realm),
+	), clientID, hashSecret(token)).Scan(&valid)
+
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return false, wrapDatabaseError(err)
+	}
+
+	return valid, nil
+}
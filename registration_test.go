@@ -0,0 +1,70 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+)
+
+func TestClientStoreRegistrationMetadata(t *testing.T) {
+	store := newTestClientStore(t)
+	ctx := context.Background()
+
+	if err := store.Upsert(ctx, &models.Client{ID: "client"}); err != nil {
+		t.Fatal(err)
+	}
+
+	meta := &RegistrationMetadata{
+		RedirectURIs:            []string{"https://example.com/a", "https://example.com/b"},
+		GrantTypes:              []string{"authorization_code", "refresh_token"},
+		TokenEndpointAuthMethod: "client_secret_basic",
+		SoftwareStatement:       "statement",
+		RegistrationAccessToken: "registration",
+	}
+	if err := store.SetRegistrationMetadata(ctx, "client", meta); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := store.GetRegistrationMetadata(ctx, "client")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := *meta
+	want.RegistrationAccessToken = ""
+	if !reflect.DeepEqual(*got, want) {
+		t.Fatalf("got metadata %+v, want %+v", *got, want)
+	}
+
+	// The registration access token is kept if no new one is provided.
+	if err = store.SetRegistrationMetadata(ctx, "client", &RegistrationMetadata{GrantTypes: []string{"client_credentials"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	for token, want := range map[string]bool{"registration": true, "other": false, "": false} {
+		valid, err := store.VerifyRegistrationAccessToken(ctx, "client", token)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if valid != want {
+			t.Errorf("token %q: got %t, want %t", token, valid, want)
+		}
+	}
+}
+
+func TestClientStoreRegistrationMetadataNotFound(t *testing.T) {
+	store := newTestClientStore(t)
+	ctx := context.Background()
+
+	if err := store.SetRegistrationMetadata(ctx, "missing", new(RegistrationMetadata)); !errors.Is(err, ErrClientNotFound) {
+		t.Fatalf("SetRegistrationMetadata: got error %v, want %v", err, ErrClientNotFound)
+	}
+
+	if _, err := store.GetRegistrationMetadata(ctx, "missing"); !errors.Is(err, ErrClientNotFound) {
+		t.Fatalf("GetRegistrationMetadata: got error %v, want %v", err, ErrClientNotFound)
+	}
+}
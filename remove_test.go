@@ -0,0 +1,118 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestTokenStoreRemoveByClientID(t *testing.T) {
+	store := newTestTokenStore(t)
+	ctx := context.Background()
+
+	for _, token := range []struct{ client, suffix string }{{"client", "a"}, {"client", "b"}, {"other", "c"}} {
+		if err := store.Create(ctx, newTestToken(token.client, "user", token.suffix)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := store.RemoveByClientID(ctx, "client")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if removed != 2 {
+		t.Fatalf("got %d tokens removed, want 2", removed)
+	}
+
+	if _, err = store.GetByAccess(ctx, "access-a"); !errors.Is(err, ErrTokenNotFound) {
+		t.Fatalf("got error %v for a token of the client, want %v", err, ErrTokenNotFound)
+	}
+
+	if _, err = store.GetByAccess(ctx, "access-c"); err != nil {
+		t.Fatalf("got error %v for a token of another client", err)
+	}
+}
+
+func TestTokenStoreRemoveByUserID(t *testing.T) {
+	store := newTestTokenStore(t)
+	ctx := context.Background()
+
+	for _, token := range []struct{ user, suffix string }{{"user", "a"}, {"user", "b"}, {"other", "c"}} {
+		if err := store.Create(ctx, newTestToken("client", token.user, token.suffix)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := store.RemoveByUserID(ctx, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if removed != 2 {
+		t.Fatalf("got %d tokens removed, want 2", removed)
+	}
+
+	if _, err = store.GetByRefresh(ctx, "refresh-b"); !errors.Is(err, ErrTokenNotFound) {
+		t.Fatalf("got error %v for a token of the user, want %v", err, ErrTokenNotFound)
+	}
+
+	if _, err = store.GetByAccess(ctx, "access-c"); err != nil {
+		t.Fatalf("got error %v for a token of another user", err)
+	}
+}
+
+func TestTokenStoreRemoveByScope(t *testing.T) {
+	store := newTestTokenStore(t)
+	ctx := context.Background()
+
+	for suffix, scope := range map[string]string{"a": "read write", "b": "write", "c": "read rewrite"} {
+		token := newTestToken("client", "user", suffix)
+		token.Scope = scope
+
+		if err := store.Create(ctx, token); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	page, err := store.ListTokens(ctx, TokenFilter{Scope: "write"}, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(page.Tokens) != 2 {
+		t.Fatalf("got %d tokens granted the scope, want 2", len(page.Tokens))
+	}
+
+	removed, err := store.RemoveByScope(ctx, "write")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if removed != 2 {
+		t.Fatalf("got %d tokens removed, want 2", removed)
+	}
+
+	// Scopes are matched as a whole, not as a substring.
+	if _, err = store.GetByAccess(ctx, "access-c"); err != nil {
+		t.Fatalf("got error %v for a token not granted the scope", err)
+	}
+}
+
+func TestTokenStoreRemoveWithoutID(t *testing.T) {
+	store, err := newTokenStore(WithTokenStoreDB(unusedDB{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, remove := range map[string]func(context.Context, string) (int64, error){
+		"RemoveByClientID": store.RemoveByClientID,
+		"RemoveByUserID":   store.RemoveByUserID,
+		"RemoveByScope":    store.RemoveByScope,
+	} {
+		removed, err := remove(context.Background(), "")
+		if err != nil || removed != 0 {
+			t.Errorf("%s: got %d tokens removed and error %v, want nothing removed", name, removed, err)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package pgstore
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// WithTokenStoreReadPool configures the database used for reading tokens,
+// for example a pool of read replicas, or a pgx v4 pool through the pgxv4
+// adapter. Writes and cleanup always use the primary connection pool. The
+// lookups that must not see stale tokens also use the primary connection
+// pool: GetByCode and GetByRefresh, as the code and refresh token are
+// single-use, and IsRevoked and Introspect, as a revoked token must not be
+// reported as valid. The read-your-writes window only covers the writes of
+// the same store.
+func WithTokenStoreReadPool(db DB) TokenStoreOption {
+	return func(s *TokenStore) error {
+		if db == nil {
+			return ErrNoConnPool
+		}
+
+		s.readPool = db
+
+		return nil
+	}
+}
+
+// WithTokenStoreReadYourWrites configures the store to read from the primary
+// connection pool for the given window after every write, so tokens are
+// readable right after being created even if the replicas are lagging.
+func WithTokenStoreReadYourWrites(window time.Duration) TokenStoreOption {
+	return func(s *TokenStore) error {
+		if window <= 0 {
+			return ErrInvalidReadYourWritesWindow
+		}
+
+		s.readYourWritesWindow = window
+
+		return nil
+	}
+}
+
+// replicaState tracks the writes to decide where reads should go.
+type replicaState struct {
+	lastWrite atomic.Int64
+}
+
+// markWrite records that the store has written to the primary.
+func (s *TokenStore) markWrite() {
+	if s.readPool != nil && s.readYourWritesWindow > 0 {
+		s.replica.lastWrite.Store(s.clock.Now().UnixNano())
+	}
+}
+
+// reader returns the connection pool reads should use.
+func (s *TokenStore) reader() querier {
+	if s.readPool == nil {
+		return s.pool
+	}
+
+	if s.readYourWritesWindow > 0 {
+		lastWrite := time.Unix(0, s.replica.lastWrite.Load())
+		if s.clock.Now().Sub(lastWrite) < s.readYourWritesWindow {
+			return s.pool
+		}
+	}
+
+	return s.readPool
+}
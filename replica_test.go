@@ -0,0 +1,42 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestTokenStoreSingleUseLookupsReadPrimary(t *testing.T) {
+	store := &TokenStore{
+		pool:     new(emptyDB),
+		readPool: unusedDB{},
+		columns:  DefaultColumnMap,
+		logger:   new(NoopLogger),
+		clock:    new(SystemClock),
+	}
+
+	ctx := context.Background()
+
+	if _, err := store.GetByCode(ctx, "code"); !errors.Is(err, ErrTokenNotFound) {
+		t.Fatalf("GetByCode: got %v, want %v", err, ErrTokenNotFound)
+	}
+
+	if _, err := store.GetByRefresh(ctx, "refresh"); !errors.Is(err, ErrTokenNotFound) {
+		t.Fatalf("GetByRefresh: got %v, want %v", err, ErrTokenNotFound)
+	}
+}
+
+func TestWithTokenStoreReadPoolAcceptsDB(t *testing.T) {
+	store, err := newTokenStore(WithTokenStoreDB(new(emptyDB)), WithTokenStoreReadPool(unusedDB{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if store.readPool == nil {
+		t.Fatal("read pool not configured")
+	}
+
+	if err = WithTokenStoreReadPool(nil)(store); !errors.Is(err, ErrNoConnPool) {
+		t.Fatalf("got %v, want %v", err, ErrNoConnPool)
+	}
+}
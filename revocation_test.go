@@ -0,0 +1,46 @@
+package pgstore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// boolRow is a row of a single boolean.
+type boolRow bool
+
+func (r boolRow) Scan(dest ...any) error {
+	*dest[0].(*bool) = bool(r)
+	return nil
+}
+
+// boolDB is a database returning the boolean for every query.
+type boolDB struct {
+	DB
+	value bool
+}
+
+func (d *boolDB) QueryRow(context.Context, string, ...any) pgx.Row {
+	return boolRow(d.value)
+}
+
+func TestTokenStoreIsRevokedReadsPrimary(t *testing.T) {
+	store := &TokenStore{
+		pool:           &boolDB{value: true},
+		readPool:       unusedDB{},
+		softRevocation: true,
+		columns:        DefaultColumnMap,
+		logger:         new(NoopLogger),
+		clock:          new(SystemClock),
+	}
+
+	revoked, err := store.IsRevoked(context.Background(), "access")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !revoked {
+		t.Fatal("revoked token reported as valid")
+	}
+}
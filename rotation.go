@@ -0,0 +1,151 @@
+package pgstore
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"github.com/jackc/pgx/v5"
+)
+
+// newFamilyID generates a random refresh token family ID.
+func newFamilyID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// RotateRefresh atomically invalidates the old refresh token and stores the
+// new token in the same token family. If the old refresh token was already
+// rotated, the whole family is considered compromised, every token of the
+// family is revoked and ErrRefreshTokenReused is returned. Revoked refresh
+// tokens are rejected with ErrTokenRevoked and expired ones with
+// ErrTokenNotFound.
+func (s *TokenStore) RotateRefresh(ctx context.Context, oldRefresh string, newInfo oauth2.TokenInfo) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	s.logger.Log(ctx, LogLevelDebug, "rotating refresh token", "refresh", oldRefresh)
+
+	if err := s.hooks.beforeCreate(ctx, newInfo); err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return err
+	}
+
+	if err := s.breaker.allow(); err != nil {
+		return err
+	}
+
+	removed, err := s.rotateRefresh(ctx, oldRefresh, newInfo)
+	s.breaker.record(err)
+
+	if errors.Is(err, ErrRefreshTokenReused) {
+		s.logger.Log(ctx, LogLevelWarn, "refresh token reused, family revoked", "refresh", oldRefresh, "revoked", len(removed))
+		s.notifyRemoved(ctx, removed)
+
+		return err
+	}
+
+	s.hooks.afterCreate(ctx, newInfo, err)
+
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return err
+	}
+
+	s.publishEvent(ctx, newTokenEvent(EventTokenCreated, newInfo))
+	s.logger.Log(ctx, LogLevelDebug, "refresh token rotated")
+
+	return nil
+}
+
+// rotateRefresh rotates the refresh token in a transaction and returns the
+// data of the removed tokens if a reuse was detected.
+func (s *TokenStore) rotateRefresh(ctx context.Context, oldRefresh string, newInfo oauth2.TokenInfo) ([][]byte, error) {
+	item, err := s.newTokenStoreItem(newInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	if item.GrantType == "" {
+		item.GrantType = string(oauth2.Refreshing)
+	}
+
+	s.markWrite()
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, wrapDatabaseError(err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var parent rowKey
+	var familyID string
+	var rotatedAt, revokedAt *time.Time
+	var expiresAt time.Time
+
+	err = tx.QueryRow(ctx, fmt.Sprintf(
+		"SELECT id, family_id, rotated_at, revoked_at, %s FROM %s WHERE %s = $1%s ORDER BY id DESC LIMIT 1 FOR UPDATE",
+		s.columns.ExpiresAt, s.table, s.columns.Refresh, s.andTenant(),
+	), oldRefresh).Scan(&parent, &familyID, &rotatedAt, &revokedAt, &expiresAt)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrTokenNotFound
+	}
+
+	if err != nil {
+		return nil, wrapDatabaseError(err)
+	}
+
+	if revokedAt != nil {
+		return nil, ErrTokenRevoked
+	}
+
+	// Tokens created before families were tracked form a family on their own.
+	if familyID == "" {
+		familyID = item.FamilyID
+		if _, err = tx.Exec(ctx, fmt.Sprintf("UPDATE %s SET family_id = $1 WHERE id = $2", s.table), familyID, parent.value()); err != nil {
+			return nil, wrapDatabaseError(err)
+		}
+	}
+
+	// A reused token revokes the family even if it expired since, as the
+	// rest of the family may still be valid.
+	if rotatedAt != nil {
+		removed, err := s.evict(ctx, tx, "family_id = $1", familyID)
+		if err != nil {
+			return nil, wrapDatabaseError(err)
+		}
+
+		if err = tx.Commit(ctx); err != nil {
+			return nil, wrapDatabaseError(err)
+		}
+
+		return removed, ErrRefreshTokenReused
+	}
+
+	if !expiresAt.After(s.clock.Now()) {
+		return nil, ErrTokenNotFound
+	}
+
+	if _, err = tx.Exec(ctx, fmt.Sprintf("UPDATE %s SET rotated_at = $1 WHERE id = $2", s.table), item.CreatedAt, parent.value()); err != nil {
+		return nil, wrapDatabaseError(err)
+	}
+
+	item.FamilyID = familyID
+	item.ParentID, item.ParentKey = parent.parentID()
+
+	if err = s.insert(ctx, tx, item); err != nil {
+		return nil, wrapDatabaseError(err)
+	}
+
+	return nil, wrapDatabaseError(tx.Commit(ctx))
+}
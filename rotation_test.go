@@ -0,0 +1,97 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+)
+
+func TestTokenStoreRotateRefreshRevoked(t *testing.T) {
+	store := newTestTokenStore(t, WithTokenStoreSoftRevocation())
+	ctx := context.Background()
+
+	if err := store.Create(ctx, newTestToken("client", "user", "a")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.RemoveByRefresh(ctx, "refresh-a"); err != nil {
+		t.Fatal(err)
+	}
+
+	err := store.RotateRefresh(ctx, "refresh-a", newTestToken("client", "user", "b"))
+	if !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("got error %v, want %v", err, ErrTokenRevoked)
+	}
+
+	if _, err = store.GetByRefresh(ctx, "refresh-b"); err == nil {
+		t.Fatal("rotated a revoked refresh token")
+	}
+}
+
+func TestTokenStoreRotateRefreshExpired(t *testing.T) {
+	store := newTestTokenStore(t)
+	ctx := context.Background()
+
+	expired := newTestToken("client", "user", "a")
+	expired.RefreshCreateAt = time.Now().Add(-2 * time.Hour)
+	expired.RefreshExpiresIn = time.Hour
+
+	if err := store.Create(ctx, expired); err != nil {
+		t.Fatal(err)
+	}
+
+	err := store.RotateRefresh(ctx, "refresh-a", newTestToken("client", "user", "b"))
+	if !errors.Is(err, ErrTokenNotFound) {
+		t.Fatalf("got error %v, want %v", err, ErrTokenNotFound)
+	}
+}
+
+func TestTokenStoreRotateRefreshReused(t *testing.T) {
+	var removed []string
+
+	store := newTestTokenStore(t,
+		WithTokenStoreSoftRevocation(),
+		WithTokenStoreHooks(Hooks{
+			AfterRemove: func(_ context.Context, info oauth2.TokenInfo, err error) {
+				if err == nil {
+					removed = append(removed, info.GetRefresh())
+				}
+			},
+		}),
+	)
+	ctx := context.Background()
+
+	if err := store.Create(ctx, newTestToken("client", "user", "a")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.RotateRefresh(ctx, "refresh-a", newTestToken("client", "user", "b")); err != nil {
+		t.Fatal(err)
+	}
+
+	err := store.RotateRefresh(ctx, "refresh-a", newTestToken("client", "user", "c"))
+	if !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("got error %v, want %v", err, ErrRefreshTokenReused)
+	}
+
+	if len(removed) != 2 {
+		t.Fatalf("got removed tokens %v, want the whole family", removed)
+	}
+
+	revoked, err := store.IsRevoked(ctx, "access-b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !revoked {
+		t.Fatal("the rotated token of the family was not revoked")
+	}
+
+	err = store.RotateRefresh(ctx, "refresh-b", newTestToken("client", "user", "d"))
+	if !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("got error %v, want %v", err, ErrTokenRevoked)
+	}
+}
@@ -0,0 +1,218 @@
+package pgstore
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CleanupSchedule decides when the expired tokens are cleaned up. Next returns
+// the time of the next cleanup after the given time.
+type CleanupSchedule interface {
+	Next(t time.Time) time.Time
+}
+
+// cronField is the set of allowed values of a cron expression field.
+type cronField uint64
+
+func (f cronField) has(v int) bool {
+	return f&(1<<uint(v)) != 0
+}
+
+// CronSchedule is a CleanupSchedule based on a standard five-field cron
+// expression (minute, hour, day of month, month, day of week).
+type CronSchedule struct {
+	minute   cronField
+	hour     cronField
+	dom      cronField
+	month    cronField
+	dow      cronField
+	anyDom   bool
+	anyDow   bool
+	location *time.Location
+}
+
+// ParseCronSchedule parses a standard five-field cron expression, evaluated in
+// the given location. Fields support "*", single values, ranges ("1-5"),
+// steps ("*/15", "0-30/10") and comma-separated lists. If the location is
+// nil, UTC is used.
+func ParseCronSchedule(expr string, loc *time.Location) (*CronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, ErrInvalidCronExpression
+	}
+
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	parsed := make([]cronField, 5)
+
+	for i, field := range fields {
+		f, err := parseCronField(field, bounds[i][0], bounds[i][1])
+		if err != nil {
+			return nil, err
+		}
+		parsed[i] = f
+	}
+
+	// Both 0 and 7 mean Sunday.
+	if parsed[4].has(7) {
+		parsed[4] |= 1
+	}
+
+	return &CronSchedule{
+		minute:   parsed[0],
+		hour:     parsed[1],
+		dom:      parsed[2],
+		month:    parsed[3],
+		dow:      parsed[4],
+		anyDom:   strings.HasPrefix(fields[2], "*"),
+		anyDow:   strings.HasPrefix(fields[4], "*"),
+		location: loc,
+	}, nil
+}
+
+// parseCronField parses a single cron expression field within the bounds.
+func parseCronField(field string, lo, hi int) (cronField, error) {
+	var f cronField
+
+	for _, part := range strings.Split(field, ",") {
+		rng, step := part, 1
+
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+				return 0, ErrInvalidCronExpression
+			}
+			rng = part[:i]
+		}
+
+		start, end := lo, hi
+		if rng != "*" {
+			var err error
+			if i := strings.Index(rng, "-"); i >= 0 {
+				if start, err = strconv.Atoi(rng[:i]); err != nil {
+					return 0, ErrInvalidCronExpression
+				}
+				if end, err = strconv.Atoi(rng[i+1:]); err != nil {
+					return 0, ErrInvalidCronExpression
+				}
+			} else {
+				if start, err = strconv.Atoi(rng); err != nil {
+					return 0, ErrInvalidCronExpression
+				}
+				end = start
+				if step > 1 {
+					end = hi
+				}
+			}
+		}
+
+		if start < lo || end > hi || start > end {
+			return 0, ErrInvalidCronExpression
+		}
+
+		for v := start; v <= end; v += step {
+			f |= 1 << uint(v)
+		}
+	}
+
+	return f, nil
+}
+
+// matchDay returns true if the day matches the day of month and day of week
+// fields. As in cron, if both fields are restricted, matching either is
+// enough. A field starting with "*", such as "*/1", is not restricted.
+func (c *CronSchedule) matchDay(t time.Time) bool {
+	dom := c.dom.has(t.Day())
+	dow := c.dow.has(int(t.Weekday()))
+
+	switch {
+	case c.anyDom && c.anyDow:
+		return true
+	case c.anyDom:
+		return dow
+	case c.anyDow:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+// later returns next if it is after t, or t advanced by a minute otherwise, as
+// the wall clock time next was created for may not exist in the location.
+func later(t time.Time, next time.Time) time.Time {
+	if next.After(t) {
+		return next
+	}
+
+	return t.Add(time.Minute)
+}
+
+// repeated returns true if the wall clock time of t already occurred, because
+// the clocks were set back when the zone offset in effect began.
+func repeated(t time.Time) bool {
+	start, _ := t.ZoneBounds()
+	if start.IsZero() {
+		return false
+	}
+
+	_, before := start.Add(-time.Second).Zone()
+	_, offset := t.Zone()
+
+	return before > offset && t.Sub(start) < time.Duration(before-offset)*time.Second
+}
+
+// Next returns the next time matching the schedule after t, or the zero time
+// if there is none within five years. Wall clock times skipped by a daylight
+// saving time transition do not match, and wall clock times repeated by one
+// match only their first occurrence.
+func (c *CronSchedule) Next(t time.Time) time.Time {
+	t = t.In(c.location).Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if !c.month.has(int(t.Month())) {
+			t = later(t, time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, c.location))
+			continue
+		}
+
+		if !c.matchDay(t) {
+			t = later(t, time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, c.location))
+			continue
+		}
+
+		// The next hour is reached in elapsed time, as its wall clock time
+		// may be repeated.
+		if !c.hour.has(t.Hour()) {
+			t = t.Add(time.Duration(60-t.Minute()) * time.Minute)
+			continue
+		}
+
+		if !c.minute.has(t.Minute()) || repeated(t) {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+// WithTokenStoreCleanupSchedule configures the schedule of the cleanup, taking
+// precedence over the cleanup interval. Use ParseCronSchedule to run the
+// cleanup off-peak, for example "0 3 * * *" for 03:00 daily.
+func WithTokenStoreCleanupSchedule(schedule CleanupSchedule) TokenStoreOption {
+	return func(s *TokenStore) error {
+		if schedule == nil {
+			return ErrNoCleanupSchedule
+		}
+
+		s.cleanupSchedule = schedule
+
+		return nil
+	}
+}
@@ -0,0 +1,96 @@
+package pgstore
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseCronScheduleInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 0 *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"*/a * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"1-a * * * *",
+		"-1 * * * *",
+		"1,,2 * * * *",
+	} {
+		if _, err := ParseCronSchedule(expr, nil); !errors.Is(err, ErrInvalidCronExpression) {
+			t.Errorf("%q: got error %v, want %v", expr, err, ErrInvalidCronExpression)
+		}
+	}
+}
+
+func TestCronScheduleNext(t *testing.T) {
+	budapest, err := time.LoadLocation("Europe/Budapest")
+	if err != nil {
+		t.Skip("time zone database not available:", err)
+	}
+
+	// The clocks of São Paulo skipped the midnight of 4 November 2018.
+	saoPaulo, err := time.LoadLocation("America/Sao_Paulo")
+	if err != nil {
+		t.Skip("time zone database not available:", err)
+	}
+
+	utc := func(month time.Month, day int, hour int, minute int) time.Time {
+		return time.Date(2023, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := map[string]struct {
+		expr string
+		loc  *time.Location
+		from time.Time
+		want time.Time
+	}{
+		"daily":                   {expr: "0 3 * * *", from: utc(1, 1, 10, 0), want: utc(1, 2, 3, 0)},
+		"strictly after":          {expr: "0,30 * * * *", from: utc(1, 1, 10, 30), want: utc(1, 1, 11, 0)},
+		"seconds truncated":       {expr: "* * * * *", from: utc(1, 1, 10, 0).Add(30 * time.Second), want: utc(1, 1, 10, 1)},
+		"step":                    {expr: "*/15 * * * *", from: utc(1, 1, 10, 7), want: utc(1, 1, 10, 15)},
+		"step from value":         {expr: "5/15 * * * *", from: utc(1, 1, 10, 6), want: utc(1, 1, 10, 20)},
+		"range step":              {expr: "0-30/10 * * * *", from: utc(1, 1, 10, 31), want: utc(1, 1, 11, 0)},
+		"ranges":                  {expr: "0 9-17 * * 1-5", from: utc(1, 7, 12, 0), want: utc(1, 9, 9, 0)},
+		"day of month":            {expr: "0 0 10 * *", from: utc(1, 7, 0, 0), want: utc(1, 10, 0, 0)},
+		"day of week":             {expr: "0 0 * * 5", from: utc(1, 7, 0, 0), want: utc(1, 13, 0, 0)},
+		"day of month or week":    {expr: "0 0 10 * 5", from: utc(1, 7, 0, 0), want: utc(1, 10, 0, 0)},
+		"day of week or month":    {expr: "0 0 20 * 5", from: utc(1, 7, 0, 0), want: utc(1, 13, 0, 0)},
+		"day of month step":       {expr: "0 0 */1 * 5", from: utc(1, 7, 0, 0), want: utc(1, 13, 0, 0)},
+		"day of week step":        {expr: "0 0 10 * */1", from: utc(1, 7, 0, 0), want: utc(1, 10, 0, 0)},
+		"sunday as seven":         {expr: "0 0 * * 7", from: utc(1, 2, 0, 0), want: utc(1, 8, 0, 0)},
+		"month":                   {expr: "0 0 1 2 *", from: utc(3, 1, 0, 0), want: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		"leap day":                {expr: "0 0 29 2 *", from: utc(3, 1, 0, 0), want: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		"never":                   {expr: "0 0 30 2 *", from: utc(1, 1, 0, 0), want: time.Time{}},
+		"location":                {expr: "0 3 * * *", loc: budapest, from: utc(1, 1, 10, 0), want: utc(1, 2, 2, 0)},
+		"spring forward skipped":  {expr: "30 2 * * *", loc: budapest, from: utc(3, 25, 11, 0), want: utc(3, 27, 0, 30)},
+		"spring forward hourly":   {expr: "0 * * * *", loc: budapest, from: utc(3, 26, 0, 30), want: utc(3, 26, 1, 0)},
+		"fall back first":         {expr: "30 2 * * *", loc: budapest, from: utc(10, 28, 22, 0), want: utc(10, 29, 0, 30)},
+		"fall back once":          {expr: "30 2 * * *", loc: budapest, from: utc(10, 29, 0, 30), want: utc(10, 30, 1, 30)},
+		"fall back hourly":        {expr: "0 * * * *", loc: budapest, from: utc(10, 28, 23, 30), want: utc(10, 29, 0, 0)},
+		"fall back hourly repeat": {expr: "0 * * * *", loc: budapest, from: utc(10, 29, 0, 0), want: utc(10, 29, 2, 0)},
+		"skipped midnight":        {expr: "0 12 4 11 *", loc: saoPaulo, from: time.Date(2018, 11, 3, 15, 0, 0, 0, time.UTC), want: time.Date(2018, 11, 4, 14, 0, 0, 0, time.UTC)},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			schedule, err := ParseCronSchedule(tt.expr, tt.loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := schedule.Next(tt.from); !got.Equal(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,299 @@
+package pgstore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// SchemaDiff is the difference between the schema expected by a store and the
+// schema of the database. The tables, columns and indexes are named as
+// configured in the store.
+type SchemaDiff struct {
+	MissingTables  []string
+	MissingColumns []string
+	// WrongTypes lists the columns of unexpected type with the expected and
+	// the actual type.
+	WrongTypes     []string
+	MissingIndexes []string
+}
+
+// Empty returns true if the schema of the database matches.
+func (d *SchemaDiff) Empty() bool {
+	return len(d.MissingTables) == 0 && len(d.MissingColumns) == 0 && len(d.WrongTypes) == 0 && len(d.MissingIndexes) == 0
+}
+
+// String returns the differences, one per line.
+func (d *SchemaDiff) String() string {
+	var lines []string
+
+	for _, diff := range []struct {
+		prefix string
+		items  []string
+	}{
+		{"missing table", d.MissingTables},
+		{"missing column", d.MissingColumns},
+		{"wrong type", d.WrongTypes},
+		{"missing index", d.MissingIndexes},
+	} {
+		for _, item := range diff.items {
+			lines = append(lines, diff.prefix+": "+item)
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// merge appends the differences of the other diff.
+func (d *SchemaDiff) merge(other *SchemaDiff) {
+	d.MissingTables = append(d.MissingTables, other.MissingTables...)
+	d.MissingColumns = append(d.MissingColumns, other.MissingColumns...)
+	d.WrongTypes = append(d.WrongTypes, other.WrongTypes...)
+	d.MissingIndexes = append(d.MissingIndexes, other.MissingIndexes...)
+}
+
+// schemaColumn is an expected column with its type as printed by the
+// format_type function.
+type schemaColumn struct {
+	name string
+	typ  string
+}
+
+// schemaTable is an expected table with its columns and indexes.
+type schemaTable struct {
+	name    string
+	columns []schemaColumn
+	indexes []string
+}
+
+// verifySchema compares the expected tables with the tables of the database.
+// It returns the diff and ErrSchemaMismatch if the schema does not match.
+func verifySchema(ctx context.Context, q querier, tables []schemaTable) (*SchemaDiff, error) {
+	diff := new(SchemaDiff)
+
+	for _, table := range tables {
+		if err := verifyTable(ctx, q, table, diff); err != nil {
+			return nil, wrapDatabaseError(err)
+		}
+	}
+
+	if !diff.Empty() {
+		return diff, ErrSchemaMismatch
+	}
+
+	return diff, nil
+}
+
+// verifyTable compares the expected table with the table of the database and
+// adds the differences to the diff.
+func verifyTable(ctx context.Context, q querier, table schemaTable, diff *SchemaDiff) error {
+	var exists bool
+	if err := q.QueryRow(ctx, "SELECT to_regclass($1) IS NOT NULL", table.name).Scan(&exists); err != nil {
+		return err
+	}
+
+	if !exists {
+		diff.MissingTables = append(diff.MissingTables, table.name)
+		return nil
+	}
+
+	rows, err := q.Query(ctx, `
+		SELECT attname::TEXT, format_type(atttypid, atttypmod) FROM pg_attribute
+		WHERE attrelid = to_regclass($1) AND attnum > 0 AND NOT attisdropped`,
+		table.name,
+	)
+	if err != nil {
+		return err
+	}
+
+	actual := make(map[string]string)
+
+	var name, typ string
+	if _, err = pgx.ForEachRow(rows, []any{&name, &typ}, func() error {
+		actual[name] = typ
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	for _, column := range table.columns {
+		typ, ok := actual[column.name]
+		switch {
+		case !ok:
+			diff.MissingColumns = append(diff.MissingColumns, table.name+"."+column.name)
+		case typ != column.typ:
+			diff.WrongTypes = append(diff.WrongTypes, fmt.Sprintf("%s.%s: expected %s, got %s", table.name, column.name, column.typ, typ))
+		}
+	}
+
+	rows, err = q.Query(ctx, `
+		SELECT c.relname::TEXT FROM pg_index i JOIN pg_class c ON c.oid = i.indexrelid
+		WHERE i.indrelid = to_regclass($1)`,
+		table.name,
+	)
+	if err != nil {
+		return err
+	}
+
+	indexes, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return err
+	}
+
+	existing := make(map[string]bool, len(indexes))
+	for _, index := range indexes {
+		existing[index] = true
+	}
+
+	for _, index := range table.indexes {
+		if !existing[index] {
+			diff.MissingIndexes = append(diff.MissingIndexes, index)
+		}
+	}
+
+	return nil
+}
+
+// schemaTables returns the tables expected by the token store.
+func (s *TokenStore) schemaTables() []schemaTable {
+	const timestamp = "timestamp with time zone"
+
+	prefix := "idx_" + unqualifiedName(s.table) + "_"
+	table := schemaTable{
+		name: s.table,
+		columns: []schemaColumn{
+			{"id", strings.ToLower(s.keyType())},
+			{s.columns.Code, "text"},
+			{s.columns.Access, "text"},
+			{s.columns.Refresh, "text"},
+			{"client_id", "text"},
+			{"user_id", "text"},
+			{"scope", "text"},
+			{"grant_type", "text"},
+			{"family_id", "text"},
+			{"realm", "text"},
+			{"issuer", "text"},
+			{"audience", "text[]"},
+			{"client_ip", "inet"},
+			{"user_agent", "text"},
+			{"parent_id", strings.ToLower(s.keyType())},
+			{"rotated_at", timestamp},
+			{"revoked_at", timestamp},
+			{"last_used_at", timestamp},
+			{"anonymized_at", timestamp},
+			{"key_id", "text"},
+			{s.columns.Data, strings.ToLower(s.dataColumnType())},
+			{s.columns.CreatedAt, timestamp},
+			{s.columns.ExpiresAt, timestamp},
+		},
+	}
+
+	for _, index := range []string{
+		"code_idx", "access_idx", "refresh_idx", "expires_idx", "client_created_idx", "family_idx", "user_idx",
+		"realm_idx", "audience_idx", "scope_idx", "expires_id_idx", "active_expires_idx", "active_client_idx", "revoked_access_idx",
+	} {
+		table.indexes = append(table.indexes, prefix+index)
+	}
+
+	if s.uniqueTokens {
+		for _, column := range []string{s.columns.Code, s.columns.Access, s.columns.Refresh} {
+			table.indexes = append(table.indexes, s.uniqueIndexes()[column])
+		}
+	}
+
+	if s.hasShardKey() {
+		table.columns = append(table.columns, schemaColumn{shardKeyColumn, "text"})
+	}
+
+	tables := []schemaTable{table}
+	for i := 0; i < s.shards; i++ {
+		tables = append(tables, schemaTable{name: s.shardName(i)})
+	}
+	if s.archiveTable != "" {
+		tables = append(tables, schemaTable{
+			name:    s.archiveTable,
+			columns: []schemaColumn{{"archived_at", timestamp}},
+			indexes: []string{"idx_" + unqualifiedName(s.archiveTable) + "_archived_idx"},
+		})
+	}
+
+	return tables
+}
+
+// VerifySchema checks that the token table has the columns, types and indexes
+// created by InitTable, so misconfigured deployments can fail at startup. It
+// returns the differences and ErrSchemaMismatch if the schema does not match.
+func (s *TokenStore) VerifySchema(ctx context.Context) (*SchemaDiff, error) {
+	s.logger.Log(ctx, LogLevelDebug, "verifying token store schema", "table", s.table)
+
+	diff, err := verifySchema(ctx, s.pool, s.schemaTables())
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error(), "diff", diff)
+	}
+
+	return diff, err
+}
+
+// schemaTables returns the tables expected by the client store.
+func (s *ClientStore) schemaTables() []schemaTable {
+	const varchar = "character varying(255)"
+
+	return []schemaTable{
+		{
+			name: s.table,
+			columns: []schemaColumn{
+				{"id", varchar},
+				{"secret", "text"},
+				{"domain", varchar},
+				{"is_public", "boolean"},
+				{"is_enabled", "boolean"},
+				{"data", strings.ToLower(s.dataColumnType())},
+				{"created_at", "timestamp with time zone"},
+				{"version", "bigint"},
+				{"key_id", "text"},
+				{"realm", "text"},
+				{"grant_types", "text[]"},
+				{"token_endpoint_auth_method", "text"},
+				{"software_statement", "text"},
+				{"registration_token_hash", "text"},
+				{"access_token_ttl", "interval"},
+				{"refresh_token_ttl", "interval"},
+			},
+			indexes: []string{unqualifiedName(s.table) + "_domain_idx"},
+		},
+		{
+			name:    s.secretTable,
+			columns: []schemaColumn{{"client_id", varchar}, {"secret_hash", "text"}},
+			indexes: []string{"idx_" + unqualifiedName(s.secretTable) + "_client_hash_idx"},
+		},
+		{
+			name:    s.redirectURITable,
+			columns: []schemaColumn{{"client_id", varchar}, {"uri", "text"}, {"prefix", "boolean"}},
+		},
+		{
+			name:    s.scopeTable,
+			columns: []schemaColumn{{"name", varchar}, {"description", "text"}},
+		},
+		{
+			name:    s.clientScopeTable,
+			columns: []schemaColumn{{"client_id", varchar}, {"scope", varchar}},
+		},
+	}
+}
+
+// VerifySchema checks that the client tables have the columns, types and
+// indexes created by InitTable, so misconfigured deployments can fail at
+// startup. It returns the differences and ErrSchemaMismatch if the schema
+// does not match.
+func (s *ClientStore) VerifySchema(ctx context.Context) (*SchemaDiff, error) {
+	s.logger.Log(ctx, LogLevelDebug, "verifying client store schema", "table", s.table)
+
+	diff, err := verifySchema(ctx, s.pool, s.schemaTables())
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error(), "diff", diff)
+	}
+
+	return diff, err
+}
@@ -0,0 +1,76 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestSchemaDiff(t *testing.T) {
+	diff := new(SchemaDiff)
+	if !diff.Empty() || diff.String() != "" {
+		t.Fatalf("got diff %q, want an empty diff", diff)
+	}
+
+	diff.merge(&SchemaDiff{MissingTables: []string{"a"}, WrongTypes: []string{"b.c: expected text, got integer"}})
+	diff.merge(&SchemaDiff{MissingIndexes: []string{"d_idx"}})
+
+	want := "missing table: a\nwrong type: b.c: expected text, got integer\nmissing index: d_idx"
+	if diff.Empty() || diff.String() != want {
+		t.Fatalf("got diff %q, want %q", diff, want)
+	}
+}
+
+func TestTokenStoreVerifySchema(t *testing.T) {
+	store := newTestTokenStore(t)
+	ctx := context.Background()
+
+	if diff, err := store.VerifySchema(ctx); err != nil {
+		t.Fatalf("got schema differences %v for the initialized table: %v", diff, err)
+	}
+
+	prefix := "idx_" + unqualifiedName(store.table) + "_"
+
+	for _, stmt := range []string{
+		"ALTER TABLE " + store.table + " DROP COLUMN issuer",
+		"ALTER TABLE " + store.table + " ALTER COLUMN user_agent TYPE VARCHAR(255)",
+		"DROP INDEX " + prefix + "user_idx",
+	} {
+		if _, err := store.pool.Exec(ctx, stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	diff, err := store.VerifySchema(ctx)
+	if !errors.Is(err, ErrSchemaMismatch) {
+		t.Fatalf("got error %v, want %v", err, ErrSchemaMismatch)
+	}
+
+	want := &SchemaDiff{
+		MissingColumns: []string{store.table + ".issuer"},
+		WrongTypes:     []string{store.table + ".user_agent: expected text, got character varying(255)"},
+		MissingIndexes: []string{prefix + "user_idx"},
+	}
+	if diff.String() != want.String() {
+		t.Fatalf("got diff %q, want %q", diff, want)
+	}
+}
+
+func TestClientStoreVerifySchemaMissingTables(t *testing.T) {
+	ctx := context.Background()
+
+	store, err := NewClientStore(WithClientStoreDSN(newTestDSN(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close(ctx)
+
+	diff, err := store.VerifySchema(ctx)
+	if !errors.Is(err, ErrSchemaMismatch) {
+		t.Fatalf("got error %v, want %v", err, ErrSchemaMismatch)
+	}
+
+	if len(diff.MissingTables) == 0 || diff.MissingTables[0] != store.table {
+		t.Fatalf("got missing tables %v, want the tables of the store", diff.MissingTables)
+	}
+}
@@ -0,0 +1,193 @@
+package pgstore
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	// DefaultScopeTable is the default table for storing the scope catalog.
+	DefaultScopeTable = "oauth2_scopes"
+	// DefaultClientScopeTable is the default table for storing the allowed
+	// scopes of clients.
+	DefaultClientScopeTable = "oauth2_client_scopes"
+)
+
+// Scope is a scope of the scope catalog.
+type Scope struct {
+	Name        string
+	Description string
+}
+
+// WithClientStoreScopeTables configures the scope catalog and the client
+// allowed scope tables.
+func WithClientStoreScopeTables(scopeTable string, clientScopeTable string) ClientStoreOption {
+	return func(s *ClientStore) error {
+		if scopeTable == "" || clientScopeTable == "" {
+			return ErrNoTable
+		}
+
+		s.scopeTable = scopeTable
+		s.clientScopeTable = clientScopeTable
+
+		return nil
+	}
+}
+
+// scopeTablesSQL returns the DDL of the scope catalog and the client allowed
+// scope tables.
+func (s *ClientStore) scopeTablesSQL() string {
+	return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %[1]s (
+			name        VARCHAR(255) PRIMARY KEY,
+			description TEXT         NOT NULL DEFAULT ''
+		);%[4]s
+
+		CREATE TABLE IF NOT EXISTS %[2]s (
+			client_id VARCHAR(255) NOT NULL REFERENCES %[3]s (id) ON DELETE CASCADE,
+			scope     VARCHAR(255) NOT NULL REFERENCES %[1]s (name) ON DELETE CASCADE,
+			PRIMARY KEY (client_id, scope)
+		);%[5]s`,
+		s.scopeTable, s.clientScopeTable, s.table, s.citusReferenceSQL(s.scopeTable), s.citusReferenceSQL(s.clientScopeTable),
+	)
+}
+
+// UpsertScope adds the scope to the scope catalog or updates its
+// description.
+func (s *ClientStore) UpsertScope(ctx context.Context, scope Scope) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	s.logger.Log(ctx, LogLevelDebug, "upserting scope", "scope", scope.Name)
+
+	_, err := s.pool.Exec(ctx, fmt.Sprintf(`
+		INSERT INTO %s (name, description) VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET description = EXCLUDED.description`,
+		s.scopeTable,
+	), scope.Name, scope.Description)
+
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return wrapDatabaseError(err)
+	}
+
+	return nil
+}
+
+// RemoveScope removes the scope from the scope catalog and from the allowed
+// scopes of every client.
+func (s *ClientStore) RemoveScope(ctx context.Context, name string) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	s.logger.Log(ctx, LogLevelDebug, "removing scope", "scope", name)
+
+	if _, err := s.pool.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE name = $1", s.scopeTable), name); err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return wrapDatabaseError(err)
+	}
+
+	return nil
+}
+
+// ListScopes returns the scope catalog.
+func (s *ClientStore) ListScopes(ctx context.Context) ([]Scope, error) {
+	s.logger.Log(ctx, LogLevelDebug, "listing scopes")
+
+	rows, err := s.pool.Query(ctx, fmt.Sprintf("SELECT name, description FROM %s ORDER BY name", s.scopeTable))
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return nil, wrapDatabaseError(err)
+	}
+	defer rows.Close()
+
+	var scopes []Scope
+	for rows.Next() {
+		var scope Scope
+		if err = rows.Scan(&scope.Name, &scope.Description); err != nil {
+			s.logger.Log(ctx, LogLevelError, err.Error())
+			return nil, wrapDatabaseError(err)
+		}
+
+		scopes = append(scopes, scope)
+	}
+
+	return scopes, wrapDatabaseError(rows.Err())
+}
+
+// SetAllowedScopes replaces the allowed scopes of the client. The scopes must
+// exist in the scope catalog. It returns ErrClientNotFound if the client does
+// not exist in the realm of the store.
+func (s *ClientStore) SetAllowedScopes(ctx context.Context, clientID string, scopes []string) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	s.logger.Log(ctx, LogLevelDebug, "setting client allowed scopes", "id", clientID, "scopes", scopes)
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return wrapDatabaseError(err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err = s.lockClient(ctx, tx, clientID); err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return wrapDatabaseError(err)
+	}
+
+	if _, err = tx.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE client_id = $1", s.clientScopeTable), clientID); err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return wrapDatabaseError(err)
+	}
+
+	_, err = tx.Exec(ctx, fmt.Sprintf(
+		"INSERT INTO %s (client_id, scope) SELECT $1, unnest($2::TEXT[]) ON CONFLICT DO NOTHING",
+		s.clientScopeTable,
+	), clientID, scopes)
+
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return wrapDatabaseError(err)
+	}
+
+	return wrapDatabaseError(tx.Commit(ctx))
+}
+
+// ValidateScopes returns the requested scopes the client is not allowed to
+// request. An empty result means every requested scope is allowed. Clients of
+// other realms are not allowed any scope.
+func (s *ClientStore) ValidateScopes(ctx context.Context, clientID string, requested []string) ([]string, error) {
+	s.logger.Log(ctx, LogLevelDebug, "validating client scopes", "id", clientID, "scopes", requested)
+
+	rows, err := s.pool.Query(ctx, fmt.Sprintf(`
+		SELECT requested.scope FROM unnest($2::TEXT[]) AS requested(scope)
+		WHERE NOT EXISTS (
+			SELECT 1 FROM %s AS allowed JOIN %s AS c ON c.id = allowed.client_id
+			WHERE allowed.client_id = $1 AND allowed.scope = requested.scope%s
+		)`,
+		s.clientScopeTable, s.table, andRealm(s.realm),
+	), clientID, requested)
+
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return nil, wrapDatabaseError(err)
+	}
+	defer rows.Close()
+
+	var denied []string
+	for rows.Next() {
+		var scope string
+		if err = rows.Scan(&scope); err != nil {
+			s.logger.Log(ctx, LogLevelError, err.Error())
+			return nil, wrapDatabaseError(err)
+		}
+
+		denied = append(denied, scope)
+	}
+
+	return denied, wrapDatabaseError(rows.Err())
+}
@@ -0,0 +1,66 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+)
+
+func TestWithClientStoreScopeTablesRequiresTables(t *testing.T) {
+	if err := WithClientStoreScopeTables("scopes", "")(&ClientStore{}); !errors.Is(err, ErrNoTable) {
+		t.Fatalf("got error %v, want %v", err, ErrNoTable)
+	}
+}
+
+func TestClientStoreScopes(t *testing.T) {
+	store := newTestClientStore(t)
+	ctx := context.Background()
+
+	for _, scope := range []Scope{{Name: "write"}, {Name: "read"}, {Name: "read", Description: "Read access"}} {
+		if err := store.UpsertScope(ctx, scope); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	scopes, err := store.ListScopes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []Scope{{Name: "read", Description: "Read access"}, {Name: "write"}}; !reflect.DeepEqual(scopes, want) {
+		t.Fatalf("got scopes %v, want %v", scopes, want)
+	}
+
+	if err = store.Upsert(ctx, &models.Client{ID: "client"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = store.SetAllowedScopes(ctx, "client", []string{"unknown"}); err == nil {
+		t.Fatal("got no error for a scope missing from the catalog")
+	}
+
+	if err = store.SetAllowedScopes(ctx, "client", []string{"read"}); err != nil {
+		t.Fatal(err)
+	}
+
+	denied, err := store.ValidateScopes(ctx, "client", []string{"read", "write"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(denied, []string{"write"}) {
+		t.Fatalf("got denied scopes %v, want %v", denied, []string{"write"})
+	}
+
+	// Removing a scope from the catalog removes it from the allowed scopes.
+	if err = store.RemoveScope(ctx, "read"); err != nil {
+		t.Fatal(err)
+	}
+
+	if denied, err = store.ValidateScopes(ctx, "client", []string{"read"}); err != nil || len(denied) != 1 {
+		t.Fatalf("got denied scopes %v and error %v, want the removed scope denied", denied, err)
+	}
+}
@@ -0,0 +1,99 @@
+package pgstore
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// shardKeyColumn is the column the token table is sharded by.
+const shardKeyColumn = "shard_key"
+
+// WithTokenStoreShards configures InitTable to shard the token table into the
+// given number of physical tables by the hash of the token value, using
+// PostgreSQL hash partitioning. The shard key is the access token, or the
+// authorization code or refresh token if the token has no access token, so
+// getting and removing a token by its access token only reads a single shard.
+// The cleanup deletes the expired tokens shard by shard. Sharding only
+// applies to new tables and cannot be combined with partitioning by expiry.
+func WithTokenStoreShards(shards int) TokenStoreOption {
+	return func(s *TokenStore) error {
+		if shards < 2 {
+			return ErrInvalidSharding
+		}
+
+		s.shards = shards
+
+		return nil
+	}
+}
+
+// shardName returns the name of the shard with the given remainder.
+func (s *TokenStore) shardName(remainder int) string {
+	return fmt.Sprintf("%s_s%d", s.table, remainder)
+}
+
+// shardKeyDefinition returns the definition of the shard key column, or an
+// empty string if the table has no shard key.
+func (s *TokenStore) shardKeyDefinition() string {
+	if !s.hasShardKey() {
+		return ""
+	}
+
+	return "\n\t\t\t" + shardKeyColumn + "     TEXT                  NOT NULL,"
+}
+
+// shardsSQL returns the DDL of the shards of the token table.
+func (s *TokenStore) shardsSQL() string {
+	var ddl string
+
+	for i := 0; i < s.shards; i++ {
+		ddl += fmt.Sprintf(
+			"\nCREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES WITH (MODULUS %d, REMAINDER %d);",
+			s.shardName(i), s.table, s.shards, i,
+		)
+	}
+
+	return ddl
+}
+
+// shardKey returns the shard key of the token.
+func shardKey(item TokenStoreItem) string {
+	switch {
+	case item.Access != "":
+		return item.Access
+	case item.Code != "":
+		return item.Code
+	default:
+		return item.Refresh
+	}
+}
+
+// andShardKey returns the condition routing a query by access token, given
+// as $1, to its shard, or an empty string if the table has no shard key.
+func (s *TokenStore) andShardKey() string {
+	if !s.hasShardKey() {
+		return ""
+	}
+
+	return " AND " + shardKeyColumn + " = $1"
+}
+
+// deleteExpiredShardTokens deletes the expired rows of the kind shard by
+// shard, so each statement only locks and scans a single shard.
+func (s *TokenStore) deleteExpiredShardTokens(ctx context.Context, now time.Time, kind cleanupKind) (int64, error) {
+	var total int64
+
+	for i := 0; i < s.shards; i++ {
+		shard := s.shardName(i)
+
+		tag, err := s.pool.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE %s", shard, s.expiredCondition(shard, kind)), now)
+		total += tag.RowsAffected()
+
+		if err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
@@ -0,0 +1,110 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenStoreShardsInvalid(t *testing.T) {
+	if err := WithTokenStoreShards(1)(&TokenStore{}); !errors.Is(err, ErrInvalidSharding) {
+		t.Fatalf("got error %v, want %v", err, ErrInvalidSharding)
+	}
+
+	_, err := newTokenStore(WithTokenStoreDB(unusedDB{}), WithTokenStoreShards(4), WithTokenStorePartitioning(24*time.Hour, 2))
+	if !errors.Is(err, ErrInvalidSharding) {
+		t.Fatalf("got error %v for sharding with partitioning, want %v", err, ErrInvalidSharding)
+	}
+}
+
+func TestShardKey(t *testing.T) {
+	for want, item := range map[string]TokenStoreItem{
+		"access":  {Code: "code", Access: "access", Refresh: "refresh"},
+		"code":    {Code: "code"},
+		"refresh": {Refresh: "refresh"},
+	} {
+		if got := shardKey(item); got != want {
+			t.Errorf("got shard key %q, want %q", got, want)
+		}
+	}
+}
+
+func TestTokenStoreShardsSQL(t *testing.T) {
+	store := &TokenStore{table: "tokens", shards: 3}
+
+	want := "\nCREATE TABLE IF NOT EXISTS tokens_s0 PARTITION OF tokens FOR VALUES WITH (MODULUS 3, REMAINDER 0);" +
+		"\nCREATE TABLE IF NOT EXISTS tokens_s1 PARTITION OF tokens FOR VALUES WITH (MODULUS 3, REMAINDER 1);" +
+		"\nCREATE TABLE IF NOT EXISTS tokens_s2 PARTITION OF tokens FOR VALUES WITH (MODULUS 3, REMAINDER 2);"
+	if got := store.shardsSQL(); got != want {
+		t.Fatalf("got DDL %q, want %q", got, want)
+	}
+
+	if got := store.andShardKey(); got != " AND shard_key = $1" {
+		t.Fatalf("got condition %q", got)
+	}
+}
+
+func TestTokenStoreDeleteExpiredShardTokens(t *testing.T) {
+	db := &batchDB{removed: []int64{1, 0, 2}}
+
+	store, err := newTokenStore(WithTokenStoreDB(db), WithTokenStoreShards(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := store.deleteExpiredTokens(context.Background(), time.Now(), cleanupAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if removed != 3 || db.calls != 3 {
+		t.Fatalf("got %d tokens removed in %d statements, want 3 in one per shard", removed, db.calls)
+	}
+}
+
+func TestTokenStoreShards(t *testing.T) {
+	store := newTestTokenStore(t, WithTokenStoreShards(4))
+	ctx := context.Background()
+
+	if ddl := store.InitTableSQL(); !strings.Contains(ddl, "PARTITION BY HASH") {
+		t.Fatalf("got DDL %s, want a hash partitioned table", ddl)
+	}
+
+	for i, suffix := range []string{"a", "b", "c", "d", "e", "f"} {
+		expiresAt := time.Now().Add(time.Hour)
+		if i%2 == 0 {
+			expiresAt = time.Now().Add(-time.Hour)
+		}
+
+		if err := store.CreateWithExpiry(ctx, newTestToken("client", "user", suffix), expiresAt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := store.GetByAccess(ctx, "access-b"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.GetByRefresh(ctx, "refresh-d"); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := store.PurgeExpired(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if removed != 3 {
+		t.Fatalf("got %d tokens purged from the shards, want 3", removed)
+	}
+
+	if err = store.RemoveByAccess(ctx, "access-f"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = store.GetByAccess(ctx, "access-f"); !errors.Is(err, ErrTokenNotFound) {
+		t.Fatalf("got error %v for a removed token, want %v", err, ErrTokenNotFound)
+	}
+}
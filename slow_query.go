@@ -0,0 +1,254 @@
+package pgstore
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// queryObserver logs the slow statements and annotates the queries with the
+// name of the operation.
+type queryObserver struct {
+	threshold time.Duration
+	annotate  bool
+}
+
+// query returns the query annotated with the name of the operation, so it can
+// be identified in pg_stat_statements and pg_stat_activity.
+func (o *queryObserver) query(op string, sql string) string {
+	if !o.annotate {
+		return sql
+	}
+
+	return "/* pgstore:" + op + " */ " + sql
+}
+
+// wrap returns the database logging the statements taking longer than the
+// threshold, or the database itself if no threshold is configured.
+func (o *queryObserver) wrap(db DB, logger Logger, clock Clock) DB {
+	if o.threshold <= 0 || db == nil {
+		return db
+	}
+
+	return &observedDB{DB: db, log: &slowQueryLog{threshold: o.threshold, logger: logger, clock: clock}}
+}
+
+// slowQueryLog logs the statements taking longer than the threshold.
+type slowQueryLog struct {
+	threshold time.Duration
+	logger    Logger
+	clock     Clock
+}
+
+// observe logs the statement started at the given time at warn level if it
+// took longer than the threshold, with the operation of its annotation.
+func (l *slowQueryLog) observe(ctx context.Context, sql string, start time.Time) {
+	duration := l.clock.Now().Sub(start)
+	if duration < l.threshold {
+		return
+	}
+
+	op := ""
+	if strings.HasPrefix(sql, "/* pgstore:") {
+		op = strings.TrimPrefix(sql[:strings.Index(sql, " */")], "/* pgstore:")
+	}
+
+	l.logger.Log(ctx, LogLevelWarn, "slow query", "operation", op, "duration", duration, "query", sql)
+}
+
+// exec runs and observes the statement.
+func (l *slowQueryLog) exec(ctx context.Context, q querier, sql string, args []any) (pgconn.CommandTag, error) {
+	defer l.observe(ctx, sql, l.clock.Now())
+	return q.Exec(ctx, sql, args...)
+}
+
+// query runs the query and observes it until its rows are closed.
+func (l *slowQueryLog) query(ctx context.Context, q querier, sql string, args []any) (pgx.Rows, error) {
+	start := l.clock.Now()
+
+	rows, err := q.Query(ctx, sql, args...)
+	if err != nil {
+		l.observe(ctx, sql, start)
+		return rows, err
+	}
+
+	return &observedRows{Rows: rows, log: l, ctx: ctx, sql: sql, start: start}, nil
+}
+
+// queryRow runs the query and observes it until its row is scanned.
+func (l *slowQueryLog) queryRow(ctx context.Context, q querier, sql string, args []any) pgx.Row {
+	start := l.clock.Now()
+	return &observedRow{Row: q.QueryRow(ctx, sql, args...), log: l, ctx: ctx, sql: sql, start: start}
+}
+
+// copyFrom runs and observes the copy.
+func (l *slowQueryLog) copyFrom(ctx context.Context, q querier, table pgx.Identifier, columns []string, src pgx.CopyFromSource) (int64, error) {
+	defer l.observe(ctx, "COPY "+table.Sanitize(), l.clock.Now())
+	return q.CopyFrom(ctx, table, columns, src)
+}
+
+// observedDB is a database logging the slow statements run on it or in its
+// transactions.
+type observedDB struct {
+	DB
+	log *slowQueryLog
+}
+
+func (d *observedDB) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return d.log.exec(ctx, d.DB, sql, args)
+}
+
+func (d *observedDB) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return d.log.query(ctx, d.DB, sql, args)
+}
+
+func (d *observedDB) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return d.log.queryRow(ctx, d.DB, sql, args)
+}
+
+func (d *observedDB) CopyFrom(ctx context.Context, table pgx.Identifier, columns []string, src pgx.CopyFromSource) (int64, error) {
+	return d.log.copyFrom(ctx, d.DB, table, columns, src)
+}
+
+func (d *observedDB) Begin(ctx context.Context) (pgx.Tx, error) {
+	tx, err := d.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &observedTx{Tx: tx, log: d.log}, nil
+}
+
+func (d *observedDB) BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+	tx, err := d.DB.BeginTx(ctx, txOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	return &observedTx{Tx: tx, log: d.log}, nil
+}
+
+// observedTx is a transaction logging the slow statements run in it.
+type observedTx struct {
+	pgx.Tx
+	log *slowQueryLog
+}
+
+func (t *observedTx) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return t.log.exec(ctx, t.Tx, sql, args)
+}
+
+func (t *observedTx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return t.log.query(ctx, t.Tx, sql, args)
+}
+
+func (t *observedTx) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return t.log.queryRow(ctx, t.Tx, sql, args)
+}
+
+func (t *observedTx) CopyFrom(ctx context.Context, table pgx.Identifier, columns []string, src pgx.CopyFromSource) (int64, error) {
+	return t.log.copyFrom(ctx, t.Tx, table, columns, src)
+}
+
+func (t *observedTx) Begin(ctx context.Context) (pgx.Tx, error) {
+	tx, err := t.Tx.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &observedTx{Tx: tx, log: t.log}, nil
+}
+
+// observedRows are the rows of a query observed until they are closed.
+type observedRows struct {
+	pgx.Rows
+	log    *slowQueryLog
+	ctx    context.Context
+	sql    string
+	start  time.Time
+	closed bool
+}
+
+func (r *observedRows) Next() bool {
+	if r.Rows.Next() {
+		return true
+	}
+
+	r.Close()
+
+	return false
+}
+
+func (r *observedRows) Close() {
+	r.Rows.Close()
+
+	if !r.closed {
+		r.closed = true
+		r.log.observe(r.ctx, r.sql, r.start)
+	}
+}
+
+// observedRow is the row of a query observed until it is scanned.
+type observedRow struct {
+	pgx.Row
+	log   *slowQueryLog
+	ctx   context.Context
+	sql   string
+	start time.Time
+}
+
+func (r *observedRow) Scan(dest ...any) error {
+	defer r.log.observe(r.ctx, r.sql, r.start)
+	return r.Row.Scan(dest...)
+}
+
+// WithTokenStoreSlowQueryThreshold configures the latency threshold above
+// which the statements of the store are logged at warn level with their
+// operation, duration and query.
+func WithTokenStoreSlowQueryThreshold(threshold time.Duration) TokenStoreOption {
+	return func(s *TokenStore) error {
+		if threshold <= 0 {
+			return ErrInvalidSlowQueryThreshold
+		}
+
+		s.observer.threshold = threshold
+
+		return nil
+	}
+}
+
+// WithTokenStoreQueryAnnotation configures the store to prefix the queries of
+// the operations with a comment naming the operation.
+func WithTokenStoreQueryAnnotation() TokenStoreOption {
+	return func(s *TokenStore) error {
+		s.observer.annotate = true
+		return nil
+	}
+}
+
+// WithClientStoreSlowQueryThreshold configures the latency threshold above
+// which the statements of the store are logged at warn level with their
+// operation, duration and query.
+func WithClientStoreSlowQueryThreshold(threshold time.Duration) ClientStoreOption {
+	return func(s *ClientStore) error {
+		if threshold <= 0 {
+			return ErrInvalidSlowQueryThreshold
+		}
+
+		s.observer.threshold = threshold
+
+		return nil
+	}
+}
+
+// WithClientStoreQueryAnnotation configures the store to prefix the queries of
+// the operations with a comment naming the operation.
+func WithClientStoreQueryAnnotation() ClientStoreOption {
+	return func(s *ClientStore) error {
+		s.observer.annotate = true
+		return nil
+	}
+}
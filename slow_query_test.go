@@ -0,0 +1,95 @@
+package pgstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// steppingClock is a clock advancing by the step on every reading.
+type steppingClock struct {
+	now  time.Time
+	step time.Duration
+}
+
+func (c *steppingClock) Now() time.Time {
+	c.now = c.now.Add(c.step)
+	return c.now
+}
+
+// execDB is a database running every statement successfully.
+type execDB struct {
+	DB
+}
+
+func (d *execDB) Exec(context.Context, string, ...any) (pgconn.CommandTag, error) {
+	return pgconn.NewCommandTag("UPDATE 1"), nil
+}
+
+func (d *execDB) Begin(context.Context) (pgx.Tx, error) {
+	return &execTx{}, nil
+}
+
+// execTx is a transaction running every statement successfully.
+type execTx struct {
+	pgx.Tx
+}
+
+func (t *execTx) Exec(context.Context, string, ...any) (pgconn.CommandTag, error) {
+	return pgconn.NewCommandTag("UPDATE 1"), nil
+}
+
+func TestQueryObserverLogsSlowStatements(t *testing.T) {
+	observer := &queryObserver{threshold: time.Second, annotate: true}
+	logger := new(recordingLogger)
+	db := observer.wrap(&execDB{}, logger, &steppingClock{step: time.Second})
+	ctx := context.Background()
+
+	if _, err := db.Exec(ctx, observer.query("Create", "INSERT")); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = tx.Exec(ctx, "UPDATE"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(logger.messages) != 2 || logger.messages[0] != "slow query" || logger.messages[1] != "slow query" {
+		t.Fatalf("got messages %q, want two slow queries", logger.messages)
+	}
+}
+
+func TestQueryObserverIgnoresFastStatements(t *testing.T) {
+	observer := &queryObserver{threshold: time.Minute}
+	logger := new(recordingLogger)
+	db := observer.wrap(&execDB{}, logger, &steppingClock{step: time.Second})
+
+	if _, err := db.Exec(context.Background(), "INSERT"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(logger.messages) != 0 {
+		t.Fatalf("got messages %q, want none", logger.messages)
+	}
+}
+
+func TestQueryObserverWrapsOnlyWithThreshold(t *testing.T) {
+	pool := new(pgxpool.Pool)
+
+	if db := new(queryObserver).wrap(pool, new(NoopLogger), new(SystemClock)); db != DB(pool) {
+		t.Fatal("database wrapped without a threshold")
+	}
+
+	db := (&queryObserver{threshold: time.Second}).wrap(pool, new(NoopLogger), new(SystemClock))
+	if native, ok := nativePool(db); !ok || native != pool {
+		t.Fatal("connection pool of the observed database not returned")
+	}
+}
@@ -0,0 +1,401 @@
+package pgstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// SplitTokenStore is a token store keeping the authorization codes, access
+// tokens and refresh tokens in separate tables, linked by the ID of the grant
+// they were issued for. Every table only holds one kind of token with its own
+// expiry, which keeps the hot tables small and their indexes tight for
+// high-volume deployments.
+type SplitTokenStore struct {
+	store        *TokenStore
+	codeTable    string
+	accessTable  string
+	refreshTable string
+}
+
+// GrantIDTokenInfo is implemented by the token types carrying the ID of the
+// grant the token was issued for, so the split token store can link the
+// tokens issued for an authorization code to the code.
+type GrantIDTokenInfo interface {
+	oauth2.TokenInfo
+	// GetGrantID returns the ID of the grant the token was issued for.
+	GetGrantID() string
+}
+
+// NewSplitTokenStore creates a new SplitTokenStore. The tables are named after
+// the token table with the "_codes", "_access" and "_refresh" suffixes. The
+// options configure the connection pool, logger, clock, codec, compression,
+// encryption, code hashing, column names, realm, issuer, read-only mode and
+// cleanup interval; the options of the single table layout, such as
+// partitioning, quotas or hooks, are rejected with ErrUnsupportedSplitOption.
+func NewSplitTokenStore(opts ...TokenStoreOption) (*SplitTokenStore, error) {
+	store, err := newTokenStore(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = store.validateSplitLayout(); err != nil {
+		store.Close(context.Background())
+		return nil, err
+	}
+
+	s := &SplitTokenStore{
+		store:        store,
+		codeTable:    store.table + "_codes",
+		accessTable:  store.table + "_access",
+		refreshTable: store.table + "_refresh",
+	}
+
+	s.InitCleanup(context.Background())
+
+	return s, nil
+}
+
+// validateSplitLayout returns ErrUnsupportedSplitOption if the store is
+// configured with an option the split token store does not implement.
+func (s *TokenStore) validateSplitLayout() error {
+	unsupported := []struct {
+		option string
+		set    bool
+	}{
+		{"soft revocation", s.softRevocation},
+		{"client quota", s.clientQuota.limit > 0},
+		{"session limit", s.sessionLimit > 0},
+		{"rate limit", s.rateLimit > 0},
+		{"unique tokens", s.uniqueTokens},
+		{"hooks", s.hooks.BeforeCreate != nil || s.hooks.AfterCreate != nil || s.hooks.AfterRemove != nil},
+		{"events", s.eventChannel != ""},
+		{"webhooks", s.webhook != nil},
+		{"circuit breaker", s.breakerThreshold > 0},
+		{"archive", s.archiveTable != ""},
+		{"anonymization", s.anonymizeAfter > 0},
+		{"cleanup schedule", s.cleanupSchedule != nil},
+		{"cleanup batches", s.cleanupBatchSize > 0},
+		{"cleanup retention", s.cleanupRetention > 0},
+		{"code cleanup", s.codeCleanupInterval > 0},
+		{"partitioning", s.partitionInterval > 0},
+		{"sharding", s.shards > 0},
+		{"citus", s.citus},
+		{"hypertable", s.hypertableChunkInterval > 0},
+		{"generated columns", s.generatedColumns},
+		{"unlogged table", s.unlogged},
+		{"storage parameters", s.storageParameters != nil},
+		{"id generator", s.idGenerator != nil},
+		{"uuid keys", s.uuidKeys != 0},
+		{"identity columns", s.identityColumns},
+		{"usage tracking", s.usageFlushInterval > 0},
+		{"read-your-writes", s.readYourWritesWindow > 0},
+	}
+
+	for _, u := range unsupported {
+		if u.set {
+			return fmt.Errorf("%w: %s", ErrUnsupportedSplitOption, u.option)
+		}
+	}
+
+	return nil
+}
+
+// grantID returns the ID of the grant the token was issued for. Tokens not
+// carrying the ID are linked by the hash of their authorization code, or of
+// their refresh or access token, so the ID is stable for the same token and
+// does not expose it.
+func (s *SplitTokenStore) grantID(info oauth2.TokenInfo) string {
+	if info, ok := info.(GrantIDTokenInfo); ok && info.GetGrantID() != "" {
+		return info.GetGrantID()
+	}
+
+	value := info.GetCode()
+	if value == "" {
+		value = info.GetRefresh()
+	}
+
+	if value == "" {
+		value = info.GetAccess()
+	}
+
+	sum := sha256.Sum256([]byte(value))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// splitTableSQL returns the DDL of a split table keyed by the token column.
+func (s *SplitTokenStore) splitTableSQL(table string, column string) string {
+	return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %[1]s (
+			%[3]s TEXT        PRIMARY KEY,
+			grant_id   TEXT        NOT NULL,
+			client_id  TEXT        NOT NULL DEFAULT '',
+			user_id    TEXT        NOT NULL DEFAULT '',
+			realm      TEXT        NOT NULL DEFAULT '',
+			issuer     TEXT        NOT NULL DEFAULT '',
+			%[4]s       %[5]s       NOT NULL,
+			%[6]s TIMESTAMPTZ NOT NULL,
+			%[7]s TIMESTAMPTZ NOT NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_%[2]s_grant_idx ON %[1]s (grant_id);
+		CREATE INDEX IF NOT EXISTS idx_%[2]s_expires_idx ON %[1]s (%[7]s);`,
+		table, unqualifiedName(table), column, s.store.columns.Data, s.store.dataColumnType(),
+		s.store.columns.CreatedAt, s.store.columns.ExpiresAt,
+	)
+}
+
+// InitTableSQL returns the DDL statements executed by InitTable without
+// executing them.
+func (s *SplitTokenStore) InitTableSQL() string {
+	return encryptionSQL(s.store.encryptionSetting) +
+		s.splitTableSQL(s.codeTable, s.store.columns.Code) + "\n" +
+		s.splitTableSQL(s.accessTable, s.store.columns.Access) + "\n" +
+		s.splitTableSQL(s.refreshTable, s.store.columns.Refresh)
+}
+
+// InitTable initializes the code, access token and refresh token tables if
+// they do not exist.
+func (s *SplitTokenStore) InitTable(ctx context.Context) error {
+	if s.store.readOnly {
+		return ErrReadOnly
+	}
+
+	s.store.logger.Log(ctx, LogLevelDebug, "initializing split token store tables", "table", s.store.table)
+
+	if _, err := s.store.pool.Exec(ctx, s.InitTableSQL()); err != nil {
+		s.store.logger.Log(ctx, LogLevelError, err.Error())
+		return wrapDatabaseError(err)
+	}
+
+	return nil
+}
+
+// insert inserts the token value of the item into the table.
+func (s *SplitTokenStore) insert(ctx context.Context, q querier, table string, column string, value string, item TokenStoreItem, expiresAt time.Time) error {
+	_, err := q.Exec(ctx, fmt.Sprintf(
+		"INSERT INTO %s (%s, grant_id, client_id, user_id, realm, issuer, %s, %s, %s) VALUES ($1, $2, $3, $4, $5, $6, %s, $8, $9)",
+		table, column, s.store.columns.Data, s.store.columns.CreatedAt, s.store.columns.ExpiresAt, s.store.encryptData("$7"),
+	), value, item.FamilyID, item.ClientID, item.UserID, s.store.realm, s.store.issuer, item.Data, item.CreatedAt, expiresAt)
+
+	return err
+}
+
+// Create creates a new token in the store. The access and refresh tokens of
+// the token are inserted in a single transaction.
+func (s *SplitTokenStore) Create(ctx context.Context, info oauth2.TokenInfo) error {
+	if s.store.readOnly {
+		return ErrReadOnly
+	}
+
+	s.store.logger.Log(ctx, LogLevelDebug, "creating token", "info", s.store.loggedInfo(info))
+
+	item, err := s.store.newTokenStoreItem(info)
+	if err != nil {
+		s.store.logger.Log(ctx, LogLevelError, err.Error())
+		return err
+	}
+
+	item.FamilyID = s.grantID(info)
+
+	if item.Code != "" {
+		err = s.insert(ctx, s.store.pool, s.codeTable, s.store.columns.Code, item.Code, item, item.ExpiresAt)
+	} else {
+		err = pgx.BeginFunc(ctx, s.store.pool, func(tx pgx.Tx) error {
+			if item.Access != "" {
+				expiresAt := info.GetAccessCreateAt().Add(info.GetAccessExpiresIn())
+				if err := s.insert(ctx, tx, s.accessTable, s.store.columns.Access, item.Access, item, expiresAt); err != nil {
+					return err
+				}
+			}
+
+			if item.Refresh != "" {
+				return s.insert(ctx, tx, s.refreshTable, s.store.columns.Refresh, item.Refresh, item, item.ExpiresAt)
+			}
+
+			return nil
+		})
+	}
+
+	if err != nil {
+		s.store.logger.Log(ctx, LogLevelError, err.Error(), "info", s.store.loggedInfo(info))
+		return wrapDatabaseError(err)
+	}
+
+	s.store.logger.Log(ctx, LogLevelDebug, "token created")
+
+	return nil
+}
+
+// get returns the token by its value in the table using the connection pool.
+// The split tables do not record the key ID, so the data is decrypted with
+// the key of the connection.
+func (s *SplitTokenStore) get(ctx context.Context, q querier, table string, column string, value string) (oauth2.TokenInfo, error) {
+	data := tokenData{store: s.store}
+
+	err := q.QueryRow(ctx, fmt.Sprintf(
+		"SELECT %s FROM %s WHERE %s = $1%s%s",
+		s.store.decryptDataWith(encryptionKeyExpr(s.store.encryptionSetting)), table, column, s.store.andUnexpired(), s.store.andTenant(),
+	), value).Scan(&data)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			s.store.logger.Log(ctx, LogLevelDebug, "token not found")
+			return nil, ErrTokenNotFound
+		}
+
+		s.store.logger.Log(ctx, LogLevelError, err.Error())
+		return nil, wrapDatabaseError(err)
+	}
+
+	return data.info, nil
+}
+
+// GetByCode returns the token by its authorization code, read from the
+// primary connection pool like TokenStore.GetByCode.
+func (s *SplitTokenStore) GetByCode(ctx context.Context, code string) (oauth2.TokenInfo, error) {
+	s.store.logger.Log(ctx, LogLevelDebug, "getting token by authorization code", "code", s.store.loggedCode(code))
+	info, err := s.get(ctx, s.store.pool, s.codeTable, s.store.columns.Code, s.store.hashCode(code))
+	if err == nil && s.store.codeHashKey != nil {
+		info.SetCode(code)
+	}
+
+	return info, err
+}
+
+// GetByAccess returns the token by its access token.
+func (s *SplitTokenStore) GetByAccess(ctx context.Context, access string) (oauth2.TokenInfo, error) {
+	s.store.logger.Log(ctx, LogLevelDebug, "getting token by access token", "access", access)
+	return s.get(ctx, s.store.reader(), s.accessTable, s.store.columns.Access, access)
+}
+
+// GetByRefresh returns the token by its refresh token, read from the primary
+// connection pool like TokenStore.GetByRefresh.
+func (s *SplitTokenStore) GetByRefresh(ctx context.Context, refresh string) (oauth2.TokenInfo, error) {
+	s.store.logger.Log(ctx, LogLevelDebug, "getting token by refresh token", "refresh", refresh)
+	return s.get(ctx, s.store.pool, s.refreshTable, s.store.columns.Refresh, refresh)
+}
+
+// removeGrant deletes the token by its value in the table together with the
+// tokens of the same grant in the other table, so removing an access token
+// removes its refresh token, like in the single table layout.
+func (s *SplitTokenStore) removeGrant(ctx context.Context, table string, column string, other string, value string) error {
+	if s.store.readOnly {
+		return ErrReadOnly
+	}
+
+	_, err := s.store.pool.Exec(ctx, fmt.Sprintf(`
+		WITH removed AS (
+			DELETE FROM %[1]s WHERE %[2]s = $1%[4]s RETURNING grant_id
+		)
+		DELETE FROM %[3]s WHERE grant_id IN (SELECT grant_id FROM removed)`,
+		table, column, other, s.store.andTenant(),
+	), value)
+
+	if err != nil {
+		s.store.logger.Log(ctx, LogLevelError, err.Error())
+		return wrapDatabaseError(err)
+	}
+
+	return nil
+}
+
+// RemoveByCode deletes the token by its authorization code.
+func (s *SplitTokenStore) RemoveByCode(ctx context.Context, code string) error {
+	s.store.logger.Log(ctx, LogLevelDebug, "removing token by authorization code", "code", s.store.loggedCode(code))
+
+	if s.store.readOnly {
+		return ErrReadOnly
+	}
+
+	_, err := s.store.pool.Exec(ctx, fmt.Sprintf(
+		"DELETE FROM %s WHERE %s = $1%s",
+		s.codeTable, s.store.columns.Code, s.store.andTenant(),
+	), s.store.hashCode(code))
+
+	if err != nil {
+		s.store.logger.Log(ctx, LogLevelError, err.Error())
+		return wrapDatabaseError(err)
+	}
+
+	return nil
+}
+
+// RemoveByAccess deletes the token by its access token, together with the
+// refresh token of the same grant.
+func (s *SplitTokenStore) RemoveByAccess(ctx context.Context, access string) error {
+	s.store.logger.Log(ctx, LogLevelDebug, "removing token by access token", "access", access)
+	return s.removeGrant(ctx, s.accessTable, s.store.columns.Access, s.refreshTable, access)
+}
+
+// RemoveByRefresh deletes the token by its refresh token, together with the
+// access token of the same grant.
+func (s *SplitTokenStore) RemoveByRefresh(ctx context.Context, refresh string) error {
+	s.store.logger.Log(ctx, LogLevelDebug, "removing token by refresh token", "refresh", refresh)
+	return s.removeGrant(ctx, s.refreshTable, s.store.columns.Refresh, s.accessTable, refresh)
+}
+
+// cleanExpired removes the expired rows of every table and returns the number
+// of removed rows.
+func (s *SplitTokenStore) cleanExpired(ctx context.Context) (int64, error) {
+	start := s.store.clock.Now()
+
+	var removed int64
+	var err error
+
+	for _, table := range []string{s.codeTable, s.accessTable, s.refreshTable} {
+		var tag pgconn.CommandTag
+		tag, err = s.store.pool.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE %s <= $1", table, s.store.columns.ExpiresAt), start)
+		removed += tag.RowsAffected()
+
+		if err != nil {
+			break
+		}
+	}
+
+	s.store.reportCleanup(ctx, "cleaning expired split tokens", start, removed, err)
+
+	return removed, err
+}
+
+// InitCleanup initializes the cleanup process, removing the expired rows
+// every cleanup interval. The cleanup runs until the context is canceled or
+// the store is closed.
+func (s *SplitTokenStore) InitCleanup(ctx context.Context) {
+	if s.store.readOnly || s.store.cleanupInterval <= 0 {
+		return
+	}
+
+	ctx, s.store.cleanupCancel = context.WithCancel(ctx)
+	s.store.cleanupDone = make(chan struct{})
+
+	go func() {
+		defer close(s.store.cleanupDone)
+
+		ticker := time.NewTicker(s.store.cleanupInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.store.runCleanup(ctx, s.cleanExpired)
+			}
+		}
+	}()
+}
+
+// Close stops the cleanup and closes the connection pool if the store owns
+// it.
+func (s *SplitTokenStore) Close(ctx context.Context) {
+	s.store.Close(ctx)
+}
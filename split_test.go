@@ -0,0 +1,74 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"github.com/go-oauth2/oauth2/v4/models"
+)
+
+func TestNewSplitTokenStoreUnsupportedOptions(t *testing.T) {
+	for name, opt := range map[string]TokenStoreOption{
+		"soft revocation": WithTokenStoreSoftRevocation(),
+		"session limit":   WithTokenStoreMaxSessionsPerUser(1),
+		"rate limit":      WithTokenStoreRateLimit(1, time.Minute),
+		"hooks":           WithTokenStoreHooks(Hooks{AfterCreate: func(context.Context, oauth2.TokenInfo, error) {}}),
+		"circuit breaker": WithTokenStoreCircuitBreaker(1, time.Minute),
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewSplitTokenStore(WithTokenStoreDB(unusedDB{}), opt)
+			if !errors.Is(err, ErrUnsupportedSplitOption) {
+				t.Fatalf("got error %v, want %v", err, ErrUnsupportedSplitOption)
+			}
+		})
+	}
+}
+
+func TestNewSplitTokenStoreSupportedOptions(t *testing.T) {
+	store, err := NewSplitTokenStore(
+		WithTokenStoreDB(unusedDB{}),
+		WithTokenStoreCodeHashing([]byte("key")),
+		WithTokenStoreReadOnly(true),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store.Close(context.Background())
+}
+
+// grantIDToken is a token carrying the ID of its grant.
+type grantIDToken struct {
+	*models.Token
+	grantID string
+}
+
+func (t *grantIDToken) GetGrantID() string {
+	return t.grantID
+}
+
+func TestSplitTokenStoreGrantID(t *testing.T) {
+	store := &SplitTokenStore{}
+
+	code := &models.Token{Code: "code"}
+	token := &models.Token{Access: "access", Refresh: "refresh"}
+
+	if store.grantID(code) != store.grantID(&models.Token{Code: "code"}) {
+		t.Fatal("grant ID of the same code differs")
+	}
+
+	if store.grantID(token) == store.grantID(&models.Token{Access: "access", Refresh: "other"}) {
+		t.Fatal("grant ID of different refresh tokens equal")
+	}
+
+	if got := store.grantID(token); got == "refresh" || got == "access" {
+		t.Fatalf("got grant ID %q, want the token hashed", got)
+	}
+
+	if got := store.grantID(&grantIDToken{Token: token, grantID: "grant"}); got != "grant" {
+		t.Fatalf("got grant ID %q, want %q", got, "grant")
+	}
+}
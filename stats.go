@@ -0,0 +1,167 @@
+package pgstore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+)
+
+const (
+	TokenKindCode    = TokenKind("code")    // authorization code
+	TokenKindAccess  = TokenKind("access")  // access token
+	TokenKindRefresh = TokenKind("refresh") // refresh token
+)
+
+// TokenKind is the kind of token stored in a row.
+type TokenKind string
+
+// TokenStats holds the usage statistics of the token store.
+type TokenStats struct {
+	// Active is the number of not yet expired tokens, not counting the revoked
+	// tokens and the rotated refresh tokens, as counted by CountActive.
+	Active int64
+	// Expired is the number of expired tokens not cleaned up yet.
+	Expired int64
+	// PerClient is the number of active tokens per client ID, as counted by
+	// CountByClient.
+	PerClient map[string]int64
+	// PerGrantType is the number of active tokens per grant type they were
+	// issued for. Tokens issued without a known grant type are counted with
+	// an empty grant type.
+	PerGrantType map[oauth2.GrantType]int64
+}
+
+// ClientStats holds the usage statistics of the client store.
+type ClientStats struct {
+	// Total is the number of stored clients.
+	Total int64
+}
+
+// Stats returns the usage statistics of the token store.
+func (s *TokenStore) Stats(ctx context.Context) (*TokenStats, error) {
+	s.logger.Log(ctx, LogLevelDebug, "getting token stats")
+
+	now := s.clock.Now()
+	stats := &TokenStats{
+		PerClient:    make(map[string]int64),
+		PerGrantType: make(map[oauth2.GrantType]int64),
+	}
+
+	err := s.reader().QueryRow(ctx, fmt.Sprintf(`
+		SELECT COUNT(*) FILTER (WHERE %[1]s), COUNT(*) FILTER (WHERE %[2]s <= $1)
+		FROM %[3]s%[4]s`,
+		s.activePredicate("$1"), s.columns.ExpiresAt, s.table, s.whereTenant(),
+	), now).Scan(&stats.Active, &stats.Expired)
+
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return nil, wrapDatabaseError(err)
+	}
+
+	if err = s.countGroups(ctx, "client_id", now, func(key string, count int64) {
+		stats.PerClient[key] = count
+	}); err != nil {
+		return nil, err
+	}
+
+	if err = s.countGroups(ctx, "grant_type", now, func(key string, count int64) {
+		stats.PerGrantType[oauth2.GrantType(key)] = count
+	}); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// activePredicate returns the condition matching the active tokens at the
+// time of the given parameter: the not yet expired tokens, not counting the
+// revoked tokens and the rotated refresh tokens. It is shared by the counts
+// and quotas, so they agree on what an active token is.
+func (s *TokenStore) activePredicate(param string) string {
+	return fmt.Sprintf("%s > %s AND rotated_at IS NULL AND revoked_at IS NULL", s.columns.ExpiresAt, param)
+}
+
+// CountActive returns the number of not yet expired tokens, not counting the
+// revoked tokens and the rotated refresh tokens.
+func (s *TokenStore) CountActive(ctx context.Context) (int64, error) {
+	s.logger.Log(ctx, LogLevelDebug, "counting active tokens")
+
+	var count int64
+	err := s.reader().QueryRow(ctx, fmt.Sprintf(
+		"SELECT COUNT(*) FROM %s WHERE %s%s",
+		s.table, s.activePredicate("$1"), s.andTenant(),
+	), s.clock.Now()).Scan(&count)
+
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return 0, wrapDatabaseError(err)
+	}
+
+	return count, nil
+}
+
+// CountByClient returns the number of not yet expired tokens issued to the
+// client, not counting the revoked tokens and the rotated refresh tokens.
+func (s *TokenStore) CountByClient(ctx context.Context, clientID string) (int64, error) {
+	s.logger.Log(ctx, LogLevelDebug, "counting active tokens by client", "client_id", clientID)
+
+	var count int64
+	err := s.reader().QueryRow(ctx, fmt.Sprintf(
+		"SELECT COUNT(*) FROM %s WHERE client_id = $1 AND %s%s",
+		s.table, s.activePredicate("$2"), s.andTenant(),
+	), clientID, s.clock.Now()).Scan(&count)
+
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return 0, wrapDatabaseError(err)
+	}
+
+	return count, nil
+}
+
+// countGroups counts the active tokens grouped by the given expression.
+func (s *TokenStore) countGroups(ctx context.Context, expr string, now time.Time, fn func(key string, count int64)) error {
+	rows, err := s.reader().Query(ctx, fmt.Sprintf(
+		"SELECT %s, COUNT(*) FROM %s WHERE %s%s GROUP BY 1",
+		expr, s.table, s.activePredicate("$1"), s.andTenant(),
+	), now)
+
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return wrapDatabaseError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		var count int64
+		if err = rows.Scan(&key, &count); err != nil {
+			s.logger.Log(ctx, LogLevelError, err.Error())
+			return wrapDatabaseError(err)
+		}
+
+		fn(key, count)
+	}
+
+	if err = rows.Err(); err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return wrapDatabaseError(err)
+	}
+
+	return nil
+}
+
+// Stats returns the usage statistics of the client store.
+func (s *ClientStore) Stats(ctx context.Context) (*ClientStats, error) {
+	s.logger.Log(ctx, LogLevelDebug, "getting client stats")
+
+	stats := new(ClientStats)
+	if err := s.pool.QueryRow(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s%s", s.table, whereRealm(s.realm))).Scan(&stats.Total); err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return nil, wrapDatabaseError(err)
+	}
+
+	return stats, nil
+}
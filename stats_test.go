@@ -0,0 +1,85 @@
+package pgstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"github.com/go-oauth2/oauth2/v4/models"
+)
+
+func TestTokenStoreStatsPerGrantType(t *testing.T) {
+	store := newTestTokenStore(t)
+	ctx := context.Background()
+
+	code := &models.Token{
+		ClientID:      "client",
+		UserID:        "user",
+		Code:          "code",
+		CodeCreateAt:  time.Now(),
+		CodeExpiresIn: time.Minute,
+	}
+
+	if err := store.Create(ctx, code); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.CreateWithMetadata(ctx, newTestToken("client", "user", "1"), TokenMetadata{GrantType: oauth2.PasswordCredentials}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Create(ctx, newTestToken("client", "user", "2")); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := store.Stats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for grantType, want := range map[oauth2.GrantType]int64{
+		oauth2.AuthorizationCode:   1,
+		oauth2.PasswordCredentials: 1,
+		"":                         1,
+	} {
+		if got := stats.PerGrantType[grantType]; got != want {
+			t.Errorf("got %d tokens of grant type %q, want %d", got, grantType, want)
+		}
+	}
+}
+
+func TestTokenStoreStatsActiveMatchesCountActive(t *testing.T) {
+	store := newTestTokenStore(t, WithTokenStoreSoftRevocation())
+	ctx := context.Background()
+
+	for _, suffix := range []string{"1", "2", "3"} {
+		if err := store.Create(ctx, newTestToken("client", "user", suffix)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := store.RemoveByAccess(ctx, "access-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := store.Stats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	active, err := store.CountActive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byClient, err := store.CountByClient(ctx, "client")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stats.Active != 2 || active != 2 || byClient != 2 || stats.PerClient["client"] != 2 {
+		t.Fatalf("got %d active tokens in stats, %d counted, %d counted by client and %d in stats by client, want 2",
+			stats.Active, active, byClient, stats.PerClient["client"])
+	}
+}
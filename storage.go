@@ -0,0 +1,79 @@
+package pgstore
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	// storageParameterPattern matches the names of storage parameters,
+	// optionally prefixed by toast.
+	storageParameterPattern = regexp.MustCompile(`^([a-z_][a-z0-9_]*\.)?[a-z_][a-z0-9_]*$`)
+	// storageValuePattern matches the numeric, boolean and enum values of
+	// storage parameters.
+	storageValuePattern = regexp.MustCompile(`^[A-Za-z0-9_.+-]+$`)
+)
+
+// WithTokenStoreStorageParameters configures the storage parameters of the
+// token table set by InitTable, for example
+// map[string]string{"fillfactor": "90", "autovacuum_vacuum_scale_factor": "0.01", "toast.autovacuum_enabled": "true"}
+// so the autovacuum keeps up with the churn of the token table. The
+// parameters are set on existing tables too, and on the shards of a sharded
+// table. They cannot be set on a table partitioned by expiry.
+func WithTokenStoreStorageParameters(params map[string]string) TokenStoreOption {
+	return func(s *TokenStore) error {
+		if len(params) == 0 {
+			return ErrInvalidStorageParameters
+		}
+
+		for name, value := range params {
+			if !storageParameterPattern.MatchString(name) || !storageValuePattern.MatchString(value) {
+				return ErrInvalidStorageParameters
+			}
+		}
+
+		s.storageParameters = params
+
+		return nil
+	}
+}
+
+// storageParametersSQL returns the statements setting the storage parameters
+// of the token table and its shards, or an empty string if none are
+// configured. The values are quoted, as PostgreSQL accepts every value of the
+// storage parameters as a string literal.
+func (s *TokenStore) storageParametersSQL() string {
+	if len(s.storageParameters) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(s.storageParameters))
+	for name := range s.storageParameters {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	params := make([]string, len(names))
+	for i, name := range names {
+		params[i] = name + " = '" + strings.ReplaceAll(s.storageParameters[name], "'", "''") + "'"
+	}
+
+	// The storage parameters of a partitioned table are set on its partitions.
+	tables := []string{s.table}
+	if s.shards > 0 {
+		tables = make([]string, s.shards)
+		for i := range tables {
+			tables[i] = s.shardName(i)
+		}
+	}
+
+	var ddl string
+	for _, table := range tables {
+		ddl += fmt.Sprintf("\nALTER TABLE %s SET (%s);", table, strings.Join(params, ", "))
+	}
+
+	return ddl
+}
@@ -0,0 +1,57 @@
+package pgstore
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithTokenStoreStorageParametersInvalid(t *testing.T) {
+	for name, params := range map[string]map[string]string{
+		"empty":          {},
+		"injected value": {"fillfactor": "90); DROP TABLE oauth2_tokens; --"},
+		"quoted value":   {"fillfactor": "'90'"},
+		"empty value":    {"fillfactor": ""},
+		"invalid name":   {"fill factor": "90"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewTokenStore(WithTokenStoreDB(unusedDB{}), WithTokenStoreStorageParameters(params))
+			if !errors.Is(err, ErrInvalidStorageParameters) {
+				t.Fatalf("got error %v, want %v", err, ErrInvalidStorageParameters)
+			}
+		})
+	}
+
+	_, err := NewTokenStore(
+		WithTokenStoreDB(unusedDB{}),
+		WithTokenStoreStorageParameters(map[string]string{"fillfactor": "90"}),
+		WithTokenStorePartitioning(24*time.Hour, 1),
+	)
+	if !errors.Is(err, ErrInvalidStorageParameters) {
+		t.Fatalf("got error %v, want %v", err, ErrInvalidStorageParameters)
+	}
+}
+
+func TestTokenStoreStorageParametersSQL(t *testing.T) {
+	params := map[string]string{"toast.autovacuum_enabled": "true", "fillfactor": "90", "autovacuum_vacuum_scale_factor": "0.01"}
+
+	store, err := NewTokenStore(WithTokenStoreDB(unusedDB{}), WithTokenStoreStorageParameters(params))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "\nALTER TABLE " + store.table + " SET (autovacuum_vacuum_scale_factor = '0.01', fillfactor = '90', toast.autovacuum_enabled = 'true');"
+	if got := store.storageParametersSQL(); got != want {
+		t.Fatalf("got DDL %q, want %q", got, want)
+	}
+
+	sharded, err := NewTokenStore(WithTokenStoreDB(unusedDB{}), WithTokenStoreStorageParameters(params), WithTokenStoreShards(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Count(sharded.storageParametersSQL(), "ALTER TABLE"); got != 2 {
+		t.Fatalf("got %d statements, want one per shard", got)
+	}
+}
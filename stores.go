@@ -0,0 +1,338 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// StoresOption is a function that configures the Stores.
+type StoresOption func(*storesConfig) error
+
+// storesConfig is the configuration shared by the stores.
+type storesConfig struct {
+	pool        DB
+	poolOpts    poolOptions
+	logger      Logger
+	clock       Clock
+	schema      string
+	tablePrefix string
+	tokenOpts   []TokenStoreOption
+	clientOpts  []ClientStoreOption
+	consentOpts []ConsentStoreOption
+}
+
+// tableName returns the name of the table qualified with the schema. The
+// table prefix is added by the stores.
+func (c *storesConfig) tableName(name string) string {
+	if c.schema != "" {
+		return c.schema + "." + name
+	}
+
+	return name
+}
+
+// WithStoresDB configures the database shared by the stores, for example a
+// pgx v4 pool adapted by the pgxv4 module.
+func WithStoresDB(db DB) StoresOption {
+	return func(c *storesConfig) error {
+		if db == nil {
+			return ErrNoConnPool
+		}
+
+		c.pool = db
+
+		return nil
+	}
+}
+
+// WithStoresConnPool configures the connection pool shared by the stores.
+func WithStoresConnPool(pool *pgxpool.Pool) StoresOption {
+	return func(c *storesConfig) error {
+		if pool == nil {
+			return ErrNoConnPool
+		}
+
+		c.pool = pool
+
+		return nil
+	}
+}
+
+// WithStoresDSN configures the stores to create a shared connection pool from
+// the connection string. The pool is closed on Close. The DSN is not used if a
+// connection pool is configured.
+func WithStoresDSN(dsn string) StoresOption {
+	return func(c *storesConfig) error {
+		if dsn == "" {
+			return ErrNoDSN
+		}
+
+		c.poolOpts.dsn = dsn
+
+		return nil
+	}
+}
+
+// WithStoresPoolSize configures the minimum and maximum number of connections
+// of the connection pool created from the DSN.
+func WithStoresPoolSize(minConns, maxConns int32) StoresOption {
+	return func(c *storesConfig) error {
+		if err := validatePoolSize(minConns, maxConns); err != nil {
+			return err
+		}
+
+		c.poolOpts.minConns = minConns
+		c.poolOpts.maxConns = maxConns
+
+		return nil
+	}
+}
+
+// WithStoresLogger configures the logger of the stores.
+func WithStoresLogger(logger Logger) StoresOption {
+	return func(c *storesConfig) error {
+		if logger == nil {
+			return ErrNoLogger
+		}
+
+		c.logger = logger
+
+		return nil
+	}
+}
+
+// WithStoresClock configures the clock of the stores.
+func WithStoresClock(clock Clock) StoresOption {
+	return func(c *storesConfig) error {
+		if clock == nil {
+			return ErrNoClock
+		}
+
+		c.clock = clock
+
+		return nil
+	}
+}
+
+// WithStoresSchema configures the schema of every table of the stores.
+func WithStoresSchema(schema string) StoresOption {
+	return func(c *storesConfig) error {
+		if schema == "" {
+			return ErrNoSchema
+		}
+
+		c.schema = schema
+
+		return nil
+	}
+}
+
+// WithStoresTablePrefix configures the prefix of every table and index name
+// of the stores, so multiple applications can share a database.
+func WithStoresTablePrefix(prefix string) StoresOption {
+	return func(c *storesConfig) error {
+		c.tablePrefix = prefix
+		return nil
+	}
+}
+
+// WithStoresTokenStoreOptions configures additional token store options. The
+// options are applied after the shared configuration.
+func WithStoresTokenStoreOptions(opts ...TokenStoreOption) StoresOption {
+	return func(c *storesConfig) error {
+		c.tokenOpts = append(c.tokenOpts, opts...)
+		return nil
+	}
+}
+
+// WithStoresClientStoreOptions configures additional client store options.
+// The options are applied after the shared configuration.
+func WithStoresClientStoreOptions(opts ...ClientStoreOption) StoresOption {
+	return func(c *storesConfig) error {
+		c.clientOpts = append(c.clientOpts, opts...)
+		return nil
+	}
+}
+
+// WithStoresConsentStoreOptions configures additional consent store options.
+// The options are applied after the shared configuration.
+func WithStoresConsentStoreOptions(opts ...ConsentStoreOption) StoresOption {
+	return func(c *storesConfig) error {
+		c.consentOpts = append(c.consentOpts, opts...)
+		return nil
+	}
+}
+
+// Stores groups the stores sharing a connection pool and configuration.
+type Stores struct {
+	TokenStore   *TokenStore
+	ClientStore  *ClientStore
+	ConsentStore *ConsentStore
+
+	pool     DB
+	ownsPool bool
+}
+
+// newStores creates the stores using the shared configuration.
+func (s *Stores) newStores(c *storesConfig) error {
+	var err error
+
+	s.TokenStore, err = NewTokenStore(append([]TokenStoreOption{
+		WithTokenStoreDB(s.pool),
+		WithTokenStoreTable(c.tableName(DefaultTokenStoreTable)),
+		WithTokenStoreTablePrefix(c.tablePrefix),
+		WithTokenStoreLogger(c.logger),
+		WithTokenStoreClock(c.clock),
+	}, c.tokenOpts...)...)
+
+	if err != nil {
+		return err
+	}
+
+	s.ClientStore, err = NewClientStore(append([]ClientStoreOption{
+		WithClientStoreDB(s.pool),
+		WithClientStoreTable(c.tableName(DefaultClientStoreTable)),
+		WithClientStoreSecretTable(c.tableName(DefaultClientSecretTable)),
+		WithClientStoreRedirectURITable(c.tableName(DefaultClientRedirectURITable)),
+		WithClientStoreScopeTables(c.tableName(DefaultScopeTable), c.tableName(DefaultClientScopeTable)),
+		WithClientStoreTablePrefix(c.tablePrefix),
+		WithClientStoreLogger(c.logger),
+		WithClientStoreClock(c.clock),
+	}, c.clientOpts...)...)
+
+	if err != nil {
+		return err
+	}
+
+	s.ConsentStore, err = NewConsentStore(append([]ConsentStoreOption{
+		WithConsentStoreDB(s.pool),
+		WithConsentStoreTable(c.tableName(DefaultConsentStoreTable)),
+		WithConsentStoreTablePrefix(c.tablePrefix),
+		WithConsentStoreLogger(c.logger),
+		WithConsentStoreClock(c.clock),
+	}, c.consentOpts...)...)
+
+	return err
+}
+
+// InitTables initializes the tables of every store. Read-only stores are
+// skipped, as their tables are expected to be initialized by the primary.
+func (s *Stores) InitTables(ctx context.Context) error {
+	if !s.ClientStore.readOnly {
+		if err := s.ClientStore.InitTable(ctx); err != nil {
+			return err
+		}
+	}
+
+	if !s.TokenStore.readOnly {
+		if err := s.TokenStore.InitTable(ctx); err != nil {
+			return err
+		}
+	}
+
+	if s.ConsentStore.readOnly {
+		return nil
+	}
+
+	return s.ConsentStore.InitTable(ctx)
+}
+
+// VerifySchema checks the schema of the token and client tables and returns
+// their differences and ErrSchemaMismatch if the schema does not match.
+func (s *Stores) VerifySchema(ctx context.Context) (*SchemaDiff, error) {
+	diff, err := s.TokenStore.VerifySchema(ctx)
+	if err != nil && !errors.Is(err, ErrSchemaMismatch) {
+		return nil, err
+	}
+
+	clientDiff, err := s.ClientStore.VerifySchema(ctx)
+	if err != nil && !errors.Is(err, ErrSchemaMismatch) {
+		return nil, err
+	}
+
+	diff.merge(clientDiff)
+	if !diff.Empty() {
+		return diff, ErrSchemaMismatch
+	}
+
+	return diff, nil
+}
+
+// InitTablesSQL returns the DDL statements executed by InitTables without
+// executing them.
+func (s *Stores) InitTablesSQL() string {
+	return strings.Join([]string{
+		s.ClientStore.InitTableSQL(),
+		s.TokenStore.InitTableSQL(),
+		s.ConsentStore.InitTableSQL(),
+	}, "\n")
+}
+
+// Close closes every store and the shared connection pool if it was created
+// from a DSN.
+func (s *Stores) Close(ctx context.Context) {
+	if s.TokenStore != nil {
+		s.TokenStore.Close(ctx)
+	}
+
+	if s.ClientStore != nil {
+		s.ClientStore.Close(ctx)
+	}
+
+	if s.ConsentStore != nil {
+		s.ConsentStore.Close(ctx)
+	}
+
+	if s.ownsPool {
+		if pool, ok := nativePool(s.pool); ok {
+			pool.Close()
+		}
+	}
+}
+
+// NewStores creates the token, client and consent stores sharing a
+// connection pool, logger, schema and table prefix, and initializes the
+// tables of the stores that are not read-only.
+func NewStores(ctx context.Context, opts ...StoresOption) (*Stores, error) {
+	c := &storesConfig{
+		logger: new(NoopLogger),
+		clock:  new(SystemClock),
+	}
+
+	for _, o := range opts {
+		if err := o(c); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &Stores{pool: c.pool}
+
+	if s.pool == nil && c.poolOpts.configured() {
+		pool, err := c.poolOpts.newPool(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		s.pool = pool
+		s.ownsPool = true
+	}
+
+	if s.pool == nil {
+		return nil, ErrNoConnPool
+	}
+
+	if err := s.newStores(c); err != nil {
+		s.Close(ctx)
+		return nil, err
+	}
+
+	if err := s.InitTables(ctx); err != nil {
+		s.Close(ctx)
+		return nil, err
+	}
+
+	return s, nil
+}
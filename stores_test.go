@@ -0,0 +1,28 @@
+package pgstore
+
+import (
+	"context"
+	"testing"
+)
+
+// recordingLogger records the logged messages and their key-value pairs.
+type recordingLogger struct {
+	messages []string
+	args     []any
+}
+
+func (l *recordingLogger) Log(_ context.Context, _ LogLevel, msg string, args ...any) {
+	l.messages = append(l.messages, msg)
+	l.args = append(l.args, args...)
+}
+
+func TestStoresCloseClosesConsentStore(t *testing.T) {
+	logger := new(recordingLogger)
+
+	stores := &Stores{ConsentStore: &ConsentStore{logger: logger}}
+	stores.Close(context.Background())
+
+	if len(logger.messages) != 1 || logger.messages[0] != "consent store closed" {
+		t.Fatalf("got messages %q, want the consent store closed", logger.messages)
+	}
+}
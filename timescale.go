@@ -0,0 +1,54 @@
+package pgstore
+
+import (
+	"fmt"
+	"time"
+)
+
+// WithTokenStoreHypertable configures InitTable to create the token table as
+// a TimescaleDB hypertable chunked on the expiry time, one chunk per chunk
+// interval, with a retention policy dropping the chunks whose every token
+// expired more than dropAfter ago. The periodic cleanup does not delete the
+// expired tokens row by row, unless an archive table is configured. The
+// hypertable cannot be combined with partitioning, sharding, Citus, unlogged
+// tables or generated columns.
+func WithTokenStoreHypertable(chunkInterval, dropAfter time.Duration) TokenStoreOption {
+	return func(s *TokenStore) error {
+		if chunkInterval <= 0 || dropAfter < 0 {
+			return ErrInvalidHypertable
+		}
+
+		s.hypertableChunkInterval = chunkInterval
+		s.hypertableDropAfter = dropAfter
+
+		return nil
+	}
+}
+
+// validateHypertable returns ErrInvalidHypertable if the hypertable is
+// configured with another table layout.
+func (s *TokenStore) validateHypertable() error {
+	if s.hypertableChunkInterval == 0 {
+		return nil
+	}
+
+	if s.partitionInterval > 0 || s.shards > 0 || s.citus || s.unlogged || s.generatedColumns {
+		return ErrInvalidHypertable
+	}
+
+	return nil
+}
+
+// hypertableSQL returns the statements creating the hypertable and its
+// retention policy, or an empty string if the hypertable is disabled.
+func (s *TokenStore) hypertableSQL() string {
+	if s.hypertableChunkInterval == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf(`
+		SELECT create_hypertable('%[1]s', '%[2]s', chunk_time_interval => INTERVAL '%[3]d microseconds', if_not_exists => TRUE);
+		SELECT add_retention_policy('%[1]s', drop_after => INTERVAL '%[4]d microseconds', if_not_exists => TRUE);`,
+		s.table, s.columns.ExpiresAt, s.hypertableChunkInterval.Microseconds(), s.hypertableDropAfter.Microseconds(),
+	)
+}
@@ -0,0 +1,60 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenStoreHypertableInvalid(t *testing.T) {
+	for _, tt := range []struct{ chunk, dropAfter time.Duration }{{chunk: 0}, {chunk: time.Hour, dropAfter: -time.Hour}} {
+		if err := WithTokenStoreHypertable(tt.chunk, tt.dropAfter)(&TokenStore{}); !errors.Is(err, ErrInvalidHypertable) {
+			t.Errorf("chunk %s, drop after %s: got error %v, want %v", tt.chunk, tt.dropAfter, err, ErrInvalidHypertable)
+		}
+	}
+
+	for name, opt := range map[string]TokenStoreOption{
+		"shards":   WithTokenStoreShards(4),
+		"unlogged": WithTokenStoreUnlogged(true),
+	} {
+		_, err := newTokenStore(WithTokenStoreDB(unusedDB{}), WithTokenStoreHypertable(24*time.Hour, time.Hour), opt)
+		if !errors.Is(err, ErrInvalidHypertable) {
+			t.Errorf("%s: got error %v, want %v", name, err, ErrInvalidHypertable)
+		}
+	}
+}
+
+func TestTokenStoreHypertableSQL(t *testing.T) {
+	store, err := newTokenStore(WithTokenStoreDB(unusedDB{}), WithTokenStoreHypertable(24*time.Hour, time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := store.primaryKeyColumns(); got != "id, expires_at" {
+		t.Fatalf("got primary key %q, want the expiry in the primary key", got)
+	}
+
+	ddl := store.InitTableSQL()
+	for _, want := range []string{
+		"create_hypertable('" + store.table + "', 'expires_at', chunk_time_interval => INTERVAL '86400000000 microseconds'",
+		"add_retention_policy('" + store.table + "', drop_after => INTERVAL '3600000000 microseconds'",
+	} {
+		if !strings.Contains(ddl, want) {
+			t.Errorf("got DDL %s, want %s", ddl, want)
+		}
+	}
+}
+
+func TestTokenStoreHypertableCleanupSkipsDeletion(t *testing.T) {
+	store, err := newTokenStore(WithTokenStoreDB(unusedDB{}), WithTokenStoreHypertable(24*time.Hour, time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The retention policy drops the expired chunks, so no statement is run.
+	if removed, err := store.cleanExpiredTokens(context.Background()); err != nil || removed != 0 {
+		t.Fatalf("got %d tokens removed and error %v, want the cleanup left to the retention policy", removed, err)
+	}
+}
@@ -2,15 +2,16 @@ package pgstore
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"net/netip"
 	"time"
 
 	"github.com/go-oauth2/oauth2/v4"
 	"github.com/go-oauth2/oauth2/v4/models"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"golang.org/x/sync/singleflight"
 )
 
 const (
@@ -34,6 +35,41 @@ func WithTokenStoreTable(table string) TokenStoreOption {
 	}
 }
 
+// WithTokenStoreTablePrefix configures the prefix added to the name of every
+// table of the store and, as a consequence, to the name of every index.
+func WithTokenStoreTablePrefix(prefix string) TokenStoreOption {
+	return func(s *TokenStore) error {
+		s.tablePrefix = prefix
+		return nil
+	}
+}
+
+// WithTokenStoreUnlogged configures InitTable to create an unlogged token
+// table. Writes to unlogged tables are faster as they skip the write-ahead
+// log, but the table is truncated after a crash and it is not replicated, so
+// the tokens must be acceptable to lose. Partitioned and sharded tables
+// cannot be unlogged.
+func WithTokenStoreUnlogged(unlogged bool) TokenStoreOption {
+	return func(s *TokenStore) error {
+		s.unlogged = unlogged
+		return nil
+	}
+}
+
+// WithTokenStoreDB configures the database the store runs its queries on,
+// for example a pgx v4 pool adapted by the pgxv4 module.
+func WithTokenStoreDB(db DB) TokenStoreOption {
+	return func(s *TokenStore) error {
+		if db == nil {
+			return ErrNoConnPool
+		}
+
+		s.pool = db
+
+		return nil
+	}
+}
+
 // WithTokenStoreConnPool configures the connection pool.
 func WithTokenStoreConnPool(pool *pgxpool.Pool) TokenStoreOption {
 	return func(s *TokenStore) error {
@@ -55,6 +91,67 @@ func WithTokenStoreCleanupInterval(interval time.Duration) TokenStoreOption {
 	}
 }
 
+// WithTokenStoreCleanupBatchSize configures the cleanup to remove the expired
+// tokens in batches of the given size, so a large purge does not saturate the
+// WAL and replication. The delay is slept between the batches.
+func WithTokenStoreCleanupBatchSize(size int, delay time.Duration) TokenStoreOption {
+	return func(s *TokenStore) error {
+		if size <= 0 || delay < 0 {
+			return ErrInvalidCleanupBatch
+		}
+
+		s.cleanupBatchSize = size
+		s.cleanupBatchDelay = delay
+
+		return nil
+	}
+}
+
+// WithTokenStoreCleanupTimeout configures the timeout of every cleanup run,
+// so a stuck cleanup does not block the following ones.
+func WithTokenStoreCleanupTimeout(timeout time.Duration) TokenStoreOption {
+	return func(s *TokenStore) error {
+		if timeout <= 0 {
+			return ErrInvalidCleanupTimeout
+		}
+
+		s.cleanupTimeout = timeout
+
+		return nil
+	}
+}
+
+// WithTokenStoreDSN configures the store to create a connection pool from the
+// connection string. The store owns the pool and closes it on Close. The DSN
+// is not used if a connection pool is configured.
+func WithTokenStoreDSN(dsn string) TokenStoreOption {
+	return func(s *TokenStore) error {
+		if dsn == "" {
+			return ErrNoDSN
+		}
+
+		s.poolOpts.dsn = dsn
+
+		return nil
+	}
+}
+
+// WithTokenStorePoolSize configures the minimum and maximum number of
+// connections of the connection pool created from the DSN or the pool
+// configuration.
+func WithTokenStorePoolSize(minConns, maxConns int32) TokenStoreOption {
+	return func(s *TokenStore) error {
+		if err := validatePoolSize(minConns, maxConns); err != nil {
+			return err
+		}
+
+		s.poolOpts.minConns = minConns
+		s.poolOpts.maxConns = maxConns
+
+		return nil
+	}
+}
+
 // WithTokenStoreLogger configures the logger.
 func WithTokenStoreLogger(logger Logger) TokenStoreOption {
 	return func(s *TokenStore) error {
@@ -68,92 +165,605 @@ func WithTokenStoreLogger(logger Logger) TokenStoreOption {
 	}
 }
 
+// WithTokenStoreClock configures the clock used for creation times, expiry
+// computation and cleanup.
+func WithTokenStoreClock(clock Clock) TokenStoreOption {
+	return func(s *TokenStore) error {
+		if clock == nil {
+			return ErrNoClock
+		}
+
+		s.clock = clock
+
+		return nil
+	}
+}
+
+// WithTokenStoreCodec configures the codec used to encode the token data
+// column.
+func WithTokenStoreCodec(codec Codec) TokenStoreOption {
+	return func(s *TokenStore) error {
+		if codec == nil {
+			return ErrNoCodec
+		}
+
+		s.codec = codec
+
+		return nil
+	}
+}
+
+// WithTokenStoreTokenFactory configures the function creating the token
+// information the stored data is decoded into. It must return a pointer to a
+// new value, so custom oauth2.TokenInfo implementations are round-tripped
+// with all of their fields.
+func WithTokenStoreTokenFactory(factory func() oauth2.TokenInfo) TokenStoreOption {
+	return func(s *TokenStore) error {
+		if factory == nil {
+			return ErrNoTokenFactory
+		}
+
+		s.newTokenInfo = factory
+
+		return nil
+	}
+}
+
+// WithTokenStoreHooks configures the hooks called around store operations.
+func WithTokenStoreHooks(hooks Hooks) TokenStoreOption {
+	return func(s *TokenStore) error {
+		s.hooks = hooks
+		return nil
+	}
+}
+
+// WithTokenStoreExpiryFiltering configures GetByCode, GetByAccess and
+// GetByRefresh to never return expired tokens, instead of returning them until
+// the cleanup removes them. The expiry is compared with the time of the
+// database.
+func WithTokenStoreExpiryFiltering() TokenStoreOption {
+	return func(s *TokenStore) error {
+		s.filterExpired = true
+		return nil
+	}
+}
+
+// andUnexpired returns the condition excluding the expired tokens, or an empty
+// string if expiry filtering is disabled.
+func (s *TokenStore) andUnexpired() string {
+	if !s.filterExpired {
+		return ""
+	}
+
+	return " AND " + s.columns.ExpiresAt + " > now()"
+}
+
+// WithTokenStoreRateLimit limits the number of tokens a client can be issued
+// within the given window. Creating a token above the limit returns
+// ErrRateLimited. The limit is checked in the transaction inserting the
+// token, serialized per client.
+func WithTokenStoreRateLimit(limit int, window time.Duration) TokenStoreOption {
+	return func(s *TokenStore) error {
+		if limit <= 0 || window <= 0 {
+			return ErrInvalidRateLimit
+		}
+
+		s.rateLimit = limit
+		s.rateLimitWindow = window
+
+		return nil
+	}
+}
+
 // TokenStoreItem data item
 type TokenStoreItem struct {
-	ID        int64     `db:"id"`
-	Code      string    `db:"code"`
-	Access    string    `db:"access_token"`
-	Refresh   string    `db:"refresh_token"`
-	Data      []byte    `db:"data"`
-	CreatedAt time.Time `db:"created_at"`
-	ExpiresAt time.Time `db:"expires_at"`
+	ID        int64      `db:"id"`
+	Key       string     `db:"-"` // ID of tables with UUID keys
+	Code      string     `db:"code"`
+	Access    string     `db:"access_token"`
+	Refresh   string     `db:"refresh_token"`
+	ClientID  string     `db:"client_id"`
+	UserID    string     `db:"user_id"`
+	Scope     string     `db:"scope"`
+	GrantType string     `db:"grant_type"`
+	FamilyID  string     `db:"family_id"`
+	Audience  []string   `db:"audience"`
+	ClientIP  netip.Addr `db:"client_ip"`
+	UserAgent string     `db:"user_agent"`
+	ParentID  *int64     `db:"parent_id"`
+	ParentKey *string    `db:"-"` // parent ID of tables with UUID keys
+	Data      []byte     `db:"data"`
+	CreatedAt time.Time  `db:"created_at"`
+	ExpiresAt time.Time  `db:"expires_at"`
 }
 
 // TokenStore is a data struct that stores oauth2 token information.
 type TokenStore struct {
-	pool            *pgxpool.Pool
-	table           string
-	logger          Logger
-	cleanupInterval time.Duration
-	cleanupTicker   *time.Ticker
+	pool              DB
+	readPool          DB
+	poolOpts          poolOptions
+	ownsPool          bool
+	table             string
+	tablePrefix       string
+	logger            Logger
+	clock             Clock
+	hooks             Hooks
+	eventChannel      string
+	rateLimit         int
+	rateLimitWindow   time.Duration
+	cleanupInterval   time.Duration
+	cleanupSchedule   CleanupSchedule
+	cleanupTimeout    time.Duration
+	cleanupBatchSize  int
+	cleanupBatchDelay time.Duration
+	partitionInterval time.Duration
+	partitionsAhead   int
+	shards            int
+	citus             bool
+	cleanupCancel     context.CancelFunc
+	cleanupDone       chan struct{}
+
+	archiveTable     string
+	archiveRetention time.Duration
+
+	cleanupRetention    time.Duration
+	codeCleanupInterval time.Duration
+	codeRetention       time.Duration
+	anonymizeAfter      time.Duration
+	anonymizationKey    []byte
+
+	lookups       *singleflight.Group
+	clientQuota   quota
+	sessionLimit  int
+	uniqueTokens  bool
+	filterExpired bool
+
+	hypertableChunkInterval time.Duration
+	hypertableDropAfter     time.Duration
+
+	codec        Codec
+	newTokenInfo func() oauth2.TokenInfo
+	compression  Compression
+
+	breakerThreshold int
+	breakerCoolDown  time.Duration
+	breaker          *circuitBreaker
+
+	encryptionSetting string
+	observer          queryObserver
+	softRevocation    bool
+	realm             string
+	issuer            string
+	columns           ColumnMap
+	generatedColumns  bool
+	unlogged          bool
+	readOnly          bool
+
+	readYourWritesWindow time.Duration
+	replica              replicaState
+
+	usageFlushInterval time.Duration
+	usage              *usageTracker
+
+	webhook     *webhookDispatcher
+	changeFeeds changeFeeds
+	idGenerator IDGenerator
+	uuidKeys    UUIDVersion
+	uuidGen     UUIDGenerator
+
+	identityColumns   bool
+	storageParameters map[string]string
+	codeHashKey       []byte
 }
 
-// scanToTokenInfo scans a row into an oauth2.TokenInfo.
-func (s *TokenStore) scanToTokenInfo(ctx context.Context, row pgx.Row) (oauth2.TokenInfo, error) {
+// scanToTokenStoreItem scans a row into a TokenStoreItem and decodes its
+// token information.
+func (s *TokenStore) scanToTokenStoreItem(ctx context.Context, row pgx.Row) (TokenStoreItem, oauth2.TokenInfo, error) {
 	var item TokenStoreItem
-	if err := row.Scan(&item.ID, &item.Code, &item.Access, &item.Refresh, &item.Data, &item.CreatedAt, &item.ExpiresAt); err != nil {
+	var key rowKey
+	if err := row.Scan(&key, &item.Code, &item.Access, &item.Refresh, &item.ClientID, &item.UserID, &item.Data, &item.CreatedAt, &item.ExpiresAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			s.logger.Log(ctx, LogLevelDebug, "token not found")
+			return item, nil, ErrTokenNotFound
+		}
+
 		s.logger.Log(ctx, LogLevelError, err.Error())
-		return nil, err
+		return item, nil, wrapDatabaseError(err)
 	}
 
-	var info models.Token
-	if err := json.Unmarshal(item.Data, &info); err != nil {
+	item.ID, item.Key = key.ID, key.Key
+
+	info, err := s.decodeTokenInfo(item.Data)
+	if err != nil {
 		s.logger.Log(ctx, LogLevelError, err.Error())
-		return nil, err
+		return item, nil, err
+	}
+
+	return item, info, nil
+}
+
+// tokenData decodes the token information of the data column directly from
+// the memory of the driver, avoiding a copy of the data. The codec must not
+// retain the data after Unmarshal returns.
+type tokenData struct {
+	store *TokenStore
+	info  oauth2.TokenInfo
+}
+
+// ScanBytes decodes the token information of the data column.
+func (d *tokenData) ScanBytes(data []byte) error {
+	info, err := d.store.decodeTokenInfo(data)
+	if err != nil {
+		return err
 	}
 
-	s.logger.Log(ctx, LogLevelDebug, "token found", "id", item.ID)
+	d.info = info
 
-	return &info, nil
+	return nil
 }
 
-// cleanExpiredTokens removes expired tokens from the store.
-func (s *TokenStore) cleanExpiredTokens(ctx context.Context) error {
-	_, err := s.pool.Query(ctx, fmt.Sprintf("DELETE FROM %s WHERE expires_at <= $1", s.table), time.Now())
-	s.logger.Log(ctx, LogLevelDebug, "cleaning expired tokens", "err", err)
-	return err
+// getColumns returns the list of columns selected when getting a token.
+func (s *TokenStore) getColumns() string {
+	return "id, " + s.decryptData()
 }
 
-// InitCleanup initializes the cleanup process.
+// scanTokenData scans a row of the columns returned by getColumns into the ID
+// and token information.
+func (s *TokenStore) scanTokenData(ctx context.Context, row pgx.Row) (rowKey, oauth2.TokenInfo, error) {
+	var id rowKey
+	data := tokenData{store: s}
+
+	if err := row.Scan(&id, &data); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			s.logger.Log(ctx, LogLevelDebug, "token not found")
+			return id, nil, ErrTokenNotFound
+		}
+
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return id, nil, wrapDatabaseError(err)
+	}
+
+	s.logger.Log(ctx, LogLevelDebug, "token found", "id", id.String())
+
+	return id, data.info, nil
+}
+
+// scanToTokenInfo scans a row of the columns returned by getColumns into an
+// oauth2.TokenInfo.
+func (s *TokenStore) scanToTokenInfo(ctx context.Context, row pgx.Row) (oauth2.TokenInfo, error) {
+	_, info, err := s.scanTokenData(ctx, row)
+	return info, err
+}
+
+// expiredCondition returns the condition matching the expired rows of the
+// kind in the table to remove in one statement, limited to the cleanup batch
+// size if configured.
+func (s *TokenStore) expiredCondition(table string, kind cleanupKind) string {
+	cond := fmt.Sprintf("%s <= %s%s", s.columns.ExpiresAt, s.expiryCutoff(kind), s.kindCondition(kind))
+	if s.anonymizeAfter > 0 {
+		cond += " AND anonymized_at IS NULL"
+	}
+	if s.cleanupBatchSize <= 0 {
+		return cond
+	}
+
+	return fmt.Sprintf(
+		"id IN (SELECT id FROM %s WHERE %s ORDER BY %s LIMIT %d)",
+		table, cond, s.columns.ExpiresAt, s.cleanupBatchSize,
+	)
+}
+
+// deleteExpiredTokens deletes the expired rows of the kind from the store.
+func (s *TokenStore) deleteExpiredTokens(ctx context.Context, now time.Time, kind cleanupKind) (int64, error) {
+	if s.shards > 0 {
+		return s.deleteExpiredShardTokens(ctx, now, kind)
+	}
+
+	tag, err := s.pool.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE %s", s.table, s.expiredCondition(s.table, kind)), now)
+	return tag.RowsAffected(), err
+}
+
+// removeExpiredTokens removes the expired rows of the kind batch by batch,
+// archiving or anonymizing them if configured.
+func (s *TokenStore) removeExpiredTokens(ctx context.Context, now time.Time, kind cleanupKind) (int64, error) {
+	var total int64
+
+	for {
+		var removed int64
+		var err error
+
+		switch {
+		case s.anonymizeAfter > 0:
+			removed, err = s.anonymizeExpiredTokens(ctx, now, kind)
+		case s.archiveTable != "":
+			removed, err = s.archiveExpiredTokens(ctx, now, kind)
+		default:
+			removed, err = s.deleteExpiredTokens(ctx, now, kind)
+		}
+
+		total += removed
+
+		if err != nil || s.cleanupBatchSize <= 0 || removed < int64(s.cleanupBatchSize) {
+			return total, err
+		}
+
+		if s.cleanupBatchDelay > 0 {
+			timer := time.NewTimer(s.cleanupBatchDelay)
+
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return total, ctx.Err()
+			case <-timer.C:
+			}
+		}
+	}
+}
+
+// cleanExpiredTokens removes expired tokens from the store, archiving them if
+// an archive table is configured, and returns the number of removed tokens.
+func (s *TokenStore) cleanExpiredTokens(ctx context.Context) (int64, error) {
+	start := s.clock.Now()
+
+	var partitionErr error
+	if s.partitionInterval > 0 {
+		if partitionErr = s.rotatePartitions(ctx, start); partitionErr != nil {
+			s.logger.Log(ctx, LogLevelError, partitionErr.Error())
+		}
+	}
+
+	var removed int64
+	var err error
+
+	// The retention policy of the hypertable drops the expired chunks.
+	if s.hypertableChunkInterval == 0 || s.archiveTable != "" {
+		removed, err = s.removeExpiredTokens(ctx, start, s.tokenCleanupKind())
+	}
+
+	if err == nil && s.archiveTable != "" {
+		err = s.purgeArchivedTokens(ctx, start)
+	}
+
+	// A failed partition rotation leaves new tokens in the default partition,
+	// so it fails the cleanup once the expired tokens are removed.
+	if err == nil {
+		err = partitionErr
+	}
+
+	s.reportCleanup(ctx, "cleaning expired tokens", start, removed, err)
+
+	return removed, err
+}
+
+// reportCleanup logs the result of a cleanup started at the given time, runs
+// the cleanup hooks and publishes the purge event.
+func (s *TokenStore) reportCleanup(ctx context.Context, msg string, start time.Time, removed int64, err error) {
+	duration := s.clock.Now().Sub(start)
+
+	s.logger.Log(ctx, LogLevelInfo, msg, "removed", removed, "duration", duration, "err", err)
+	s.hooks.afterCleanup(ctx, CleanupResult{Removed: removed, Duration: duration, Err: err})
+
+	if err == nil && removed > 0 {
+		s.publishEvent(ctx, Event{Type: EventTokenExpiredPurged, Count: removed})
+	}
+}
+
+// PurgeExpired removes the expired tokens and authorization codes on demand,
+// the same way the periodic cleanups do, and returns the number of removed
+// tokens.
+func (s *TokenStore) PurgeExpired(ctx context.Context) (int64, error) {
+	if s.readOnly {
+		return 0, ErrReadOnly
+	}
+
+	s.logger.Log(ctx, LogLevelDebug, "purging expired tokens")
+
+	removed, err := s.cleanExpiredTokens(ctx)
+	if err != nil || s.codeCleanupInterval == 0 {
+		return removed, wrapDatabaseError(err)
+	}
+
+	codes, err := s.cleanExpiredCodes(ctx)
+
+	return removed + codes, wrapDatabaseError(err)
+}
+
+// InitCleanup initializes the cleanup process. The cleanup runs until the
+// context is canceled or the store is closed.
 func (s *TokenStore) InitCleanup(ctx context.Context) {
-	if s.cleanupInterval > 0 {
-		s.cleanupTicker = time.NewTicker(s.cleanupInterval)
+	if s.readOnly {
+		return
+	}
+
+	tokenCleanup := s.cleanupInterval > 0 || s.cleanupSchedule != nil
+
+	if tokenCleanup || s.codeCleanupInterval > 0 {
+		ctx, s.cleanupCancel = context.WithCancel(ctx)
+		s.cleanupDone = make(chan struct{})
+
 		go func() {
-			for range s.cleanupTicker.C {
-				if err := s.cleanExpiredTokens(ctx); err != nil {
-					s.logger.Log(ctx, LogLevelError, err.Error())
+			defer close(s.cleanupDone)
+
+			var tokens, codes <-chan time.Time
+
+			var timer *time.Timer
+			if tokenCleanup {
+				timer = time.NewTimer(s.nextCleanupDelay())
+				defer timer.Stop()
+				tokens = timer.C
+			}
+
+			if s.codeCleanupInterval > 0 {
+				ticker := time.NewTicker(s.codeCleanupInterval)
+				defer ticker.Stop()
+				codes = ticker.C
+			}
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-tokens:
+					s.runCleanup(ctx, s.cleanExpiredTokens)
+					timer.Reset(s.nextCleanupDelay())
+				case <-codes:
+					s.runCleanup(ctx, s.cleanExpiredCodes)
 				}
 			}
 		}()
 	}
 }
 
-// InitTable initializes the token store table if it does not exist and creates
-// the indexes.
+// runCleanup runs a single cleanup, limited to the cleanup timeout if set.
+func (s *TokenStore) runCleanup(ctx context.Context, clean func(context.Context) (int64, error)) {
+	if s.cleanupTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.cleanupTimeout)
+		defer cancel()
+	}
+
+	if _, err := clean(ctx); err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+	}
+}
+
+// StopCleanup stops the cleanup process without closing the store. An
+// in-flight cleanup is canceled and waited for.
+func (s *TokenStore) StopCleanup() {
+	s.stopCleanup(context.Background())
+}
+
+// nextCleanupDelay returns the time to wait until the next cleanup.
+func (s *TokenStore) nextCleanupDelay() time.Duration {
+	if s.cleanupSchedule == nil {
+		return s.cleanupInterval
+	}
+
+	now := s.clock.Now()
+	next := s.cleanupSchedule.Next(now)
+
+	if next.IsZero() {
+		// The schedule has no next run; check again a day later.
+		return 24 * time.Hour
+	}
+
+	return next.Sub(now)
+}
+
+// stopCleanup cancels the cleanup process and waits for the in-flight cleanup
+// to finish until the context is done.
+func (s *TokenStore) stopCleanup(ctx context.Context) {
+	if s.cleanupCancel == nil {
+		return
+	}
+
+	s.logger.Log(ctx, LogLevelDebug, "stopping cleanup")
+	s.cleanupCancel()
+
+	select {
+	case <-s.cleanupDone:
+	case <-ctx.Done():
+		s.logger.Log(ctx, LogLevelWarn, "waiting for cleanup to finish aborted", "error", ctx.Err())
+	}
+}
+
+// tableKind returns the kind of the token table used in its DDL.
+func (s *TokenStore) tableKind() string {
+	if s.unlogged {
+		return "UNLOGGED "
+	}
+
+	return ""
+}
+
+// InitTableSQL returns the DDL statements executed by InitTable without
+// executing them, so schema changes can be reviewed and applied separately.
+func (s *TokenStore) InitTableSQL() string {
+	ddl := encryptionSQL(s.encryptionSetting) + s.generatedFunctionSQL() + fmt.Sprintf(`
+		CREATE %[16]sTABLE IF NOT EXISTS %[1]s (
+			id            %[20]s                NOT NULL,%[18]s
+			%[6]s          TEXT                  NOT NULL%[12]s,
+			%[7]s  TEXT                  NOT NULL%[13]s,
+			%[8]s TEXT                  NOT NULL%[14]s,
+			client_id     TEXT                  NOT NULL DEFAULT '',
+			user_id       TEXT                  NOT NULL DEFAULT '',
+			scope         TEXT                  NOT NULL DEFAULT '',
+			grant_type    TEXT                  NOT NULL DEFAULT '',
+			family_id     TEXT                  NOT NULL DEFAULT '',
+			realm         TEXT                  NOT NULL DEFAULT '',
+			issuer        TEXT                  NOT NULL DEFAULT '',
+			audience      TEXT[]                NOT NULL DEFAULT '{}',
+			client_ip     INET,
+			user_agent    TEXT                  NOT NULL DEFAULT '',
+			parent_id     %[21]s,
+			rotated_at    TIMESTAMPTZ,
+			revoked_at    TIMESTAMPTZ,
+			last_used_at  TIMESTAMPTZ,
+			anonymized_at TIMESTAMPTZ,
+			key_id        TEXT                  NOT NULL DEFAULT '',
+			%[9]s          %[2]s                 NOT NULL,
+			%[10]s    TIMESTAMPTZ           NOT NULL,
+			%[11]s    TIMESTAMPTZ           NOT NULL%[15]s,
+			PRIMARY KEY (%[4]s)
+		)%[5]s;
+%[17]s%[19]s
+
+		CREATE INDEX IF NOT EXISTS idx_%[3]s_code_idx ON %[1]s (%[6]s);
+		CREATE INDEX IF NOT EXISTS idx_%[3]s_access_idx ON %[1]s (%[7]s);
+		CREATE INDEX IF NOT EXISTS idx_%[3]s_refresh_idx ON %[1]s (%[8]s);
+		CREATE INDEX IF NOT EXISTS idx_%[3]s_expires_idx ON %[1]s (%[11]s);
+		CREATE INDEX IF NOT EXISTS idx_%[3]s_client_created_idx ON %[1]s (client_id, %[10]s);
+		CREATE INDEX IF NOT EXISTS idx_%[3]s_family_idx ON %[1]s (family_id);
+		CREATE INDEX IF NOT EXISTS idx_%[3]s_user_idx ON %[1]s (user_id);
+		CREATE INDEX IF NOT EXISTS idx_%[3]s_realm_idx ON %[1]s (realm);
+		CREATE INDEX IF NOT EXISTS idx_%[3]s_audience_idx ON %[1]s USING GIN (audience);
+		CREATE INDEX IF NOT EXISTS idx_%[3]s_scope_idx ON %[1]s USING GIN (string_to_array(scope, ' '));
+		CREATE INDEX IF NOT EXISTS idx_%[3]s_expires_id_idx ON %[1]s (%[11]s, id);
+		CREATE INDEX IF NOT EXISTS idx_%[3]s_active_expires_idx ON %[1]s (%[11]s) WHERE rotated_at IS NULL;
+		CREATE INDEX IF NOT EXISTS idx_%[3]s_active_client_idx ON %[1]s (client_id, %[11]s) WHERE rotated_at IS NULL;
+		CREATE INDEX IF NOT EXISTS idx_%[3]s_revoked_access_idx ON %[1]s (%[7]s) WHERE revoked_at IS NOT NULL;`,
+		s.table, s.dataColumnType(), unqualifiedName(s.table), s.primaryKeyColumns(), s.partitionClause(),
+		s.columns.Code, s.columns.Access, s.columns.Refresh, s.columns.Data, s.columns.CreatedAt, s.columns.ExpiresAt,
+		s.generatedAs("Code"), s.generatedAs("Access"), s.generatedAs("Refresh"), s.generatedExpiresAt(),
+		s.tableKind(), s.upgradeSQL(), s.shardKeyDefinition(), s.citusDistributedSQL(s.table), s.idColumnType(), s.keyType(),
+	)
+
+	if s.partitionInterval > 0 {
+		ddl += "\n" + s.defaultPartitionSQL()
+	}
+
+	if s.shards > 0 {
+		ddl += s.shardsSQL()
+	}
+
+	ddl += s.storageParametersSQL()
+
+	ddl += s.hypertableSQL() + s.uniqueIndexesSQL()
+
+	if s.archiveTable != "" {
+		ddl += "\n" + s.archiveTableSQL() + s.citusDistributedSQL(s.archiveTable)
+	}
+
+	return ddl
+}
+
+// InitTable initializes the token store table if it does not exist, adds the
+// columns missing from tables created by earlier versions and creates the
+// indexes.
 func (s *TokenStore) InitTable(ctx context.Context) error {
-	s.logger.Log(ctx, LogLevelDebug, "initializing token store table", "table", s.table)
+	if s.readOnly {
+		return ErrReadOnly
+	}
 
-	_, err := s.pool.Exec(ctx, fmt.Sprintf(`
-		CREATE TABLE IF NOT EXISTS %[1]s (
-			id            BIGSERIAL PRIMARY KEY NOT NULL,
-			code          TEXT                  NOT NULL,
-			access_token  TEXT                  NOT NULL,
-			refresh_token TEXT                  NOT NULL,
-			data          JSONB                 NOT NULL,
-			created_at    TIMESTAMPTZ           NOT NULL,
-			expires_at    TIMESTAMPTZ           NOT NULL
-		);
-
-		CREATE INDEX IF NOT EXISTS idx_%[1]s_code_idx ON %[1]s (code);
-		CREATE INDEX IF NOT EXISTS idx_%[1]s_access_idx ON %[1]s (access_token);
-		CREATE INDEX IF NOT EXISTS idx_%[1]s_refresh_idx ON %[1]s (refresh_token);
-		CREATE INDEX IF NOT EXISTS idx_%[1]s_expires_idx ON %[1]s (expires_at);`,
-		s.table,
-	))
+	s.logger.Log(ctx, LogLevelDebug, "initializing token store table", "table", s.table)
 
-	if err != nil {
+	if _, err := s.pool.Exec(ctx, s.InitTableSQL()); err != nil {
 		s.logger.Log(ctx, LogLevelError, err.Error())
-		return err
+		return wrapDatabaseError(err)
+	}
+
+	if s.partitionInterval > 0 {
+		return s.createPartitions(ctx, s.clock.Now())
 	}
 
 	return nil
@@ -161,21 +771,78 @@ func (s *TokenStore) InitTable(ctx context.Context) error {
 
 // Create creates a new token in the store.
 func (s *TokenStore) Create(ctx context.Context, info oauth2.TokenInfo) error {
-	s.logger.Log(ctx, LogLevelDebug, "creating token", "info", info)
+	return s.createWithExpiry(ctx, info, time.Time{}, TokenMetadata{})
+}
 
-	data, err := json.Marshal(info)
-	if err != nil {
+// CreateWithExpiry creates a new token in the store that expires at the given
+// time, independently of the durations of the token. The token is removed by
+// the cleanup after this time, so it can be used for example to keep an
+// impersonation token shorter than the usual tokens. The expiry cannot be
+// overridden with generated columns.
+func (s *TokenStore) CreateWithExpiry(ctx context.Context, info oauth2.TokenInfo, expiresAt time.Time) error {
+	if expiresAt.IsZero() || s.generatedColumns {
+		return ErrInvalidExpiry
+	}
+
+	return s.createWithExpiry(ctx, info, expiresAt, TokenMetadata{})
+}
+
+// createWithExpiry creates the token with the request metadata, overriding
+// its expiry if it is not zero.
+func (s *TokenStore) createWithExpiry(ctx context.Context, info oauth2.TokenInfo, expiresAt time.Time, meta TokenMetadata) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	s.logger.Log(ctx, LogLevelDebug, "creating token", "info", s.loggedInfo(info))
+
+	if err := s.hooks.beforeCreate(ctx, info); err != nil {
 		s.logger.Log(ctx, LogLevelError, err.Error())
 		return err
 	}
 
+	if err := s.breaker.allow(); err != nil {
+		return err
+	}
+
+	err := s.create(ctx, info, expiresAt, meta)
+	s.breaker.record(err)
+	s.hooks.afterCreate(ctx, info, err)
+
+	if err == nil {
+		s.publishEvent(ctx, newTokenEvent(EventTokenCreated, info))
+	}
+
+	return err
+}
+
+// newTokenStoreItem creates the data item stored for the token.
+func (s *TokenStore) newTokenStoreItem(info oauth2.TokenInfo) (TokenStoreItem, error) {
+	data, err := s.encodeStoredTokenInfo(info)
+	if err != nil {
+		return TokenStoreItem{}, err
+	}
+
+	familyID, err := newFamilyID()
+	if err != nil {
+		return TokenStoreItem{}, err
+	}
+
 	item := TokenStoreItem{
+		ID:        s.newID(),
+		Key:       s.newKey(),
+		ClientID:  info.GetClientID(),
+		UserID:    info.GetUserID(),
+		Scope:     info.GetScope(),
+		GrantType: string(tokenGrantType(info)),
+		FamilyID:  familyID,
+		Audience:  tokenAudience(info),
 		Data:      data,
-		CreatedAt: time.Now(),
+		CreatedAt: s.clock.Now(),
 	}
 
 	if code := info.GetCode(); code != "" {
-		item.Code = code
+		item.Code = s.hashCode(code)
 		item.ExpiresAt = info.GetCodeCreateAt().Add(info.GetCodeExpiresIn())
 	} else {
 		if access := info.GetAccess(); access != "" {
@@ -189,64 +856,201 @@ func (s *TokenStore) Create(ctx context.Context, info oauth2.TokenInfo) error {
 		}
 	}
 
-	_, err = s.pool.Exec(ctx, fmt.Sprintf(`
-		INSERT INTO %s (code, access_token, refresh_token, data, created_at, expires_at)
-		VALUES ($1, $2, $3, $4, $5, $6)`,
-		s.table,
-	), item.Code, item.Access, item.Refresh, item.Data, item.CreatedAt, item.ExpiresAt)
+	return item, nil
+}
+
+// insert inserts the data item into the token table.
+func (s *TokenStore) insert(ctx context.Context, q querier, item TokenStoreItem) error {
+	s.markWrite()
+
+	columns, values := s.insertColumns(item)
+	_, err := q.Exec(ctx, s.observer.query("Create", s.insertSQL(columns)), values...)
 
+	return err
+}
+
+// create stores the token with the request metadata, overriding its expiry if
+// it is not zero.
+func (s *TokenStore) create(ctx context.Context, info oauth2.TokenInfo, expiresAt time.Time, meta TokenMetadata) error {
+	item, err := s.newTokenStoreItem(info)
 	if err != nil {
-		s.logger.Log(ctx, LogLevelError, err.Error(), "info", info, "item", item)
+		s.logger.Log(ctx, LogLevelError, err.Error())
 		return err
 	}
 
+	item.ClientIP = meta.ClientIP
+	item.UserAgent = meta.UserAgent
+
+	if meta.GrantType != "" {
+		item.GrantType = string(meta.GrantType)
+	}
+
+	if !expiresAt.IsZero() {
+		item.ExpiresAt = expiresAt
+	}
+
+	if s.hasQuota(item) {
+		err = s.insertWithQuota(ctx, item)
+	} else {
+		err = s.insert(ctx, s.pool, item)
+	}
+
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error(), "info", s.loggedInfo(info), "item", item)
+		return wrapDatabaseError(s.duplicateTokenError(err))
+	}
+
 	s.logger.Log(ctx, LogLevelDebug, "token created")
 
 	return nil
 }
 
-// GetByCode returns the token by its authorization code.
+// GetByCode returns the token by its authorization code. The code is read
+// from the primary connection pool, so a code already exchanged on another
+// instance is not found on a lagging replica.
 func (s *TokenStore) GetByCode(ctx context.Context, code string) (oauth2.TokenInfo, error) {
-	s.logger.Log(ctx, LogLevelDebug, "getting token by authorization code", "code", code)
-	row := s.pool.QueryRow(ctx, fmt.Sprintf("SELECT * FROM %s WHERE code = $1", s.table), code)
-	return s.scanToTokenInfo(ctx, row)
+	s.logger.Log(ctx, LogLevelDebug, "getting token by authorization code", "code", s.loggedCode(code))
+
+	if err := s.breaker.allow(); err != nil {
+		return nil, err
+	}
+
+	row := s.pool.QueryRow(ctx, s.observer.query("GetByCode", fmt.Sprintf("SELECT %s FROM %s WHERE %s = $1 AND revoked_at IS NULL%s%s", s.getColumns(), s.table, s.columns.Code, s.andUnexpired(), s.andTenant())), s.hashCode(code))
+	info, err := s.scanToTokenInfo(ctx, row)
+	s.breaker.record(err)
+
+	if err == nil && s.codeHashKey != nil {
+		info.SetCode(code)
+	}
+
+	return info, err
 }
 
 // GetByAccess returns the token by its access token.
 func (s *TokenStore) GetByAccess(ctx context.Context, access string) (oauth2.TokenInfo, error) {
 	s.logger.Log(ctx, LogLevelDebug, "getting token by access token", "access", access)
-	row := s.pool.QueryRow(ctx, fmt.Sprintf("SELECT * FROM %s WHERE access_token = $1", s.table), access)
-	return s.scanToTokenInfo(ctx, row)
+
+	if err := s.breaker.allow(); err != nil {
+		return nil, err
+	}
+
+	query := s.observer.query("GetByAccess", fmt.Sprintf("SELECT %s FROM %s WHERE %s = $1%s AND revoked_at IS NULL%s%s", s.getColumns(), s.table, s.columns.Access, s.andShardKey(), s.andUnexpired(), s.andTenant()))
+
+	var info oauth2.TokenInfo
+	var err error
+
+	if s.lookups != nil {
+		info, err = s.getCoalesced(ctx, query, access)
+	} else {
+		info, err = s.scanToUsedTokenInfo(ctx, s.reader().QueryRow(ctx, query, access))
+	}
+	s.breaker.record(err)
+
+	return info, err
 }
 
-// GetByRefresh returns the token by its refresh token.
+// GetByRefresh returns the token by its refresh token. The token is read from
+// the primary connection pool, so a refresh token already rotated or revoked
+// on another instance is not found on a lagging replica.
 func (s *TokenStore) GetByRefresh(ctx context.Context, refresh string) (oauth2.TokenInfo, error) {
 	s.logger.Log(ctx, LogLevelDebug, "getting token by refresh token", "refresh", refresh)
-	row := s.pool.QueryRow(ctx, fmt.Sprintf("SELECT * FROM %s WHERE refresh_token = $1", s.table), refresh)
-	return s.scanToTokenInfo(ctx, row)
+
+	if err := s.breaker.allow(); err != nil {
+		return nil, err
+	}
+
+	row := s.pool.QueryRow(ctx, s.observer.query("GetByRefresh", fmt.Sprintf("SELECT %s FROM %s WHERE %s = $1 AND rotated_at IS NULL AND revoked_at IS NULL%s%s", s.getColumns(), s.table, s.columns.Refresh, s.andUnexpired(), s.andTenant())), refresh)
+	info, err := s.scanToUsedTokenInfo(ctx, row)
+	s.breaker.record(err)
+
+	return info, err
 }
 
-// RemoveByCode deletes the token by its authorization code.
-func (s *TokenStore) RemoveByCode(ctx context.Context, code string) error {
-	s.logger.Log(ctx, LogLevelDebug, "removing token by authorization code", "code", code)
+// removeBy deletes, or revokes if soft revocation is enabled, the tokens
+// matching the value of the given column and returns the number of removed
+// tokens. Removing a token that does not exist is not an error.
+func (s *TokenStore) removeBy(ctx context.Context, column string, value string) (int64, error) {
+	return s.removeWhere(ctx, column+" = $1", value)
+}
 
-	if code == "" {
-		s.logger.Log(ctx, LogLevelWarn, "no code was provided")
-		return nil
+// removeWhere deletes, or revokes if soft revocation is enabled, the tokens
+// matching the condition on the value, referenced as $1, and returns the
+// number of removed tokens.
+func (s *TokenStore) removeWhere(ctx context.Context, cond string, value any) (int64, error) {
+	if s.readOnly {
+		return 0, ErrReadOnly
+	}
+
+	if err := s.breaker.allow(); err != nil {
+		return 0, err
 	}
 
-	_, err := s.pool.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE code = $1", s.table), code)
+	s.markWrite()
 
-	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+	query, args := s.removeQuery(cond, value)
+
+	rows, err := s.pool.Query(ctx, s.observer.query("RemoveBy", query), args...)
+	if err != nil {
+		s.breaker.record(err)
 		s.logger.Log(ctx, LogLevelError, err.Error())
-		return err
+		s.hooks.afterRemove(ctx, nil, err)
+		return 0, wrapDatabaseError(err)
 	}
 
-	s.logger.Log(ctx, LogLevelInfo, "token removed")
+	removed, err := pgx.CollectRows(rows, pgx.RowTo[[]byte])
+	s.breaker.record(err)
 
-	return nil
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		s.hooks.afterRemove(ctx, nil, err)
+		return 0, wrapDatabaseError(err)
+	}
+
+	s.notifyRemoved(ctx, removed)
+
+	return int64(len(removed)), nil
 }
 
+// notifyRemoved runs the hooks of the removed tokens, given by their data,
+// and publishes one event and webhook delivery for all of them.
+func (s *TokenStore) notifyRemoved(ctx context.Context, removed [][]byte) {
+	infos := make([]oauth2.TokenInfo, 0, len(removed))
+
+	for _, data := range removed {
+		info, err := s.decodeTokenInfo(data)
+		if err != nil {
+			s.logger.Log(ctx, LogLevelError, err.Error())
+			s.hooks.afterRemove(ctx, nil, err)
+			continue
+		}
+
+		s.hooks.afterRemove(ctx, info, nil)
+		infos = append(infos, info)
+	}
+
+	if len(infos) > 0 {
+		s.publishEvent(ctx, newRemovedEvent(infos))
+	}
+
+	s.notifyWebhook(infos)
+
+	s.logger.Log(ctx, LogLevelInfo, "token removed", "count", len(removed))
+}
+
+// RemoveByCode deletes the token by its authorization code.
+func (s *TokenStore) RemoveByCode(ctx context.Context, code string) error {
+	s.logger.Log(ctx, LogLevelDebug, "removing token by authorization code", "code", s.loggedCode(code))
+
+	if code == "" {
+		s.logger.Log(ctx, LogLevelWarn, "no code was provided")
+		return nil
+	}
+
+	_, err := s.removeBy(ctx, s.columns.Code, s.hashCode(code))
+	return err
+}
+
+// RemoveByAccess deletes the token by its access token.
 func (s *TokenStore) RemoveByAccess(ctx context.Context, access string) error {
 	s.logger.Log(ctx, LogLevelDebug, "removing token by access token", "access", access)
 
@@ -255,18 +1059,11 @@ func (s *TokenStore) RemoveByAccess(ctx context.Context, access string) error {
 		return nil
 	}
 
-	_, err := s.pool.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE access_token = $1", s.table), access)
-
-	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
-		s.logger.Log(ctx, LogLevelError, err.Error())
-		return err
-	}
-
-	s.logger.Log(ctx, LogLevelInfo, "token removed")
-
-	return nil
+	_, err := s.removeWhere(ctx, s.columns.Access+" = $1"+s.andShardKey(), access)
+	return err
 }
 
+// RemoveByRefresh deletes the token by its refresh token.
 func (s *TokenStore) RemoveByRefresh(ctx context.Context, refresh string) error {
 	s.logger.Log(ctx, LogLevelDebug, "removing token by refresh token", "refresh", refresh)
 
@@ -275,25 +1072,112 @@ func (s *TokenStore) RemoveByRefresh(ctx context.Context, refresh string) error
 		return nil
 	}
 
-	_, err := s.pool.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE refresh_token = $1", s.table), refresh)
+	_, err := s.removeBy(ctx, s.columns.Refresh, refresh)
+	return err
+}
 
-	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
-		s.logger.Log(ctx, LogLevelError, err.Error())
-		return err
+// RemoveByClientID deletes every token issued to the client, for example when
+// the client is compromised, and returns the number of removed tokens.
+func (s *TokenStore) RemoveByClientID(ctx context.Context, clientID string) (int64, error) {
+	s.logger.Log(ctx, LogLevelDebug, "removing tokens by client id", "client_id", clientID)
+
+	if clientID == "" {
+		s.logger.Log(ctx, LogLevelWarn, "no client id was provided")
+		return 0, nil
 	}
 
-	s.logger.Log(ctx, LogLevelInfo, "token removed")
+	return s.removeBy(ctx, "client_id", clientID)
+}
 
-	return nil
+// RemoveByUserID deletes every token issued to the user, logging the user out
+// everywhere, and returns the number of removed tokens.
+func (s *TokenStore) RemoveByUserID(ctx context.Context, userID string) (int64, error) {
+	s.logger.Log(ctx, LogLevelDebug, "removing tokens by user id", "user_id", userID)
+
+	if userID == "" {
+		s.logger.Log(ctx, LogLevelWarn, "no user id was provided")
+		return 0, nil
+	}
+
+	return s.removeBy(ctx, "user_id", userID)
+}
+
+// RemoveByScope deletes every token granted the scope, for example when the
+// scope is withdrawn, and returns the number of removed tokens.
+func (s *TokenStore) RemoveByScope(ctx context.Context, scope string) (int64, error) {
+	s.logger.Log(ctx, LogLevelDebug, "removing tokens by scope", "scope", scope)
+
+	if scope == "" {
+		s.logger.Log(ctx, LogLevelWarn, "no scope was provided")
+		return 0, nil
+	}
+
+	return s.removeWhere(ctx, scopeCondition(1), scope)
+}
+
+// RemoveBatch deletes the tokens of the given kind in a single statement, for
+// example to revoke thousands of tokens during an incident, and returns the
+// number of removed tokens.
+func (s *TokenStore) RemoveBatch(ctx context.Context, tokens []string, kind TokenKind) (int64, error) {
+	s.logger.Log(ctx, LogLevelDebug, "removing token batch", "count", len(tokens), "kind", kind)
+
+	column, err := s.kindColumn(kind)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(tokens) == 0 {
+		return 0, nil
+	}
+
+	if kind == TokenKindCode {
+		tokens = s.hashCodes(tokens)
+	}
+
+	return s.removeWhere(ctx, column+" = ANY($1)", tokens)
+}
+
+// kindColumn returns the column of the tokens of the given kind.
+func (s *TokenStore) kindColumn(kind TokenKind) (string, error) {
+	switch kind {
+	case TokenKindCode:
+		return s.columns.Code, nil
+	case TokenKindAccess:
+		return s.columns.Access, nil
+	case TokenKindRefresh:
+		return s.columns.Refresh, nil
+	default:
+		return "", ErrInvalidTokenKind
+	}
 }
 
-// Close closes the store and releases any resources.
+// scopeCondition returns the condition matching the tokens granted the scope
+// passed as the parameter with the given index. Scopes are space separated.
+func scopeCondition(param int) string {
+	return fmt.Sprintf("string_to_array(scope, ' ') @> ARRAY[$%d]", param)
+}
+
+// Close closes the store and releases any resources. It cancels the cleanup
+// process and waits for an in-flight cleanup until the context is done, then
+// closes the connection pool if it was created by the store.
 func (s *TokenStore) Close(ctx context.Context) {
 	s.logger.Log(ctx, LogLevelDebug, "closing token store")
 
-	if s.cleanupTicker != nil {
-		s.logger.Log(ctx, LogLevelDebug, "stopping cleanup ticker")
-		s.cleanupTicker.Stop()
+	s.stopCleanup(ctx)
+
+	s.stopUsageTracking(ctx)
+
+	s.changeFeeds.stop(ctx, s.logger)
+
+	if s.webhook != nil {
+		s.webhook.close(ctx)
+	}
+
+	if s.ownsPool {
+		s.logger.Log(ctx, LogLevelDebug, "closing connection pool")
+		if pool, ok := nativePool(s.pool); ok {
+			pool.Close()
+		}
 	}
 
 	s.logger.Log(ctx, LogLevelDebug, "token store closed")
@@ -301,9 +1185,29 @@ func (s *TokenStore) Close(ctx context.Context) {
 
 // NewTokenStore creates a new TokenStore.
 func NewTokenStore(opts ...TokenStoreOption) (*TokenStore, error) {
+	s, err := newTokenStore(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	s.InitCleanup(context.Background())
+	s.initUsageTracking(context.Background())
+
+	return s, nil
+}
+
+// newTokenStore creates a new TokenStore without starting the cleanup and
+// usage tracking.
+func newTokenStore(opts ...TokenStoreOption) (*TokenStore, error) {
 	s := &TokenStore{
-		table:  DefaultTokenStoreTable,
-		logger: new(NoopLogger),
+		table:   DefaultTokenStoreTable,
+		logger:  new(NoopLogger),
+		clock:   new(SystemClock),
+		codec:   new(JSONCodec),
+		columns: DefaultColumnMap,
+		newTokenInfo: func() oauth2.TokenInfo {
+			return models.NewToken()
+		},
 	}
 
 	for _, o := range opts {
@@ -312,11 +1216,83 @@ func NewTokenStore(opts ...TokenStoreOption) (*TokenStore, error) {
 		}
 	}
 
+	if err := s.validateGeneratedColumns(); err != nil {
+		return nil, err
+	}
+
+	if s.shards > 0 && s.partitionInterval > 0 {
+		return nil, ErrInvalidSharding
+	}
+
+	if s.citus && (s.shards > 0 || s.partitionInterval > 0) {
+		return nil, ErrInvalidCitus
+	}
+
+	if err := s.validateHypertable(); err != nil {
+		return nil, err
+	}
+
+	if err := s.validateUniqueTokens(); err != nil {
+		return nil, err
+	}
+
+	if err := s.validateAnonymization(); err != nil {
+		return nil, err
+	}
+
+	if s.webhook != nil {
+		s.webhook.logger = s.logger
+	}
+
+	if (s.uuidKeys != 0 && s.idGenerator != nil) || (s.uuidGen != nil && s.uuidKeys == 0) {
+		return nil, ErrInvalidUUIDKeys
+	}
+
+	if s.identityColumns && (s.idGenerator != nil || s.uuidKeys != 0) {
+		return nil, ErrInvalidIdentityColumns
+	}
+
+	if s.storageParameters != nil && s.partitionInterval > 0 {
+		return nil, ErrInvalidStorageParameters
+	}
+
+	if s.codeHashKey != nil && s.generatedColumns {
+		return nil, ErrInvalidCodeHashing
+	}
+
+	if s.unlogged && (s.partitionInterval > 0 || s.shards > 0) {
+		return nil, ErrUnloggedPartitioning
+	}
+
+	s.breaker = newCircuitBreaker(s.breakerThreshold, s.breakerCoolDown, s.clock)
+
+	if s.tablePrefix != "" {
+		s.table = prefixedName(s.tablePrefix, s.table)
+		if s.archiveTable != "" {
+			s.archiveTable = prefixedName(s.tablePrefix, s.archiveTable)
+		}
+	}
+
+	if s.pool == nil && s.poolOpts.configured() {
+		pool, err := s.poolOpts.newPool(context.Background())
+		if err != nil {
+			return nil, err
+		}
+
+		s.pool = pool
+		s.ownsPool = true
+	}
+
 	if s.pool == nil {
 		return nil, ErrNoConnPool
 	}
 
-	s.InitCleanup(context.Background())
+	s.pool = s.observer.wrap(s.pool, s.logger, s.clock)
+	s.readPool = s.observer.wrap(s.readPool, s.logger, s.clock)
+
+	if s.webhook != nil {
+		s.webhook.start()
+	}
 
 	return s, nil
 }
@@ -0,0 +1,195 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// redactedArg replaces the query arguments passed to the tracer.
+const redactedArg = "[redacted]"
+
+// redactingTracer is a pgx.QueryTracer replacing the query arguments and the
+// details of the errors, which contain tokens and secrets, before passing them
+// to the wrapped tracer. The batch, copy, prepare and connect traces are
+// passed to the wrapped tracer if it implements the matching pgx interface.
+type redactingTracer struct {
+	tracer pgx.QueryTracer
+}
+
+// NewRedactingTracer wraps the tracer so the query arguments and the details
+// of the errors are redacted before they reach it. The number of arguments is
+// kept.
+func NewRedactingTracer(tracer pgx.QueryTracer) pgx.QueryTracer {
+	return &redactingTracer{tracer: tracer}
+}
+
+// redactArgs returns the redacted arguments.
+func redactArgs(args []any) []any {
+	redacted := make([]any, len(args))
+	for i := range redacted {
+		redacted[i] = redactedArg
+	}
+
+	return redacted
+}
+
+// redactError returns the error with the detail of the PostgreSQL error
+// redacted, as it contains the conflicting values of unique violations, such
+// as tokens.
+func redactError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || (pgErr.Detail == "" && pgErr.Where == "" && pgErr.InternalQuery == "") {
+		return err
+	}
+
+	redacted := *pgErr
+	for _, field := range []*string{&redacted.Detail, &redacted.Where, &redacted.InternalQuery} {
+		if *field != "" {
+			*field = redactedArg
+		}
+	}
+
+	return &redacted
+}
+
+// TraceQueryStart passes the query with redacted arguments to the tracer.
+func (t *redactingTracer) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	data.Args = redactArgs(data.Args)
+	return t.tracer.TraceQueryStart(ctx, conn, data)
+}
+
+// TraceQueryEnd passes the end of the query with the redacted error to the
+// tracer.
+func (t *redactingTracer) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryEndData) {
+	data.Err = redactError(data.Err)
+	t.tracer.TraceQueryEnd(ctx, conn, data)
+}
+
+// TraceBatchStart passes the start of the batch to the tracer. The arguments
+// of the queued queries are not accessible through the batch.
+func (t *redactingTracer) TraceBatchStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceBatchStartData) context.Context {
+	if tracer, ok := t.tracer.(pgx.BatchTracer); ok {
+		return tracer.TraceBatchStart(ctx, conn, data)
+	}
+
+	return ctx
+}
+
+// TraceBatchQuery passes the query of the batch with redacted arguments and
+// error to the tracer.
+func (t *redactingTracer) TraceBatchQuery(ctx context.Context, conn *pgx.Conn, data pgx.TraceBatchQueryData) {
+	if tracer, ok := t.tracer.(pgx.BatchTracer); ok {
+		data.Args = redactArgs(data.Args)
+		data.Err = redactError(data.Err)
+		tracer.TraceBatchQuery(ctx, conn, data)
+	}
+}
+
+// TraceBatchEnd passes the end of the batch with the redacted error to the
+// tracer.
+func (t *redactingTracer) TraceBatchEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceBatchEndData) {
+	if tracer, ok := t.tracer.(pgx.BatchTracer); ok {
+		data.Err = redactError(data.Err)
+		tracer.TraceBatchEnd(ctx, conn, data)
+	}
+}
+
+// TraceCopyFromStart passes the start of the copy to the tracer.
+func (t *redactingTracer) TraceCopyFromStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceCopyFromStartData) context.Context {
+	if tracer, ok := t.tracer.(pgx.CopyFromTracer); ok {
+		return tracer.TraceCopyFromStart(ctx, conn, data)
+	}
+
+	return ctx
+}
+
+// TraceCopyFromEnd passes the end of the copy with the redacted error to the
+// tracer.
+func (t *redactingTracer) TraceCopyFromEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceCopyFromEndData) {
+	if tracer, ok := t.tracer.(pgx.CopyFromTracer); ok {
+		data.Err = redactError(data.Err)
+		tracer.TraceCopyFromEnd(ctx, conn, data)
+	}
+}
+
+// TracePrepareStart passes the start of the statement preparation to the
+// tracer.
+func (t *redactingTracer) TracePrepareStart(ctx context.Context, conn *pgx.Conn, data pgx.TracePrepareStartData) context.Context {
+	if tracer, ok := t.tracer.(pgx.PrepareTracer); ok {
+		return tracer.TracePrepareStart(ctx, conn, data)
+	}
+
+	return ctx
+}
+
+// TracePrepareEnd passes the end of the statement preparation with the
+// redacted error to the tracer.
+func (t *redactingTracer) TracePrepareEnd(ctx context.Context, conn *pgx.Conn, data pgx.TracePrepareEndData) {
+	if tracer, ok := t.tracer.(pgx.PrepareTracer); ok {
+		data.Err = redactError(data.Err)
+		tracer.TracePrepareEnd(ctx, conn, data)
+	}
+}
+
+// TraceConnectStart passes the start of the connection to the tracer.
+func (t *redactingTracer) TraceConnectStart(ctx context.Context, data pgx.TraceConnectStartData) context.Context {
+	if tracer, ok := t.tracer.(pgx.ConnectTracer); ok {
+		return tracer.TraceConnectStart(ctx, data)
+	}
+
+	return ctx
+}
+
+// TraceConnectEnd passes the end of the connection to the tracer.
+func (t *redactingTracer) TraceConnectEnd(ctx context.Context, data pgx.TraceConnectEndData) {
+	if tracer, ok := t.tracer.(pgx.ConnectTracer); ok {
+		tracer.TraceConnectEnd(ctx, data)
+	}
+}
+
+// WithTokenStoreTracer configures the tracer of the connection pool created
+// from the DSN, so the timing and errors of the queries flow into existing pgx
+// observability setups. The query arguments are redacted. Use
+// NewRedactingTracer for a connection pool created by the application.
+func WithTokenStoreTracer(tracer pgx.QueryTracer) TokenStoreOption {
+	return func(s *TokenStore) error {
+		if tracer == nil {
+			return ErrNoTracer
+		}
+
+		s.poolOpts.tracer = NewRedactingTracer(tracer)
+
+		return nil
+	}
+}
+
+// WithClientStoreTracer configures the tracer of the connection pool created
+// from the DSN. The query arguments are redacted.
+func WithClientStoreTracer(tracer pgx.QueryTracer) ClientStoreOption {
+	return func(s *ClientStore) error {
+		if tracer == nil {
+			return ErrNoTracer
+		}
+
+		s.poolOpts.tracer = NewRedactingTracer(tracer)
+
+		return nil
+	}
+}
+
+// WithStoresTracer configures the tracer of the connection pool created from
+// the DSN. The query arguments are redacted.
+func WithStoresTracer(tracer pgx.QueryTracer) StoresOption {
+	return func(c *storesConfig) error {
+		if tracer == nil {
+			return ErrNoTracer
+		}
+
+		c.poolOpts.tracer = NewRedactingTracer(tracer)
+
+		return nil
+	}
+}
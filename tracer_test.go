@@ -0,0 +1,75 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// recordingTracer records the traced query and batch data.
+type recordingTracer struct {
+	start pgx.TraceQueryStartData
+	end   pgx.TraceQueryEndData
+	batch pgx.TraceBatchQueryData
+}
+
+func (t *recordingTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	t.start = data
+	return ctx
+}
+
+func (t *recordingTracer) TraceQueryEnd(_ context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	t.end = data
+}
+
+func (t *recordingTracer) TraceBatchStart(ctx context.Context, _ *pgx.Conn, _ pgx.TraceBatchStartData) context.Context {
+	return ctx
+}
+
+func (t *recordingTracer) TraceBatchQuery(_ context.Context, _ *pgx.Conn, data pgx.TraceBatchQueryData) {
+	t.batch = data
+}
+
+func (t *recordingTracer) TraceBatchEnd(context.Context, *pgx.Conn, pgx.TraceBatchEndData) {}
+
+func TestRedactingTracer(t *testing.T) {
+	recorder := new(recordingTracer)
+	tracer := NewRedactingTracer(recorder)
+	ctx := context.Background()
+
+	pgErr := &pgconn.PgError{Code: "23505", Message: "duplicate key", Detail: "Key (access_token)=(secret) already exists."}
+
+	tracer.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: "INSERT", Args: []any{"secret"}})
+	tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{Err: fmt.Errorf("insert: %w", pgErr)})
+	tracer.(pgx.BatchTracer).TraceBatchQuery(ctx, nil, pgx.TraceBatchQueryData{SQL: "INSERT", Args: []any{"secret"}, Err: pgErr})
+
+	if recorder.start.Args[0] != redactedArg || recorder.batch.Args[0] != redactedArg {
+		t.Fatalf("got arguments %v and %v, want them redacted", recorder.start.Args, recorder.batch.Args)
+	}
+
+	for _, err := range []error{recorder.end.Err, recorder.batch.Err} {
+		var traced *pgconn.PgError
+		if !errors.As(err, &traced) || traced.Code != "23505" || traced.Detail != redactedArg {
+			t.Fatalf("got error %#v, want the detail redacted", err)
+		}
+	}
+
+	if pgErr.Detail == redactedArg {
+		t.Fatal("error of the caller modified")
+	}
+}
+
+func TestRedactingTracerIgnoresMissingInterfaces(t *testing.T) {
+	tracer := NewRedactingTracer(new(recordingTracer)).(pgx.CopyFromTracer)
+	ctx := context.Background()
+
+	if got := tracer.TraceCopyFromStart(ctx, nil, pgx.TraceCopyFromStartData{}); got != ctx {
+		t.Fatal("context of the copy changed")
+	}
+
+	tracer.TraceCopyFromEnd(ctx, nil, pgx.TraceCopyFromEndData{})
+}
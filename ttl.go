@@ -0,0 +1,88 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// TokenTTLs are the token lifetimes of a client. A zero lifetime is not
+// overridden, so the default of the authorization server applies.
+type TokenTTLs struct {
+	Access  time.Duration
+	Refresh time.Duration
+}
+
+// nullDuration returns nil for a zero duration, stored as NULL.
+func nullDuration(d time.Duration) *time.Duration {
+	if d == 0 {
+		return nil
+	}
+
+	return &d
+}
+
+// SetTokenTTLs overrides the token lifetimes of the client, so different
+// clients can have different token lifetimes managed in the store.
+func (s *ClientStore) SetTokenTTLs(ctx context.Context, clientID string, ttls TokenTTLs) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	if ttls.Access < 0 || ttls.Refresh < 0 {
+		return ErrInvalidTokenTTL
+	}
+
+	s.logger.Log(ctx, LogLevelDebug, "setting client token ttls", "id", clientID, "access", ttls.Access, "refresh", ttls.Refresh)
+
+	tag, err := s.pool.Exec(ctx, fmt.Sprintf(
+		"UPDATE %s SET access_token_ttl = $2, refresh_token_ttl = $3 WHERE id = $1%s",
+		s.table, andRealm(s.realm),
+	), clientID, nullDuration(ttls.Access), nullDuration(ttls.Refresh))
+
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return wrapDatabaseError(err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrClientNotFound
+	}
+
+	return nil
+}
+
+// GetTokenTTLs returns the token lifetimes of the client. The lifetimes not
+// overridden are zero.
+func (s *ClientStore) GetTokenTTLs(ctx context.Context, clientID string) (TokenTTLs, error) {
+	s.logger.Log(ctx, LogLevelDebug, "getting client token ttls", "id", clientID)
+
+	var access, refresh *time.Duration
+	err := s.pool.QueryRow(ctx, fmt.Sprintf(
+		"SELECT access_token_ttl, refresh_token_ttl FROM %s WHERE id = $1%s",
+		s.table, andRealm(s.realm),
+	), clientID).Scan(&access, &refresh)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return TokenTTLs{}, ErrClientNotFound
+	}
+
+	if err != nil {
+		s.logger.Log(ctx, LogLevelError, err.Error())
+		return TokenTTLs{}, wrapDatabaseError(err)
+	}
+
+	var ttls TokenTTLs
+	if access != nil {
+		ttls.Access = *access
+	}
+
+	if refresh != nil {
+		ttls.Refresh = *refresh
+	}
+
+	return ttls, nil
+}
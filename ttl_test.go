@@ -0,0 +1,64 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4/models"
+)
+
+func TestClientStoreSetTokenTTLsInvalid(t *testing.T) {
+	store, err := NewClientStore(WithClientStoreDB(unusedDB{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ttls := range []TokenTTLs{{Access: -time.Minute}, {Refresh: -time.Minute}} {
+		if err = store.SetTokenTTLs(context.Background(), "client", ttls); !errors.Is(err, ErrInvalidTokenTTL) {
+			t.Errorf("%+v: got error %v, want %v", ttls, err, ErrInvalidTokenTTL)
+		}
+	}
+}
+
+func TestClientStoreTokenTTLs(t *testing.T) {
+	store := newTestClientStore(t)
+	ctx := context.Background()
+
+	if err := store.Create(&models.Client{ID: "client", Domain: "https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	ttls, err := store.GetTokenTTLs(ctx, "client")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ttls != (TokenTTLs{}) {
+		t.Fatalf("got lifetimes %+v, want none overridden", ttls)
+	}
+
+	// A zero lifetime is stored as NULL, so only the access token lifetime is
+	// overridden.
+	want := TokenTTLs{Access: 5 * time.Minute}
+	if err = store.SetTokenTTLs(ctx, "client", want); err != nil {
+		t.Fatal(err)
+	}
+
+	if ttls, err = store.GetTokenTTLs(ctx, "client"); err != nil {
+		t.Fatal(err)
+	}
+
+	if ttls != want {
+		t.Fatalf("got lifetimes %+v, want %+v", ttls, want)
+	}
+
+	if err = store.SetTokenTTLs(ctx, "missing", want); !errors.Is(err, ErrClientNotFound) {
+		t.Fatalf("got error %v for a missing client, want %v", err, ErrClientNotFound)
+	}
+
+	if _, err = store.GetTokenTTLs(ctx, "missing"); !errors.Is(err, ErrClientNotFound) {
+		t.Fatalf("got error %v for a missing client, want %v", err, ErrClientNotFound)
+	}
+}
@@ -0,0 +1,82 @@
+package pgstore
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// WithTokenStoreUniqueTokens configures InitTable to create partial unique
+// indexes on the non-empty authorization codes, access tokens and refresh
+// tokens, so token collisions are caught instead of storing duplicates.
+// Create returns ErrDuplicateToken if the token collides with a stored one.
+// Unique indexes on the token columns cannot be created on partitioned,
+// sharded, distributed or hypertable token tables.
+func WithTokenStoreUniqueTokens() TokenStoreOption {
+	return func(s *TokenStore) error {
+		s.uniqueTokens = true
+		return nil
+	}
+}
+
+// validateUniqueTokens returns ErrInvalidUniqueTokens if unique tokens are
+// configured with a table layout not supporting unique indexes on the token
+// columns.
+func (s *TokenStore) validateUniqueTokens() error {
+	if !s.uniqueTokens {
+		return nil
+	}
+
+	if s.partitionInterval > 0 || s.shards > 0 || s.citus || s.hypertableChunkInterval > 0 {
+		return ErrInvalidUniqueTokens
+	}
+
+	return nil
+}
+
+// uniqueIndexes returns the names of the unique indexes by token column.
+func (s *TokenStore) uniqueIndexes() map[string]string {
+	prefix := "idx_" + unqualifiedName(s.table) + "_"
+
+	return map[string]string{
+		s.columns.Code:    prefix + "code_unique_idx",
+		s.columns.Access:  prefix + "access_unique_idx",
+		s.columns.Refresh: prefix + "refresh_unique_idx",
+	}
+}
+
+// uniqueIndexesSQL returns the DDL of the unique indexes, or an empty string
+// if unique tokens are disabled.
+func (s *TokenStore) uniqueIndexesSQL() string {
+	if !s.uniqueTokens {
+		return ""
+	}
+
+	var ddl string
+	for _, column := range []string{s.columns.Code, s.columns.Access, s.columns.Refresh} {
+		ddl += fmt.Sprintf(
+			"\nCREATE UNIQUE INDEX IF NOT EXISTS %s ON %s (%s) WHERE %s <> '';",
+			s.uniqueIndexes()[column], s.table, column, column,
+		)
+	}
+
+	return ddl
+}
+
+// duplicateTokenError returns ErrDuplicateToken if the error is a violation of
+// a unique token index, or the error otherwise.
+func (s *TokenStore) duplicateTokenError(err error) error {
+	var pgErr *pgconn.PgError
+	if !s.uniqueTokens || !errors.As(err, &pgErr) || pgErr.Code != "23505" {
+		return err
+	}
+
+	for _, index := range s.uniqueIndexes() {
+		if pgErr.ConstraintName == index {
+			return ErrDuplicateToken
+		}
+	}
+
+	return err
+}
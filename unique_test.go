@@ -0,0 +1,25 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTokenStoreDuplicateTokenDoesNotOpenCircuitBreaker(t *testing.T) {
+	store := newTestTokenStore(t, WithTokenStoreUniqueTokens(), WithTokenStoreCircuitBreaker(1, time.Hour))
+	ctx := context.Background()
+
+	if err := store.Create(ctx, newTestToken("client", "user", "a")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Create(ctx, newTestToken("client", "user", "a")); !errors.Is(err, ErrDuplicateToken) {
+		t.Fatalf("got error %v, want %v", err, ErrDuplicateToken)
+	}
+
+	if err := store.Create(ctx, newTestToken("client", "user", "b")); err != nil {
+		t.Fatalf("got error %v after a token collision", err)
+	}
+}
@@ -0,0 +1,34 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewTokenStoreUnloggedPartitioning(t *testing.T) {
+	_, err := newTokenStore(WithTokenStoreDB(unusedDB{}), WithTokenStoreUnlogged(true), WithTokenStorePartitioning(24*time.Hour, 2))
+	if !errors.Is(err, ErrUnloggedPartitioning) {
+		t.Fatalf("got error %v, want %v", err, ErrUnloggedPartitioning)
+	}
+}
+
+func TestTokenStoreUnlogged(t *testing.T) {
+	store := newTestTokenStore(t, WithTokenStoreUnlogged(true))
+	ctx := context.Background()
+
+	if ddl := store.InitTableSQL(); !strings.Contains(ddl, "CREATE UNLOGGED TABLE IF NOT EXISTS "+store.table+" (") {
+		t.Fatalf("got DDL %s, want an unlogged table", ddl)
+	}
+
+	var persistence string
+	if err := store.pool.QueryRow(ctx, "SELECT relpersistence::TEXT FROM pg_class WHERE oid = $1::regclass", store.table).Scan(&persistence); err != nil {
+		t.Fatal(err)
+	}
+
+	if persistence != "u" {
+		t.Fatalf("got persistence %q, want an unlogged table", persistence)
+	}
+}
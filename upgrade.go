@@ -0,0 +1,156 @@
+package pgstore
+
+import (
+	"fmt"
+	"strings"
+)
+
+// upgradeColumn is a column added to the tables created by earlier versions,
+// with the statement backfilling it from the data column.
+type upgradeColumn struct {
+	name       string
+	definition string
+	backfill   string
+}
+
+// addColumnsSQL returns the statements adding the columns missing from an
+// existing table. The backfill of a column runs only when the column is
+// added, so the statements are idempotent and cheap once the table is up to
+// date.
+func addColumnsSQL(table string, columns []upgradeColumn) string {
+	stmts := make([]string, 0, len(columns))
+	for _, c := range columns {
+		backfill := ""
+		if c.backfill != "" {
+			backfill = "\n\t\t\t\t" + c.backfill + ";"
+		}
+
+		stmts = append(stmts, fmt.Sprintf(`
+		DO $$
+		BEGIN
+			IF NOT EXISTS (
+				SELECT 1 FROM pg_attribute
+				WHERE attrelid = '%[1]s'::regclass AND attname = '%[2]s' AND NOT attisdropped
+			) THEN
+				ALTER TABLE %[1]s ADD COLUMN %[2]s %[3]s;%[4]s
+			END IF;
+		END
+		$$;`,
+			table, c.name, c.definition, backfill,
+		))
+	}
+
+	return strings.Join(stmts, "\n")
+}
+
+// upgradeSQL returns the statements adding the columns introduced after the
+// first version of the token table. The tokens stored before token families
+// were introduced get a family of their own, so they are never grouped into
+// one family by their empty family ID.
+func (s *TokenStore) upgradeSQL() string {
+	return s.upgradeColumnsSQL(s.table) + fmt.Sprintf(`
+		UPDATE %s SET family_id = md5(id::text) WHERE family_id = '';`,
+		s.table,
+	)
+}
+
+// upgradeColumnsSQL returns the statements adding the columns introduced
+// after the first version of the token table to the token or archive table.
+// The client, user and scope columns are backfilled from the data column if
+// it is stored as JSONB.
+func (s *TokenStore) upgradeColumnsSQL(table string) string {
+	backfill := func(column string, field string) string {
+		if s.dataColumnType() != "JSONB" {
+			return ""
+		}
+
+		return fmt.Sprintf("UPDATE %s SET %s = COALESCE(%s->>'%s', '')", table, column, s.columns.Data, field)
+	}
+
+	return addColumnsSQL(table, []upgradeColumn{
+		{"client_id", "TEXT NOT NULL DEFAULT ''", backfill("client_id", "ClientID")},
+		{"user_id", "TEXT NOT NULL DEFAULT ''", backfill("user_id", "UserID")},
+		{"scope", "TEXT NOT NULL DEFAULT ''", backfill("scope", "Scope")},
+		{"family_id", "TEXT NOT NULL DEFAULT ''", ""},
+		{"realm", "TEXT NOT NULL DEFAULT ''", ""},
+		{"issuer", "TEXT NOT NULL DEFAULT ''", ""},
+		{"audience", "TEXT[] NOT NULL DEFAULT '{}'", ""},
+		{"client_ip", "INET", ""},
+		{"user_agent", "TEXT NOT NULL DEFAULT ''", ""},
+		{"parent_id", s.keyType(), ""},
+		{"rotated_at", "TIMESTAMPTZ", ""},
+		{"revoked_at", "TIMESTAMPTZ", ""},
+		{"last_used_at", "TIMESTAMPTZ", ""},
+		{"anonymized_at", "TIMESTAMPTZ", ""},
+		{"grant_type", "TEXT NOT NULL DEFAULT ''", ""},
+		{"key_id", "TEXT NOT NULL DEFAULT ''", keyIDBackfill(table, s.encryptionSetting)},
+	})
+}
+
+// alterColumnTypeSQL returns the statement changing the type of the column of
+// an existing table if it has another type, so the table is only locked when
+// the column is changed.
+func alterColumnTypeSQL(table string, column string, typ string) string {
+	return fmt.Sprintf(`
+		DO $$
+		BEGIN
+			IF EXISTS (
+				SELECT 1 FROM pg_attribute
+				WHERE attrelid = '%[1]s'::regclass AND attname = '%[2]s' AND NOT attisdropped
+				AND format_type(atttypid, atttypmod) <> lower('%[3]s')
+			) THEN
+				ALTER TABLE %[1]s ALTER COLUMN %[2]s TYPE %[3]s;
+			END IF;
+		END
+		$$;`,
+		table, column, typ,
+	)
+}
+
+// redirectURIsUpgradeSQL returns the statement moving the redirect URIs of
+// the registration metadata, stored in a column of the client table by
+// earlier versions, into the redirect URI table, so the redirect URIs are
+// stored in one place.
+func (s *ClientStore) redirectURIsUpgradeSQL() string {
+	return fmt.Sprintf(`
+		DO $$
+		BEGIN
+			IF EXISTS (
+				SELECT 1 FROM pg_attribute
+				WHERE attrelid = '%[1]s'::regclass AND attname = 'redirect_uris' AND NOT attisdropped
+			) THEN
+				INSERT INTO %[2]s (client_id, uri)
+				SELECT id, unnest(redirect_uris) FROM %[1]s
+				ON CONFLICT DO NOTHING;
+				ALTER TABLE %[1]s DROP COLUMN redirect_uris;
+			END IF;
+		END
+		$$;`,
+		s.table, s.redirectURITable,
+	)
+}
+
+// upgradeSQL returns the statements adding the columns introduced after the
+// first version of the client table. The secret column is widened from
+// VARCHAR(255), which encrypted secrets do not fit in. The public flag is
+// backfilled from the data column if it is stored as JSONB.
+func (s *ClientStore) upgradeSQL() string {
+	publicBackfill := ""
+	if s.dataColumnType() == "JSONB" {
+		publicBackfill = fmt.Sprintf("UPDATE %s SET is_public = COALESCE((data->>'Public')::boolean, FALSE)", s.table)
+	}
+
+	return alterColumnTypeSQL(s.table, "secret", "TEXT") + addColumnsSQL(s.table, []upgradeColumn{
+		{"is_public", "BOOLEAN NOT NULL DEFAULT FALSE", publicBackfill},
+		{"version", "BIGINT NOT NULL DEFAULT 1", ""},
+		{"realm", "TEXT NOT NULL DEFAULT ''", ""},
+		{"is_enabled", "BOOLEAN NOT NULL DEFAULT TRUE", ""},
+		{"grant_types", "TEXT[] NOT NULL DEFAULT '{}'", ""},
+		{"token_endpoint_auth_method", "TEXT NOT NULL DEFAULT ''", ""},
+		{"software_statement", "TEXT NOT NULL DEFAULT ''", ""},
+		{"registration_token_hash", "TEXT NOT NULL DEFAULT ''", ""},
+		{"access_token_ttl", "INTERVAL", ""},
+		{"refresh_token_ttl", "INTERVAL", ""},
+		{"key_id", "TEXT NOT NULL DEFAULT ''", keyIDBackfill(s.table, s.encryptionSetting)},
+	})
+}
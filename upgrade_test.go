@@ -0,0 +1,110 @@
+package pgstore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func TestTokenStoreUpgradeBackfillsFamilies(t *testing.T) {
+	store := newTestTokenStore(t)
+	ctx := context.Background()
+
+	for _, suffix := range []string{"1", "2"} {
+		if err := store.Create(ctx, newTestToken("client", "user", suffix)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := store.pool.Exec(ctx, "UPDATE "+store.table+" SET family_id = ''"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.InitTable(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var families, empty int
+	if err := store.pool.QueryRow(ctx,
+		"SELECT count(DISTINCT family_id), count(*) FILTER (WHERE family_id = '') FROM "+store.table,
+	).Scan(&families, &empty); err != nil {
+		t.Fatal(err)
+	}
+
+	if families != 2 || empty != 0 {
+		t.Fatalf("got %d families and %d tokens without a family, want 2 and 0", families, empty)
+	}
+}
+
+func TestTokenStoreUpgradesArchiveTable(t *testing.T) {
+	store := newTestTokenStore(t, WithTokenStoreArchive(DefaultTokenArchiveTable, 0))
+	ctx := context.Background()
+
+	if _, err := store.pool.Exec(ctx, "ALTER TABLE "+store.archiveTable+" DROP COLUMN grant_type"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.InitTable(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var exists bool
+	if err := store.pool.QueryRow(ctx,
+		"SELECT EXISTS (SELECT 1 FROM pg_attribute WHERE attrelid = $1::regclass AND attname = 'grant_type' AND NOT attisdropped)",
+		store.archiveTable,
+	).Scan(&exists); err != nil {
+		t.Fatal(err)
+	}
+
+	if !exists {
+		t.Fatal("grant_type column not added to the archive table")
+	}
+}
+
+func TestClientStoreUpgradesBaselineTable(t *testing.T) {
+	dsn := newTestDSN(t)
+	ctx := context.Background()
+
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close(ctx)
+
+	if _, err = conn.Exec(ctx, `
+		CREATE TABLE `+DefaultClientStoreTable+` (
+			id         VARCHAR(255) PRIMARY KEY,
+			secret     VARCHAR(255) NOT NULL,
+			domain     VARCHAR(255) NOT NULL,
+			data       JSONB        NOT NULL,
+			created_at TIMESTAMPTZ  NOT NULL
+		);
+		INSERT INTO `+DefaultClientStoreTable+` (id, secret, domain, data, created_at) VALUES
+			('public', '', 'https://example.com', '{"ID": "public", "Public": true}', now()),
+			('confidential', 'secret', 'https://example.com', '{"ID": "confidential"}', now());`,
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := NewClientStore(WithClientStoreDSN(dsn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close(ctx)
+
+	if err = store.InitTable(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for id, want := range map[string]bool{"public": true, "confidential": false} {
+		info, err := store.GetByID(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if info.IsPublic() != want {
+			t.Errorf("client %s: got public %t, want %t", id, info.IsPublic(), want)
+		}
+	}
+}
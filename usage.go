@@ -0,0 +1,146 @@
+package pgstore
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"github.com/jackc/pgx/v5"
+)
+
+// usageTracker collects token usage times in memory until they are flushed.
+type usageTracker struct {
+	mu      sync.Mutex
+	pending map[rowKey]time.Time
+	done    chan struct{}
+	wg      sync.WaitGroup
+	stop    sync.Once
+}
+
+// touch records the usage time of the token row.
+func (t *usageTracker) touch(id rowKey, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.pending[id] = at
+}
+
+// drain returns and resets the recorded usage times.
+func (t *usageTracker) drain() ([]string, []time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ids := make([]string, 0, len(t.pending))
+	times := make([]time.Time, 0, len(t.pending))
+
+	for id, at := range t.pending {
+		ids = append(ids, id.String())
+		times = append(times, at)
+	}
+
+	t.pending = make(map[rowKey]time.Time)
+
+	return ids, times
+}
+
+// WithTokenStoreLastUsedTracking enables updating the last_used_at column of
+// tokens returned by GetByAccess and GetByRefresh. To avoid a write on every
+// lookup, the usage times are collected in memory and written in a single
+// statement every flush interval.
+func WithTokenStoreLastUsedTracking(flushInterval time.Duration) TokenStoreOption {
+	return func(s *TokenStore) error {
+		if flushInterval <= 0 {
+			return ErrInvalidFlushInterval
+		}
+
+		s.usageFlushInterval = flushInterval
+
+		return nil
+	}
+}
+
+// scanToUsedTokenInfo scans a row of the columns returned by getColumns into
+// an oauth2.TokenInfo and records the usage of the token if last used
+// tracking is enabled.
+func (s *TokenStore) scanToUsedTokenInfo(ctx context.Context, row pgx.Row) (oauth2.TokenInfo, error) {
+	id, info, err := s.scanTokenData(ctx, row)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.usage != nil {
+		s.usage.touch(id, s.clock.Now())
+	}
+
+	return info, nil
+}
+
+// flushUsage writes the collected usage times to the token table.
+func (s *TokenStore) flushUsage(ctx context.Context) error {
+	ids, times := s.usage.drain()
+	if len(ids) == 0 {
+		return nil
+	}
+
+	_, err := s.pool.Exec(ctx, fmt.Sprintf(`
+		UPDATE %s AS t SET last_used_at = u.used_at
+		FROM unnest($1::TEXT[], $2::TIMESTAMPTZ[]) AS u(id, used_at)
+		WHERE t.id = u.id::%s`,
+		s.table, s.keyType(),
+	), ids, times)
+
+	s.logger.Log(ctx, LogLevelDebug, "flushing token usage", "count", len(ids), "err", err)
+
+	return err
+}
+
+// initUsageTracking starts flushing the collected usage times periodically.
+func (s *TokenStore) initUsageTracking(ctx context.Context) {
+	if s.usageFlushInterval > 0 && !s.readOnly {
+		s.usage = &usageTracker{
+			pending: make(map[rowKey]time.Time),
+			done:    make(chan struct{}),
+		}
+
+		s.usage.wg.Add(1)
+
+		go func() {
+			defer s.usage.wg.Done()
+
+			ticker := time.NewTicker(s.usageFlushInterval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-s.usage.done:
+					return
+				case <-ticker.C:
+					if err := s.flushUsage(ctx); err != nil {
+						s.logger.Log(ctx, LogLevelError, err.Error())
+					}
+				}
+			}
+		}()
+	}
+}
+
+// stopUsageTracking stops the periodic flush, waits for an in-flight flush
+// and writes the remaining usage times once.
+func (s *TokenStore) stopUsageTracking(ctx context.Context) {
+	if s.usage == nil {
+		return
+	}
+
+	s.usage.stop.Do(func() {
+		s.logger.Log(ctx, LogLevelDebug, "stopping usage tracking")
+
+		close(s.usage.done)
+		s.usage.wg.Wait()
+
+		if err := s.flushUsage(ctx); err != nil {
+			s.logger.Log(ctx, LogLevelError, err.Error())
+		}
+	})
+}
@@ -0,0 +1,59 @@
+package pgstore
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTokenStoreStopUsageTrackingStopsFlushing(t *testing.T) {
+	store := &TokenStore{logger: new(NoopLogger), clock: new(SystemClock), usageFlushInterval: time.Millisecond}
+	ctx := context.Background()
+
+	store.initUsageTracking(ctx)
+	time.Sleep(5 * time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		store.stopUsageTracking(ctx)
+		store.stopUsageTracking(ctx)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("stopping usage tracking did not return")
+	}
+
+	select {
+	case <-store.usage.done:
+	default:
+		t.Fatal("flush goroutine not stopped")
+	}
+}
+
+func TestTokenStoreCloseFlushesUsage(t *testing.T) {
+	store := newTestTokenStore(t, WithTokenStoreLastUsedTracking(time.Hour))
+	ctx := context.Background()
+
+	if err := store.Create(ctx, newTestToken("client", "user", "1")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.GetByAccess(ctx, "access-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	store.stopUsageTracking(ctx)
+
+	var used bool
+	if err := store.pool.QueryRow(ctx, "SELECT last_used_at IS NOT NULL FROM "+store.table).Scan(&used); err != nil {
+		t.Fatal(err)
+	}
+
+	if !used {
+		t.Fatal("usage not flushed on close")
+	}
+}
@@ -0,0 +1,129 @@
+package pgstore
+
+import (
+	"fmt"
+	"strconv"
+)
+
+const (
+	UUIDv4 = UUIDVersion(4) // random UUIDs generated by gen_random_uuid
+	UUIDv7 = UUIDVersion(7) // time-ordered UUIDs generated by uuidv7
+)
+
+// UUIDVersion is the version of the UUID primary keys of the token table.
+type UUIDVersion int
+
+// WithTokenStoreUUIDKeys configures InitTable to create the token table with
+// a UUID primary key of the given version generated by the database, for
+// deployments standardizing on UUID keys. Version 7 keys require the uuidv7
+// function of PostgreSQL 18, unless they are generated by the application,
+// see WithTokenStoreUUIDGenerator. The keys of the tokens are reported in the Key
+// fields instead of the ID fields, which are zero. UUID keys cannot be
+// combined with an ID generator, and existing tables with BIGINT keys are not
+// converted.
+func WithTokenStoreUUIDKeys(version UUIDVersion) TokenStoreOption {
+	return func(s *TokenStore) error {
+		if version != UUIDv4 && version != UUIDv7 {
+			return ErrInvalidUUIDKeys
+		}
+
+		s.uuidKeys = version
+
+		return nil
+	}
+}
+
+// keyType returns the SQL type of the ID and parent ID columns.
+func (s *TokenStore) keyType() string {
+	if s.uuidKeys != 0 {
+		return "UUID"
+	}
+
+	return "BIGINT"
+}
+
+// uuidDefault returns the default of the UUID ID column.
+func (s *TokenStore) uuidDefault() string {
+	if s.uuidKeys == UUIDv7 {
+		return "uuidv7()"
+	}
+
+	return "gen_random_uuid()"
+}
+
+// rowKey is the ID of a token row, a BIGINT or a UUID depending on the
+// schema of the token table.
+type rowKey struct {
+	ID    int64
+	Key   string
+	Valid bool
+}
+
+// Scan implements the sql.Scanner interface. UUIDs are scanned as strings.
+func (k *rowKey) Scan(src any) error {
+	*k = rowKey{}
+
+	switch v := src.(type) {
+	case nil:
+	case int64:
+		k.ID, k.Valid = v, true
+	case string:
+		k.Key, k.Valid = v, true
+	default:
+		return fmt.Errorf("cannot scan %T into a token row id", src)
+	}
+
+	return nil
+}
+
+// value returns the ID as a query argument.
+func (k rowKey) value() any {
+	if !k.Valid {
+		return nil
+	}
+
+	if k.Key != "" {
+		return k.Key
+	}
+
+	return k.ID
+}
+
+// String returns the ID as text.
+func (k rowKey) String() string {
+	if k.Key != "" {
+		return k.Key
+	}
+
+	return strconv.FormatInt(k.ID, 10)
+}
+
+// parentID returns the ID as the parent ID of a token, nil if it is NULL.
+func (k rowKey) parentID() (*int64, *string) {
+	switch {
+	case !k.Valid:
+		return nil, nil
+	case k.Key != "":
+		return nil, &k.Key
+	default:
+		return &k.ID, nil
+	}
+}
+
+// parentValue returns the parent ID of the item as a query argument.
+func (s *TokenStore) parentValue(item TokenStoreItem) any {
+	if s.uuidKeys != 0 {
+		return item.ParentKey
+	}
+
+	return item.ParentID
+}
+
+// cursorValue returns the ID of the cursor as a query argument.
+func (s *TokenStore) cursorValue(cursor *TokenCursor) any {
+	if s.uuidKeys != 0 {
+		return cursor.Key
+	}
+
+	return cursor.ID
+}
@@ -0,0 +1,151 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewTokenStoreIncompatibleOptions(t *testing.T) {
+	snowflake, err := NewSnowflakeGenerator(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		opts []TokenStoreOption
+		want error
+	}{
+		"sharding with partitioning": {
+			opts: []TokenStoreOption{WithTokenStoreShards(4), WithTokenStorePartitioning(24*time.Hour, 1)},
+			want: ErrInvalidSharding,
+		},
+		"citus with sharding": {
+			opts: []TokenStoreOption{WithTokenStoreCitus(), WithTokenStoreShards(4)},
+			want: ErrInvalidCitus,
+		},
+		"citus with partitioning": {
+			opts: []TokenStoreOption{WithTokenStoreCitus(), WithTokenStorePartitioning(24*time.Hour, 1)},
+			want: ErrInvalidCitus,
+		},
+		"uuid keys with id generator": {
+			opts: []TokenStoreOption{WithTokenStoreUUIDKeys(UUIDv4), WithTokenStoreIDGenerator(snowflake)},
+			want: ErrInvalidUUIDKeys,
+		},
+		"unsupported uuid version": {
+			opts: []TokenStoreOption{WithTokenStoreUUIDKeys(UUIDVersion(1))},
+			want: ErrInvalidUUIDKeys,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewTokenStore(append([]TokenStoreOption{WithTokenStoreDB(unusedDB{})}, tt.opts...)...)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("got error %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenStoreUUIDKeysDDL(t *testing.T) {
+	for version, want := range map[UUIDVersion]string{
+		UUIDv4: "id            UUID DEFAULT gen_random_uuid()",
+		UUIDv7: "id            UUID DEFAULT uuidv7()",
+	} {
+		store, err := NewTokenStore(WithTokenStoreDB(unusedDB{}), WithTokenStoreUUIDKeys(version))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ddl := store.InitTableSQL()
+
+		if !strings.Contains(ddl, want) {
+			t.Errorf("got DDL without %q:\n%s", want, ddl)
+		}
+
+		if !strings.Contains(ddl, "parent_id     UUID,") {
+			t.Errorf("got DDL without a UUID parent ID:\n%s", ddl)
+		}
+
+		if store.hasIDSequence() {
+			t.Error("got an ID sequence for UUID keys")
+		}
+	}
+}
+
+func TestRowKeyScan(t *testing.T) {
+	const uuid = "0188e6a4-7c6f-7cde-8b2a-6c1e0e2f4a10"
+
+	tests := map[string]struct {
+		src       any
+		want      rowKey
+		wantValue any
+	}{
+		"bigint": {src: int64(42), want: rowKey{ID: 42, Valid: true}, wantValue: int64(42)},
+		"uuid":   {src: uuid, want: rowKey{Key: uuid, Valid: true}, wantValue: uuid},
+		"null":   {src: nil, want: rowKey{}, wantValue: nil},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			key := rowKey{ID: 1, Key: "stale", Valid: true}
+			if err := key.Scan(tt.src); err != nil {
+				t.Fatal(err)
+			}
+
+			if key != tt.want {
+				t.Fatalf("got key %+v, want %+v", key, tt.want)
+			}
+
+			if got := key.value(); got != tt.wantValue {
+				t.Fatalf("got value %v, want %v", got, tt.wantValue)
+			}
+
+			id, parentKey := key.parentID()
+			if (id != nil) != (tt.want.Valid && tt.want.Key == "") || (parentKey != nil) != (tt.want.Key != "") {
+				t.Fatalf("got parent ID %v and key %v for %+v", id, parentKey, key)
+			}
+		})
+	}
+
+	var key rowKey
+	if err := key.Scan(1.5); err == nil {
+		t.Fatal("got no error scanning a float")
+	}
+}
+
+func TestTokenStoreUUIDKeys(t *testing.T) {
+	store := newTestTokenStore(t, WithTokenStoreUUIDKeys(UUIDv4))
+	ctx := context.Background()
+
+	if err := store.Create(ctx, newTestToken("client", "user", "a")); err != nil {
+		t.Fatal(err)
+	}
+
+	rotated := newTestToken("client", "user", "b")
+	if err := store.RotateRefresh(ctx, "refresh-a", rotated); err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := store.ListTokens(ctx, TokenFilter{UserID: "user"}, nil, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(page.Tokens) == 0 {
+		t.Fatal("got no tokens")
+	}
+
+	for _, record := range page.Tokens {
+		if record.Key == "" || record.ID != 0 {
+			t.Fatalf("got record ID %d and key %q, want a UUID key", record.ID, record.Key)
+		}
+	}
+
+	if _, err = store.GetByRefresh(ctx, "refresh-b"); err != nil {
+		t.Fatal(err)
+	}
+}
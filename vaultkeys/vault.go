@@ -0,0 +1,72 @@
+// Package vaultkeys provides a pgstore.UnwrapFunc decrypting data keys with
+// the HashiCorp Vault transit secrets engine.
+package vaultkeys
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	pgstore "github.com/gabor-boros/go-oauth2-pg"
+)
+
+// decryptRequest is the body of a transit decrypt request.
+type decryptRequest struct {
+	Ciphertext string `json:"ciphertext"`
+}
+
+// decryptResponse is the body of a transit decrypt response.
+type decryptResponse struct {
+	Data struct {
+		Plaintext string `json:"plaintext"`
+	} `json:"data"`
+	Errors []string `json:"errors"`
+}
+
+// NewUnwrapFunc returns a pgstore.UnwrapFunc decrypting the data keys, wrapped
+// as "vault:v1:..." ciphertexts, with the named transit key. The data keys
+// can be generated with the "transit/datakey/wrapped/<name>" endpoint. If the
+// client is nil, http.DefaultClient is used.
+func NewUnwrapFunc(client *http.Client, addr string, token string, keyName string) pgstore.UnwrapFunc {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	url := fmt.Sprintf("%s/v1/transit/decrypt/%s", strings.TrimSuffix(addr, "/"), keyName)
+
+	return func(ctx context.Context, wrapped []byte) ([]byte, error) {
+		body, err := json.Marshal(decryptRequest{Ciphertext: string(wrapped)})
+		if err != nil {
+			return nil, err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Vault-Token", token)
+
+		res, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer res.Body.Close()
+
+		var decrypted decryptResponse
+		if err = json.NewDecoder(res.Body).Decode(&decrypted); err != nil {
+			return nil, err
+		}
+
+		if res.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("vault transit decrypt failed with status %d: %s", res.StatusCode, strings.Join(decrypted.Errors, "; "))
+		}
+
+		return base64.StdEncoding.DecodeString(decrypted.Data.Plaintext)
+	}
+}
@@ -0,0 +1,280 @@
+package pgstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+)
+
+const (
+	// WebhookSignatureHeader is the header carrying the HMAC-SHA256 signature
+	// of the webhook body, as "sha256=" followed by the hex encoded signature.
+	WebhookSignatureHeader = "X-Webhook-Signature"
+	// webhookTimeout is the timeout of a webhook request.
+	webhookTimeout = 10 * time.Second
+	// webhookBackoff is the delay before the first retry of a webhook, doubled
+	// after every retry.
+	webhookBackoff = time.Second
+	// webhookWorkers is the number of webhooks sent at the same time.
+	webhookWorkers = 4
+	// webhookQueueSize is the number of webhooks waiting to be sent, beyond
+	// which new webhooks are dropped.
+	webhookQueueSize = 1000
+)
+
+// RevocationWebhook is the body of the webhook sent when tokens are revoked.
+// The tokens are identified by the SHA-256 hashes of their values, so
+// resource servers can purge the tokens from their caches without the tokens
+// being sent.
+type RevocationWebhook struct {
+	Type      EventType      `json:"type"`
+	Tokens    []RevokedToken `json:"tokens"`
+	Timestamp time.Time      `json:"timestamp"`
+}
+
+// RevokedToken is a revoked token of a revocation webhook.
+type RevokedToken struct {
+	ClientID    string `json:"client_id,omitempty"`
+	UserID      string `json:"user_id,omitempty"`
+	AccessHash  string `json:"access_token_hash,omitempty"`
+	RefreshHash string `json:"refresh_token_hash,omitempty"`
+}
+
+// tokenHash returns the hex encoded SHA-256 hash of the token, or an empty
+// string for an empty token.
+func tokenHash(token string) string {
+	if token == "" {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// newRevokedToken creates the revoked token of the token information.
+func newRevokedToken(info oauth2.TokenInfo) RevokedToken {
+	return RevokedToken{
+		ClientID:    info.GetClientID(),
+		UserID:      info.GetUserID(),
+		AccessHash:  tokenHash(info.GetAccess()),
+		RefreshHash: tokenHash(info.GetRefresh()),
+	}
+}
+
+// webhookDispatcher sends the webhooks in the background by a fixed number of
+// workers reading a bounded queue, retrying failed deliveries.
+type webhookDispatcher struct {
+	url     string
+	secret  []byte
+	retries int
+	client  *http.Client
+	logger  Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	queue   chan []byte
+	closed  bool
+	dropped atomic.Uint64
+}
+
+// WithTokenStoreRevocationWebhook configures a webhook sent to the URL when
+// tokens are removed through the Remove methods, so downstream resource
+// servers can purge their caches. The body is signed with HMAC-SHA256 using
+// the secret in the WebhookSignatureHeader header. Webhooks are sent in the
+// background and failed deliveries are retried up to the given number of
+// times with exponential backoff; a webhook failing every retry is logged and
+// dropped. Webhooks are also dropped if too many are waiting to be sent, see
+// DroppedWebhooks. Close waits for the pending webhooks.
+func WithTokenStoreRevocationWebhook(webhookURL string, secret []byte, retries int) TokenStoreOption {
+	return func(s *TokenStore) error {
+		u, err := url.Parse(webhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || len(secret) == 0 || retries < 0 {
+			return ErrInvalidWebhook
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		s.webhook = &webhookDispatcher{
+			url:     webhookURL,
+			secret:  secret,
+			retries: retries,
+			client:  &http.Client{Timeout: webhookTimeout},
+			ctx:     ctx,
+			cancel:  cancel,
+			queue:   make(chan []byte, webhookQueueSize),
+		}
+
+		return nil
+	}
+}
+
+// sign returns the signature of the body.
+func (d *webhookDispatcher) sign(body []byte) string {
+	mac := hmac.New(sha256.New, d.secret)
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// send sends the body once.
+func (d *webhookDispatcher) send(body []byte) error {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, d.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookSignatureHeader, d.sign(body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// start starts the workers sending the queued webhooks.
+func (d *webhookDispatcher) start() {
+	d.wg.Add(webhookWorkers)
+
+	for i := 0; i < webhookWorkers; i++ {
+		go func() {
+			defer d.wg.Done()
+
+			for body := range d.queue {
+				d.deliver(body)
+			}
+		}()
+	}
+}
+
+// deliver sends the body, retrying failed deliveries with exponential
+// backoff until the dispatcher is cancelled.
+func (d *webhookDispatcher) deliver(body []byte) {
+	backoff := webhookBackoff
+
+	for attempt := 0; d.ctx.Err() == nil; attempt++ {
+		err := d.send(body)
+		if err == nil {
+			return
+		}
+
+		if attempt == d.retries {
+			d.logger.Log(d.ctx, LogLevelError, "revocation webhook failed", "url", d.url, "attempts", attempt+1, "err", err)
+			return
+		}
+
+		d.logger.Log(d.ctx, LogLevelWarn, "revocation webhook failed, retrying", "url", d.url, "attempt", attempt+1, "err", err)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-d.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		backoff *= 2
+	}
+}
+
+// dispatch queues the webhook to be sent in the background. The webhook is
+// dropped if the queue is full or the dispatcher is closed.
+func (d *webhookDispatcher) dispatch(webhook RevocationWebhook) {
+	body, err := json.Marshal(webhook)
+	if err != nil {
+		d.logger.Log(d.ctx, LogLevelError, err.Error())
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		d.dropped.Add(1)
+		d.logger.Log(d.ctx, LogLevelWarn, "revocation webhook dropped, store closed", "url", d.url)
+		return
+	}
+
+	select {
+	case d.queue <- body:
+	default:
+		d.dropped.Add(1)
+		d.logger.Log(d.ctx, LogLevelWarn, "revocation webhook dropped, queue full", "url", d.url)
+	}
+}
+
+// close stops accepting webhooks, waits for the pending webhooks until the
+// context is done, and cancels the remaining ones.
+func (d *webhookDispatcher) close(ctx context.Context) {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.queue)
+	}
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+
+	d.cancel()
+}
+
+// DroppedWebhooks returns the number of revocation webhooks dropped because
+// too many webhooks were waiting to be sent or the store was closed. The
+// webhooks failing every retry are not counted.
+func (s *TokenStore) DroppedWebhooks() uint64 {
+	if s.webhook == nil {
+		return 0
+	}
+
+	return s.webhook.dropped.Load()
+}
+
+// notifyWebhook dispatches the revocation webhook of the removed tokens.
+func (s *TokenStore) notifyWebhook(removed []oauth2.TokenInfo) {
+	if s.webhook == nil || len(removed) == 0 {
+		return
+	}
+
+	webhook := RevocationWebhook{
+		Type:      EventTokenRevoked,
+		Tokens:    make([]RevokedToken, 0, len(removed)),
+		Timestamp: s.clock.Now(),
+	}
+
+	for _, info := range removed {
+		webhook.Tokens = append(webhook.Tokens, newRevokedToken(info))
+	}
+
+	s.webhook.dispatch(webhook)
+}
@@ -0,0 +1,70 @@
+package pgstore
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRevocationWebhookQueue(t *testing.T) {
+	started := make(chan struct{}, webhookWorkers)
+	release := make(chan struct{})
+
+	var delivered atomic.Int64
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(WebhookSignatureHeader) == "" {
+			t.Error("webhook not signed")
+		}
+
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+
+		<-release
+		delivered.Add(1)
+	}))
+	defer server.Close()
+
+	store, err := NewTokenStore(WithTokenStoreDB(unusedDB{}), WithTokenStoreRevocationWebhook(server.URL, []byte("secret"), 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	webhook := RevocationWebhook{Type: EventTokenRevoked, Timestamp: time.Now()}
+
+	// Every worker blocks on a webhook, then the queue is filled.
+	for i := 0; i < webhookWorkers; i++ {
+		store.webhook.dispatch(webhook)
+	}
+
+	for i := 0; i < webhookWorkers; i++ {
+		<-started
+	}
+
+	for i := 0; i < webhookQueueSize+3; i++ {
+		store.webhook.dispatch(webhook)
+	}
+
+	if got := store.DroppedWebhooks(); got != 3 {
+		t.Fatalf("got %d dropped webhooks, want 3", got)
+	}
+
+	close(release)
+	store.Close(context.Background())
+
+	if got := delivered.Load(); got != webhookWorkers+webhookQueueSize {
+		t.Fatalf("got %d delivered webhooks, want %d", got, webhookWorkers+webhookQueueSize)
+	}
+
+	// Webhooks dispatched after the store is closed are dropped.
+	store.webhook.dispatch(webhook)
+
+	if got := store.DroppedWebhooks(); got != 4 {
+		t.Fatalf("got %d dropped webhooks, want 4", got)
+	}
+}
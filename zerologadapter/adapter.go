@@ -0,0 +1,41 @@
+// Package zerologadapter provides a pgstore.Logger backed by zerolog.
+package zerologadapter
+
+import (
+	"context"
+
+	"github.com/rs/zerolog"
+
+	pgstore "github.com/gabor-boros/go-oauth2-pg"
+)
+
+// Logger is a pgstore.Logger that writes to a zerolog.Logger.
+type Logger struct {
+	logger zerolog.Logger
+}
+
+// Log logs a message with the given level. The args are expected to be
+// key-value pairs.
+func (l *Logger) Log(_ context.Context, level pgstore.LogLevel, msg string, args ...any) {
+	var event *zerolog.Event
+
+	switch level {
+	case pgstore.LogLevelDebug:
+		event = l.logger.Debug()
+	case pgstore.LogLevelInfo:
+		event = l.logger.Info()
+	case pgstore.LogLevelWarn:
+		event = l.logger.Warn()
+	case pgstore.LogLevelError:
+		event = l.logger.Error()
+	default:
+		event = l.logger.Log()
+	}
+
+	event.Fields(args).Msg(msg)
+}
+
+// NewLogger creates a new Logger wrapping the given zerolog.Logger.
+func NewLogger(logger zerolog.Logger) *Logger {
+	return &Logger{logger: logger}
+}
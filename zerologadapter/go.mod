@@ -0,0 +1,22 @@
+module github.com/gabor-boros/go-oauth2-pg/zerologadapter
+
+go 1.19
+
+require (
+	github.com/gabor-boros/go-oauth2-pg v0.0.0-20261016074631-76a630171ac1
+	github.com/rs/zerolog v1.29.0
+)
+
+require (
+	github.com/go-oauth2/oauth2/v4 v4.5.2 // indirect
+	github.com/jackc/pgpassfile v1.0.0 // indirect
+	github.com/jackc/pgservicefile v0.0.0-20221227161230-091c0ba34f0a // indirect
+	github.com/jackc/pgx/v5 v5.3.1 // indirect
+	github.com/jackc/puddle/v2 v2.2.0 // indirect
+	github.com/mattn/go-colorable v0.1.12 // indirect
+	github.com/mattn/go-isatty v0.0.14 // indirect
+	golang.org/x/crypto v0.11.0 // indirect
+	golang.org/x/sync v0.3.0 // indirect
+	golang.org/x/sys v0.10.0 // indirect
+	golang.org/x/text v0.11.0 // indirect
+)